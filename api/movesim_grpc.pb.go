@@ -0,0 +1,251 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: api/movesim.proto
+
+package api
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// SimulationClient is the client API for Simulation service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SimulationClient interface {
+	// Subscribe streams every position update that matches the
+	// filter, until the client cancels or the simulation ends.
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (Simulation_SubscribeClient, error)
+	// SetSpeed runs the simulation faster or slower than real time.
+	SetSpeed(ctx context.Context, in *SetSpeedRequest, opts ...grpc.CallOption) (*SetSpeedResponse, error)
+	// AddMovers starts new movers, in a region if one is named.
+	AddMovers(ctx context.Context, in *AddMoversRequest, opts ...grpc.CallOption) (*AddMoversResponse, error)
+	// RemoveMover stops a mover and deletes it from the outputs.
+	RemoveMover(ctx context.Context, in *RemoveMoverRequest, opts ...grpc.CallOption) (*RemoveMoverResponse, error)
+}
+
+type simulationClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSimulationClient(cc grpc.ClientConnInterface) SimulationClient {
+	return &simulationClient{cc}
+}
+
+func (c *simulationClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (Simulation_SubscribeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Simulation_ServiceDesc.Streams[0], "/movesim.v1.Simulation/Subscribe", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &simulationSubscribeClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Simulation_SubscribeClient interface {
+	Recv() (*PositionUpdate, error)
+	grpc.ClientStream
+}
+
+type simulationSubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *simulationSubscribeClient) Recv() (*PositionUpdate, error) {
+	m := new(PositionUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *simulationClient) SetSpeed(ctx context.Context, in *SetSpeedRequest, opts ...grpc.CallOption) (*SetSpeedResponse, error) {
+	out := new(SetSpeedResponse)
+	err := c.cc.Invoke(ctx, "/movesim.v1.Simulation/SetSpeed", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulationClient) AddMovers(ctx context.Context, in *AddMoversRequest, opts ...grpc.CallOption) (*AddMoversResponse, error) {
+	out := new(AddMoversResponse)
+	err := c.cc.Invoke(ctx, "/movesim.v1.Simulation/AddMovers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *simulationClient) RemoveMover(ctx context.Context, in *RemoveMoverRequest, opts ...grpc.CallOption) (*RemoveMoverResponse, error) {
+	out := new(RemoveMoverResponse)
+	err := c.cc.Invoke(ctx, "/movesim.v1.Simulation/RemoveMover", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SimulationServer is the server API for Simulation service.
+// All implementations must embed UnimplementedSimulationServer
+// for forward compatibility
+type SimulationServer interface {
+	// Subscribe streams every position update that matches the
+	// filter, until the client cancels or the simulation ends.
+	Subscribe(*SubscribeRequest, Simulation_SubscribeServer) error
+	// SetSpeed runs the simulation faster or slower than real time.
+	SetSpeed(context.Context, *SetSpeedRequest) (*SetSpeedResponse, error)
+	// AddMovers starts new movers, in a region if one is named.
+	AddMovers(context.Context, *AddMoversRequest) (*AddMoversResponse, error)
+	// RemoveMover stops a mover and deletes it from the outputs.
+	RemoveMover(context.Context, *RemoveMoverRequest) (*RemoveMoverResponse, error)
+	mustEmbedUnimplementedSimulationServer()
+}
+
+// UnimplementedSimulationServer must be embedded to have forward compatible implementations.
+type UnimplementedSimulationServer struct {
+}
+
+func (UnimplementedSimulationServer) Subscribe(*SubscribeRequest, Simulation_SubscribeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedSimulationServer) SetSpeed(context.Context, *SetSpeedRequest) (*SetSpeedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetSpeed not implemented")
+}
+func (UnimplementedSimulationServer) AddMovers(context.Context, *AddMoversRequest) (*AddMoversResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddMovers not implemented")
+}
+func (UnimplementedSimulationServer) RemoveMover(context.Context, *RemoveMoverRequest) (*RemoveMoverResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveMover not implemented")
+}
+func (UnimplementedSimulationServer) mustEmbedUnimplementedSimulationServer() {}
+
+// UnsafeSimulationServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SimulationServer will
+// result in compilation errors.
+type UnsafeSimulationServer interface {
+	mustEmbedUnimplementedSimulationServer()
+}
+
+func RegisterSimulationServer(s grpc.ServiceRegistrar, srv SimulationServer) {
+	s.RegisterService(&Simulation_ServiceDesc, srv)
+}
+
+func _Simulation_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SimulationServer).Subscribe(m, &simulationSubscribeServer{stream})
+}
+
+type Simulation_SubscribeServer interface {
+	Send(*PositionUpdate) error
+	grpc.ServerStream
+}
+
+type simulationSubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *simulationSubscribeServer) Send(m *PositionUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Simulation_SetSpeed_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetSpeedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulationServer).SetSpeed(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/movesim.v1.Simulation/SetSpeed",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulationServer).SetSpeed(ctx, req.(*SetSpeedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulation_AddMovers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddMoversRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulationServer).AddMovers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/movesim.v1.Simulation/AddMovers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulationServer).AddMovers(ctx, req.(*AddMoversRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Simulation_RemoveMover_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveMoverRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SimulationServer).RemoveMover(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/movesim.v1.Simulation/RemoveMover",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SimulationServer).RemoveMover(ctx, req.(*RemoveMoverRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Simulation_ServiceDesc is the grpc.ServiceDesc for Simulation service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Simulation_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "movesim.v1.Simulation",
+	HandlerType: (*SimulationServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "SetSpeed",
+			Handler:    _Simulation_SetSpeed_Handler,
+		},
+		{
+			MethodName: "AddMovers",
+			Handler:    _Simulation_AddMovers_Handler,
+		},
+		{
+			MethodName: "RemoveMover",
+			Handler:    _Simulation_RemoveMover_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _Simulation_Subscribe_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/movesim.proto",
+}
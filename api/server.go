@@ -0,0 +1,269 @@
+// Package api serves the Simulation gRPC service defined in
+// movesim.proto: a stream of position updates, filtered by
+// bounding box or mover id, and calls to control a running
+// simulation. Regenerate the service code after changing the
+// definition with
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//		--go-grpc_out=. --go-grpc_opt=paths=source_relative api/movesim.proto
+package api
+
+import (
+	// System
+	"context"
+	"sync"
+	"time"
+
+	// gRPC
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Simulation
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/sim"
+	"github.com/pramsey/movesim/sink"
+)
+
+// Updates buffered per subscriber, past which a slow
+// subscriber misses updates rather than holding up the movers
+const subscriberBuffer = 1024
+
+// Most movers one AddMovers call may start
+const maxAddMovers = 10000
+
+// Server is the Simulation service of one simulation. It is also
+// the sink feeding the subscriptions, so put it among the sinks of
+// the simulation, then Attach the simulation to control it.
+type Server struct {
+	UnimplementedSimulationServer
+
+	sim   *sim.Simulation
+	stats *sink.RunStats
+
+	mutex       sync.Mutex
+	subscribers map[*subscriber]struct{}
+	closed      bool
+}
+
+type subscriber struct {
+	bbox    *BoundingBox
+	ids     map[int32]bool
+	updates chan *PositionUpdate
+	dropped int
+}
+
+func NewServer() *Server {
+	return &Server{
+		stats:       sink.NewRunStats(),
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+// Attach hands the server the simulation to control.
+func (s *Server) Attach(simulation *sim.Simulation) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sim = simulation
+}
+
+func (s *Server) simulation() (*sim.Simulation, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.sim == nil {
+		return nil, status.Error(codes.Unavailable, "no simulation running")
+	}
+	return s.sim, nil
+}
+
+// Sink methods, publishing to the subscribers
+
+func (s *Server) Name() string {
+	return "grpc"
+}
+
+func (s *Server) Stats() *sink.RunStats {
+	return s.stats
+}
+
+func (s *Server) Create(m mover.Mover) error {
+	s.publish(PositionUpdate_KIND_CREATE, []mover.Mover{m})
+	return nil
+}
+
+func (s *Server) WritePosition(m mover.Mover) error {
+	return s.WriteBatch([]mover.Mover{m})
+}
+
+func (s *Server) WriteBatch(ms []mover.Mover) error {
+	start := time.Now()
+	s.publish(PositionUpdate_KIND_MOVE, ms)
+	s.stats.RecordWrite(len(ms), time.Since(start), nil)
+	return nil
+}
+
+func (s *Server) Delete(m mover.Mover) error {
+	s.publish(PositionUpdate_KIND_DELETE, []mover.Mover{m})
+	return nil
+}
+
+// Close ends every subscription.
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for sub := range s.subscribers {
+		close(sub.updates)
+		delete(s.subscribers, sub)
+	}
+	s.closed = true
+	return nil
+}
+
+func (s *Server) publish(kind PositionUpdate_Kind, ms []mover.Mover) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, m := range ms {
+		// Shared by the subscribers, and never changed
+		var update *PositionUpdate
+		for sub := range s.subscribers {
+			if !sub.matches(m) {
+				continue
+			}
+			if update == nil {
+				update = newPositionUpdate(kind, m)
+			}
+			select {
+			case sub.updates <- update:
+			default:
+				sub.dropped++
+			}
+		}
+	}
+}
+
+func (sub *subscriber) matches(m mover.Mover) bool {
+	if len(sub.ids) > 0 && !sub.ids[int32(m.Id)] {
+		return false
+	}
+	if b := sub.bbox; b != nil {
+		return m.X >= b.MinX && m.X <= b.MaxX && m.Y >= b.MinY && m.Y <= b.MaxY
+	}
+	return true
+}
+
+func newPositionUpdate(kind PositionUpdate_Kind, m mover.Mover) *PositionUpdate {
+	update := &PositionUpdate{
+		Kind:     kind,
+		Id:       int32(m.Id),
+		X:        m.X,
+		Y:        m.Y,
+		Heading:  int32(m.Heading),
+		Velocity: m.Velocity,
+		Ts:       timestamppb.New(m.DeviceTime(m.Ts)),
+		Name:     m.Name,
+		Color:    m.Color,
+	}
+	if m.Class != nil {
+		update.Class = m.Class.Name
+	}
+	if m.Region != nil {
+		update.Region = m.Region.Name
+	}
+	return update
+}
+
+// Service methods
+
+func (s *Server) Subscribe(req *SubscribeRequest, stream Simulation_SubscribeServer) error {
+	sub := &subscriber{
+		bbox:    req.Bbox,
+		updates: make(chan *PositionUpdate, subscriberBuffer),
+	}
+	if len(req.MoverIds) > 0 {
+		sub.ids = make(map[int32]bool, len(req.MoverIds))
+		for _, id := range req.MoverIds {
+			sub.ids[id] = true
+		}
+	}
+
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return status.Error(codes.Unavailable, "simulation has ended")
+	}
+	s.subscribers[sub] = struct{}{}
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.subscribers, sub)
+		dropped := sub.dropped
+		s.mutex.Unlock()
+		if dropped > 0 {
+			log.Warnf("Subscriber fell behind and missed %d updates", dropped)
+		}
+	}()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case update, ok := <-sub.updates:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(update); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) SetSpeed(ctx context.Context, req *SetSpeedRequest) (*SetSpeedResponse, error) {
+	simulation, err := s.simulation()
+	if err != nil {
+		return nil, err
+	}
+	if err := simulation.SetSpeed(req.Factor); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &SetSpeedResponse{Factor: simulation.Speed()}, nil
+}
+
+func (s *Server) AddMovers(ctx context.Context, req *AddMoversRequest) (*AddMoversResponse, error) {
+	simulation, err := s.simulation()
+	if err != nil {
+		return nil, err
+	}
+	if req.Count <= 0 || req.Count > maxAddMovers {
+		return nil, status.Errorf(codes.InvalidArgument, "count must be between 1 and %d", maxAddMovers)
+	}
+	world := simulation.World()
+	var region *mover.Region
+	if req.Region != "" {
+		if region = world.Props.RegionNamed(req.Region); region == nil {
+			return nil, status.Errorf(codes.NotFound, "unknown region '%s'", req.Region)
+		}
+	}
+
+	resp := &AddMoversResponse{}
+	for i := 0; i < int(req.Count); i++ {
+		m, err := world.NewMoverIn(simulation.NewId(), region)
+		if err != nil {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		simulation.AddMover(m)
+		resp.Ids = append(resp.Ids, int32(m.Id))
+	}
+	return resp, nil
+}
+
+func (s *Server) RemoveMover(ctx context.Context, req *RemoveMoverRequest) (*RemoveMoverResponse, error) {
+	simulation, err := s.simulation()
+	if err != nil {
+		return nil, err
+	}
+	return &RemoveMoverResponse{Removed: simulation.RemoveMover(int(req.Id))}, nil
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	// System
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	// gRPC
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+
+	// Simulation
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/sim"
+)
+
+// serve starts a gRPC server of the service on a local port,
+// returning a client of it.
+func serve(t *testing.T, server *Server) SimulationClient {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer()
+	RegisterSimulationServer(grpcServer, server)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewSimulationClient(conn)
+}
+
+func TestSubscribe(t *testing.T) {
+	server := NewServer()
+	client := serve(t, server)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.Subscribe(ctx, &SubscribeRequest{
+		Bbox:     &BoundingBox{MinX: -124, MinY: 49, MaxX: -123, MaxY: 50},
+		MoverIds: []int32{1, 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		server.mutex.Lock()
+		subscribed := len(server.subscribers)
+		server.mutex.Unlock()
+		if subscribed > 0 {
+			break
+		}
+		if ctx.Err() != nil {
+			t.Fatal("no subscriber after 5s")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	class := &mover.Class{Name: "ship"}
+	server.Create(mover.Mover{Id: 1, X: -123.5, Y: 49.5, Class: class})
+	// Not one of the ids, then out of the box
+	server.WritePosition(mover.Mover{Id: 3, X: -123.5, Y: 49.5, Class: class})
+	server.WriteBatch([]mover.Mover{{Id: 2, X: -122, Y: 49.5, Class: class}, {Id: 1, X: -123.6, Y: 49.6, Class: class}})
+	server.Delete(mover.Mover{Id: 1, X: -123.6, Y: 49.6, Class: class})
+	server.Close()
+
+	want := []struct {
+		kind PositionUpdate_Kind
+		x    float64
+	}{
+		{PositionUpdate_KIND_CREATE, -123.5},
+		{PositionUpdate_KIND_MOVE, -123.6},
+		{PositionUpdate_KIND_DELETE, -123.6},
+	}
+	for i, w := range want {
+		update, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("update %d: %v", i, err)
+		}
+		if update.Kind != w.kind || update.Id != 1 || update.X != w.x || update.Class != "ship" {
+			t.Errorf("update %d is %v, want %v of mover 1 at x %g", i, update, w.kind, w.x)
+		}
+	}
+	if update, err := stream.Recv(); err != io.EOF {
+		t.Errorf("after the close, got %v, %v, want the end of the stream", update, err)
+	}
+	stream, err = client.Subscribe(ctx, &SubscribeRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("subscribing after the close: %v, want unavailable", err)
+	}
+}
+
+func TestControl(t *testing.T) {
+	server := NewServer()
+	client := serve(t, server)
+	ctx := context.Background()
+	if _, err := client.SetSpeed(ctx, &SetSpeedRequest{Factor: 2}); status.Code(err) != codes.Unavailable {
+		t.Errorf("SetSpeed() before a simulation: %v, want unavailable", err)
+	}
+
+	opts := sim.DefaultOptions()
+	opts.Regions = []*mover.Region{{
+		Name:           "east",
+		Count:          1,
+		StartRectangle: geo.Rectangle{MinX: -124, MinY: 48, MaxX: -123, MaxY: 49},
+	}}
+	if err := opts.Init(); err != nil {
+		t.Fatal(err)
+	}
+	simulation, err := sim.NewSimulation(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Attach(simulation)
+
+	if resp, err := client.SetSpeed(ctx, &SetSpeedRequest{Factor: 2}); err != nil || resp.Factor != 2 {
+		t.Errorf("SetSpeed(2) = %v, %v, want 2", resp, err)
+	}
+	if _, err := client.SetSpeed(ctx, &SetSpeedRequest{Factor: -1}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("SetSpeed(-1): %v, want an invalid argument", err)
+	}
+	if resp, err := client.AddMovers(ctx, &AddMoversRequest{Count: 2}); err != nil || len(resp.Ids) != 2 || resp.Ids[0] == resp.Ids[1] {
+		t.Errorf("AddMovers(2) = %v, %v, want two new ids", resp, err)
+	}
+	if _, err := client.AddMovers(ctx, &AddMoversRequest{Count: 0}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("AddMovers(0): %v, want an invalid argument", err)
+	}
+	if _, err := client.AddMovers(ctx, &AddMoversRequest{Count: 1, Region: "west"}); status.Code(err) != codes.NotFound {
+		t.Errorf("AddMovers() to an unknown region: %v, want not found", err)
+	}
+	if resp, err := client.RemoveMover(ctx, &RemoveMoverRequest{Id: 42}); err != nil || resp.Removed {
+		t.Errorf("RemoveMover(42) = %v, %v, want nothing removed", resp, err)
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	// System
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Configuration
+	"github.com/spf13/viper"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+)
+
+// A bundle is a directory or zip file that describes a whole
+// scenario, so it can be shared and run without code changes:
+//
+//	bundle.toml     configuration, in any format viper reads
+//	zones.geojson   constraint polygons, unless the config names some
+//	routes.geojson  destination points, unless the config lists some
+//
+// Scripted events are the rollouts in the bundle configuration.
+// Relative file names in the configuration are read from the bundle.
+const (
+	bundleConfigName = "bundle"
+	bundleZonesFile  = "zones.geojson"
+	bundleRoutesFile = "routes.geojson"
+)
+
+// BundleProps is the descriptive part of a bundle configuration.
+type BundleProps struct {
+	Name        string
+	Description string
+	Author      string
+	Version     string
+}
+
+// Bundle is an opened scenario bundle.
+type Bundle struct {
+	Path  string
+	Props BundleProps
+	files fs.FS
+}
+
+// Set with --bundle, and opened by initConfig
+var bundlePath string
+var moverBundle *Bundle
+
+// OpenBundle opens a bundle directory or zip file.
+func OpenBundle(filename string) (*Bundle, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	b := &Bundle{Path: filename}
+	if info.IsDir() {
+		b.files = os.DirFS(filename)
+		return b, nil
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("bundle %s is neither a directory nor a zip file: %v", filename, err)
+	}
+	b.files = archive
+	// Zips made by compressing a folder hold it as a single
+	// top level directory, so look inside that instead
+	if entries, err := fs.ReadDir(archive, "."); err == nil && len(entries) == 1 && entries[0].IsDir() {
+		if sub, err := fs.Sub(archive, entries[0].Name()); err == nil {
+			b.files = sub
+		}
+	}
+	return b, nil
+}
+
+// Has reports whether the bundle holds the named file.
+func (b *Bundle) Has(name string) bool {
+	if b == nil || filepath.IsAbs(name) {
+		return false
+	}
+	_, err := fs.Stat(b.files, path.Clean(filepath.ToSlash(name)))
+	return err == nil
+}
+
+func (b *Bundle) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(b.files, path.Clean(filepath.ToSlash(name)))
+}
+
+// readConfig loads the bundle configuration into v, as the
+// base that any config file given alongside is merged over.
+func (b *Bundle) readConfig(v *viper.Viper) error {
+	for _, ext := range viper.SupportedExts {
+		name := bundleConfigName + "." + ext
+		if !b.Has(name) {
+			continue
+		}
+		data, err := b.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		// Read through a separate instance, as the config type
+		// would otherwise stick for the config file read next
+		config := viper.New()
+		config.SetConfigType(ext)
+		if err := config.ReadConfig(bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("unable to read %s: %v", name, err)
+		}
+		if err := config.UnmarshalKey("Bundle", &b.Props); err != nil {
+			return err
+		}
+		return v.MergeConfigMap(config.AllSettings())
+	}
+	return fmt.Errorf("bundle %s has no %s configuration", b.Path, bundleConfigName)
+}
+
+// apply fills in the layers the bundle carries by convention.
+func (b *Bundle) apply(config *MoversConfig) error {
+	constraint := &config.Constraint
+	if constraint.File == "" && constraint.Query == "" && b.Has(bundleZonesFile) {
+		constraint.File = bundleZonesFile
+	}
+	if len(config.Destination.Pois) == 0 && b.Has(bundleRoutesFile) {
+		features, err := b.readGeoJSON(bundleRoutesFile)
+		if err != nil {
+			return err
+		}
+		for _, f := range features {
+			config.Destination.Pois = append(config.Destination.Pois, f.Points...)
+		}
+	}
+	return nil
+}
+
+func (b *Bundle) readGeoJSON(name string) ([]geo.Feature, error) {
+	data, err := b.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	features, err := geo.ParseGeoJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s from bundle: %v", name, err)
+	}
+	return features, nil
+}
+
+// readGeoJSON reads a GeoJSON file from the bundle when it has
+// one of that name, and from disk otherwise.
+func readGeoJSON(name string) ([]geo.Feature, error) {
+	if moverBundle.Has(name) {
+		return moverBundle.readGeoJSON(name)
+	}
+	return geo.ReadGeoJSONFile(name)
+}
+
+// openBundle opens the bundle named on the command line, if any,
+// for layerConfig to read its configuration.
+func openBundle() {
+	if bundlePath == "" {
+		return
+	}
+	b, err := OpenBundle(bundlePath)
+	if err != nil {
+		log.Fatalf("Unable to open bundle: %v", err)
+	}
+	moverBundle = b
+}
+
+func (b *Bundle) String() string {
+	name := b.Props.Name
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(b.Path), filepath.Ext(b.Path))
+	}
+	if b.Props.Version != "" {
+		name += " " + b.Props.Version
+	}
+	if b.Props.Author != "" {
+		name += " by " + b.Props.Author
+	}
+	return name
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	// System
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Simulation
+	"github.com/pramsey/movesim/sink/postgis"
+)
+
+// runClean deletes the rows earlier runs left in the objects
+// table and, if asked, in the history table, so repeated demo
+// runs do not pile up stale movers.
+func runClean(args []string) {
+	flags, configFile := newFlagSet("movesim clean")
+	run := flags.String("run", "", "id of the run to clean up")
+	all := flags.Bool("all", false, "clean up every run")
+	history := flags.Bool("history", false, "delete history rows too")
+	flags.Parse(args)
+
+	initConfig(*configFile)
+	if *run == "" && !*all || *run != "" && *all {
+		log.Fatal("Usage: movesim clean [flags] --run id | --all")
+	}
+	objects, historyTable := dbProps.ObjectsTable, dbProps.HistoryTable
+	if strings.Contains(objects+historyTable, "{") {
+		log.Fatal("Templated tables cannot be cleaned up by name, use --cleanup-on-exit")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	dbPool := connectDatabase(ctx, "", "")
+	defer dbPool.Close()
+	deleted, err := postgis.CleanRun(ctx, dbPool, objects, historyTable, *run, *history)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *all {
+		log.Infof("Cleaned up %d rows of every run", deleted)
+	} else {
+		log.Infof("Cleaned up %d rows of run %s", deleted, *run)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	// System
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Simulation
+	"github.com/pramsey/movesim/sink"
+	"github.com/pramsey/movesim/sink/postgis"
+)
+
+// CompareResult is the summary of one side of a comparison run.
+type CompareResult struct {
+	Name     string `json:"name"`
+	Strategy string `json:"strategy"`
+	Wkb      bool   `json:"wkb"`
+	sink.RunSummary
+}
+
+// runCompare drives one simulated fleet against two targets at
+// once, so both write strategies, or point encodings, see exactly
+// the same movement and load, and reports their metrics together.
+func runCompare(args []string) {
+	flags, configFile := newFlagSet("movesim compare")
+	strategyA := flags.String("strategy-a", postgis.StrategyUpdate, "write strategy of target A")
+	strategyB := flags.String("strategy-b", postgis.StrategyAppend, "write strategy of target B")
+	targetA := flags.String("target-a", "", "database URL of target A (default DATABASE_URL)")
+	targetB := flags.String("target-b", "", "database URL of target B (default DATABASE_URL)")
+	wkbA := flags.Bool("wkb-a", false, "pass positions to target A as EWKB")
+	wkbB := flags.Bool("wkb-b", false, "pass positions to target B as EWKB")
+	duration := flags.Duration("duration", time.Minute, "duration of the comparison")
+	format := flags.String("format", "text", "report format, text or json")
+	flags.Parse(args)
+
+	initConfig(*configFile)
+	for _, strategy := range []string{*strategyA, *strategyB} {
+		if _, ok := postgis.StrategySql[strategy]; !ok {
+			log.Fatalf("Unknown write strategy '%s'", strategy)
+		}
+	}
+	if *format != "text" && *format != "json" {
+		log.Fatalf("Unknown report format '%s'", *format)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	poolA := connectDatabase(ctx, *targetA, *strategyA)
+	defer poolA.Close()
+	poolB := connectDatabase(ctx, *targetB, *strategyB)
+	defer poolB.Close()
+	loadConstraint(ctx, poolA)
+	loadDensity(ctx, poolA)
+	loadObstacles(ctx, poolA)
+	loadRegions()
+	loadNetwork()
+
+	targets := []postgis.Target{
+		{Name: "A", DbPool: poolA, Strategy: *strategyA, Wkb: *wkbA, PointZ: migratePointZ(ctx, poolA)},
+		{Name: "B", DbPool: poolB, Strategy: *strategyB, Wkb: *wkbB, PointZ: migratePointZ(ctx, poolB)},
+	}
+	log.Infof("Comparing '%s' against '%s' with %d movers for %s",
+		compareLabel(*strategyA, *wkbA), compareLabel(*strategyB, *wkbB), moverConfig.MaxMovers, *duration)
+
+	runCtx, cancel := context.WithTimeout(ctx, *duration)
+	defer cancel()
+	sinks := make([]sink.Sink, len(targets))
+	for i, target := range targets {
+		sinks[i] = postgis.NewWriter(target, moverConfig.BatchSize, moverConfig.SleepInterval)
+	}
+	stats := simulate(runCtx, moverConfig.Options, sinks...)
+
+	results := make([]CompareResult, len(targets))
+	for i, target := range targets {
+		results[i] = CompareResult{
+			Name:       target.Name,
+			Strategy:   target.Strategy,
+			Wkb:        target.Wkb,
+			RunSummary: stats[i].Summary(),
+		}
+	}
+	if err := writeCompareResults(os.Stdout, *format, results); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// compareLabel is the write strategy, and the point
+// encoding if it is not the default.
+func compareLabel(strategy string, wkb bool) string {
+	if wkb {
+		return strategy + ", ewkb"
+	}
+	return strategy
+}
+
+func writeCompareResults(out io.Writer, format string, results []CompareResult) error {
+	if format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	}
+
+	a, b := results[0], results[1]
+	ratio := func(va, vb float64) string {
+		if va == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.2fx", vb/va)
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "\tA (%s)\tB (%s)\tB/A\t\n",
+		compareLabel(a.Strategy, a.Wkb), compareLabel(b.Strategy, b.Wkb))
+	rows := []struct {
+		label  string
+		va, vb float64
+		format string
+	}{
+		{"updates", float64(a.Updates), float64(b.Updates), "%.0f"},
+		{"errors", float64(a.Errors), float64(b.Errors), "%.0f"},
+		{"repairs", float64(a.Repairs), float64(b.Repairs), "%.0f"},
+		{"updates/sec", a.UpdatesPerSec, b.UpdatesPerSec, "%.1f"},
+		{"latency mean ms", a.LatencyMean, b.LatencyMean, "%.2f"},
+		{"latency p50 ms", a.LatencyP50, b.LatencyP50, "%.2f"},
+		{"latency p95 ms", a.LatencyP95, b.LatencyP95, "%.2f"},
+		{"latency p99 ms", a.LatencyP99, b.LatencyP99, "%.2f"},
+		{"latency max ms", a.LatencyMax, b.LatencyMax, "%.2f"},
+	}
+	for _, r := range rows {
+		fmt.Fprintf(w, "%s\t"+r.format+"\t"+r.format+"\t%s\t\n", r.label, r.va, r.vb, ratio(r.va, r.vb))
+	}
+	return w.Flush()
+}
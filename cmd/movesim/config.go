@@ -0,0 +1,337 @@
+package main
+
+import (
+	// System
+	"fmt"
+	"net/url"
+	"strings"
+
+	// Configuration
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Simulation
+	"github.com/pramsey/movesim/feature"
+	"github.com/pramsey/movesim/sim"
+	"github.com/pramsey/movesim/sink/postgis"
+	"github.com/pramsey/movesim/snapshot"
+)
+
+// MoversConfig is the Movers section of the configuration: the
+// simulation options, and the batch size of the database sink.
+type MoversConfig struct {
+	sim.Options `mapstructure:",squash"`
+	BatchSize   int
+}
+
+var moverConfig MoversConfig = defaultMoversConfig()
+
+// defaultMoversConfig is the compiled-in Movers configuration,
+// which the config layers go over.
+func defaultMoversConfig() MoversConfig {
+	return MoversConfig{
+		Options:   sim.DefaultOptions(),
+		BatchSize: 1,
+	}
+}
+
+// Database connection settings. The DATABASE_URL environment
+// variable takes precedence over the config file. MaxConns and
+// MinConns size the connection pool, zero keeps the pgx default.
+// Events says where proximity events go, and Control listens for
+// commands to the movers, see postgis.ListenCommands. ObjectsTable
+// and HistoryTable are where positions go, and may be templated
+// with {class}, {region} and {tenant}, which is Tenant, see
+// postgis.Tables, and Templates replace the statements writing to
+// them, see postgis.Templates, and Wkb passes points to the default
+// statements as EWKB rather than coordinates. Partitions splits the
+// history tables by time and drops old positions, see
+// postgis.PartitionProps. Rows are tagged with the Run id, a new
+// one for every run unless set, and CleanupOnExit deletes them
+// when the run ends, along with their history rows with
+// CleanupHistory. Adopt carries on from the movers an earlier run
+// left in the objects table, see postgis.ReadStates, and
+// RecordSummary writes the summary of the run to moving.runs.
+type Database struct {
+	DbConnection   string
+	WriteStrategy  string
+	MaxConns       int32
+	MinConns       int32
+	Events         string
+	Control        bool
+	ObjectsTable   string
+	HistoryTable   string
+	Tenant         string
+	Templates      postgis.Templates
+	Wkb            bool
+	Partitions     postgis.PartitionProps
+	Run            string
+	CleanupOnExit  bool
+	CleanupHistory bool
+	Adopt          bool
+	RecordSummary  bool
+}
+
+var dbProps Database = Database{
+	WriteStrategy: postgis.StrategyUpdate,
+	Events:        postgis.EventsTable,
+	ObjectsTable:  postgis.DefaultObjectsTable,
+	HistoryTable:  postgis.DefaultHistoryTable,
+	Partitions: postgis.PartitionProps{
+		Ahead: 2,
+	},
+}
+
+// The flags bound to configuration keys, so that a reload can
+// layer them over the config file again, see layerConfig.
+var boundFlags = make(map[string]*pflag.Flag)
+
+// bindFlag has the flag set the configuration key when given.
+func bindFlag(key string, flag *pflag.Flag) {
+	boundFlags[key] = flag
+	viper.BindPFlag(key, flag)
+}
+
+// newFlagSet starts the flags for a command with the options
+// that every command shares, returning the config file flag.
+func newFlagSet(command string) (*pflag.FlagSet, *string) {
+	flags := pflag.NewFlagSet(command, pflag.ExitOnError)
+	configFile := flags.StringP("config", "c", "", "configuration file (toml, yaml or json)")
+	flags.StringVar(&bundlePath, "bundle", "", "scenario bundle directory or zip file")
+	flags.String("log-level", logProps.Level, "log level (trace, debug, info, warn, error)")
+	flags.String("log-format", logProps.Format, "log format (text or json)")
+	flags.Int("log-every", logProps.TickEvery, "log one mover tick in N at debug level, 0 for none")
+	bindFlag("Logging.Level", flags.Lookup("log-level"))
+	bindFlag("Logging.Format", flags.Lookup("log-format"))
+	bindFlag("Logging.TickEvery", flags.Lookup("log-every"))
+	flags.String("http", "", "address for the admin HTTP endpoints, such as localhost:8080")
+	bindFlag("Http.Address", flags.Lookup("http"))
+	flags.String("grpc", "", "address for the gRPC API, such as localhost:9090")
+	bindFlag("Grpc.Address", flags.Lookup("grpc"))
+	return flags, configFile
+}
+
+// addRunFlags adds the options of commands that write a run
+// to the database.
+func addRunFlags(flags *pflag.FlagSet) {
+	flags.String("run-id", "", "id to tag the rows of this run with (default a new one)")
+	bindFlag("Database.Run", flags.Lookup("run-id"))
+	flags.Bool("cleanup-on-exit", false, "delete the rows of this run from the objects table on exit")
+	bindFlag("Database.CleanupOnExit", flags.Lookup("cleanup-on-exit"))
+	flags.Bool("cleanup-history", false, "with --cleanup-on-exit, delete its history rows too")
+	bindFlag("Database.CleanupHistory", flags.Lookup("cleanup-history"))
+	flags.Bool("wkb", false, "pass positions to the database as EWKB rather than coordinates")
+	bindFlag("Database.Wkb", flags.Lookup("wkb"))
+	flags.Bool("adopt", false, "carry on from the movers an earlier run left in the objects table")
+	bindFlag("Database.Adopt", flags.Lookup("adopt"))
+	flags.Bool("record-summary", false, "write the summary of the run to moving.runs when it ends")
+	bindFlag("Database.RecordSummary", flags.Lookup("record-summary"))
+	flags.BoolVar(&watchConfigFile, "watch-config", false, "reload the config file whenever it changes, not only on SIGHUP")
+}
+
+// configSnapshot is the configuration as loaded, from the
+// file, the environment and the flags, less anything that may
+// hold a credential, see redact.
+func configSnapshot() map[string]interface{} {
+	return redact(viper.AllSettings()).(map[string]interface{})
+}
+
+// secretKeys are the parts of the names of settings that may hold
+// a credential, such as the database connection or the Redis
+// password, in the lower case viper keeps keys in.
+var secretKeys = []string{"password", "secret", "token", "dsn", "credential", "dbconnection"}
+
+// redact takes the settings that may hold a credential out of
+// the value, at any depth, and the password out of any URL.
+func redact(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if !secretKey(key) {
+				redacted[key] = redact(item)
+			}
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = redact(item)
+		}
+		return redacted
+	case []map[string]interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = redact(item)
+		}
+		return redacted
+	case string:
+		if u, err := url.Parse(v); err == nil && u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				u.User = url.User(u.User.Username())
+				return u.String()
+			}
+		}
+		return v
+	}
+	return value
+}
+
+func secretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// The scenario of movesim run, read under the bundle, and the
+// config file read over it, kept for reloads, see reloadConfig.
+var scenarioFile string
+var configFileLoaded string
+
+// layerConfig reads the configuration into v, each layer over the
+// one before: the scenario, the bundle, the config file, then the
+// environment and the flags given.
+func layerConfig(v *viper.Viper, configFile string) error {
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.BindEnv("Database.DbConnection", "DATABASE_URL")
+	if scenarioFile != "" {
+		v.SetConfigFile(scenarioFile)
+		if err := v.ReadInConfig(); err != nil {
+			return fmt.Errorf("unable to read scenario %s: %v", scenarioFile, err)
+		}
+	}
+	// A bundle provides the base configuration, and a config
+	// file alongside it only overrides what it sets
+	if moverBundle != nil {
+		if err := moverBundle.readConfig(v); err != nil {
+			return fmt.Errorf("unable to load bundle: %v", err)
+		}
+	}
+	if configFile != "" {
+		v.SetConfigFile(configFile)
+		if err := v.MergeInConfig(); err != nil {
+			return fmt.Errorf("unable to read config file %s: %v", configFile, err)
+		}
+	}
+	for key, flag := range boundFlags {
+		if err := v.BindPFlag(key, flag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshalMovers reads the Movers section of v over config. Only
+// keys present in a layer are written, so anything left out keeps
+// the value config had.
+func unmarshalMovers(v *viper.Viper, config *MoversConfig) error {
+	if err := v.UnmarshalKey("Movers", config); err != nil {
+		return err
+	}
+	config.Shard.Index = v.GetInt("Movers.Shard.Index")
+	config.Shard.Count = v.GetInt("Movers.Shard.Count")
+	return nil
+}
+
+// initConfig layers the optional config file and the environment
+// over the compiled-in defaults in moverConfig.
+func initConfig(configFile string) {
+	openBundle()
+	if err := layerConfig(viper.GetViper(), configFile); err != nil {
+		log.Fatal(err)
+	}
+	configFileLoaded = configFile
+
+	logProps.Level = viper.GetString("Logging.Level")
+	logProps.Format = viper.GetString("Logging.Format")
+	logProps.TickEvery = viper.GetInt("Logging.TickEvery")
+	initLogging()
+	if moverBundle != nil {
+		log.Infof("Using bundle %s", moverBundle)
+		if moverBundle.Props.Description != "" {
+			log.Info(moverBundle.Props.Description)
+		}
+	}
+	if configFile != "" {
+		log.Infof("Using config file %s", viper.ConfigFileUsed())
+	}
+
+	if err := unmarshalMovers(viper.GetViper(), &moverConfig); err != nil {
+		log.Fatalf("Unable to parse Movers configuration: %v", err)
+	}
+	if err := viper.UnmarshalKey("Output", &outputProps); err != nil {
+		log.Fatalf("Unable to parse Output configuration: %v", err)
+	}
+	if err := viper.UnmarshalKey("Database", &dbProps); err != nil {
+		log.Fatalf("Unable to parse Database configuration: %v", err)
+	}
+	dbProps.DbConnection = viper.GetString("Database.DbConnection")
+	dbProps.Run = viper.GetString("Database.Run")
+	dbProps.CleanupOnExit = viper.GetBool("Database.CleanupOnExit")
+	dbProps.CleanupHistory = viper.GetBool("Database.CleanupHistory")
+	dbProps.Adopt = viper.GetBool("Database.Adopt")
+	dbProps.Wkb = viper.GetBool("Database.Wkb")
+	dbProps.RecordSummary = viper.GetBool("Database.RecordSummary")
+	httpProps.Address = viper.GetString("Http.Address")
+	httpProps.Tiles = viper.GetBool("Http.Tiles")
+	httpProps.Events = viper.GetBool("Http.Events")
+	grpcProps.Address = viper.GetString("Grpc.Address")
+	if err := viper.UnmarshalKey("Telemetry", &telemetryProps); err != nil {
+		log.Fatalf("Unable to parse Telemetry configuration: %v", err)
+	}
+	if err := viper.UnmarshalKey("Health", &healthProps); err != nil {
+		log.Fatalf("Unable to parse Health configuration: %v", err)
+	}
+	if healthProps.MaxWriteAge < 0 {
+		log.Fatalf("Health MaxWriteAge %s is negative", healthProps.MaxWriteAge)
+	}
+	if healthProps.Interval <= 0 {
+		log.Fatalf("Health Interval %s is not positive", healthProps.Interval)
+	}
+	if err := viper.UnmarshalKey("Snapshot", &snapshotProps); err != nil {
+		log.Fatalf("Unable to parse Snapshot configuration: %v", err)
+	}
+	if err := snapshot.Check(snapshotProps.Format); err != nil {
+		log.Fatal(err)
+	}
+	var features map[string]bool
+	if err := viper.UnmarshalKey("Features", &features); err != nil {
+		log.Fatalf("Unable to parse Features configuration: %v", err)
+	}
+	for name, enabled := range features {
+		if err := feature.Set(name, enabled); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if _, ok := postgis.StrategySql[dbProps.WriteStrategy]; !ok {
+		log.Fatalf("Unknown write strategy '%s'", dbProps.WriteStrategy)
+	}
+	if err := dbProps.Templates.Check(); err != nil {
+		log.Fatal(err)
+	}
+	if err := dbProps.Partitions.Check(); err != nil {
+		log.Fatal(err)
+	}
+	switch dbProps.Events {
+	case postgis.EventsTable, postgis.EventsNotify, postgis.EventsBoth:
+	default:
+		log.Fatalf("Unknown events destination '%s'", dbProps.Events)
+	}
+
+	if moverBundle != nil {
+		if err := moverBundle.apply(&moverConfig); err != nil {
+			log.Fatalf("Unable to load bundle: %v", err)
+		}
+	}
+	moverConfig.LogEvery = logProps.TickEvery
+	if err := moverConfig.Init(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	// System
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Simulation
+	"github.com/pramsey/movesim/sink"
+	"github.com/pramsey/movesim/sink/postgis"
+)
+
+// ExperimentResult is the outcome of one combination
+// of the experiment parameter matrix.
+type ExperimentResult struct {
+	Movers    int    `json:"movers"`
+	Interval  string `json:"interval"`
+	BatchSize int    `json:"batch_size"`
+	sink.RunSummary
+}
+
+var experimentCsvHeader = []string{
+	"movers", "interval", "batch_size",
+	"duration_s", "updates", "writes", "errors", "repairs", "updates_per_sec",
+	"latency_mean_ms", "latency_p50_ms", "latency_p95_ms", "latency_p99_ms", "latency_max_ms",
+}
+
+func (r ExperimentResult) csvRecord() []string {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	return []string{
+		strconv.Itoa(r.Movers), r.Interval, strconv.Itoa(r.BatchSize),
+		f(r.Duration), strconv.FormatInt(r.Updates, 10), strconv.FormatInt(r.Writes, 10),
+		strconv.FormatInt(r.Errors, 10), strconv.FormatInt(r.Repairs, 10), f(r.UpdatesPerSec),
+		f(r.LatencyMean), f(r.LatencyP50), f(r.LatencyP95), f(r.LatencyP99), f(r.LatencyMax),
+	}
+}
+
+// runExperiment executes every combination of movers, interval and
+// batch size for a fixed duration and writes the consolidated
+// throughput and latency results as CSV or JSON.
+func runExperiment(args []string) {
+	flags, configFile := newFlagSet("movesim experiment")
+	moverCounts := flags.IntSlice("movers", []int{10, 50, 100}, "mover counts to test")
+	intervals := flags.DurationSlice("interval", []time.Duration{time.Second}, "sleep intervals to test")
+	batchSizes := flags.IntSlice("batch", []int{1}, "write batch sizes to test")
+	duration := flags.Duration("duration", 30*time.Second, "duration of each combination")
+	output := flags.StringP("output", "o", "", "results file (default stdout)")
+	format := flags.String("format", "", "results format, csv or json (default from output extension, else csv)")
+	flags.Parse(args)
+
+	initConfig(*configFile)
+	resultFormat := strings.ToLower(*format)
+	if resultFormat == "" {
+		resultFormat = "csv"
+		if strings.EqualFold(filepath.Ext(*output), ".json") {
+			resultFormat = "json"
+		}
+	}
+	if resultFormat != "csv" && resultFormat != "json" {
+		log.Fatalf("Unknown results format '%s'", resultFormat)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	dbPool := connectDatabase(ctx, "", dbProps.WriteStrategy)
+	defer dbPool.Close()
+	loadConstraint(ctx, dbPool)
+	loadDensity(ctx, dbPool)
+	loadObstacles(ctx, dbPool)
+	loadNetwork()
+	if len(moverConfig.Regions) > 0 {
+		// Regions would override the mover counts under test
+		log.Warn("Experiments ignore the configured regions")
+		moverConfig.Regions = nil
+	}
+
+	z := migratePointZ(ctx, dbPool)
+
+	var results []ExperimentResult
+	total := len(*moverCounts) * len(*intervals) * len(*batchSizes)
+sweep:
+	for _, movers := range *moverCounts {
+		for _, interval := range *intervals {
+			for _, batchSize := range *batchSizes {
+				if ctx.Err() != nil {
+					break sweep
+				}
+				opts := moverConfig.Options
+				opts.MaxMovers = movers
+				opts.SleepInterval = interval
+				log.Infof("Experiment %d/%d: movers=%d interval=%s batch=%d",
+					len(results)+1, total, movers, interval, batchSize)
+
+				runCtx, cancel := context.WithTimeout(ctx, *duration)
+				writer := postgis.NewWriter(postgis.Target{
+					Name:     "database",
+					DbPool:   dbPool,
+					Strategy: dbProps.WriteStrategy,
+					Wkb:      dbProps.Wkb,
+					PointZ:   z,
+				}, batchSize, interval)
+				stats := simulate(runCtx, opts, writer)[0]
+				cancel()
+
+				result := ExperimentResult{
+					Movers:     movers,
+					Interval:   interval.String(),
+					BatchSize:  batchSize,
+					RunSummary: stats.Summary(),
+				}
+				log.Infof("Experiment %d/%d: %.1f updates/sec, p95 latency %.2fms, %d errors",
+					len(results)+1, total, result.UpdatesPerSec, result.LatencyP95, result.Errors)
+				results = append(results, result)
+			}
+		}
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		out = file
+	}
+	if err := writeExperimentResults(out, resultFormat, results); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func writeExperimentResults(out io.Writer, format string, results []ExperimentResult) error {
+	if format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	}
+	writer := csv.NewWriter(out)
+	if err := writer.Write(experimentCsvHeader); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if err := writer.Write(r.csvRecord()); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
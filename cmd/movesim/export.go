@@ -0,0 +1,168 @@
+package main
+
+import (
+	// System
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Movers
+	"github.com/pramsey/movesim/export"
+	"github.com/pramsey/movesim/mover"
+
+	// Simulation
+	"github.com/pramsey/movesim/sim"
+	"github.com/pramsey/movesim/sink/memory"
+	"github.com/pramsey/movesim/sink/postgis"
+)
+
+// runExport writes the positions of the history table, or of
+// a fleet simulated in memory for a dry run, as a trajectory
+// file, by mover and then by time, for analysis elsewhere.
+func runExport(args []string) {
+	flags, configFile := newFlagSet("movesim export")
+	format := flags.String("format", export.FormatCSV, "file format (csv or parquet)")
+	output := flags.String("output", "-", "file to write, - for standard output")
+	run := flags.String("run", "", "id of the run to export (default every run)")
+	from := flags.String("from", "", "export positions from this time, RFC 3339")
+	to := flags.String("to", "", "export positions before this time, RFC 3339")
+	dryRun := flags.Bool("dry-run", false, "export a fleet simulated in memory, without a database")
+	movers := flags.Int("movers", 0, "number of movers to simulate for a dry run (default from the configuration)")
+	hours := flags.Float64("hours", 1, "simulated hours of movement for a dry run")
+	seed := flags.Int64("seed", 0, "random seed for a dry run")
+	flags.Parse(args)
+
+	initConfig(*configFile)
+	if err := export.Check(*format); err != nil {
+		log.Fatal(err)
+	}
+	query := postgis.HistoryQuery{Table: dbProps.HistoryTable, Run: *run}
+	for _, t := range []struct {
+		value string
+		ts    *time.Time
+	}{{*from, &query.From}, {*to, &query.To}} {
+		if t.value == "" {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339, t.value)
+		if err != nil {
+			log.Fatalf("Invalid time '%s': %v", t.value, err)
+		}
+		*t.ts = ts
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		out = f
+	}
+	buffered := bufio.NewWriter(out)
+	w, err := export.NewWriter(buffered, *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	count := 0
+	write := func(m mover.Mover) error {
+		count++
+		if m.SleepInterval == 0 {
+			// The history table does not keep how often each
+			// mover ticked, take speeds at the fleet interval
+			m.SleepInterval = moverConfig.SleepInterval
+		}
+		return w.Write(m)
+	}
+	if *dryRun {
+		if *movers > 0 {
+			moverConfig.MaxMovers = *movers
+		}
+		if *hours <= 0 {
+			log.Fatalf("Invalid number of hours %g", *hours)
+		}
+		if flags.Changed("seed") {
+			seedRandom(*seed)
+		}
+		err = exportSimulated(ctx, time.Duration(*hours*float64(time.Hour)), query, write)
+	} else {
+		if strings.Contains(query.Table, "{") {
+			log.Fatal("Templated history tables cannot be exported by name")
+		}
+		dbPool := connectDatabase(ctx, "", "")
+		defer dbPool.Close()
+		err = postgis.ReadHistory(ctx, dbPool, query, write)
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err != nil {
+		log.Fatalf("Unable to export trajectories: %v", err)
+	}
+	log.Infof("Exported %d positions as %s", count, *format)
+}
+
+// exportSimulated simulates the fleet in memory for the span up to
+// now, and writes the positions the query picks, by mover and then
+// by time, as the history table would give them.
+func exportSimulated(ctx context.Context, span time.Duration, query postgis.HistoryQuery, write func(m mover.Mover) error) error {
+	if moverConfig.Constraint.Query != "" {
+		log.Warn("Dry run, ignoring the constraint query")
+		moverConfig.Constraint.Query = ""
+	}
+	if moverConfig.StartDensity.Query != "" {
+		log.Warn("Dry run, ignoring the start density query")
+		moverConfig.StartDensity.Query = ""
+	}
+	if moverConfig.Obstacles.Query != "" {
+		log.Warn("Dry run, ignoring the obstacle query")
+		moverConfig.Obstacles.Query = ""
+	}
+	loadConstraint(ctx, nil)
+	loadDensity(ctx, nil)
+	loadObstacles(ctx, nil)
+	loadRegions()
+	loadNetwork()
+
+	out := memory.NewSink(true)
+	s, err := sim.NewSimulation(moverConfig.Options, out)
+	if err != nil {
+		return err
+	}
+	start := time.Now().Add(-span).Truncate(time.Second)
+	log.Infof("Simulating %s of movement for %d movers to export", span, moverConfig.MaxMovers)
+	s.Generate(ctx, start, span, postgis.DefaultCopyRows)
+
+	history := out.History()
+	sort.SliceStable(history, func(i, j int) bool {
+		if history[i].Id != history[j].Id {
+			return history[i].Id < history[j].Id
+		}
+		return history[i].Ts.Before(history[j].Ts)
+	})
+	for _, m := range history {
+		ts := m.DeviceTime(m.Ts)
+		if !query.From.IsZero() && ts.Before(query.From) || !query.To.IsZero() && !ts.Before(query.To) {
+			continue
+		}
+		if err := write(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
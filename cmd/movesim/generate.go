@@ -0,0 +1,162 @@
+package main
+
+import (
+	// System
+	"context"
+	"os"
+	"os/signal"
+	"time"
+
+	// PostgreSQL connection
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Simulation
+	"github.com/pramsey/movesim/sim"
+	"github.com/pramsey/movesim/sink"
+	"github.com/pramsey/movesim/sink/memory"
+	"github.com/pramsey/movesim/sink/postgis"
+)
+
+// runGenerate simulates hours of movement for the fleet as fast as
+// it can, without waiting for the clock, and bulk loads the history
+// of every position with COPY, to make datasets for benchmarking
+// spatio-temporal queries.
+func runGenerate(args []string) {
+	flags, configFile := newFlagSet("movesim generate")
+	movers := flags.Int("movers", 0, "number of movers (default from the configuration)")
+	hours := flags.Float64("hours", 1, "simulated hours of movement")
+	startTime := flags.String("start", "", "simulated start time, RFC 3339 (default the hours before now)")
+	rows := flags.Int("rows", postgis.DefaultCopyRows, "positions per COPY")
+	seed := flags.Int64("seed", 0, "random seed")
+	dryRun := flags.Bool("dry-run", false, "generate the positions in memory only, without a database")
+	addRunFlags(flags)
+	addShardFlags(flags)
+	flags.Parse(args)
+
+	initConfig(*configFile)
+	if *movers > 0 {
+		if len(moverConfig.Regions) > 0 {
+			log.Fatal("Regions set the number of movers, leave out --movers")
+		}
+		moverConfig.MaxMovers = *movers
+	}
+	if *hours <= 0 {
+		log.Fatalf("Invalid number of hours %g", *hours)
+	}
+	span := time.Duration(*hours * float64(time.Hour))
+	start := time.Now().Add(-span).Truncate(time.Second)
+	if *startTime != "" {
+		var err error
+		if start, err = time.Parse(time.RFC3339, *startTime); err != nil {
+			log.Fatalf("Invalid start time '%s': %v", *startTime, err)
+		}
+	}
+	if flags.Changed("seed") {
+		seedRandom(*seed)
+	}
+	if watchConfigFile {
+		log.Warn("Generating, not watching the config file")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	var out sink.Sink
+	var writer *postgis.CopyWriter
+	var dbPool *pgxpool.Pool
+	if *dryRun {
+		if moverConfig.Constraint.Query != "" {
+			log.Warn("Dry run, ignoring the constraint query")
+			moverConfig.Constraint.Query = ""
+		}
+		if moverConfig.StartDensity.Query != "" {
+			log.Warn("Dry run, ignoring the start density query")
+			moverConfig.StartDensity.Query = ""
+		}
+		if moverConfig.Obstacles.Query != "" {
+			log.Warn("Dry run, ignoring the obstacle query")
+			moverConfig.Obstacles.Query = ""
+		}
+		if moverConfig.Style.Persist {
+			log.Warn("Dry run, not keeping mover styles")
+			moverConfig.Style.Persist = false
+		}
+		if dbProps.RecordSummary {
+			log.Warn("Dry run, not recording the summary of the run")
+			dbProps.RecordSummary = false
+		}
+		if dbProps.CleanupOnExit {
+			log.Warn("Dry run, nothing to clean up")
+			dbProps.CleanupOnExit = false
+		}
+		if dbProps.Adopt {
+			log.Warn("Dry run, not adopting existing movers")
+			dbProps.Adopt = false
+		}
+		loadConstraint(ctx, nil)
+		loadDensity(ctx, nil)
+		loadObstacles(ctx, nil)
+		out = memory.NewSink(false)
+	} else {
+		dbPool = connectDatabase(ctx, "", "")
+		defer dbPool.Close()
+		startRun(ctx, dbPool)
+		defer finishRun(dbPool)
+		loadConstraint(ctx, dbPool)
+		loadDensity(ctx, dbPool)
+		loadObstacles(ctx, dbPool)
+		loadStyles(ctx, dbPool)
+		loadStates(ctx, dbPool)
+		if moverConfig.Styles != nil {
+			defer saveStyles(context.Background(), dbPool, moverConfig.Styles)
+		}
+		// Partitions for the positions of the span, and none
+		// dropped, as they may well be out of the retention window
+		partitioner := newPartitioner(ctx, dbPool)
+		if partitioner != nil {
+			if err := partitioner.Cover(ctx, start, start.Add(span)); err != nil {
+				log.Fatalf("Unable to partition history: %v", err)
+			}
+		}
+		writer = postgis.NewCopyWriter(postgis.Target{
+			Name:   "database",
+			DbPool: dbPool,
+			Events: dbProps.Events,
+			Tables: postgis.Tables{
+				Objects: dbProps.ObjectsTable,
+				History: dbProps.HistoryTable,
+				Tenant:  dbProps.Tenant,
+			},
+			Templates:   dbProps.Templates,
+			PointZ:      migratePointZ(ctx, dbPool),
+			Partitioner: partitioner,
+			Run:         dbProps.Run,
+		}, *rows)
+		out = writer
+	}
+	loadRegions()
+	loadNetwork()
+
+	defer startTelemetry(ctx)()
+	s, err := sim.NewSimulation(moverConfig.Options, out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Generating %s of movement from %s for %d movers using movement model '%s'",
+		span, start.Format(time.RFC3339), moverConfig.MaxMovers, moverConfig.Model)
+	if moverConfig.Shard.Sharded() {
+		log.Infof("Generating shard %d of %d", moverConfig.Shard.Index, moverConfig.Shard.Count)
+	}
+	stats := s.Generate(ctx, start, span, *rows)
+	summary := stats[0].Summary()
+	log.Infof("Generated %d positions into %s in %.1fs, %.1f positions/sec, %d errors",
+		summary.Updates, out.Name(), summary.Duration, summary.UpdatesPerSec, summary.Errors)
+	if dbProps.CleanupOnExit {
+		cleanupRun([]*postgis.CopyWriter{writer})
+	}
+	if dbProps.RecordSummary {
+		recordSummary(dbPool, summarize(s, []sink.Sink{out}, stats))
+	}
+}
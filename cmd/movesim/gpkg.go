@@ -0,0 +1,14 @@
+//go:build gpkg
+
+package main
+
+import (
+	// Simulation
+	"github.com/pramsey/movesim/sink"
+	"github.com/pramsey/movesim/sink/gpkg"
+)
+
+// newGpkgSink opens the GeoPackage sink of the output settings.
+func newGpkgSink() (sink.Sink, error) {
+	return gpkg.NewSink(outputProps.Gpkg.File, outputProps.Gpkg.History, moverConfig.SleepInterval)
+}
@@ -0,0 +1,17 @@
+//go:build !gpkg
+
+package main
+
+import (
+	// System
+	"errors"
+
+	// Simulation
+	"github.com/pramsey/movesim/sink"
+)
+
+// newGpkgSink fails, as the GeoPackage sink needs cgo for SQLite
+// and so is left out of builds without the gpkg tag.
+func newGpkgSink() (sink.Sink, error) {
+	return nil, errors.New("this build has no GeoPackage sink, build with -tags gpkg")
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	// System
+	"context"
+	"net"
+
+	// gRPC
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Simulation
+	"github.com/pramsey/movesim/api"
+	"github.com/pramsey/movesim/health"
+)
+
+// GrpcProps configures the gRPC API, which only runs
+// when Address is set.
+type GrpcProps struct {
+	Address string
+}
+
+var grpcProps GrpcProps
+
+// startGrpc serves the API, and the standard health service with
+// the readiness of the checker, until the context is done. The
+// subscriptions end when the simulation closes its sinks.
+func startGrpc(ctx context.Context, server *api.Server, checker *health.Checker) {
+	listener, err := net.Listen("tcp", grpcProps.Address)
+	if err != nil {
+		log.Fatalf("Unable to serve gRPC API: %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	api.RegisterSimulationServer(grpcServer, server)
+	healthServer := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	go checker.Serve(ctx, healthServer, healthProps.Interval, api.Simulation_ServiceDesc.ServiceName)
+	go func() {
+		<-ctx.Done()
+		grpcServer.GracefulStop()
+	}()
+	go func() {
+		log.Infof("Serving gRPC API on %s", listener.Addr())
+		if err := grpcServer.Serve(listener); err != nil {
+			log.Errorf("gRPC server failed: %v", err)
+		}
+	}()
+}
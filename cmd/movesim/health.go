@@ -0,0 +1,39 @@
+package main
+
+import (
+	// System
+	"time"
+
+	// PostgreSQL connection
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	// Simulation
+	"github.com/pramsey/movesim/health"
+	"github.com/pramsey/movesim/sim"
+	"github.com/pramsey/movesim/sink"
+)
+
+// HealthProps configures the health checks of the admin HTTP
+// server and the gRPC API. The simulator is not ready while no
+// position has been written for MaxWriteAge, zero never checks.
+// Interval is how often the gRPC health status is brought up to
+// date.
+type HealthProps struct {
+	MaxWriteAge time.Duration
+	Interval    time.Duration
+}
+
+var healthProps = HealthProps{
+	MaxWriteAge: time.Minute,
+	Interval:    5 * time.Second,
+}
+
+// newChecker looks over the simulation, and the writes of the
+// given sinks.
+func newChecker(dbPool *pgxpool.Pool, sinks []sink.Sink, s *sim.Simulation) *health.Checker {
+	stats := make([]*sink.RunStats, len(sinks))
+	for i, out := range sinks {
+		stats[i] = out.Stats()
+	}
+	return health.NewChecker(dbPool, stats, s.Count, healthProps.MaxWriteAge)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	// System
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Movers
+	"github.com/pramsey/movesim/health"
+	"github.com/pramsey/movesim/sink"
+	"github.com/pramsey/movesim/snapshot"
+	"github.com/pramsey/movesim/sse"
+	"github.com/pramsey/movesim/tiles"
+
+	// Feature flags
+	"github.com/pramsey/movesim/feature"
+)
+
+// HttpProps configures the admin HTTP server, which only runs
+// when Address is set. Tiles serves the fleet as vector tiles,
+// and Events streams it as Server-Sent Events.
+type HttpProps struct {
+	Address string
+	Tiles   bool
+	Events  bool
+}
+
+var httpProps HttpProps
+
+// SinkStats is the running statistics of one sink.
+type SinkStats struct {
+	Sink string `json:"sink"`
+	sink.RunSummary
+}
+
+// startHttp serves the admin endpoints until the context is done,
+// with /stats reporting on the sinks, /snapshot dumping the fleet,
+// /healthz and /readyz the probes of the checker, and /tiles and
+// /events serving the tile and event servers among the sinks, if any.
+func startHttp(ctx context.Context, sinks []sink.Sink, fleet snapshot.Source, checker *health.Checker) {
+	if httpProps.Address == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	features := http.StripPrefix("/features", feature.Handler())
+	mux.Handle("/features", features)
+	mux.Handle("/features/", features)
+	for _, s := range sinks {
+		if tileServer, ok := s.(*tiles.Server); ok {
+			mux.Handle("/tiles/", tileServer)
+			log.Infof("Serving tiles on http://%s/tiles/{z}/{x}/{y}.pbf", httpProps.Address)
+		}
+		if eventServer, ok := s.(*sse.Server); ok {
+			mux.Handle("/events", eventServer)
+			log.Infof("Streaming events on http://%s/events", httpProps.Address)
+		}
+	}
+	mux.Handle("/snapshot", snapshot.Handler(fleet))
+	mux.Handle("/healthz", checker.LiveHandler())
+	mux.Handle("/readyz", checker.ReadyHandler())
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		stats := make([]SinkStats, len(sinks))
+		for i, s := range sinks {
+			stats[i] = SinkStats{Sink: s.Name(), RunSummary: s.Stats().Summary()}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(stats)
+	})
+
+	server := &http.Server{
+		Addr:              httpProps.Address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	go func() {
+		log.Infof("Serving admin endpoints on http://%s", httpProps.Address)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("Admin server failed: %v", err)
+		}
+	}()
+}
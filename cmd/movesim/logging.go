@@ -0,0 +1,41 @@
+package main
+
+import (
+	// System
+	"os"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+)
+
+// LoggingProps controls the log. Level is any logrus level name,
+// Format is "text" or "json". Every mover tick is logged at debug
+// level, thinned to one tick in TickEvery (zero turns it off).
+type LoggingProps struct {
+	Level     string
+	Format    string
+	TickEvery int
+}
+
+var logProps LoggingProps = LoggingProps{
+	Level:     "info",
+	Format:    "text",
+	TickEvery: 1,
+}
+
+func initLogging() {
+	level, err := log.ParseLevel(logProps.Level)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.SetLevel(level)
+	switch logProps.Format {
+	case "text":
+		log.SetFormatter(&log.TextFormatter{})
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		log.Fatalf("Unknown log format '%s'", logProps.Format)
+	}
+	log.SetOutput(os.Stderr)
+}
@@ -0,0 +1,382 @@
+// Command movesim runs the simulator against a database, files
+// or an AIS feed, and measures how the database keeps up.
+package main
+
+import (
+	// System
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	// PostgreSQL connection
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Simulation
+	"github.com/pramsey/movesim/api"
+	"github.com/pramsey/movesim/sim"
+	"github.com/pramsey/movesim/sink"
+	"github.com/pramsey/movesim/sink/limit"
+	"github.com/pramsey/movesim/sink/memory"
+	"github.com/pramsey/movesim/sink/postgis"
+	"github.com/pramsey/movesim/sse"
+	"github.com/pramsey/movesim/tiles"
+)
+
+// simulate runs the fleet until the context is cancelled, writing
+// every position to each of the sinks, and returns the statistics
+// of the run per sink.
+func simulate(ctx context.Context, opts sim.Options, sinks ...sink.Sink) []*sink.RunStats {
+	s, err := sim.NewSimulation(opts, sinks...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return s.Run(ctx)
+}
+
+// connectDatabase opens a connection pool, by default to
+// the configured database, with the statements for writing
+// with the strategy prepared. An empty strategy prepares none,
+// for pools that only read.
+func connectDatabase(ctx context.Context, dbUrl string, strategy string) *pgxpool.Pool {
+	if dbUrl == "" {
+		dbUrl = dbProps.DbConnection
+	}
+	if dbUrl == "" {
+		log.Fatal("Unable to find DATABASE_URL")
+	}
+
+	dbConfig, err := pgxpool.ParseConfig(dbUrl)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if dbProps.MaxConns > 0 {
+		dbConfig.MaxConns = dbProps.MaxConns
+	}
+	if dbProps.MinConns > 0 {
+		dbConfig.MinConns = dbProps.MinConns
+	}
+	if strategy != "" {
+		dbConfig.AfterConnect = postgis.PrepareStatements(strategy)
+	}
+	dbPool, err := pgxpool.ConnectConfig(ctx, dbConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return dbPool
+}
+
+// runSimulation is the default command, moving the fleet
+// until interrupted.
+func runSimulation(args []string) {
+	flags, configFile := newFlagSet("movesim")
+	dryRun := flags.Bool("dry-run", false, "move the fleet in memory only, without a database or other output")
+	seed := flags.Int64("seed", 0, "random seed (default a new one)")
+	addRunFlags(flags)
+	addShardFlags(flags)
+	flags.Parse(args)
+
+	// Read config file and environment configuration first
+	initConfig(*configFile)
+	if flags.Changed("seed") {
+		seedRandom(*seed)
+	}
+	runFleet(*dryRun, []string{outputProps.Sink}, 0, nil)
+}
+
+// runFleet moves the configured fleet, writing to the sinks of the
+// given kinds, or only to memory in a dry run, until interrupted
+// or, if duration is set, for that long, playing the events.
+func runFleet(dryRun bool, kinds []string, duration time.Duration, events []ScenarioEvent) {
+	log.Infof("Using movement model '%s'", moverConfig.Model)
+	if moverConfig.Shard.Sharded() {
+		log.Infof("Running shard %d of %d", moverConfig.Shard.Index, moverConfig.Shard.Count)
+	}
+
+	var dbPool *pgxpool.Pool
+	if dryRun {
+		if moverConfig.Constraint.Query != "" {
+			log.Warn("Dry run, ignoring the constraint query")
+			moverConfig.Constraint.Query = ""
+		}
+		if moverConfig.StartDensity.Query != "" {
+			log.Warn("Dry run, ignoring the start density query")
+			moverConfig.StartDensity.Query = ""
+		}
+		if moverConfig.Obstacles.Query != "" {
+			log.Warn("Dry run, ignoring the obstacle query")
+			moverConfig.Obstacles.Query = ""
+		}
+		if dbProps.Adopt {
+			log.Warn("Dry run, not adopting existing movers")
+			dbProps.Adopt = false
+		}
+		if moverConfig.Style.Persist {
+			log.Warn("Dry run, not keeping mover styles")
+			moverConfig.Style.Persist = false
+		}
+		if dbProps.RecordSummary {
+			log.Warn("Dry run, not recording the summary of the run")
+			dbProps.RecordSummary = false
+		}
+	} else if outputNeedsDatabase(kinds) {
+		strategy := ""
+		for _, kind := range kinds {
+			if kind == SinkDatabase {
+				strategy = dbProps.WriteStrategy
+			}
+		}
+		writes := strategy != ""
+		if dbProps.Templates != (postgis.Templates{}) {
+			// Statements from templates are not prepared up
+			// front, the default tables need not exist
+			strategy = ""
+		}
+		dbPool = connectDatabase(context.Background(), "", strategy)
+		defer dbPool.Close()
+		startRun(context.Background(), dbPool)
+		defer finishRun(dbPool)
+		if writes {
+			pointZ = migratePointZ(context.Background(), dbPool)
+			historyPartitioner = newPartitioner(context.Background(), dbPool)
+		}
+	}
+	loadConstraint(context.Background(), dbPool)
+	loadDensity(context.Background(), dbPool)
+	loadObstacles(context.Background(), dbPool)
+	loadStates(context.Background(), dbPool)
+	loadStyles(context.Background(), dbPool)
+	loadRegions()
+	loadNetwork()
+
+	var sinks []sink.Sink
+	var writers []*postgis.Writer
+	if dryRun {
+		sinks = append(sinks, memory.NewSink(false))
+	} else {
+		for _, kind := range kinds {
+			out, err := newOutputSink(context.Background(), dbPool, kind)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if writer, ok := out.(*postgis.Writer); ok {
+				writers = append(writers, writer)
+			}
+			if outputProps.Limit.Enabled() {
+				out = limit.NewSink(out, outputProps.Limit)
+			}
+			sinks = append(sinks, out)
+		}
+	}
+	outputs := len(sinks)
+
+	// Run until interrupt signal, which shuts down
+	// everything attached to this context before exit
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+	var apiServer *api.Server
+	if grpcProps.Address != "" {
+		apiServer = api.NewServer()
+		sinks = append(sinks, apiServer)
+	}
+	if httpProps.Address != "" && httpProps.Tiles {
+		sinks = append(sinks, tiles.NewServer())
+	}
+	if httpProps.Address != "" && httpProps.Events {
+		sinks = append(sinks, sse.NewServer())
+	}
+	if historyPartitioner != nil {
+		go historyPartitioner.Run(ctx)
+	}
+	defer startTelemetry(ctx)()
+	s, err := sim.NewSimulation(moverConfig.Options, sinks...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if moverConfig.Shard.Sharded() && dbPool != nil {
+		go reportShard(ctx, dbPool, sinks[0].Stats(), s)
+	}
+	go watchConfig(ctx, s)
+	checker := newChecker(dbPool, sinks[:outputs], s)
+	startHttp(ctx, sinks, s.Movers, checker)
+	watchSnapshots(ctx, s.Movers)
+	if apiServer != nil {
+		apiServer.Attach(s)
+		startGrpc(ctx, apiServer, checker)
+	}
+	if len(events) > 0 {
+		go playEvents(ctx, s, events)
+	}
+	if dbProps.Control && dbPool != nil {
+		go postgis.ListenCommands(ctx, dbPool, s.Command)
+	}
+	if moverConfig.Styles != nil {
+		go keepStyles(ctx, dbPool, moverConfig.Styles)
+	}
+	stats := s.Run(ctx)
+	if moverConfig.Styles != nil {
+		saveStyles(context.Background(), dbPool, moverConfig.Styles)
+	}
+	if dbProps.CleanupOnExit {
+		cleanupRun(writers)
+	}
+	if moverConfig.Shard.Sharded() && dbPool != nil {
+		// The final word on this shard
+		if err := postgis.WriteShardStats(context.Background(), dbPool, shardStats(stats[0], s)); err != nil {
+			log.Errorf("Unable to report shard stats: %v", err)
+		}
+	}
+
+	if dry, ok := sinks[0].(*memory.Sink); ok {
+		created, deleted := dry.Counts()
+		summary := stats[0].Summary()
+		trips := 0
+		for _, t := range dry.Trips() {
+			if t.Ended() {
+				trips++
+			}
+		}
+		log.Infof("Dry run moved %d movers (%d still alive, %d retired) through %d positions, %.1f updates/sec, %d repairs, %d events, %d trips",
+			created, len(dry.Movers()), deleted, summary.Updates, summary.UpdatesPerSec, summary.Repairs, len(dry.Events()), trips)
+	}
+	summary := summarize(s, sinks[:outputs], stats)
+	logSummary(summary)
+	if dbProps.RecordSummary && dbPool != nil {
+		recordSummary(dbPool, summary)
+	}
+}
+
+// runSeed is the seed of the random numbers of the movers,
+// recorded with the run.
+var runSeed int64
+
+// seedRandom seeds the random numbers of the movers, each of
+// which draws from its own source seeded from this and its id,
+// so that the same seed moves them the same way however their
+// goroutines are scheduled.
+func seedRandom(seed int64) {
+	runSeed = seed
+	moverConfig.Seed = seed
+}
+
+// startRun picks a run id to tag the rows of this run with,
+// unless one was given, and records the run in moving.runs.
+func startRun(ctx context.Context, dbPool *pgxpool.Pool) {
+	if dbProps.Run == "" && moverConfig.Shard.Sharded() {
+		log.Fatal("The shards of a fleet need the same --run-id, to add up their stats")
+	}
+	if dbProps.Run == "" {
+		dbProps.Run = time.Now().UTC().Format("20060102-150405") + fmt.Sprintf("-%04x", rand.Intn(0x10000))
+	}
+	log.Infof("Run id %s", dbProps.Run)
+
+	host, _ := os.Hostname()
+	err := postgis.StartRun(ctx, dbPool, postgis.RunRecord{
+		Id:      dbProps.Run,
+		Command: strings.Join(os.Args, " "),
+		Host:    host,
+		Started: time.Now(),
+		Seed:    runSeed,
+		Movers:  moverConfig.FleetSize(),
+		Config:  configSnapshot(),
+	})
+	if err != nil {
+		// Runs still work against databases without the table
+		log.Warnf("Unable to record run %s: %v", dbProps.Run, err)
+	}
+}
+
+// finishRun records the end of the run.
+func finishRun(dbPool *pgxpool.Pool) {
+	if err := postgis.EndRun(context.Background(), dbPool, dbProps.Run, time.Now()); err != nil {
+		log.Warnf("Unable to record the end of run %s: %v", dbProps.Run, err)
+	}
+}
+
+// cleaner is an output that can delete the rows of its run,
+// such as postgis.Writer and postgis.CopyWriter.
+type cleaner interface {
+	Cleanup(ctx context.Context, withHistory bool) (int64, error)
+}
+
+// cleanupRun deletes the rows the writers wrote in this run.
+func cleanupRun[W cleaner](writers []W) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for _, writer := range writers {
+		deleted, err := writer.Cleanup(ctx, dbProps.CleanupHistory)
+		if err != nil {
+			log.Errorf("Unable to clean up run %s: %v", dbProps.Run, err)
+			continue
+		}
+		log.Infof("Cleaned up %d rows of run %s", deleted, dbProps.Run)
+	}
+}
+
+func main() {
+
+	// Initialize random number generators, the shared one for
+	// run ids and sampling, and that of the movers
+	rand.Seed(time.Now().UnixNano())
+	seedRandom(time.Now().UnixNano())
+
+	command, args := "", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "":
+		runSimulation(args)
+	case "run":
+		runScenario(args)
+	case "clean":
+		runClean(args)
+	case "generate":
+		runGenerate(args)
+	case "experiment":
+		runExperiment(args)
+	case "compare":
+		runCompare(args)
+	case "stats":
+		runStats(args)
+	case "export":
+		runExport(args)
+	default:
+		log.Fatalf("Unknown command '%s'", command)
+	}
+
+	// relay := broadcast.NewRelay[msg]() // Create a relay for msg values
+	// defer relay.Close()
+
+	// // Listener goroutines
+	// for i := 0; i < 2; i++ {
+	//     go func(i int) {
+	//         l := relay.Listener(1)  // Create a listener with a buffer capacity of 1
+	//         for n := range l.Ch() { // Ranges over notifications
+	//             fmt.Printf("listener %d has received a notification: %v\n", i, n)
+	//         }
+	//     }(i)
+	// }
+
+	// // Notifiers
+	// time.Sleep(time.Second)
+	// relay.Notify(msgA)                                     // Send notification with guaranteed delivery
+	// // ctx, _ := context.WithTimeout(context.Background(), 10) // Context with immediate timeout
+	// // relay.NotifyCtx(ctx, msgB)                             // Send notification respecting context cancellation
+	// relay.Notify(msgB)
+	// time.Sleep(time.Second)                                // Allow time for previous messages to be processed
+	// relay.Broadcast(msgC)                                  // Send notification without guaranteed delivery
+	// time.Sleep(time.Second)                                // Allow time for previous messages to be processed
+}
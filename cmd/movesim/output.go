@@ -0,0 +1,367 @@
+package main
+
+import (
+	// System
+	"context"
+	"fmt"
+	"strings"
+
+	// PostgreSQL connection
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/osm"
+	"github.com/pramsey/movesim/sink"
+	"github.com/pramsey/movesim/sink/ais"
+	"github.com/pramsey/movesim/sink/file"
+	"github.com/pramsey/movesim/sink/limit"
+	"github.com/pramsey/movesim/sink/postgis"
+	"github.com/pramsey/movesim/sink/redis"
+)
+
+// Sink types
+const (
+	SinkDatabase = "database"
+	SinkFile     = "file"
+	SinkAis      = "ais"
+	SinkRedis    = "redis"
+	SinkGpkg     = "gpkg"
+)
+
+// OutputProps selects the sink for the default command. File,
+// Format and Geometry (the geometry encoding) only apply to the
+// file sink, Ais to the ais sink, Redis to the redis sink and
+// Gpkg to the gpkg sink. Limit applies to whichever sink is used.
+type OutputProps struct {
+	Sink     string
+	File     string
+	Format   string
+	Geometry string
+	Ais      ais.Props
+	Redis    redis.Props
+	Gpkg     GpkgProps
+	Limit    limit.Props
+}
+
+// GpkgProps is the GeoPackage file to write, and whether
+// to keep every position in its history table.
+type GpkgProps struct {
+	File    string
+	History bool
+}
+
+var outputProps OutputProps = OutputProps{
+	Sink:     SinkDatabase,
+	File:     "-",
+	Format:   file.FormatLines,
+	Geometry: geo.EncodingGeoJSON,
+	Ais: ais.Props{
+		Protocol: "tcp",
+		Address:  "localhost:10110",
+		MmsiBase: 366900000,
+	},
+	Redis: redis.Props{
+		Address: "localhost:6379",
+		Channel: "movesim",
+		GeoKey:  "movesim:objects",
+	},
+	Gpkg: GpkgProps{
+		File:    "movesim.gpkg",
+		History: true,
+	},
+}
+
+// outputNeedsDatabase reports whether the sinks of the given
+// kinds, or anything else configured, need a database connection.
+func outputNeedsDatabase(kinds []string) bool {
+	for _, kind := range kinds {
+		if kind == SinkDatabase {
+			return true
+		}
+	}
+	return moverConfig.Constraint.Query != "" || moverConfig.StartDensity.Query != "" ||
+		moverConfig.Obstacles.Query != "" || moverConfig.Style.Persist || dbProps.Control || dbProps.Adopt || dbProps.RecordSummary
+}
+
+// historyPartitioner keeps the history tables of the database
+// sink partitioned, when they are to be, see newPartitioner.
+var historyPartitioner *postgis.Partitioner
+
+// newPartitioner sets up partitioning of the history tables, when
+// configured, partitioning the history table straight away unless
+// it is templated, in which case each is as it comes up.
+func newPartitioner(ctx context.Context, dbPool *pgxpool.Pool) *postgis.Partitioner {
+	if !dbProps.Partitions.Enabled() {
+		return nil
+	}
+	partitioner := postgis.NewPartitioner(dbPool, dbProps.Partitions)
+	if !strings.Contains(dbProps.HistoryTable, "{") {
+		if err := partitioner.Add(ctx, dbProps.HistoryTable); err != nil {
+			log.Fatalf("Unable to partition history: %v", err)
+		}
+	}
+	return partitioner
+}
+
+// pointZ has the database sink write positions with their
+// altitudes, to tables turned PointZ by migratePointZ.
+var pointZ bool
+
+// migratePointZ turns the position columns of the database tables
+// PointZ when movers have altitudes, reporting whether they do.
+// Templated tables are turned as they come up, and tables written
+// by statement templates are left as they are.
+func migratePointZ(ctx context.Context, dbPool *pgxpool.Pool) bool {
+	if !moverConfig.HasAltitude() {
+		return false
+	}
+	if dbProps.Templates != (postgis.Templates{}) {
+		return true
+	}
+	objects, history := dbProps.ObjectsTable, dbProps.HistoryTable
+	if objects == "" {
+		objects = postgis.DefaultObjectsTable
+	}
+	if history == "" {
+		history = postgis.DefaultHistoryTable
+	}
+	var tables []string
+	for _, table := range []string{objects, history} {
+		if !strings.Contains(table, "{") {
+			tables = append(tables, table)
+		}
+	}
+	if err := postgis.MigratePointZ(ctx, dbPool, tables...); err != nil {
+		log.Fatal(err)
+	}
+	return true
+}
+
+// newOutputSink opens a sink of the given kind, set up from the
+// Output configuration. The pool is only used by the database sink.
+func newOutputSink(ctx context.Context, dbPool *pgxpool.Pool, kind string) (sink.Sink, error) {
+	switch kind {
+	case SinkDatabase:
+		return postgis.NewWriter(postgis.Target{
+			Name:     "database",
+			DbPool:   dbPool,
+			Strategy: dbProps.WriteStrategy,
+			Events:   dbProps.Events,
+			Tables: postgis.Tables{
+				Objects: dbProps.ObjectsTable,
+				History: dbProps.HistoryTable,
+				Tenant:  dbProps.Tenant,
+			},
+			Templates:   dbProps.Templates,
+			Wkb:         dbProps.Wkb,
+			PointZ:      pointZ,
+			Partitioner: historyPartitioner,
+			Run:         dbProps.Run,
+		}, moverConfig.BatchSize, moverConfig.SleepInterval), nil
+	case SinkFile:
+		return file.NewSink(outputProps.File, outputProps.Format, outputProps.Geometry, moverConfig.SleepInterval)
+	case SinkAis:
+		return ais.NewSink(outputProps.Ais)
+	case SinkRedis:
+		return redis.NewSink(outputProps.Redis)
+	case SinkGpkg:
+		return newGpkgSink()
+	default:
+		return nil, fmt.Errorf("unknown sink '%s'", kind)
+	}
+}
+
+// loadConstraint reads the configured polygon layer, if any,
+// into the simulation options.
+func loadConstraint(ctx context.Context, dbPool *pgxpool.Pool) {
+	props := moverConfig.Constraint
+	if props.File == "" && props.Query == "" {
+		return
+	}
+
+	var features []geo.Feature
+	var err error
+	if props.File != "" {
+		features, err = readGeoJSON(props.File)
+	} else if dbPool == nil {
+		log.Fatal("Constraint query needs a database connection")
+	} else {
+		features, err = postgis.QueryFeatures(ctx, dbPool, props.Query)
+	}
+	if err != nil {
+		log.Fatalf("Unable to load constraint layer: %v", err)
+	}
+
+	var polygons []geo.Polygon
+	for _, f := range features {
+		polygons = append(polygons, f.Polygons...)
+	}
+	constraint, err := mover.NewConstraint(polygons, props.Mode)
+	if err != nil {
+		log.Fatal(err)
+	}
+	moverConfig.Layer = constraint
+	log.Infof("Loaded %d constraint polygons, movers stay %s", len(polygons), props.Mode)
+}
+
+// loadObstacles reads the configured no-go zones, if any,
+// into the simulation options.
+func loadObstacles(ctx context.Context, dbPool *pgxpool.Pool) {
+	props := moverConfig.Obstacles
+	if props.File == "" && props.Query == "" {
+		return
+	}
+
+	var features []geo.Feature
+	var err error
+	if props.File != "" {
+		features, err = readGeoJSON(props.File)
+	} else if dbPool == nil {
+		log.Fatal("Obstacle query needs a database connection")
+	} else {
+		features, err = postgis.QueryFeatures(ctx, dbPool, props.Query)
+	}
+	if err != nil {
+		log.Fatalf("Unable to load obstacle layer: %v", err)
+	}
+
+	names := make([]string, len(features))
+	polygons := make([][]geo.Polygon, len(features))
+	for i, f := range features {
+		if name, ok := f.Properties[props.Name]; ok && name != nil {
+			names[i] = fmt.Sprint(name)
+		}
+		polygons[i] = f.Polygons
+	}
+	zones, err := mover.NewZones(names, polygons)
+	if err != nil {
+		log.Fatal(err)
+	}
+	moverConfig.Zones = zones
+	log.Infof("Loaded %d no-go zones", zones.Len())
+}
+
+// loadStates reads the movers an earlier run left in the objects
+// table into the simulation options, when asked to adopt them, for
+// the movers of this run with the same ids to carry on from.
+func loadStates(ctx context.Context, dbPool *pgxpool.Pool) {
+	if !dbProps.Adopt {
+		return
+	}
+	if strings.Contains(dbProps.ObjectsTable+dbProps.HistoryTable, "{") {
+		log.Fatal("Templated tables cannot be adopted by name")
+	}
+	states, err := postgis.ReadStates(ctx, dbPool, dbProps.ObjectsTable, dbProps.HistoryTable)
+	if err != nil {
+		log.Fatalf("Unable to read existing movers: %v", err)
+	}
+	moverConfig.Adopt = states
+	log.Infof("Adopting %d existing movers", len(states))
+}
+
+// loadDensity reads the configured start density, if any,
+// into the simulation options.
+func loadDensity(ctx context.Context, dbPool *pgxpool.Pool) {
+	props := moverConfig.StartDensity
+	var density *mover.Density
+	var err error
+	switch {
+	case props.Raster != "":
+		var grid *geo.Grid
+		if grid, err = geo.ReadAsciiGrid(props.Raster); err == nil {
+			density, err = mover.NewGridDensity(grid)
+		}
+	case props.File != "" || props.Query != "":
+		var features []geo.Feature
+		if props.File != "" {
+			features, err = readGeoJSON(props.File)
+		} else if dbPool == nil {
+			log.Fatal("Start density query needs a database connection")
+		} else {
+			features, err = postgis.QueryFeatures(ctx, dbPool, props.Query)
+		}
+		if err == nil {
+			density, err = weightedPoints(features, props)
+		}
+	default:
+		return
+	}
+	if err != nil {
+		log.Fatalf("Unable to load start density: %v", err)
+	}
+	moverConfig.Density = density
+	log.Infof("Loaded start density of %d weighted places", density.Len())
+}
+
+// weightedPoints makes a density of the points of the features,
+// weighted by their weight property, or evenly without one.
+func weightedPoints(features []geo.Feature, props mover.DensityProps) (*mover.Density, error) {
+	var points []geo.Point
+	var weights []float64
+	for _, f := range features {
+		weight := 1.0
+		if value, ok := f.Properties[props.Weight]; ok && value != nil {
+			n, ok := value.(float64)
+			if !ok {
+				return nil, fmt.Errorf("weight %v is not a number", value)
+			}
+			weight = n
+		}
+		for _, p := range f.Points {
+			points = append(points, p)
+			weights = append(weights, weight)
+		}
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("no points to start from")
+	}
+	return mover.NewDensity(points, weights, props.Spread)
+}
+
+// loadNetwork reads the street network into the simulation
+// options, when the route model needs one.
+func loadNetwork() {
+	props := moverConfig.Route
+	if moverConfig.Model != mover.ModelRoute {
+		return
+	}
+	if props.File == "" {
+		log.Fatal("Route model needs a street network file")
+	}
+	network, err := osm.ReadNetwork(props.File, props.Highways)
+	if err != nil {
+		log.Fatalf("Unable to load street network: %v", err)
+	}
+	moverConfig.Network = network
+	log.Infof("Loaded street network of %d nodes from %s", network.Len(), props.File)
+}
+
+// loadRegions reads the polygons of the regions that have them.
+func loadRegions() {
+	for _, region := range moverConfig.Regions {
+		if region.File == "" {
+			continue
+		}
+		features, err := readGeoJSON(region.File)
+		if err != nil {
+			log.Fatalf("Unable to load region '%s': %v", region.Name, err)
+		}
+		var polygons []geo.Polygon
+		for _, f := range features {
+			polygons = append(polygons, f.Polygons...)
+		}
+		if err := region.SetArea(polygons); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, region := range moverConfig.Regions {
+		log.Infof("Region '%s' starts %d movers", region.Name, region.Count)
+	}
+}
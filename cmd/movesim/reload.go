@@ -0,0 +1,117 @@
+package main
+
+import (
+	// System
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	// Configuration
+	"github.com/spf13/viper"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Simulation
+	"github.com/pramsey/movesim/sim"
+)
+
+// How often a watched config file is checked for changes
+const configPollInterval = 2 * time.Second
+
+// watchConfigFile reloads the config file whenever it changes,
+// and not only on SIGHUP, see --watch-config.
+var watchConfigFile bool
+
+// watchConfig reloads the config file on SIGHUP, and as it changes
+// if it is watched, until the context is done, handing the settings
+// the running simulation can take on to it, see sim.LiveProps.
+func watchConfig(ctx context.Context, s *sim.Simulation) {
+	configFile := viper.ConfigFileUsed()
+	if configFile == "" {
+		return
+	}
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	var poll <-chan time.Time
+	modified := modTime(configFile)
+	if watchConfigFile {
+		ticker := time.NewTicker(configPollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+		log.Infof("Watching config file %s", configFile)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+			log.Infof("Reloading config file %s", configFile)
+		case <-poll:
+			latest := modTime(configFile)
+			if latest.Equal(modified) {
+				continue
+			}
+			modified = latest
+			log.Infof("Config file %s changed, reloading", configFile)
+		}
+		reloadConfig(s)
+	}
+}
+
+// modTime is when the file was last written, zero if it cannot
+// be read, as while an editor is replacing it.
+func modTime(name string) time.Time {
+	info, err := os.Stat(name)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// reloadConfig reads the configuration again, layered as at the
+// start, see layerConfig, so that a key taken out of the config
+// file falls back to the bundle or the default, and the flags still
+// win. It applies what can change at runtime: the log level, and
+// the fleet size and mover tuning, see sim.LiveProps. Anything else
+// changed is left for the next start. An invalid file changes
+// nothing.
+func reloadConfig(s *sim.Simulation) {
+	v := viper.New()
+	if err := layerConfig(v, configFileLoaded); err != nil {
+		log.Errorf("Unable to reload config file: %v", err)
+		return
+	}
+	level, err := log.ParseLevel(v.GetString("Logging.Level"))
+	if err != nil {
+		log.Errorf("Unable to reload config file: %v", err)
+		return
+	}
+	config := defaultMoversConfig()
+	if err := unmarshalMovers(v, &config); err != nil {
+		log.Errorf("Unable to parse reloaded Movers configuration: %v", err)
+		return
+	}
+	if moverBundle != nil {
+		if err := moverBundle.apply(&config); err != nil {
+			log.Errorf("Unable to reload bundle: %v", err)
+			return
+		}
+	}
+	if err := config.Init(); err != nil {
+		log.Errorf("Unable to reload config file: %v", err)
+		return
+	}
+	if err := s.Reconfigure(config.Live()); err != nil {
+		log.Errorf("Unable to reconfigure: %v", err)
+		return
+	}
+	if level != log.GetLevel() {
+		log.SetLevel(level)
+		log.Infof("Log level set to %s", level)
+	}
+}
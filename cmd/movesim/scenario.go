@@ -0,0 +1,186 @@
+package main
+
+import (
+	// System
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Configuration
+	"github.com/spf13/viper"
+
+	// Simulation
+	"github.com/pramsey/movesim/sim"
+
+	// Feature flags
+	"github.com/pramsey/movesim/feature"
+)
+
+// A scenario file is a configuration file, in YAML, JSON or any
+// other format viper reads, describing a whole run: the fleet and
+// its behavior in Movers, the sinks in Output, and in Scenario how
+// long to run, the random seed, which sinks to write to and the
+// events to play. A config file given with --config is merged over
+// it, as over a bundle.
+
+// ScenarioProps is the Scenario section of a scenario file. A zero
+// Duration runs until interrupted, a zero Seed picks one at random,
+// and no Sinks writes to the Output.Sink.
+type ScenarioProps struct {
+	Name        string
+	Description string
+	Duration    time.Duration
+	Seed        int64
+	Sinks       []string
+	Events      []ScenarioEvent
+}
+
+// Scenario event actions
+const (
+	// Start Count movers, in Region if set
+	ActionAdd = "add"
+	// Stop the movers with the Ids
+	ActionRemove = "remove"
+	// Run at Factor times real time
+	ActionSpeed = "speed"
+	// Switch the named Feature flag to Enabled
+	ActionFeature = "feature"
+)
+
+// ScenarioEvent is something that happens At a time into the run.
+type ScenarioEvent struct {
+	At      time.Duration
+	Action  string
+	Count   int
+	Region  string
+	Ids     []int
+	Factor  float64
+	Feature string
+	Enabled bool
+}
+
+func (e ScenarioEvent) check() error {
+	switch e.Action {
+	case ActionAdd:
+		if e.Count <= 0 {
+			return fmt.Errorf("add event at %s has no count", e.At)
+		}
+		if e.Region != "" && moverConfig.RegionNamed(e.Region) == nil {
+			return fmt.Errorf("add event at %s names unknown region '%s'", e.At, e.Region)
+		}
+	case ActionRemove:
+		if len(e.Ids) == 0 {
+			return fmt.Errorf("remove event at %s has no ids", e.At)
+		}
+	case ActionSpeed:
+		if e.Factor <= 0 {
+			return fmt.Errorf("speed event at %s needs a positive factor", e.At)
+		}
+	case ActionFeature:
+		if e.Feature == "" {
+			return fmt.Errorf("feature event at %s names no feature", e.At)
+		}
+	default:
+		return fmt.Errorf("unknown scenario action '%s'", e.Action)
+	}
+	return nil
+}
+
+// runScenario runs the simulation a scenario file describes.
+func runScenario(args []string) {
+	flags, configFile := newFlagSet("movesim run")
+	dryRun := flags.Bool("dry-run", false, "move the fleet in memory only, without a database or other output")
+	duration := flags.Duration("duration", 0, "run for this long, overriding the scenario")
+	seed := flags.Int64("seed", 0, "random seed, overriding the scenario")
+	addRunFlags(flags)
+	addShardFlags(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		log.Fatal("Usage: movesim run [flags] scenario.yaml")
+	}
+	// The scenario is the base configuration
+	scenarioFile = flags.Arg(0)
+	initConfig(*configFile)
+
+	var scenario ScenarioProps
+	if err := viper.UnmarshalKey("Scenario", &scenario); err != nil {
+		log.Fatalf("Unable to parse Scenario: %v", err)
+	}
+	if flags.Changed("duration") {
+		scenario.Duration = *duration
+	}
+	if flags.Changed("seed") {
+		scenario.Seed = *seed
+	}
+	if scenario.Seed == 0 {
+		scenario.Seed = time.Now().UnixNano()
+	}
+	seedRandom(scenario.Seed)
+	if len(scenario.Sinks) == 0 {
+		scenario.Sinks = []string{outputProps.Sink}
+	}
+	for _, event := range scenario.Events {
+		if err := event.check(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	name := scenario.Name
+	if name == "" {
+		name = scenarioFile
+	}
+	log.Infof("Running scenario %s with seed %d", name, scenario.Seed)
+	if scenario.Description != "" {
+		log.Info(scenario.Description)
+	}
+	runFleet(*dryRun, scenario.Sinks, scenario.Duration, scenario.Events)
+}
+
+// playEvents carries out the scenario events at their times
+// into the run, until the context is done.
+func playEvents(ctx context.Context, s *sim.Simulation, events []ScenarioEvent) {
+	events = append([]ScenarioEvent(nil), events...)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At < events[j].At })
+	start := time.Now()
+	for _, event := range events {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(start.Add(event.At))):
+		}
+		if err := playEvent(s, event); err != nil {
+			log.Errorf("Scenario %s event at %s failed: %v", event.Action, event.At, err)
+		}
+	}
+}
+
+func playEvent(s *sim.Simulation, event ScenarioEvent) error {
+	log.Infof("Scenario %s event at %s", event.Action, event.At)
+	switch event.Action {
+	case ActionAdd:
+		world := s.World()
+		region := world.Props.RegionNamed(event.Region)
+		for i := 0; i < event.Count; i++ {
+			m, err := world.NewMoverIn(s.NewId(), region)
+			if err != nil {
+				return err
+			}
+			s.AddMover(m)
+		}
+	case ActionRemove:
+		for _, id := range event.Ids {
+			if !s.RemoveMover(id) {
+				log.Warnf("Scenario remove event found no mover %d", id)
+			}
+		}
+	case ActionSpeed:
+		return s.SetSpeed(event.Factor)
+	case ActionFeature:
+		return feature.Set(event.Feature, event.Enabled)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	// System
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	// PostgreSQL connection
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	// Configuration
+	"github.com/spf13/pflag"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Simulation
+	"github.com/pramsey/movesim/sim"
+	"github.com/pramsey/movesim/sink"
+	"github.com/pramsey/movesim/sink/postgis"
+)
+
+// How often the processes of a sharded run report their stats
+const shardReportInterval = 10 * time.Second
+
+// addShardFlags adds the options of commands that can run
+// one shard of a fleet split between several processes.
+func addShardFlags(flags *pflag.FlagSet) {
+	flags.Int("shard-index", 0, "index of this process among the shards of the fleet, from zero")
+	bindFlag("Movers.Shard.Index", flags.Lookup("shard-index"))
+	flags.Int("shard-count", 0, "number of processes the fleet is split between")
+	bindFlag("Movers.Shard.Count", flags.Lookup("shard-count"))
+}
+
+// shardStats is the latest stats of this shard, from the
+// stats of its database sink.
+func shardStats(stats *sink.RunStats, s *sim.Simulation) postgis.ShardStats {
+	host, _ := os.Hostname()
+	return postgis.ShardStats{
+		Run:        dbProps.Run,
+		Shard:      moverConfig.Shard.Index,
+		Shards:     moverConfig.Shard.Count,
+		Host:       host,
+		Movers:     s.Count(),
+		RunSummary: stats.Summary(),
+	}
+}
+
+// reportShard writes the stats of this shard to moving.shards
+// every little while, until the context is done.
+func reportShard(ctx context.Context, dbPool *pgxpool.Pool, stats *sink.RunStats, s *sim.Simulation) {
+	ticker := time.NewTicker(shardReportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := postgis.WriteShardStats(ctx, dbPool, shardStats(stats, s)); err != nil {
+				log.Errorf("Unable to report shard stats: %v", err)
+			}
+		}
+	}
+}
+
+// runStats reports the stats of the shards of a run, as each last
+// wrote them to the database, and what they add up to.
+func runStats(args []string) {
+	flags, configFile := newFlagSet("movesim stats")
+	flags.String("run-id", "", "id of the run to report on")
+	bindFlag("Database.Run", flags.Lookup("run-id"))
+	flags.Parse(args)
+
+	initConfig(*configFile)
+	if dbProps.Run == "" {
+		log.Fatal("Usage: movesim stats [flags] --run-id id")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	dbPool := connectDatabase(ctx, "", "")
+	defer dbPool.Close()
+	shards, err := postgis.ReadShardStats(ctx, dbPool, dbProps.Run)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(shards) == 0 {
+		log.Fatalf("No shard of run %s has reported", dbProps.Run)
+	}
+	for _, s := range shards {
+		printShard(fmt.Sprintf("shard %d", s.Shard), s)
+	}
+	total := postgis.CombineShards(shards)
+	printShard("total", total)
+	if len(shards) < total.Shards {
+		log.Warnf("Only %d of %d shards have reported", len(shards), total.Shards)
+	}
+}
+
+func printShard(label string, s postgis.ShardStats) {
+	fmt.Printf("%-10s %6d movers %10d positions %9.1f updates/sec %6d errors, latency mean %.2fms p95 %.2fms max %.2fms, updated %s\n",
+		label, s.Movers, s.Updates, s.UpdatesPerSec, s.Errors, s.LatencyMean, s.LatencyP95, s.LatencyMax,
+		s.Updated.Local().Format(time.RFC3339))
+}
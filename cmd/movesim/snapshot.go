@@ -0,0 +1,73 @@
+package main
+
+import (
+	// System
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Simulation
+	"github.com/pramsey/movesim/snapshot"
+)
+
+// SnapshotProps is where a snapshot signal (SIGUSR1) dumps the
+// fleet state: a file in Dir named for the time of the dump, in
+// Format, geojson or csv.
+type SnapshotProps struct {
+	Dir    string
+	Format string
+}
+
+var snapshotProps = SnapshotProps{
+	Dir:    ".",
+	Format: snapshot.FormatGeoJSON,
+}
+
+// watchSnapshots dumps the fleet to a file every time the process
+// gets a snapshot signal, until the context is done.
+func watchSnapshots(ctx context.Context, fleet snapshot.Source) {
+	if len(snapshotSignals) == 0 {
+		return
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, snapshotSignals...)
+	go func() {
+		defer signal.Stop(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+				path, n, err := writeSnapshot(fleet)
+				if err != nil {
+					log.Errorf("Unable to write snapshot: %v", err)
+					continue
+				}
+				log.Infof("Wrote snapshot of %d movers to %s", n, path)
+			}
+		}
+	}()
+}
+
+// writeSnapshot writes the fleet to a new file, returning
+// its name and the number of movers in it.
+func writeSnapshot(fleet snapshot.Source) (string, int, error) {
+	name := fmt.Sprintf("snapshot-%s.%s", time.Now().UTC().Format("20060102-150405.000"), snapshotProps.Format)
+	path := filepath.Join(snapshotProps.Dir, name)
+	file, err := os.Create(path)
+	if err != nil {
+		return "", 0, err
+	}
+	movers := fleet()
+	if err := snapshot.Write(file, snapshotProps.Format, movers); err != nil {
+		file.Close()
+		return "", 0, err
+	}
+	return path, len(movers), file.Close()
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import (
+	// System
+	"os"
+)
+
+// No user signals to ask for snapshots with, use /snapshot
+var snapshotSignals []os.Signal
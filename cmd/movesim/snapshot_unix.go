@@ -0,0 +1,11 @@
+//go:build unix
+
+package main
+
+import (
+	// System
+	"os"
+	"syscall"
+)
+
+var snapshotSignals = []os.Signal{syscall.SIGUSR1}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	// System
+	"context"
+	"time"
+
+	// PostgreSQL connection
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/sink/postgis"
+)
+
+// How often the styles of new movers are saved
+const styleSaveInterval = 10 * time.Second
+
+// loadStyles reads the styles movers had in earlier runs into the
+// simulation options, when they are to be kept.
+func loadStyles(ctx context.Context, dbPool *pgxpool.Pool) {
+	if !moverConfig.Style.Persist {
+		return
+	}
+	styles, err := postgis.ReadStyles(ctx, dbPool)
+	if err != nil {
+		log.Fatalf("Unable to read mover styles: %v", err)
+	}
+	moverConfig.Styles = mover.NewStyles(styles)
+	log.Infof("Loaded the styles of %d movers", len(styles))
+}
+
+// keepStyles saves the styles of new movers every little while,
+// until the context is done.
+func keepStyles(ctx context.Context, dbPool *pgxpool.Pool, styles *mover.Styles) {
+	ticker := time.NewTicker(styleSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			saveStyles(ctx, dbPool, styles)
+		}
+	}
+}
+
+// saveStyles saves the styles of the movers that are new
+// since they were last saved.
+func saveStyles(ctx context.Context, dbPool *pgxpool.Pool, styles *mover.Styles) {
+	if err := postgis.WriteStyles(ctx, dbPool, styles.Added()); err != nil {
+		log.Errorf("Unable to save mover styles: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	// System
+	"context"
+	"sort"
+	"time"
+
+	// PostgreSQL connection
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Simulation
+	"github.com/pramsey/movesim/sim"
+	"github.com/pramsey/movesim/sink"
+	"github.com/pramsey/movesim/sink/postgis"
+)
+
+// ExitSummary is what a run did, for the numbers of a load test:
+// the ticks its movers moved and how far, by class, and the
+// throughput, latency and errors of each of its outputs.
+type ExitSummary struct {
+	sim.Tally
+	Sinks []SinkStats `json:"sinks"`
+}
+
+// summarize collects the summary of a run from the simulation
+// and the statistics of its outputs.
+func summarize(s *sim.Simulation, outputs []sink.Sink, stats []*sink.RunStats) ExitSummary {
+	summary := ExitSummary{Tally: s.Tally()}
+	for i, out := range outputs {
+		summary.Sinks = append(summary.Sinks, SinkStats{Sink: out.Name(), RunSummary: stats[i].Summary()})
+	}
+	return summary
+}
+
+// logSummary logs the summary of a run as it ends.
+func logSummary(summary ExitSummary) {
+	log.Infof("Movers moved %d ticks in all", summary.Ticks)
+	classes := make([]string, 0, len(summary.Distance))
+	for class := range summary.Distance {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		if class == "" {
+			log.Infof("Movers moved %.0f meters", summary.Distance[class])
+		} else {
+			log.Infof("Movers of class '%s' moved %.0f meters", class, summary.Distance[class])
+		}
+	}
+	for _, stats := range summary.Sinks {
+		log.Infof("Wrote %d positions to %s in %d writes over %.1fs, %.1f updates/sec, %d errors",
+			stats.Updates, stats.Sink, stats.Writes, stats.Duration, stats.UpdatesPerSec, stats.Errors)
+		if stats.Writes > stats.Errors {
+			log.Infof("Write latency to %s mean %.2fms, p50 %.2fms, p95 %.2fms, p99 %.2fms, max %.2fms",
+				stats.Sink, stats.LatencyMean, stats.LatencyP50, stats.LatencyP95, stats.LatencyP99, stats.LatencyMax)
+		}
+		if stats.Dropped > 0 || stats.Coalesced > 0 {
+			log.Infof("Queue to %s dropped %d and coalesced %d updates, at most %d deep",
+				stats.Sink, stats.Dropped, stats.Coalesced, stats.QueueMax)
+		}
+	}
+}
+
+// recordSummary writes the summary of the run to moving.runs.
+// Shards share the record of their run, so rather than have each
+// overwrite the others, their stats are left to moving.shards.
+func recordSummary(dbPool *pgxpool.Pool, summary ExitSummary) {
+	if moverConfig.Shard.Sharded() {
+		log.Warn("Not recording the summary of a shard, see movesim stats")
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := postgis.WriteRunSummary(ctx, dbPool, dbProps.Run, summary); err != nil {
+		log.Warnf("Unable to record the summary of run %s: %v", dbProps.Run, err)
+	}
+}
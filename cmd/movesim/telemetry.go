@@ -0,0 +1,38 @@
+package main
+
+import (
+	// System
+	"context"
+	"time"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Simulation
+	"github.com/pramsey/movesim/telemetry"
+)
+
+var telemetryProps = telemetry.Props{
+	Insecure:    true,
+	ServiceName: "movesim",
+}
+
+// startTelemetry exports traces if they are enabled, returning
+// the function that flushes the last of them on the way out.
+func startTelemetry(ctx context.Context) func() {
+	if !telemetryProps.Enabled {
+		return func() {}
+	}
+	shutdown, err := telemetry.Start(ctx, telemetryProps)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Info("Exporting traces over OTLP")
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			log.Errorf("Unable to flush traces: %v", err)
+		}
+	}
+}
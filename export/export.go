@@ -0,0 +1,86 @@
+// Package export writes mover positions as tidy trajectory files,
+// one row per position with the columns id, ts, lon, lat, speed and
+// heading, as CSV or Parquet, to go straight into pandas, DuckDB
+// and the like. Timestamps are the device times, in UTC, and speeds
+// are in meters per second, see Speed.
+package export
+
+import (
+	// System
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+// Trajectory file formats
+const (
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+)
+
+// Columns are the columns of every trajectory file.
+var Columns = []string{"id", "ts", "lon", "lat", "speed", "heading"}
+
+// Check reports an error for formats it does not write.
+func Check(format string) error {
+	switch format {
+	case FormatCSV, FormatParquet:
+		return nil
+	default:
+		return fmt.Errorf("unknown export format '%s'", format)
+	}
+}
+
+// Speed is the speed of the mover over the ground in meters per
+// second, or zero if its SleepInterval is not known, see
+// mover.Mover.GroundSpeed.
+func Speed(m mover.Mover) float64 {
+	return m.GroundSpeed()
+}
+
+// Writer writes positions, in the order given, which for tidy
+// trajectories is by mover and then by time. Close finishes the
+// file, but leaves the underlying writer open.
+type Writer interface {
+	Write(m mover.Mover) error
+	Close() error
+}
+
+// NewWriter writes a trajectory file of the format to out.
+func NewWriter(out io.Writer, format string) (Writer, error) {
+	if err := Check(format); err != nil {
+		return nil, err
+	}
+	if format == FormatParquet {
+		return newParquetWriter(out), nil
+	}
+	w := &csvWriter{out: csv.NewWriter(out)}
+	w.out.Write(Columns)
+	return w, nil
+}
+
+type csvWriter struct {
+	out    *csv.Writer
+	record [6]string
+}
+
+func (w *csvWriter) Write(m mover.Mover) error {
+	r := w.record[:]
+	r[0] = strconv.Itoa(m.Id)
+	r[1] = m.DeviceTime(m.Ts).UTC().Format(time.RFC3339Nano)
+	r[2] = strconv.FormatFloat(m.X, 'f', -1, 64)
+	r[3] = strconv.FormatFloat(m.Y, 'f', -1, 64)
+	r[4] = strconv.FormatFloat(Speed(m), 'f', -1, 64)
+	r[5] = strconv.Itoa(m.Heading)
+	return w.out.Write(r)
+}
+
+func (w *csvWriter) Close() error {
+	w.out.Flush()
+	return w.out.Error()
+}
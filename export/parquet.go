@@ -0,0 +1,238 @@
+package export
+
+import (
+	// System
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+// Parquet files are written with no compression and plain
+// encoding, which every reader understands, in row groups of
+// parquetGroupRows rows and pages of at most parquetPageRows.
+const (
+	parquetMagic     = "PAR1"
+	parquetGroupRows = 1 << 20
+	parquetPageRows  = 1 << 16
+	parquetCreatedBy = "movesim"
+)
+
+// Parquet physical types, encodings and other enums
+const (
+	parquetInt32  = 1
+	parquetInt64  = 2
+	parquetDouble = 5
+
+	parquetRequired = 0
+	parquetPlain    = 0
+	parquetRle      = 3
+	parquetDataPage = 0
+
+	parquetTimestampMicros = 10
+)
+
+// parquetColumn is a column of the trajectory files, and
+// the values of the row group being gathered.
+type parquetColumn struct {
+	name string
+	kind int32
+	// Timestamps carry their logical type as well
+	timestamp bool
+	values    []byte
+}
+
+// columnMeta is what the footer needs about a column chunk.
+type columnMeta struct {
+	offset int64
+	size   int64
+}
+
+type rowGroup struct {
+	rows    int64
+	columns []columnMeta
+}
+
+// parquetWriter gathers a row group of rows at a time, column by
+// column, writing out each group as it fills up and the footer
+// describing them all on Close.
+type parquetWriter struct {
+	out     *bufio.Writer
+	offset  int64
+	columns []*parquetColumn
+	rows    int
+	groups  []rowGroup
+	err     error
+}
+
+func newParquetWriter(w io.Writer) *parquetWriter {
+	p := &parquetWriter{
+		out: bufio.NewWriter(w),
+		columns: []*parquetColumn{
+			{name: "id", kind: parquetInt32},
+			{name: "ts", kind: parquetInt64, timestamp: true},
+			{name: "lon", kind: parquetDouble},
+			{name: "lat", kind: parquetDouble},
+			{name: "speed", kind: parquetDouble},
+			{name: "heading", kind: parquetInt32},
+		},
+	}
+	p.write([]byte(parquetMagic))
+	return p
+}
+
+// write sends bytes out, keeping count of the offset into
+// the file, and keeping the first error for later.
+func (p *parquetWriter) write(b []byte) {
+	if p.err != nil {
+		return
+	}
+	n, err := p.out.Write(b)
+	p.offset += int64(n)
+	p.err = err
+}
+
+func (p *parquetWriter) Write(m mover.Mover) error {
+	c := p.columns
+	c[0].values = binary.LittleEndian.AppendUint32(c[0].values, uint32(int32(m.Id)))
+	c[1].values = binary.LittleEndian.AppendUint64(c[1].values, uint64(m.DeviceTime(m.Ts).UnixMicro()))
+	c[2].values = binary.LittleEndian.AppendUint64(c[2].values, math.Float64bits(m.X))
+	c[3].values = binary.LittleEndian.AppendUint64(c[3].values, math.Float64bits(m.Y))
+	c[4].values = binary.LittleEndian.AppendUint64(c[4].values, math.Float64bits(Speed(m)))
+	c[5].values = binary.LittleEndian.AppendUint32(c[5].values, uint32(int32(m.Heading)))
+	p.rows++
+	if p.rows >= parquetGroupRows {
+		p.flushGroup()
+	}
+	return p.err
+}
+
+// flushGroup writes out the rows gathered so far as a row
+// group, each column as a chunk of data pages.
+func (p *parquetWriter) flushGroup() {
+	if p.rows == 0 {
+		return
+	}
+	group := rowGroup{rows: int64(p.rows)}
+	for _, c := range p.columns {
+		width := len(c.values) / p.rows
+		meta := columnMeta{offset: p.offset}
+		for start := 0; start < p.rows; start += parquetPageRows {
+			end := start + parquetPageRows
+			if end > p.rows {
+				end = p.rows
+			}
+			data := c.values[start*width : end*width]
+			p.write(pageHeader(end-start, len(data)))
+			p.write(data)
+		}
+		meta.size = p.offset - meta.offset
+		group.columns = append(group.columns, meta)
+		c.values = c.values[:0]
+	}
+	p.groups = append(p.groups, group)
+	p.rows = 0
+}
+
+// pageHeader is the header of a data page of plain values.
+func pageHeader(values, size int) []byte {
+	var c compact
+	c.begin(0)
+	c.i32(1, parquetDataPage)
+	c.i32(2, int32(size))
+	c.i32(3, int32(size))
+	c.begin(5)
+	c.i32(1, int32(values))
+	c.i32(2, parquetPlain)
+	c.i32(3, parquetRle)
+	c.i32(4, parquetRle)
+	c.end()
+	c.end()
+	return c.buf
+}
+
+// footer is the file metadata: the schema, and where the
+// column chunks of every row group are.
+func (p *parquetWriter) footer() []byte {
+	var rows int64
+	for _, g := range p.groups {
+		rows += g.rows
+	}
+	var c compact
+	c.begin(0)
+	c.i32(1, 1)
+	c.list(2, thriftStruct, len(p.columns)+1)
+	c.element()
+	c.binary(4, "schema")
+	c.i32(5, int32(len(p.columns)))
+	c.end()
+	for _, col := range p.columns {
+		c.element()
+		c.i32(1, col.kind)
+		c.i32(3, parquetRequired)
+		c.binary(4, col.name)
+		if col.timestamp {
+			c.i32(6, parquetTimestampMicros)
+			// LogicalType TIMESTAMP, in microseconds since the epoch in UTC
+			c.begin(10)
+			c.begin(8)
+			c.boolean(1, true)
+			c.begin(2)
+			c.begin(2)
+			c.end()
+			c.end()
+			c.end()
+			c.end()
+		}
+		c.end()
+	}
+	c.i64(3, rows)
+	c.list(4, thriftStruct, len(p.groups))
+	for _, g := range p.groups {
+		c.element()
+		c.list(1, thriftStruct, len(g.columns))
+		var total int64
+		for i, meta := range g.columns {
+			col := p.columns[i]
+			total += meta.size
+			c.element()
+			c.i64(2, meta.offset)
+			c.begin(3)
+			c.i32(1, col.kind)
+			c.list(2, thriftI32, 2)
+			c.listI32(parquetPlain)
+			c.listI32(parquetRle)
+			c.list(3, thriftBinary, 1)
+			c.listBinary(col.name)
+			c.i32(4, 0)
+			c.i64(5, g.rows)
+			c.i64(6, meta.size)
+			c.i64(7, meta.size)
+			c.i64(9, meta.offset)
+			c.end()
+			c.end()
+		}
+		c.i64(2, total)
+		c.i64(3, g.rows)
+		c.end()
+	}
+	c.binary(6, parquetCreatedBy)
+	c.end()
+	return c.buf
+}
+
+// Close writes out the last row group and the footer.
+func (p *parquetWriter) Close() error {
+	p.flushGroup()
+	footer := p.footer()
+	p.write(footer)
+	p.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer))))
+	p.write([]byte(parquetMagic))
+	if p.err != nil {
+		return p.err
+	}
+	return p.out.Flush()
+}
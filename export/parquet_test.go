@@ -0,0 +1,310 @@
+package export
+
+import (
+	// System
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+// thriftReader decodes Thrift compact protocol structs into maps
+// of field id to value, straight from the specification rather
+// than from the encoder, so that the two can be checked against
+// each other.
+type thriftReader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+type thriftStructValue map[int16]interface{}
+
+func (r *thriftReader) fail(format string, args ...interface{}) {
+	if r.err == nil {
+		r.err = fmt.Errorf(format, args...)
+	}
+	r.pos = len(r.buf)
+}
+
+func (r *thriftReader) byte() byte {
+	if r.pos >= len(r.buf) {
+		r.fail("read past the end at %d", r.pos)
+		return 0
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		r.fail("bad varint at %d", r.pos)
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) bytes(n int) []byte {
+	if n < 0 || r.pos+n > len(r.buf) {
+		r.fail("read of %d bytes past the end at %d", n, r.pos)
+		return nil
+	}
+	b := r.buf[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *thriftReader) value(kind byte, element bool) interface{} {
+	switch kind {
+	case 1, 2:
+		if element {
+			return r.byte() == 1
+		}
+		return kind == 1
+	case 3:
+		return int64(int8(r.byte()))
+	case 4, 5, 6:
+		return r.zigzag()
+	case 7:
+		return math.Float64frombits(binary.LittleEndian.Uint64(r.bytes(8)))
+	case 8:
+		return string(r.bytes(int(r.uvarint())))
+	case 9, 10:
+		header := r.byte()
+		size, elem := int(header>>4), header&0x0f
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		list := make([]interface{}, 0, size)
+		for i := 0; i < size && r.err == nil; i++ {
+			list = append(list, r.value(elem, true))
+		}
+		return list
+	case 12:
+		return r.structValue()
+	}
+	r.fail("unknown type %d at %d", kind, r.pos)
+	return nil
+}
+
+func (r *thriftReader) structValue() thriftStructValue {
+	s := make(thriftStructValue)
+	var last int16
+	for r.err == nil {
+		header := r.byte()
+		if header == 0 {
+			return s
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		s[id] = r.value(header&0x0f, false)
+		last = id
+	}
+	return s
+}
+
+func (s thriftStructValue) int(id int16) int64 {
+	v, _ := s[id].(int64)
+	return v
+}
+
+func (s thriftStructValue) sub(id int16) thriftStructValue {
+	v, _ := s[id].(thriftStructValue)
+	return v
+}
+
+func (s thriftStructValue) list(id int16) []interface{} {
+	v, _ := s[id].([]interface{})
+	return v
+}
+
+// readParquet decodes a file of plain encoded, uncompressed and
+// required columns, giving the schema and the values of every
+// column, by name.
+func readParquet(t *testing.T, file []byte) ([]thriftStructValue, map[string][]interface{}) {
+	t.Helper()
+	if len(file) < 12 || string(file[:4]) != "PAR1" || string(file[len(file)-4:]) != "PAR1" {
+		t.Fatal("file does not start and end with PAR1")
+	}
+	size := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footer := &thriftReader{buf: file[len(file)-8-size : len(file)-8]}
+	meta := footer.structValue()
+	if footer.err != nil || footer.pos != size {
+		t.Fatalf("bad footer: %v, %d of %d bytes read", footer.err, footer.pos, size)
+	}
+	var schema []thriftStructValue
+	for _, e := range meta.list(2) {
+		schema = append(schema, e.(thriftStructValue))
+	}
+	if len(schema) == 0 || schema[0].int(5) != int64(len(schema)-1) {
+		t.Fatalf("schema root does not have %d children", len(schema)-1)
+	}
+
+	values := make(map[string][]interface{})
+	var rows int64
+	for _, g := range meta.list(4) {
+		group := g.(thriftStructValue)
+		groupRows := group.int(3)
+		rows += groupRows
+		for i, c := range group.list(1) {
+			chunk := c.(thriftStructValue)
+			column := chunk.sub(3)
+			name := column.list(3)[0].(string)
+			if name != schema[i+1][4] {
+				t.Fatalf("column chunk %d is %s, not %s", i, name, schema[i+1][4])
+			}
+			if column.int(4) != 0 {
+				t.Fatalf("column %s is compressed", name)
+			}
+			if column.int(5) != groupRows {
+				t.Fatalf("column %s has %d values in a group of %d rows", name, column.int(5), groupRows)
+			}
+			page := &thriftReader{buf: file, pos: int(column.int(9))}
+			start := page.pos
+			for read := int64(0); read < groupRows; {
+				header := page.structValue()
+				data := page.bytes(int(header.int(3)))
+				if page.err != nil {
+					t.Fatalf("column %s: %v", name, page.err)
+				}
+				if header.int(1) != 0 || header.sub(5).int(2) != 0 {
+					t.Fatalf("column %s has a page that is not plain data", name)
+				}
+				n := header.sub(5).int(1)
+				if n <= 0 || len(data)%int(n) != 0 {
+					t.Fatalf("column %s has a page of %d bytes for %d values", name, len(data), n)
+				}
+				width := len(data) / int(n)
+				for v := 0; v < int(n); v++ {
+					b := data[v*width : (v+1)*width]
+					switch schema[i+1].int(1) {
+					case 1:
+						values[name] = append(values[name], int64(int32(binary.LittleEndian.Uint32(b))))
+					case 2:
+						values[name] = append(values[name], int64(binary.LittleEndian.Uint64(b)))
+					case 5:
+						values[name] = append(values[name], math.Float64frombits(binary.LittleEndian.Uint64(b)))
+					default:
+						t.Fatalf("column %s has unexpected type %d", name, schema[i+1].int(1))
+					}
+				}
+				read += n
+			}
+			if int64(page.pos-start) != column.int(7) {
+				t.Fatalf("column %s is %d bytes, not %d", name, page.pos-start, column.int(7))
+			}
+		}
+	}
+	if rows != meta.int(3) {
+		t.Fatalf("row groups hold %d rows, not %d", rows, meta.int(3))
+	}
+	return schema[1:], values
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	// Enough rows for more than one page
+	rows := parquetPageRows + 100
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var movers []mover.Mover
+	for i := 0; i < rows; i++ {
+		movers = append(movers, mover.Mover{
+			Id:            i % 7,
+			Ts:            start.Add(time.Duration(i) * time.Millisecond),
+			X:             -123.1 + float64(i)*1e-6,
+			Y:             49.2 - float64(i)*1e-6,
+			Velocity:      0.0001 * float64(i%5),
+			Heading:       i % 360,
+			SleepInterval: 2 * time.Second,
+		})
+	}
+	var file bytes.Buffer
+	w, err := NewWriter(&file, FormatParquet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range movers {
+		if err := w.Write(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	schema, values := readParquet(t, file.Bytes())
+	types := []int64{parquetInt32, parquetInt64, parquetDouble, parquetDouble, parquetDouble, parquetInt32}
+	for i, column := range schema {
+		if column[4] != Columns[i] || column.int(1) != types[i] {
+			t.Errorf("column %d is %v of type %d, want %s of type %d", i, column[4], column.int(1), Columns[i], types[i])
+		}
+	}
+	if ts := schema[1]; ts.int(6) != parquetTimestampMicros || ts.sub(10).sub(8)[1] != true {
+		t.Errorf("ts column is not a UTC timestamp in microseconds: %v", ts)
+	}
+	for i, m := range movers {
+		want := map[string]interface{}{
+			"id":      int64(m.Id),
+			"ts":      m.Ts.UnixMicro(),
+			"lon":     m.X,
+			"lat":     m.Y,
+			"speed":   Speed(m),
+			"heading": int64(m.Heading),
+		}
+		for name, v := range want {
+			if len(values[name]) != rows {
+				t.Fatalf("column %s has %d values, want %d", name, len(values[name]), rows)
+			}
+			if values[name][i] != v {
+				t.Fatalf("row %d column %s is %v, want %v", i, name, values[name][i], v)
+			}
+		}
+	}
+}
+
+func TestSpeed(t *testing.T) {
+	m := mover.Mover{Velocity: 0.001, SleepInterval: 2 * time.Second}
+	if got, want := Speed(m), 0.001*111320.0/2; math.Abs(got-want) > 1e-9 {
+		t.Errorf("Speed() = %f, want %f m/s", got, want)
+	}
+	m.SleepInterval = 0
+	if got := Speed(m); got != 0 {
+		t.Errorf("Speed() with no interval = %f, want 0", got)
+	}
+
+	// Heading east, a degree of longitude is half as long at 60N
+	// as on the equator
+	equator := mover.Mover{Velocity: 0.001, Heading: 270, SleepInterval: time.Second}
+	north := equator
+	north.Y = 60
+	if got, want := Speed(north), Speed(equator)/2; math.Abs(got-want) > 1e-9 {
+		t.Errorf("Speed() heading east at 60N = %f, want %f m/s", got, want)
+	}
+	if got, want := Speed(equator), 0.001*111320.0; math.Abs(got-want) > 1e-9 {
+		t.Errorf("Speed() heading east on the equator = %f, want %f m/s", got, want)
+	}
+	// Against the haversine distance of one step, to within the
+	// difference of the earth models
+	north.Heading = 225
+	x, y := mover.Project(north.X, north.Y, north.Heading, north.Velocity)
+	if got, want := Speed(north), geo.Distance(north.X, north.Y, x, y); math.Abs(got-want) > want*0.005 {
+		t.Errorf("Speed() heading southeast at 60N = %f, want %f m/s", got, want)
+	}
+}
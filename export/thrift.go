@@ -0,0 +1,110 @@
+package export
+
+import (
+	// System
+	"encoding/binary"
+)
+
+// Thrift compact protocol types, as used in field and list headers
+const (
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// compact encodes Thrift structs in the compact protocol, which
+// the Parquet page headers and file footer are written in. Fields
+// must be written in increasing order of id within each struct.
+type compact struct {
+	buf []byte
+	// Last field id of each struct being written, innermost last
+	last []int16
+}
+
+func (c *compact) varint(v uint64) {
+	c.buf = binary.AppendUvarint(c.buf, v)
+}
+
+func (c *compact) zigzag(v int64) {
+	c.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (c *compact) field(id int16, kind byte) {
+	last := &c.last[len(c.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		c.buf = append(c.buf, byte(delta)<<4|kind)
+	} else {
+		c.buf = append(c.buf, kind)
+		c.zigzag(int64(id))
+	}
+	*last = id
+}
+
+func (c *compact) i32(id int16, v int32) {
+	c.field(id, thriftI32)
+	c.zigzag(int64(v))
+}
+
+func (c *compact) i64(id int16, v int64) {
+	c.field(id, thriftI64)
+	c.zigzag(v)
+}
+
+func (c *compact) boolean(id int16, v bool) {
+	if v {
+		c.field(id, thriftTrue)
+	} else {
+		c.field(id, thriftFalse)
+	}
+}
+
+func (c *compact) binary(id int16, v string) {
+	c.field(id, thriftBinary)
+	c.varint(uint64(len(v)))
+	c.buf = append(c.buf, v...)
+}
+
+// begin starts a struct, as the field of the given id of the
+// enclosing struct, or the top level struct for an id of zero.
+func (c *compact) begin(id int16) {
+	if id != 0 {
+		c.field(id, thriftStruct)
+	}
+	c.last = append(c.last, 0)
+}
+
+// element starts a struct as the next element of a list.
+func (c *compact) element() {
+	c.last = append(c.last, 0)
+}
+
+func (c *compact) end() {
+	c.buf = append(c.buf, 0)
+	c.last = c.last[:len(c.last)-1]
+}
+
+// list starts a list of n elements of the kind, which
+// must follow, as the field of the given id.
+func (c *compact) list(id int16, kind byte, n int) {
+	c.field(id, thriftList)
+	if n < 15 {
+		c.buf = append(c.buf, byte(n)<<4|kind)
+	} else {
+		c.buf = append(c.buf, 0xf0|kind)
+		c.varint(uint64(n))
+	}
+}
+
+// listI32 and listBinary write the elements of a list.
+func (c *compact) listI32(v int32) {
+	c.zigzag(int64(v))
+}
+
+func (c *compact) listBinary(v string) {
+	c.varint(uint64(len(v)))
+	c.buf = append(c.buf, v...)
+}
@@ -0,0 +1,178 @@
+// Package feature keeps runtime switches for the experimental parts
+// of the simulator, so they can be tried out on a running instance
+// and switched off again at once, without a restart. Packages
+// register the flags they check; programs set them from their
+// configuration, and Handler lets them be toggled over HTTP.
+package feature
+
+import (
+	// System
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+)
+
+// Flag is one switch. Checking it is cheap enough to do every tick.
+type Flag struct {
+	name        string
+	description string
+	initial     bool
+	enabled     atomic.Bool
+}
+
+// State is a flag as reported to clients.
+type State struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+	Default     bool   `json:"default"`
+}
+
+var registry = struct {
+	sync.RWMutex
+	flags map[string]*Flag
+}{flags: make(map[string]*Flag)}
+
+// Register adds a flag, enabled or not by default. Names are lower
+// case, with underscores rather than dots so they can be used as
+// configuration keys. Registering a name twice returns the first flag.
+func Register(name, description string, enabled bool) *Flag {
+	registry.Lock()
+	defer registry.Unlock()
+	if f, ok := registry.flags[name]; ok {
+		return f
+	}
+	f := &Flag{name: name, description: description, initial: enabled}
+	f.enabled.Store(enabled)
+	registry.flags[name] = f
+	return f
+}
+
+func (f *Flag) Enabled() bool {
+	return f.enabled.Load()
+}
+
+func (f *Flag) state() State {
+	return State{
+		Name:        f.name,
+		Description: f.description,
+		Enabled:     f.Enabled(),
+		Default:     f.initial,
+	}
+}
+
+func lookup(name string) (*Flag, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+	f, ok := registry.flags[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown feature '%s'", name)
+	}
+	return f, nil
+}
+
+// Set switches the named flag on or off.
+func Set(name string, enabled bool) error {
+	f, err := lookup(name)
+	if err != nil {
+		return err
+	}
+	if f.enabled.Swap(enabled) != enabled {
+		if enabled {
+			log.Infof("Feature %s enabled", f.name)
+		} else {
+			log.Infof("Feature %s disabled", f.name)
+		}
+	}
+	return nil
+}
+
+// Reset puts the named flag back to its default.
+func Reset(name string) error {
+	f, err := lookup(name)
+	if err != nil {
+		return err
+	}
+	return Set(f.name, f.initial)
+}
+
+// All lists every flag, by name.
+func All() []State {
+	registry.RLock()
+	defer registry.RUnlock()
+	states := make([]State, 0, len(registry.flags))
+	for _, f := range registry.flags {
+		states = append(states, f.state())
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
+
+// Handler serves the flags as JSON. GET lists them all, or one by
+// name, PUT or POST to a name with ?enabled=true or a JSON body
+// {"enabled": true} sets it, and DELETE resets it to its default.
+func Handler() http.Handler {
+	return http.HandlerFunc(serveHTTP)
+}
+
+func serveHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(r.URL.Path, "/")
+	if name == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, All())
+		return
+	}
+	f, err := lookup(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var body struct {
+			Enabled *bool `json:"enabled"`
+		}
+		if value := r.URL.Query().Get("enabled"); value != "" {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			body.Enabled = &enabled
+		} else if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if body.Enabled == nil {
+			http.Error(w, "missing enabled value", http.StatusBadRequest)
+			return
+		}
+		Set(f.name, *body.Enabled)
+	case http.MethodDelete:
+		Reset(f.name)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, f.state())
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
@@ -0,0 +1,86 @@
+package feature
+
+import (
+	// System
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFlags(t *testing.T) {
+	f := Register("test_flags", "a flag for the tests", false)
+	if again := Register("test_flags", "another", true); again != f {
+		t.Error("Register() of a name twice made a second flag")
+	}
+	if f.Enabled() {
+		t.Error("flag enabled, want its default off")
+	}
+	if err := Set("TEST_FLAGS", true); err != nil || !f.Enabled() {
+		t.Errorf("Set() = %v, enabled %t, want it on", err, f.Enabled())
+	}
+	if err := Reset("test_flags"); err != nil || f.Enabled() {
+		t.Errorf("Reset() = %v, enabled %t, want it back off", err, f.Enabled())
+	}
+	if err := Set("no_such_flag", true); err == nil {
+		t.Error("Set() of an unknown flag, want an error")
+	}
+	found := false
+	for _, state := range All() {
+		if state.Name == "test_flags" {
+			found = state.Description == "a flag for the tests" && !state.Enabled && !state.Default
+		}
+	}
+	if !found {
+		t.Errorf("All() = %v, want the flag listed as registered", All())
+	}
+}
+
+func TestHandler(t *testing.T) {
+	f := Register("test_handler", "a flag for the handler", false)
+	tests := []struct {
+		method  string
+		target  string
+		body    string
+		status  int
+		enabled bool
+	}{
+		{http.MethodGet, "/test_handler", "", http.StatusOK, false},
+		{http.MethodPut, "/test_handler?enabled=true", "", http.StatusOK, true},
+		{http.MethodDelete, "/test_handler", "", http.StatusOK, false},
+		{http.MethodPost, "/test_handler", `{"enabled": true}`, http.StatusOK, true},
+		{http.MethodPost, "/test_handler", `{}`, http.StatusBadRequest, true},
+		{http.MethodPut, "/test_handler?enabled=maybe", "", http.StatusBadRequest, true},
+		{http.MethodPatch, "/test_handler", "", http.StatusMethodNotAllowed, true},
+		{http.MethodGet, "/no_such_flag", "", http.StatusNotFound, true},
+		{http.MethodPost, "/", "", http.StatusMethodNotAllowed, true},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		Handler().ServeHTTP(w, httptest.NewRequest(test.method, test.target, strings.NewReader(test.body)))
+		if w.Code != test.status {
+			t.Errorf("%s %s: status %d, want %d", test.method, test.target, w.Code, test.status)
+		}
+		if f.Enabled() != test.enabled {
+			t.Errorf("%s %s: enabled %t, want %t", test.method, test.target, f.Enabled(), test.enabled)
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+		var state State
+		if err := json.Unmarshal(w.Body.Bytes(), &state); err != nil {
+			t.Fatal(err)
+		}
+		if state.Name != "test_handler" || state.Enabled != test.enabled {
+			t.Errorf("%s %s: reported %+v, want enabled %t", test.method, test.target, state, test.enabled)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	var states []State
+	if err := json.Unmarshal(w.Body.Bytes(), &states); err != nil || len(states) == 0 {
+		t.Errorf("listing is %s, %v, want every flag", w.Body.String(), err)
+	}
+}
@@ -0,0 +1,41 @@
+package geo
+
+import (
+	// System
+	"math"
+)
+
+// Distances are set and reported in meters, while positions are in
+// degrees, whose length in meters depends on where they are.
+
+// Meters per degree of latitude, near enough
+const MetersPerDegree = 111320.0
+
+// Mean radius of the earth in meters, and the length of a degree
+// along a great circle
+const (
+	earthRadius  = 6371008.8
+	degreeMeters = earthRadius * math.Pi / 180
+)
+
+// Distance is the great circle distance between two positions,
+// in meters.
+func Distance(x1, y1, x2, y2 float64) float64 {
+	lat1 := y1 * math.Pi / 180
+	lat2 := y2 * math.Pi / 180
+	sinLat := math.Sin((lat2 - lat1) / 2)
+	sinLon := math.Sin((x2 - x1) * math.Pi / 360)
+	h := sinLat*sinLat + math.Cos(lat1)*math.Cos(lat2)*sinLon*sinLon
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// DegreesAround is a radius in degrees that takes in every position
+// within the distance in meters of a position at the latitude, as
+// degrees of longitude shrink toward the poles.
+func DegreesAround(meters, lat float64) float64 {
+	radius := meters / degreeMeters
+	// Measured at the edge nearest the pole, where they are shortest
+	poleward := math.Min(math.Abs(lat)+radius, 90)
+	scale := math.Max(math.Cos(poleward*math.Pi/180), 0.01)
+	return radius / scale
+}
@@ -0,0 +1,54 @@
+package geo
+
+import (
+	// System
+	"math"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name           string
+		x1, y1, x2, y2 float64
+		want           float64
+		within         float64
+	}{
+		{"same place", -123, 49, -123, 49, 0, 1e-9},
+		{"degree of latitude", 0, 0, 0, 1, 111195, 1},
+		{"degree of longitude at the equator", 0, 0, 1, 0, 111195, 1},
+		{"degree of longitude at 60 north", 10, 60, 11, 60, 55597, 1},
+		{"across the antimeridian", 179.5, 0, -179.5, 0, 111195, 1},
+		{"pole to pole", 0, 90, 0, -90, math.Pi * earthRadius, 1},
+		{"Vancouver to Seattle", -123.1207, 49.2827, -122.3321, 47.6062, 195700, 500},
+	}
+	for _, test := range tests {
+		got := Distance(test.x1, test.y1, test.x2, test.y2)
+		if math.Abs(got-test.want) > test.within {
+			t.Errorf("%s: Distance = %.1f, want %.1f", test.name, got, test.want)
+		}
+		if back := Distance(test.x2, test.y2, test.x1, test.y1); math.Abs(back-got) > 1e-6 {
+			t.Errorf("%s: Distance is %.1f one way and %.1f the other", test.name, got, back)
+		}
+	}
+}
+
+func TestDegreesAround(t *testing.T) {
+	tests := []struct {
+		meters, lat float64
+	}{
+		{100, 0},
+		{100, 49},
+		{5000, 70},
+		{10, -85},
+	}
+	for _, test := range tests {
+		radius := DegreesAround(test.meters, test.lat)
+		// Due east and due north at that radius are at least as far
+		if d := Distance(0, test.lat, radius, test.lat); d < test.meters {
+			t.Errorf("DegreesAround(%g, %g) = %g reaches only %.2f meters east", test.meters, test.lat, radius, d)
+		}
+		if d := Distance(0, test.lat, 0, test.lat+radius); d < test.meters {
+			t.Errorf("DegreesAround(%g, %g) = %g reaches only %.2f meters north", test.meters, test.lat, radius, d)
+		}
+	}
+}
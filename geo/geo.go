@@ -0,0 +1,399 @@
+// Package geo holds the planar geometry the simulator works in:
+// points and rectangles in longitude/latitude, polygon layers read
+// from GeoJSON, and the encodings used to write points out.
+package geo
+
+import (
+	// System
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Point struct {
+	X float64
+	Y float64
+}
+
+type Rectangle struct {
+	MinX float64
+	MinY float64
+	MaxX float64
+	MaxY float64
+}
+
+// Polygon is a shell followed by any holes, each ring
+// a closed list of points.
+type Polygon [][]Point
+
+// Feature is the part of a GeoJSON feature the simulator uses:
+// its properties and any polygons or points in its geometry.
+type Feature struct {
+	Properties map[string]interface{}
+	Polygons   []Polygon
+	Points     []Point
+}
+
+type geojsonPoint struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+type geojsonObject struct {
+	Type        string                 `json:"type"`
+	Properties  map[string]interface{} `json:"properties"`
+	Geometry    *geojsonObject         `json:"geometry"`
+	Features    []geojsonObject        `json:"features"`
+	Geometries  []geojsonObject        `json:"geometries"`
+	Coordinates json.RawMessage        `json:"coordinates"`
+}
+
+// ReadGeoJSONFile reads the features of a GeoJSON file, which may
+// hold a FeatureCollection, a single Feature or a bare geometry.
+func ReadGeoJSONFile(filename string) ([]Feature, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGeoJSON(data)
+}
+
+func ParseGeoJSON(data []byte) ([]Feature, error) {
+	var obj geojsonObject
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	switch obj.Type {
+	case "FeatureCollection":
+		features := make([]Feature, 0, len(obj.Features))
+		for _, f := range obj.Features {
+			feature, err := f.feature()
+			if err != nil {
+				return nil, err
+			}
+			features = append(features, feature)
+		}
+		return features, nil
+	case "Feature":
+		feature, err := obj.feature()
+		return []Feature{feature}, err
+	default:
+		feature := Feature{}
+		err := obj.addGeometry(&feature)
+		return []Feature{feature}, err
+	}
+}
+
+func (obj geojsonObject) feature() (Feature, error) {
+	feature := Feature{Properties: obj.Properties}
+	if obj.Geometry == nil {
+		return feature, nil
+	}
+	err := obj.Geometry.addGeometry(&feature)
+	return feature, err
+}
+
+func (obj geojsonObject) addGeometry(feature *Feature) error {
+	switch obj.Type {
+	case "Point":
+		var c []float64
+		if err := json.Unmarshal(obj.Coordinates, &c); err != nil {
+			return err
+		}
+		p, err := coordPoint(c)
+		if err != nil {
+			return err
+		}
+		feature.Points = append(feature.Points, p)
+	case "MultiPoint":
+		var cs [][]float64
+		if err := json.Unmarshal(obj.Coordinates, &cs); err != nil {
+			return err
+		}
+		for _, c := range cs {
+			p, err := coordPoint(c)
+			if err != nil {
+				return err
+			}
+			feature.Points = append(feature.Points, p)
+		}
+	case "Polygon":
+		var rings [][][]float64
+		if err := json.Unmarshal(obj.Coordinates, &rings); err != nil {
+			return err
+		}
+		poly, err := coordPolygon(rings)
+		if err != nil {
+			return err
+		}
+		feature.Polygons = append(feature.Polygons, poly)
+	case "MultiPolygon":
+		var polys [][][][]float64
+		if err := json.Unmarshal(obj.Coordinates, &polys); err != nil {
+			return err
+		}
+		for _, rings := range polys {
+			poly, err := coordPolygon(rings)
+			if err != nil {
+				return err
+			}
+			feature.Polygons = append(feature.Polygons, poly)
+		}
+	case "GeometryCollection":
+		for _, g := range obj.Geometries {
+			if err := g.addGeometry(feature); err != nil {
+				return err
+			}
+		}
+	case "LineString", "MultiLineString":
+		// Not used by anything yet
+	default:
+		return fmt.Errorf("unsupported GeoJSON type '%s'", obj.Type)
+	}
+	return nil
+}
+
+func coordPoint(c []float64) (Point, error) {
+	if len(c) < 2 {
+		return Point{}, fmt.Errorf("invalid GeoJSON coordinate %v", c)
+	}
+	return Point{X: c[0], Y: c[1]}, nil
+}
+
+func coordPolygon(rings [][][]float64) (Polygon, error) {
+	poly := make(Polygon, 0, len(rings))
+	for _, ring := range rings {
+		points := make([]Point, 0, len(ring))
+		for _, c := range ring {
+			p, err := coordPoint(c)
+			if err != nil {
+				return nil, err
+			}
+			points = append(points, p)
+		}
+		poly = append(poly, points)
+	}
+	return poly, nil
+}
+
+type edge struct {
+	A, B Point
+	MaxX float64
+}
+
+// PolygonIndex answers point-in-polygon questions against a set of
+// polygons. The edges are sorted into horizontal bands, so a test
+// only casts its ray against the edges in its own band, making it
+// cheap enough to run for every mover on every tick.
+type PolygonIndex struct {
+	extent     Rectangle
+	bandHeight float64
+	bands      [][]edge
+}
+
+// Target number of edges per band, before edges spanning
+// several bands are counted more than once.
+const edgesPerBand = 16
+
+func NewPolygonIndex(polygons []Polygon) *PolygonIndex {
+	idx := &PolygonIndex{
+		extent: Rectangle{
+			MinX: math.Inf(1), MinY: math.Inf(1),
+			MaxX: math.Inf(-1), MaxY: math.Inf(-1),
+		},
+	}
+	var edges []edge
+	for _, poly := range polygons {
+		for _, ring := range poly {
+			for i := 0; i+1 < len(ring); i++ {
+				a, b := ring[i], ring[i+1]
+				if a.Y == b.Y {
+					// Horizontal edges never cross a horizontal ray
+					continue
+				}
+				edges = append(edges, edge{A: a, B: b, MaxX: math.Max(a.X, b.X)})
+			}
+			for _, p := range ring {
+				idx.extent.MinX = math.Min(idx.extent.MinX, p.X)
+				idx.extent.MinY = math.Min(idx.extent.MinY, p.Y)
+				idx.extent.MaxX = math.Max(idx.extent.MaxX, p.X)
+				idx.extent.MaxY = math.Max(idx.extent.MaxY, p.Y)
+			}
+		}
+	}
+	if len(edges) == 0 {
+		return idx
+	}
+
+	numBands := len(edges)/edgesPerBand + 1
+	idx.bandHeight = (idx.extent.MaxY - idx.extent.MinY) / float64(numBands)
+	if idx.bandHeight <= 0 {
+		idx.bandHeight = 1
+	}
+	idx.bands = make([][]edge, numBands)
+	for _, e := range edges {
+		first := idx.band(math.Min(e.A.Y, e.B.Y))
+		last := idx.band(math.Max(e.A.Y, e.B.Y))
+		for b := first; b <= last; b++ {
+			idx.bands[b] = append(idx.bands[b], e)
+		}
+	}
+	// Sorted by MaxX, so a test can skip straight past the
+	// edges that lie entirely to the left of its point
+	for _, band := range idx.bands {
+		sort.Slice(band, func(i, j int) bool { return band[i].MaxX < band[j].MaxX })
+	}
+	return idx
+}
+
+// Extent is the bounding rectangle of the indexed polygons.
+func (idx *PolygonIndex) Extent() Rectangle {
+	return idx.extent
+}
+
+func (idx *PolygonIndex) band(y float64) int {
+	b := int((y - idx.extent.MinY) / idx.bandHeight)
+	if b < 0 {
+		b = 0
+	}
+	if b >= len(idx.bands) {
+		b = len(idx.bands) - 1
+	}
+	return b
+}
+
+// Contains reports whether the point falls inside the polygons,
+// using the even-odd rule across all rings, so holes are respected
+// (and areas where polygons overlap count as outside).
+func (idx *PolygonIndex) Contains(x, y float64) bool {
+	if len(idx.bands) == 0 ||
+		x < idx.extent.MinX || x > idx.extent.MaxX ||
+		y < idx.extent.MinY || y > idx.extent.MaxY {
+		return false
+	}
+	inside := false
+	band := idx.bands[idx.band(y)]
+	first := sort.Search(len(band), func(i int) bool { return band[i].MaxX > x })
+	for _, e := range band[first:] {
+		// Cast the ray to the right, half-open in y so that
+		// a vertex shared by two edges is only counted once
+		if (e.A.Y > y) == (e.B.Y > y) {
+			continue
+		}
+		xCross := e.A.X + (y-e.A.Y)*(e.B.X-e.A.X)/(e.B.Y-e.A.Y)
+		if xCross > x {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Geometry encodings for sinks
+const (
+	EncodingGeoJSON = "geojson"
+	EncodingWKT     = "wkt"
+	EncodingWKB     = "wkb"
+	EncodingEWKB    = "ewkb"
+)
+
+// All geometries are longitude/latitude
+const srid = 4326
+
+// The point functions taking coordinates take x and y, with an
+// optional z making a PointZ.
+
+// PointWKT formats a point as well-known text.
+func PointWKT(x, y float64) string {
+	return CoordinatesWKT([]float64{x, y})
+}
+
+func CoordinatesWKT(c []float64) string {
+	var sb strings.Builder
+	sb.WriteString("POINT")
+	if len(c) > 2 {
+		sb.WriteString(" Z ")
+	}
+	sb.WriteByte('(')
+	for i, v := range c {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	sb.WriteByte(')')
+	return sb.String()
+}
+
+// PointWKB encodes a point as little-endian well-known binary,
+// or as PostGIS extended WKB carrying the SRID when ewkb is set.
+func PointWKB(x, y float64, ewkb bool) []byte {
+	return CoordinatesWKB([]float64{x, y}, ewkb)
+}
+
+// CoordinatesWKB flags a z coordinate the ISO way in WKB,
+// and the PostGIS way in extended WKB.
+func CoordinatesWKB(c []float64, ewkb bool) []byte {
+	const wkbPoint = 1
+	const isoZOffset = 1000
+	const ewkbZFlag = 0x80000000
+	const ewkbSridFlag = 0x20000000
+	hasZ := len(c) > 2
+	buf := make([]byte, 0, 9+8*len(c))
+	buf = append(buf, 1)
+	if ewkb {
+		geomType := uint32(wkbPoint | ewkbSridFlag)
+		if hasZ {
+			geomType |= ewkbZFlag
+		}
+		buf = binary.LittleEndian.AppendUint32(buf, geomType)
+		buf = binary.LittleEndian.AppendUint32(buf, srid)
+	} else {
+		geomType := uint32(wkbPoint)
+		if hasZ {
+			geomType += isoZOffset
+		}
+		buf = binary.LittleEndian.AppendUint32(buf, geomType)
+	}
+	for _, v := range c {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+	}
+	return buf
+}
+
+// CoordinatesGPKG encodes a point as a GeoPackage geometry blob:
+// the little-endian header with the SRID and no envelope, then WKB.
+func CoordinatesGPKG(c []float64) []byte {
+	const flagLittleEndian = 1
+	buf := make([]byte, 0, 17+8*len(c))
+	buf = append(buf, 'G', 'P', 0, flagLittleEndian)
+	buf = binary.LittleEndian.AppendUint32(buf, srid)
+	return append(buf, CoordinatesWKB(c, false)...)
+}
+
+// EncodePoint renders a point in one of the text encodings, with
+// binary encodings as upper case hex, the way PostGIS prints them.
+func EncodePoint(x, y float64, encoding string) (string, error) {
+	return EncodeCoordinates([]float64{x, y}, encoding)
+}
+
+func EncodeCoordinates(c []float64, encoding string) (string, error) {
+	switch encoding {
+	case EncodingGeoJSON:
+		b, err := json.Marshal(geojsonPoint{Type: "Point", Coordinates: c})
+		return string(b), err
+	case EncodingWKT:
+		return CoordinatesWKT(c), nil
+	case EncodingWKB:
+		return strings.ToUpper(hex.EncodeToString(CoordinatesWKB(c, false))), nil
+	case EncodingEWKB:
+		return strings.ToUpper(hex.EncodeToString(CoordinatesWKB(c, true))), nil
+	default:
+		return "", fmt.Errorf("unknown geometry encoding '%s'", encoding)
+	}
+}
@@ -0,0 +1,120 @@
+package geo
+
+import (
+	// System
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Grid is a raster of values over the cells of a regular grid,
+// such as population counts, row by row from the top, with NaN
+// where there is no data.
+type Grid struct {
+	Cols   int
+	Rows   int
+	MinX   float64
+	MinY   float64
+	CellX  float64
+	CellY  float64
+	Values []float64
+}
+
+// ReadAsciiGrid reads an ESRI ASCII grid file, the simple raster
+// format that GDAL writes as AAIGrid.
+func ReadAsciiGrid(filename string) (*Grid, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	grid, err := ParseAsciiGrid(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return grid, nil
+}
+
+// ParseAsciiGrid reads an ESRI ASCII grid: a header of ncols,
+// nrows, xllcorner or xllcenter, yllcorner or yllcenter, cellsize
+// (or dx and dy) and an optional nodata_value, then the values.
+func ParseAsciiGrid(r io.Reader) (*Grid, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	scanner.Split(bufio.ScanWords)
+
+	header := make(map[string]float64)
+	var first string
+	for scanner.Scan() {
+		key := strings.ToLower(scanner.Text())
+		if _, err := strconv.ParseFloat(key, 64); err == nil {
+			// The values have started
+			first = key
+			break
+		}
+		if !scanner.Scan() {
+			return nil, fmt.Errorf("missing value of %s", key)
+		}
+		value, err := strconv.ParseFloat(scanner.Text(), 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad value of %s: %v", key, err)
+		}
+		header[key] = value
+	}
+
+	g := &Grid{Cols: int(header["ncols"]), Rows: int(header["nrows"])}
+	if g.Cols <= 0 || g.Rows <= 0 {
+		return nil, fmt.Errorf("grid needs ncols and nrows")
+	}
+	g.CellX, g.CellY = header["cellsize"], header["cellsize"]
+	if dx, ok := header["dx"]; ok {
+		g.CellX, g.CellY = dx, header["dy"]
+	}
+	if g.CellX <= 0 || g.CellY <= 0 {
+		return nil, fmt.Errorf("grid needs a cellsize")
+	}
+	g.MinX, g.MinY = header["xllcorner"], header["yllcorner"]
+	if x, ok := header["xllcenter"]; ok {
+		g.MinX = x - g.CellX/2
+	}
+	if y, ok := header["yllcenter"]; ok {
+		g.MinY = y - g.CellY/2
+	}
+	nodata, hasNodata := header["nodata_value"]
+
+	g.Values = make([]float64, 0, g.Cols*g.Rows)
+	word := first
+	for word != "" {
+		v, err := strconv.ParseFloat(word, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad grid value '%s'", word)
+		}
+		if hasNodata && v == nodata {
+			v = math.NaN()
+		}
+		g.Values = append(g.Values, v)
+		word = ""
+		if scanner.Scan() {
+			word = scanner.Text()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(g.Values) != g.Cols*g.Rows {
+		return nil, fmt.Errorf("grid has %d values, not %d by %d", len(g.Values), g.Cols, g.Rows)
+	}
+	return g, nil
+}
+
+// Cell is the rectangle of the cell in the column and row,
+// counting rows from the top.
+func (g *Grid) Cell(col, row int) Rectangle {
+	minX := g.MinX + float64(col)*g.CellX
+	maxY := g.MinY + float64(g.Rows-row)*g.CellY
+	return Rectangle{MinX: minX, MinY: maxY - g.CellY, MaxX: minX + g.CellX, MaxY: maxY}
+}
@@ -0,0 +1,132 @@
+package geo
+
+import (
+	// System
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseAsciiGrid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  Grid
+	}{
+		{
+			name: "corner",
+			input: `ncols 3
+nrows 2
+xllcorner 10
+yllcorner 20
+cellsize 0.5
+1 2 3
+4 5 6
+`,
+			want: Grid{Cols: 3, Rows: 2, MinX: 10, MinY: 20, CellX: 0.5, CellY: 0.5,
+				Values: []float64{1, 2, 3, 4, 5, 6}},
+		},
+		{
+			name: "center",
+			input: `NCOLS 2
+NROWS 1
+XLLCENTER 10.5
+YLLCENTER 20.5
+CELLSIZE 1
+7 8`,
+			want: Grid{Cols: 2, Rows: 1, MinX: 10, MinY: 20, CellX: 1, CellY: 1,
+				Values: []float64{7, 8}},
+		},
+		{
+			name: "dx and dy",
+			input: `ncols 1
+nrows 2
+xllcorner -1
+yllcorner -2
+dx 0.25
+dy 0.5
+1.5
+-2.5`,
+			want: Grid{Cols: 1, Rows: 2, MinX: -1, MinY: -2, CellX: 0.25, CellY: 0.5,
+				Values: []float64{1.5, -2.5}},
+		},
+		{
+			name: "nodata",
+			input: `ncols 2
+nrows 2
+xllcorner 0
+yllcorner 0
+cellsize 1
+nodata_value -9999
+1 -9999
+-9999 4`,
+			want: Grid{Cols: 2, Rows: 2, CellX: 1, CellY: 1,
+				Values: []float64{1, math.NaN(), math.NaN(), 4}},
+		},
+	}
+	for _, test := range tests {
+		g, err := ParseAsciiGrid(strings.NewReader(test.input))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if g.Cols != test.want.Cols || g.Rows != test.want.Rows ||
+			g.MinX != test.want.MinX || g.MinY != test.want.MinY ||
+			g.CellX != test.want.CellX || g.CellY != test.want.CellY {
+			t.Errorf("%s: got %d by %d at (%g, %g) by (%g, %g), want %d by %d at (%g, %g) by (%g, %g)",
+				test.name, g.Cols, g.Rows, g.MinX, g.MinY, g.CellX, g.CellY,
+				test.want.Cols, test.want.Rows, test.want.MinX, test.want.MinY, test.want.CellX, test.want.CellY)
+		}
+		if len(g.Values) != len(test.want.Values) {
+			t.Errorf("%s: got %d values, want %d", test.name, len(g.Values), len(test.want.Values))
+			continue
+		}
+		for i, v := range g.Values {
+			want := test.want.Values[i]
+			if v != want && !(math.IsNaN(v) && math.IsNaN(want)) {
+				t.Errorf("%s: value %d is %g, want %g", test.name, i, v, want)
+			}
+		}
+	}
+}
+
+func TestParseAsciiGridErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{"empty", "", "ncols and nrows"},
+		{"no rows", "ncols 2 cellsize 1 1 2", "ncols and nrows"},
+		{"no cellsize", "ncols 1 nrows 1 xllcorner 0 yllcorner 0 1", "cellsize"},
+		{"missing value", "ncols 1 nrows", "missing value of nrows"},
+		{"bad header value", "ncols one nrows 1", "bad value of ncols"},
+		{"bad grid value", "ncols 2 nrows 1 cellsize 1 1 x", "bad grid value 'x'"},
+		{"too few values", "ncols 2 nrows 2 cellsize 1 1 2 3", "has 3 values, not 2 by 2"},
+		{"too many values", "ncols 1 nrows 1 cellsize 1 1 2", "has 2 values, not 1 by 1"},
+	}
+	for _, test := range tests {
+		_, err := ParseAsciiGrid(strings.NewReader(test.input))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got error %v, want one about %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestGridCell(t *testing.T) {
+	g := Grid{Cols: 3, Rows: 2, MinX: 10, MinY: 20, CellX: 0.5, CellY: 0.25}
+	tests := []struct {
+		col, row int
+		want     Rectangle
+	}{
+		{0, 0, Rectangle{MinX: 10, MinY: 20.25, MaxX: 10.5, MaxY: 20.5}},
+		{2, 0, Rectangle{MinX: 11, MinY: 20.25, MaxX: 11.5, MaxY: 20.5}},
+		{0, 1, Rectangle{MinX: 10, MinY: 20, MaxX: 10.5, MaxY: 20.25}},
+		{2, 1, Rectangle{MinX: 11, MinY: 20, MaxX: 11.5, MaxY: 20.25}},
+	}
+	for _, test := range tests {
+		if got := g.Cell(test.col, test.row); got != test.want {
+			t.Errorf("Cell(%d, %d) = %+v, want %+v", test.col, test.row, got, test.want)
+		}
+	}
+}
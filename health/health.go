@@ -0,0 +1,180 @@
+// Package health reports whether a running simulator is alive and
+// ready for work, for orchestrators such as Kubernetes running it
+// as a long-lived workload: over HTTP for liveness and readiness
+// probes, and as the standard gRPC health service.
+package health
+
+import (
+	// System
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	// PostgreSQL connection
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	// gRPC
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	// Movers
+	"github.com/pramsey/movesim/sink"
+)
+
+// How long a database ping may take before the
+// database counts as unreachable
+const pingTimeout = 2 * time.Second
+
+// Report is the state of the simulator as the probes see it.
+// Database is "ok", "none" without a database, or the error
+// reaching it.
+type Report struct {
+	Status    string     `json:"status"`
+	Database  string     `json:"database"`
+	Movers    int        `json:"movers"`
+	LastWrite *time.Time `json:"last_write"`
+	Uptime    float64    `json:"uptime_s"`
+	Problems  []string   `json:"problems,omitempty"`
+}
+
+// Checker looks over a running simulation: the database pool, if
+// any, the stats of its sinks and the number of running movers.
+type Checker struct {
+	dbPool      *pgxpool.Pool
+	stats       []*sink.RunStats
+	movers      func() int
+	maxWriteAge time.Duration
+	started     time.Time
+}
+
+// NewChecker looks over the simulation from now on. It is ready
+// once the database, if any, answers and, with a maxWriteAge, the
+// sinks have written within that long, allowing that long from
+// now for the first write.
+func NewChecker(dbPool *pgxpool.Pool, stats []*sink.RunStats, movers func() int, maxWriteAge time.Duration) *Checker {
+	return &Checker{
+		dbPool:      dbPool,
+		stats:       stats,
+		movers:      movers,
+		maxWriteAge: maxWriteAge,
+		started:     time.Now(),
+	}
+}
+
+// lastWrite is the latest successful write of any sink.
+func (c *Checker) lastWrite() time.Time {
+	var last time.Time
+	for _, stats := range c.stats {
+		if ts := stats.LastWrite(); ts.After(last) {
+			last = ts
+		}
+	}
+	return last
+}
+
+// Live reports on the simulator without checking anything
+// beyond the process, which is alive as long as it answers.
+func (c *Checker) Live() Report {
+	r := Report{
+		Status:   "ok",
+		Database: "none",
+		Uptime:   time.Since(c.started).Seconds(),
+	}
+	if c.movers != nil {
+		r.Movers = c.movers()
+	}
+	if last := c.lastWrite(); !last.IsZero() {
+		r.LastWrite = &last
+	}
+	return r
+}
+
+// Ready checks the database and the writes, reporting
+// whether the simulator is ready.
+func (c *Checker) Ready(ctx context.Context) (Report, bool) {
+	r := c.Live()
+	if c.dbPool != nil {
+		ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+		defer cancel()
+		if err := c.dbPool.Ping(ctx); err != nil {
+			r.Database = err.Error()
+			r.Problems = append(r.Problems, "database unreachable")
+		} else {
+			r.Database = "ok"
+		}
+	}
+	if c.maxWriteAge > 0 {
+		since := c.started
+		if r.LastWrite != nil {
+			since = *r.LastWrite
+		}
+		if age := time.Since(since); age > c.maxWriteAge {
+			r.Problems = append(r.Problems, fmt.Sprintf("no write for %s", age.Round(time.Millisecond)))
+		}
+	}
+	ready := len(r.Problems) == 0
+	if ready {
+		r.Status = "ready"
+	} else {
+		r.Status = "not ready"
+	}
+	return r, ready
+}
+
+// LiveHandler serves the liveness probe, /healthz.
+func (c *Checker) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, c.Live(), http.StatusOK)
+	})
+}
+
+// ReadyHandler serves the readiness probe, /readyz, answering
+// 503 Service Unavailable when the simulator is not ready.
+func (c *Checker) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report, ready := c.Ready(r.Context())
+		status := http.StatusOK
+		if !ready {
+			status = http.StatusServiceUnavailable
+		}
+		writeReport(w, report, status)
+	})
+}
+
+func writeReport(w http.ResponseWriter, report Report, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+}
+
+// Serve keeps the status of a gRPC health server up to date with
+// the readiness of the simulator, for the server as a whole and for
+// each of the named services, checking every interval until the
+// context is done, when it reports them no longer serving.
+func (c *Checker) Serve(ctx context.Context, server *grpchealth.Server, interval time.Duration, services ...string) {
+	update := func(status healthpb.HealthCheckResponse_ServingStatus) {
+		server.SetServingStatus("", status)
+		for _, service := range services {
+			server.SetServingStatus(service, status)
+		}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		status := healthpb.HealthCheckResponse_NOT_SERVING
+		if _, ready := c.Ready(ctx); ready {
+			status = healthpb.HealthCheckResponse_SERVING
+		}
+		update(status)
+		select {
+		case <-ctx.Done():
+			server.Shutdown()
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package health
+
+import (
+	// System
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	// gRPC
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	// Movers
+	"github.com/pramsey/movesim/sink"
+)
+
+func TestReady(t *testing.T) {
+	stats := sink.NewRunStats()
+	c := NewChecker(nil, []*sink.RunStats{stats}, func() int { return 5 }, time.Minute)
+	report, ready := c.Ready(context.Background())
+	if !ready || report.Status != "ready" || report.Database != "none" || report.Movers != 5 || report.LastWrite != nil {
+		t.Errorf("Ready() = %+v, %t, want ready with 5 movers and no write yet", report, ready)
+	}
+
+	// Past the grace for the first write
+	c.started = time.Now().Add(-2 * time.Minute)
+	if report, ready := c.Ready(context.Background()); ready || len(report.Problems) != 1 {
+		t.Errorf("Ready() = %+v, %t, want not ready for want of a write", report, ready)
+	}
+	stats.RecordWrite(1, time.Millisecond, nil)
+	if report, ready := c.Ready(context.Background()); !ready || report.LastWrite == nil {
+		t.Errorf("Ready() = %+v, %t, want ready after a write", report, ready)
+	}
+
+	// Without a write age, writes are not checked
+	c = NewChecker(nil, nil, nil, 0)
+	c.started = time.Now().Add(-time.Hour)
+	if report, ready := c.Ready(context.Background()); !ready {
+		t.Errorf("Ready() = %+v, %t, want ready", report, ready)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	c := NewChecker(nil, nil, nil, time.Minute)
+	c.started = time.Now().Add(-2 * time.Minute)
+	tests := []struct {
+		name    string
+		handler http.Handler
+		status  int
+		want    string
+	}{
+		{"live", c.LiveHandler(), http.StatusOK, "ok"},
+		{"ready", c.ReadyHandler(), http.StatusServiceUnavailable, "not ready"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		test.handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		var report Report
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if w.Code != test.status || report.Status != test.want {
+			t.Errorf("%s: status %d, %s, want %d, %s", test.name, w.Code, report.Status, test.status, test.want)
+		}
+		if w.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s: content type %s, want JSON", test.name, w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	stats := sink.NewRunStats()
+	c := NewChecker(nil, []*sink.RunStats{stats}, nil, time.Minute)
+	c.started = time.Now().Add(-2 * time.Minute)
+	server := grpchealth.NewServer()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Serve(ctx, server, 10*time.Millisecond, "movesim.Movesim")
+		close(done)
+	}()
+
+	waitFor := func(service string, want healthpb.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			response, err := server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+			if err == nil && response.Status == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("service '%s' is %v, %v, want %v", service, response, err, want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	waitFor("", healthpb.HealthCheckResponse_NOT_SERVING)
+	stats.RecordWrite(1, time.Millisecond, nil)
+	waitFor("", healthpb.HealthCheckResponse_SERVING)
+	waitFor("movesim.Movesim", healthpb.HealthCheckResponse_SERVING)
+	cancel()
+	<-done
+	waitFor("movesim.Movesim", healthpb.HealthCheckResponse_NOT_SERVING)
+}
@@ -0,0 +1,132 @@
+package mover
+
+import (
+	// System
+	"math"
+	"time"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+)
+
+// State is the last known state of a mover, as an earlier run left
+// it, for a new mover of the same id to carry on from, see Adopt.
+// Heading and Velocity are nil where they were not recorded, and
+// are then worked out from the Previous position, at PreviousTs,
+// if there is one. Class, Color and Props are empty where they
+// were not recorded.
+type State struct {
+	Id         int
+	X          float64
+	Y          float64
+	Z          float64
+	Ts         time.Time
+	Heading    *int
+	Velocity   *float64
+	Previous   *geo.Point
+	PreviousTs time.Time
+	Class      string
+	Color      string
+	Props      map[string]interface{}
+}
+
+// Adopt carries on from the state, so a restarted simulation picks
+// up where the last one left off: the mover takes over its class,
+// if one of that name is still configured, its color, its payload
+// fields, with the values of its class attributes and its firmware,
+// its position, its altitude if it has one, and its heading and
+// velocity, as far as they are known, keeping its own where they
+// are not. Its region and convoy are those of this run, and its
+// name follows from its id, see StyleProps.
+func (m *Mover) Adopt(w *World, s State) {
+	if class := w.Props.ClassNamed(s.Class); class != nil && class != m.Class {
+		m.adoptClass(w, class)
+	}
+	if s.Color != "" {
+		m.Color = s.Color
+	}
+	m.adoptFields(s.Props)
+	m.X, m.Y = s.X, s.Y
+	if m.HasZ {
+		m.Z = s.Z
+	}
+	if heading, velocity, ok := s.motion(m.SleepInterval); ok {
+		m.Heading = heading
+		m.Velocity = velocity
+	}
+	if s.Heading != nil {
+		m.Heading = NormalizeHeading(*s.Heading)
+	}
+	if s.Velocity != nil {
+		m.Velocity = *s.Velocity
+	}
+	m.Velocity = m.physics(w).clampSpeed(m.Velocity)
+	m.recolor(w.Props)
+	m.Zone, _ = w.Zones.Zone(m.X, m.Y)
+}
+
+// adoptClass makes the mover one of the class, in place of the one
+// its id gave it, with the attributes and altitude of the class.
+func (m *Mover) adoptClass(w *World, class *Class) {
+	if m.Class != nil && len(m.Class.Attributes) > 0 {
+		fields := m.copyFields(0)
+		for _, a := range m.Class.Attributes {
+			delete(fields, a.Name)
+		}
+		m.Fields = fields
+		m.attributes = nil
+	}
+	m.Class = class
+	m.SleepInterval = w.sleepInterval(class)
+	m.HasZ, m.Z, m.Climb = false, 0, 0
+	m.startAltitude(w.Props)
+	m.startAttributes()
+}
+
+// adoptFields takes over the payload fields, but for those of the
+// region and convoy of this run: the values of the class attributes,
+// at the precision they were written with, and the firmware, which
+// rollouts then do not install again, see ApplyRollouts.
+func (m *Mover) adoptFields(props map[string]interface{}) {
+	if len(props) == 0 {
+		return
+	}
+	fields := m.copyFields(len(props))
+	for k, v := range props {
+		if k == "region" || k == "convoy" {
+			continue
+		}
+		if m.Region != nil {
+			if _, ok := m.Region.Fields[k]; ok {
+				continue
+			}
+		}
+		fields[k] = v
+	}
+	m.Fields = fields
+	if m.Class != nil {
+		for _, a := range m.Class.Attributes {
+			if v, ok := props[a.Name].(float64); ok {
+				m.attributes[a.Name] = v
+			}
+		}
+	}
+	if firmware, ok := props["firmware"].(string); ok {
+		m.Firmware = firmware
+	}
+}
+
+// motion works out the heading and velocity, in degrees per tick
+// of the interval, of the last step from the previous position,
+// unless there is none, or it wrapped around the world.
+func (s State) motion(interval time.Duration) (int, float64, bool) {
+	if s.Previous == nil || !s.PreviousTs.Before(s.Ts) || interval <= 0 {
+		return 0, 0, false
+	}
+	dx, dy := s.X-s.Previous.X, s.Y-s.Previous.Y
+	if math.Abs(dx) > 180 {
+		return 0, 0, false
+	}
+	ticks := float64(s.Ts.Sub(s.PreviousTs)) / float64(interval)
+	return VectorHeading(dx, dy), math.Hypot(dx, dy) / ticks, true
+}
@@ -0,0 +1,96 @@
+package mover
+
+import (
+	// System
+	"testing"
+	"time"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+)
+
+func TestAdoptState(t *testing.T) {
+	props := DefaultProps()
+	props.Classes = []*Class{
+		{Name: "car", Weight: 1, SleepInterval: time.Second},
+		{Name: "truck", Weight: 0, SleepInterval: 5 * time.Second, Attributes: []Attribute{
+			{Name: "fuel", Rule: RuleDrain, Min: 0, Max: 100, Rate: -1},
+		}},
+	}
+	props.Regions = []*Region{{
+		Name:           "east",
+		Count:          1,
+		StartRectangle: geo.Rectangle{MinX: -124, MinY: 48, MaxX: -123, MaxY: 49},
+		Fields:         map[string]interface{}{"city": "Victoria"},
+	}}
+	if err := props.Init(); err != nil {
+		t.Fatal(err)
+	}
+	w := NewWorld(&props, nil)
+	m, err := w.NewMoverIn(7, props.Regions[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Class.Name != "car" {
+		t.Fatalf("mover is a %s, want a car to start with", m.Class.Name)
+	}
+	m.Adopt(w, State{
+		Id:    7,
+		X:     -123.4,
+		Y:     48.4,
+		Class: "truck",
+		Color: "#123456",
+		Props: map[string]interface{}{
+			"fuel":     42.0,
+			"firmware": "v2",
+			"owner":    "fleet",
+			"region":   "west",
+			"city":     "Vancouver",
+			"convoy":   3.0,
+		},
+	})
+	if m.Class.Name != "truck" || m.SleepInterval != 5*time.Second {
+		t.Errorf("mover is a %s every %s, want a truck every 5s", m.Class.Name, m.SleepInterval)
+	}
+	if m.Color != "#123456" {
+		t.Errorf("mover color is %s, want the adopted one", m.Color)
+	}
+	if m.X != -123.4 || m.Y != 48.4 {
+		t.Errorf("mover is at (%g, %g), want the adopted position", m.X, m.Y)
+	}
+	if m.Firmware != "v2" {
+		t.Errorf("mover firmware is '%s', want v2", m.Firmware)
+	}
+	want := map[string]interface{}{"fuel": 42.0, "firmware": "v2", "owner": "fleet", "region": "east", "city": "Victoria"}
+	for k, v := range want {
+		if m.Fields[k] != v {
+			t.Errorf("field %s is %v, want %v", k, m.Fields[k], v)
+		}
+	}
+	if _, ok := m.Fields["convoy"]; ok {
+		t.Errorf("mover kept the convoy of the earlier run")
+	}
+	m.evolveAttributes()
+	if fuel := m.Fields["fuel"]; fuel != 41.0 {
+		t.Errorf("fuel is %v after a tick, want it to carry on from 42", fuel)
+	}
+
+	// An unknown class keeps the one of the id
+	other, err := w.NewMover(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Adopt(w, State{Id: 8, Class: "bus"})
+	if other.Class.Name != "car" {
+		t.Errorf("mover is a %s, want the car of its id", other.Class.Name)
+	}
+}
+
+func TestRolloutAdoptedFirmware(t *testing.T) {
+	m := Mover{Firmware: "v2", RolloutRank: 0.9, SleepInterval: time.Second}
+	rollouts := []RolloutProps{{Firmware: "v2", Fraction: 0.5, SleepInterval: 10 * time.Second, Offline: time.Hour}}
+	m.ApplyRollouts(rollouts, time.Minute)
+	if m.SleepInterval != 10*time.Second || !m.OfflineUntil.IsZero() {
+		t.Errorf("mover reports every %s, offline until %s, want every 10s and online", m.SleepInterval, m.OfflineUntil)
+	}
+}
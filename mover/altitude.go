@@ -0,0 +1,89 @@
+package mover
+
+import (
+	// System
+	"fmt"
+	"math"
+)
+
+// AltitudeProps gives movers a third dimension, for drones and
+// aircraft, in meters. Movers start at a random altitude between
+// Floor and Ceiling and climb or descend at a rate that drifts by
+// MaxClimbChange a tick (one standard deviation), up to MaxClimb
+// meters a tick either way, turning back at the floor and the
+// ceiling. A zero Ceiling keeps movers on the ground.
+type AltitudeProps struct {
+	Floor          float64
+	Ceiling        float64
+	MaxClimb       float64
+	MaxClimbChange float64
+}
+
+func (a AltitudeProps) enabled() bool {
+	return a.Ceiling != 0
+}
+
+func (a AltitudeProps) init() error {
+	if !a.enabled() {
+		return nil
+	}
+	if a.Floor >= a.Ceiling {
+		return fmt.Errorf("altitude floor %g is not below the ceiling %g", a.Floor, a.Ceiling)
+	}
+	if a.MaxClimb < 0 || a.MaxClimbChange < 0 {
+		return fmt.Errorf("altitude climb rates must not be negative")
+	}
+	return nil
+}
+
+// HasAltitude reports whether any movers, of the fleet or of
+// one of the classes, have altitudes.
+func (p *Props) HasAltitude() bool {
+	if p.Altitude.enabled() {
+		return true
+	}
+	for _, class := range p.Classes {
+		if class.Altitude.enabled() {
+			return true
+		}
+	}
+	return false
+}
+
+// altitude is the altitude settings of the mover class,
+// or of the fleet.
+func (m *Mover) altitude(props *Props) AltitudeProps {
+	if m.Class != nil && m.Class.Altitude.enabled() {
+		return m.Class.Altitude
+	}
+	return props.Altitude
+}
+
+// startAltitude puts the mover at a random altitude, if it has one.
+func (m *Mover) startAltitude(props *Props) {
+	alt := m.altitude(props)
+	if !alt.enabled() {
+		return
+	}
+	m.HasZ = true
+	m.Z = alt.Floor + m.rng.Float64()*(alt.Ceiling-alt.Floor)
+}
+
+// climb drifts the climb rate and moves the mover up or down by
+// it, turning back at the floor and the ceiling.
+func (m *Mover) climb(w *World) {
+	if !m.HasZ {
+		return
+	}
+	alt := m.altitude(w.Props)
+	m.Climb += m.rng.NormFloat64() * alt.MaxClimbChange
+	m.Climb = clamp(m.Climb, -alt.MaxClimb, alt.MaxClimb)
+	m.Z += m.Climb * w.Pace()
+	if m.Z < alt.Floor || m.Z > alt.Ceiling {
+		m.Z = reflectRange(m.Z, alt.Floor, alt.Ceiling)
+		m.Climb = -m.Climb
+	}
+	if math.IsNaN(m.Z) {
+		m.Z = alt.Floor
+	}
+}
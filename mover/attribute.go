@@ -0,0 +1,129 @@
+package mover
+
+import (
+	// System
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Attribute rules
+const (
+	// Change by Rate every tick, starting over from the
+	// other end of the range when the value runs out
+	RuleDrain = "drain"
+	// Take a random step, with standard deviation Rate, every tick
+	RuleWalk = "walk"
+	// Keep the starting value
+	RuleConstant = "constant"
+)
+
+// Attribute is a telemetry value that the movers of a class carry
+// in their payload, such as a fuel level, a temperature or a battery
+// percentage. Each mover starts at a random value between Min and
+// Max, which then evolves every tick by the Rule, kept between Min
+// and Max. The payload reports it rounded to Decimals places, while
+// the mover keeps it at full precision, so that changes of less than
+// the rounding add up.
+type Attribute struct {
+	Name     string
+	Rule     string
+	Min      float64
+	Max      float64
+	Rate     float64
+	Decimals int
+}
+
+func (a *Attribute) init() error {
+	if a.Name == "" {
+		return fmt.Errorf("attribute has no name")
+	}
+	switch a.Rule {
+	case RuleDrain, RuleWalk, RuleConstant:
+	default:
+		return fmt.Errorf("attribute '%s' has unknown rule '%s'", a.Name, a.Rule)
+	}
+	if a.Min > a.Max {
+		return fmt.Errorf("attribute '%s' has Min above Max", a.Name)
+	}
+	return nil
+}
+
+func (a *Attribute) round(value float64) float64 {
+	scale := math.Pow(10, float64(a.Decimals))
+	return math.Round(value*scale) / scale
+}
+
+// start is a random starting value.
+func (a *Attribute) start(rng *rand.Rand) float64 {
+	return a.Min + rng.Float64()*(a.Max-a.Min)
+}
+
+// next is the value a tick after the given one.
+func (a *Attribute) next(rng *rand.Rand, value float64) float64 {
+	switch a.Rule {
+	case RuleDrain:
+		value += a.Rate
+		if value < a.Min {
+			value = a.Max
+		} else if value > a.Max {
+			value = a.Min
+		}
+	case RuleWalk:
+		value += rng.NormFloat64() * a.Rate
+		value = math.Max(a.Min, math.Min(a.Max, value))
+	}
+	return value
+}
+
+// startAttributes gives the mover the starting values of the
+// attributes of its class.
+func (m *Mover) startAttributes() {
+	if m.Class == nil || len(m.Class.Attributes) == 0 {
+		return
+	}
+	fields := m.copyFields(len(m.Class.Attributes))
+	values := make(map[string]float64, len(m.Class.Attributes))
+	for i := range m.Class.Attributes {
+		a := &m.Class.Attributes[i]
+		values[a.Name] = a.start(m.rng)
+		fields[a.Name] = a.round(values[a.Name])
+	}
+	m.Fields = fields
+	m.attributes = values
+}
+
+// evolveAttributes moves the attributes of the mover on a tick.
+func (m *Mover) evolveAttributes() {
+	if m.Class == nil || len(m.Class.Attributes) == 0 {
+		return
+	}
+	fields := m.copyFields(0)
+	values := make(map[string]float64, len(m.Class.Attributes))
+	for i := range m.Class.Attributes {
+		a := &m.Class.Attributes[i]
+		value, ok := m.attributes[a.Name]
+		if !ok {
+			// Carried on from the payload, as of an adopted mover
+			value, ok = fields[a.Name].(float64)
+		}
+		if !ok {
+			value = a.start(m.rng)
+		}
+		values[a.Name] = a.next(m.rng, value)
+		fields[a.Name] = a.round(values[a.Name])
+	}
+	m.Fields = fields
+	m.attributes = values
+}
+
+// copyFields is a copy of the payload fields with room for more.
+// Sinks hold copies of the mover, so a fields map that has been
+// written must never change.
+func (m *Mover) copyFields(extra int) map[string]interface{} {
+	fields := make(map[string]interface{}, len(m.Fields)+extra)
+	for k, v := range m.Fields {
+		fields[k] = v
+	}
+	return fields
+}
@@ -0,0 +1,54 @@
+package mover
+
+import (
+	// System
+	"math"
+	"testing"
+)
+
+func TestAttributeDrain(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     float64
+		decimals int
+		ticks    int
+		want     float64
+	}{
+		{"smaller than the rounding", -0.05, 1, 10, 49.5},
+		{"smaller than the rounding, up", 0.004, 2, 100, 50.4},
+		{"whole steps", -1, 0, 3, 47},
+		{"refills when empty", -10, 0, 6, 100},
+	}
+	for _, test := range tests {
+		class := &Class{Name: "truck", Attributes: []Attribute{
+			{Name: "fuel", Rule: RuleDrain, Min: 0, Max: 100, Rate: test.rate, Decimals: test.decimals},
+		}}
+		m := Mover{Class: class, Fields: map[string]interface{}{"fuel": 50.0}}
+		for i := 0; i < test.ticks; i++ {
+			m.evolveAttributes()
+		}
+		if fuel := m.Fields["fuel"].(float64); math.Abs(fuel-test.want) > 1e-9 {
+			t.Errorf("%s: fuel is %g after %d ticks, want %g", test.name, fuel, test.ticks, test.want)
+		}
+	}
+}
+
+func TestAttributeRound(t *testing.T) {
+	tests := []struct {
+		decimals int
+		value    float64
+		want     float64
+	}{
+		{0, 49.5, 50},
+		{1, 49.95, 50},
+		{1, 49.94, 49.9},
+		{2, 0.125, 0.13},
+		{-1, 1234, 1230},
+	}
+	for _, test := range tests {
+		a := Attribute{Decimals: test.decimals}
+		if got := a.round(test.value); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("round(%g) to %d places = %g, want %g", test.value, test.decimals, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package mover
+
+import (
+	// System
+	"math"
+)
+
+// BoidsProps controls the flocking model. Distances are in the
+// same units as mover coordinates, and the weights scale the
+// three classic steering rules against each other.
+type BoidsProps struct {
+	NeighborRadius   float64
+	SeparationRadius float64
+	SeparationWeight float64
+	AlignmentWeight  float64
+	CohesionWeight   float64
+	MaxTurn          int
+}
+
+// flock steers the mover using separation, alignment and cohesion
+// against the neighbors found within NeighborRadius.
+func (m *Mover) flock(w *World, neighbors []Mover) {
+	props := w.Props
+	bp := props.Boids
+	hx, hy := HeadingVector(m.Heading)
+
+	var count int
+	var sepX, sepY float64
+	var aliX, aliY, aliSpeed float64
+	var cenX, cenY float64
+	for _, n := range neighbors {
+		if n.Id == m.Id {
+			continue
+		}
+		dx := n.X - m.X
+		dy := n.Y - m.Y
+		dist := math.Hypot(dx, dy)
+		count++
+		if dist > 0 && dist < bp.SeparationRadius {
+			// Push away harder the closer the neighbor is
+			sepX -= dx / (dist * dist)
+			sepY -= dy / (dist * dist)
+		}
+		nx, ny := HeadingVector(n.Heading)
+		aliX += nx
+		aliY += ny
+		aliSpeed += n.Velocity
+		cenX += dx
+		cenY += dy
+	}
+
+	if count > 0 {
+		desX, desY := hx, hy
+		if sx, sy, ok := unitVector(sepX, sepY); ok {
+			desX += bp.SeparationWeight * sx
+			desY += bp.SeparationWeight * sy
+		}
+		if ax, ay, ok := unitVector(aliX, aliY); ok {
+			desX += bp.AlignmentWeight * ax
+			desY += bp.AlignmentWeight * ay
+		}
+		if cx, cy, ok := unitVector(cenX, cenY); ok {
+			desX += bp.CohesionWeight * cx
+			desY += bp.CohesionWeight * cy
+		}
+		if desX != 0 || desY != 0 {
+			m.Heading = TurnToward(m.Heading, VectorHeading(desX, desY), bp.MaxTurn)
+		}
+		// Match speed with the flock in proportion to alignment
+		avgSpeed := aliSpeed / float64(count)
+		blend := bp.AlignmentWeight / (1.0 + bp.AlignmentWeight)
+		m.Velocity = m.Velocity + (avgSpeed-m.Velocity)*blend
+	}
+
+	// Keep a little individual jitter so flocks do not lock up
+	if props.MaxHeadingChange > 0 {
+		headingChange := m.rng.Intn(2*props.MaxHeadingChange) - props.MaxHeadingChange
+		m.Heading = NormalizeHeading(m.Heading + headingChange)
+	}
+}
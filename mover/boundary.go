@@ -0,0 +1,134 @@
+package mover
+
+import (
+	// System
+	"fmt"
+	"math"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+)
+
+// Boundary behaviors, what a mover does on reaching the edge of
+// the start rectangle, or of its region. Wrapping brings it back in
+// at the opposite edge, which is a jump across the world for any
+// downstream analysis; the others keep every step a short one.
+const (
+	// Come back in at the opposite edge
+	BoundaryWrap = "wrap"
+	// Reflect the heading off the edge, like a ball off a wall
+	BoundaryBounce = "bounce"
+	// Hold at the edge, keeping the heading
+	BoundaryClamp = "clamp"
+	// Stop short of the edge and turn around
+	BoundaryTurn = "turn"
+	// Leave the simulation, to be replaced by a new mover
+	BoundaryRespawn = "respawn"
+)
+
+func validBoundary(boundary string) error {
+	switch boundary {
+	case BoundaryWrap, BoundaryBounce, BoundaryClamp, BoundaryTurn, BoundaryRespawn:
+		return nil
+	default:
+		return fmt.Errorf("unknown boundary behavior '%s'", boundary)
+	}
+}
+
+// boundary is the behavior of the mover class, or of the fleet.
+func (m *Mover) boundary(props *Props) string {
+	if m.Class != nil && m.Class.Boundary != "" {
+		return m.Class.Boundary
+	}
+	if props.Boundary == "" {
+		return BoundaryWrap
+	}
+	return props.Boundary
+}
+
+// Moved is how far the mover went in meters since it was at (x, y),
+// as before its last step, the short way round for movers that wrap
+// from one edge of their bounds to the other, rather than across.
+func (m *Mover) Moved(props *Props, x, y float64) float64 {
+	toX, toY := m.X, m.Y
+	if m.boundary(props) == BoundaryWrap {
+		rect := m.bounds(props)
+		toX = x + unwrap(toX-x, rect.MaxX-rect.MinX)
+		toY = y + unwrap(toY-y, rect.MaxY-rect.MinY)
+	}
+	return geo.Distance(x, y, toX, toY)
+}
+
+// unwrap is the shortest of the offset and the offsets a size
+// either side of it.
+func unwrap(d, size float64) float64 {
+	switch {
+	case size <= 0:
+		return d
+	case d > size/2:
+		return d - size
+	case d < -size/2:
+		return d + size
+	}
+	return d
+}
+
+// confine applies the mover boundary behavior to a step from its
+// position to (x, y) along the heading, returning where it ends up
+// and its heading afterwards. out reports a mover that left its
+// bounds, to respawn.
+func (m *Mover) confine(props *Props, x, y float64, heading int) (float64, float64, int, bool) {
+	rect := m.bounds(props)
+	if contains(rect, x, y) {
+		return x, y, heading, false
+	}
+	switch m.boundary(props) {
+	case BoundaryBounce:
+		x, y, heading = Reflect(x, y, heading, rect)
+		return x, y, heading, false
+	case BoundaryClamp:
+		return clamp(x, rect.MinX, rect.MaxX), clamp(y, rect.MinY, rect.MaxY), heading, false
+	case BoundaryTurn:
+		return m.X, m.Y, NormalizeHeading(heading + 180), false
+	case BoundaryRespawn:
+		return m.X, m.Y, heading, true
+	default:
+		x, y = Wrap(x, y, rect)
+		return x, y, heading, false
+	}
+}
+
+// Reflect bounces a point that has left the rectangle back off the
+// edges it crossed, mirroring the heading too.
+func Reflect(x, y float64, heading int, rect geo.Rectangle) (float64, float64, int) {
+	if x < rect.MinX || x > rect.MaxX {
+		// Crossing a side flips the east-west direction
+		x = reflectRange(x, rect.MinX, rect.MaxX)
+		heading = -heading
+	}
+	if y < rect.MinY || y > rect.MaxY {
+		// Crossing the top or bottom flips the north-south direction
+		y = reflectRange(y, rect.MinY, rect.MaxY)
+		heading = 180 - heading
+	}
+	return x, y, NormalizeHeading(heading)
+}
+
+// reflectRange mirrors v back into [min, max] off the edge it
+// crossed, clamping it if the step was longer than the range.
+func reflectRange(v, min, max float64) float64 {
+	if v < min {
+		v = 2*min - v
+	} else if v > max {
+		v = 2*max - v
+	}
+	return clamp(v, min, max)
+}
+
+func contains(rect geo.Rectangle, x, y float64) bool {
+	return x >= rect.MinX && x <= rect.MaxX && y >= rect.MinY && y <= rect.MaxY
+}
+
+func clamp(v, min, max float64) float64 {
+	return math.Max(min, math.Min(max, v))
+}
@@ -0,0 +1,123 @@
+package mover
+
+import (
+	// System
+	"fmt"
+	"math"
+	"time"
+)
+
+// Class is a kind of mover, such as ships or cars. Weight is the
+// class share of the fleet. Priority and the zoom range are
+// rendering hints passed through to the outputs, so map clients
+// can declutter large fleets consistently: higher priority draws
+// on top, and movers only show between MinZoom and MaxZoom. Color
+// is the color of its movers, with the class color strategy, see
+// StyleProps. Attributes are the telemetry its movers report.
+// SleepInterval, Jitter, Boundary, Altitude and Physics, when
+// set, override the fleet settings for its movers, so ships can
+// report every thirty seconds and cars every two.
+type Class struct {
+	Name          string
+	Weight        float64
+	Priority      int
+	MinZoom       int
+	MaxZoom       int
+	Color         string
+	SleepInterval time.Duration
+	Jitter        JitterProps
+	Boundary      string
+	Altitude      AltitudeProps
+	Physics       PhysicsProps
+	Attributes    []Attribute
+}
+
+var DefaultClass = Class{
+	Name:     "default",
+	Weight:   1.0,
+	Priority: 0,
+	MinZoom:  0,
+	MaxZoom:  22,
+}
+
+// initClasses fills in any class settings left out of the config,
+// and falls back to the single default class.
+func (p *Props) initClasses() error {
+	if len(p.Classes) == 0 {
+		p.Classes = []*Class{&DefaultClass}
+		return nil
+	}
+	for i, class := range p.Classes {
+		if class.Name == "" {
+			return fmt.Errorf("mover class %d has no name", i)
+		}
+		if class.Weight < 0 {
+			return fmt.Errorf("mover class '%s' has a negative weight", class.Name)
+		}
+		if class.SleepInterval < 0 {
+			return fmt.Errorf("mover class '%s' has a negative sleep interval", class.Name)
+		}
+		if class.Jitter.set() {
+			if err := class.Jitter.init(); err != nil {
+				return fmt.Errorf("mover class '%s': %w", class.Name, err)
+			}
+		}
+		if class.Boundary != "" {
+			if err := validBoundary(class.Boundary); err != nil {
+				return fmt.Errorf("mover class '%s': %w", class.Name, err)
+			}
+		}
+		if err := class.Physics.init(); err != nil {
+			return fmt.Errorf("mover class '%s': %w", class.Name, err)
+		}
+		if err := class.Altitude.init(); err != nil {
+			return fmt.Errorf("mover class '%s': %w", class.Name, err)
+		}
+		if class.MaxZoom == 0 {
+			class.MaxZoom = DefaultClass.MaxZoom
+		}
+		for i := range class.Attributes {
+			if err := class.Attributes[i].init(); err != nil {
+				return fmt.Errorf("mover class '%s': %w", class.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ClassFor assigns a class to a mover id in proportion to the
+// class weights. Ids are spread with the golden ratio rather than
+// at random, so the assignment is stable from run to run and
+// close to the weights even for small fleets.
+func (p *Props) ClassFor(moverId int) *Class {
+	classes := p.Classes
+	if len(classes) == 0 {
+		return &DefaultClass
+	}
+	var total float64
+	for _, class := range classes {
+		total += class.Weight
+	}
+	if total <= 0 {
+		return classes[moverId%len(classes)]
+	}
+	_, frac := math.Modf(float64(moverId) * 0.6180339887498949)
+	pick := frac * total
+	for _, class := range classes {
+		if pick < class.Weight {
+			return class
+		}
+		pick -= class.Weight
+	}
+	return classes[len(classes)-1]
+}
+
+// ClassNamed is the class of the name, nil if there is none.
+func (p *Props) ClassNamed(name string) *Class {
+	for _, class := range p.Classes {
+		if class.Name == name {
+			return class
+		}
+	}
+	return nil
+}
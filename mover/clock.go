@@ -0,0 +1,33 @@
+package mover
+
+import (
+	// System
+	"time"
+)
+
+// ClockProps sets the distribution of device clock errors. Each
+// mover draws a fixed offset and a drift rate from normal
+// distributions with these parameters when it is created. Drift
+// is in parts per million, so a drift of 100 gains 0.36 seconds
+// an hour. The zero value gives every mover a perfect clock.
+type ClockProps struct {
+	OffsetMean   time.Duration
+	OffsetStdDev time.Duration
+	DriftMean    float64
+	DriftStdDev  float64
+}
+
+// skewClock gives the mover its own device clock error.
+func (m *Mover) skewClock(props ClockProps, now time.Time) {
+	offset := float64(props.OffsetMean) + m.rng.NormFloat64()*float64(props.OffsetStdDev)
+	m.ClockOffset = time.Duration(offset)
+	m.ClockDrift = props.DriftMean + m.rng.NormFloat64()*props.DriftStdDev
+	m.ClockStart = now
+}
+
+// DeviceTime converts true time into what the mover's own clock
+// reads at that moment. The true time is what is kept in Ts.
+func (m Mover) DeviceTime(t time.Time) time.Time {
+	drift := time.Duration(float64(t.Sub(m.ClockStart)) * m.ClockDrift / 1e6)
+	return t.Add(m.ClockOffset + drift)
+}
@@ -0,0 +1,91 @@
+package mover
+
+import (
+	// System
+	"fmt"
+	"math"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+)
+
+// Commands from outside the simulation, for interactive demos
+const (
+	// Head for X, Y whatever the movement model, and park there
+	CommandDestination = "destination"
+	// Stop where it is
+	CommandFreeze = "freeze"
+	// Move again after a freeze or parking
+	CommandResume = "resume"
+	// Travel at Velocity from now on
+	CommandSpeed = "speed"
+)
+
+// Command is an instruction for one running mover.
+type Command struct {
+	Kind     string
+	X        float64
+	Y        float64
+	Velocity float64
+}
+
+// Check reports commands that cannot be carried out.
+func (c Command) Check() error {
+	switch c.Kind {
+	case CommandDestination:
+		if !validCoordinate(c.X, c.Y) {
+			return fmt.Errorf("invalid destination (%f, %f)", c.X, c.Y)
+		}
+	case CommandFreeze, CommandResume:
+	case CommandSpeed:
+		if c.Velocity <= 0 || math.IsInf(c.Velocity, 0) || math.IsNaN(c.Velocity) {
+			return fmt.Errorf("invalid velocity %f", c.Velocity)
+		}
+	default:
+		return fmt.Errorf("unknown command '%s'", c.Kind)
+	}
+	return nil
+}
+
+// Apply carries out the command, taking effect from the next step.
+func (m *Mover) Apply(w *World, c Command) {
+	switch c.Kind {
+	case CommandDestination:
+		m.Destination = geo.Point{X: c.X, Y: c.Y}
+		m.HasDestination = true
+		m.Steered = true
+		m.Frozen = false
+		m.Velocity = m.cruise(w.Props)
+	case CommandFreeze:
+		m.Frozen = true
+		m.Velocity = 0
+	case CommandResume:
+		if m.Frozen {
+			m.Frozen = false
+			m.Velocity = m.cruise(w.Props)
+		}
+	case CommandSpeed:
+		m.Cruise = c.Velocity
+		if !m.Frozen {
+			m.Velocity = c.Velocity
+		}
+	}
+	m.Logger().Infof("Command %s", c.Kind)
+}
+
+// cruise is the velocity the mover travels at when under way.
+func (m *Mover) cruise(props *Props) float64 {
+	if m.Cruise > 0 {
+		return m.Cruise
+	}
+	return props.StartVelocity
+}
+
+// steer takes the mover to its commanded destination, parking
+// it there until it is resumed or sent somewhere else.
+func (m *Mover) steer(w *World) {
+	if m.approach(w, m.cruise(w.Props)) {
+		m.Steered = false
+		m.Frozen = true
+	}
+}
@@ -0,0 +1,103 @@
+package mover
+
+import (
+	// System
+	"fmt"
+	"math/rand"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+)
+
+// Constraint modes
+const (
+	// Movers must stay inside the polygons (cars on land)
+	ConstraintInside = "inside"
+	// Movers bounce off the polygons (ships around land)
+	ConstraintOutside = "outside"
+)
+
+// ConstraintProps names a polygon layer that limits where movers
+// can go, read either from a GeoJSON File or from a Query against
+// the database that returns one geometry column. Loading the layer
+// is up to the program, see NewConstraint.
+type ConstraintProps struct {
+	File  string
+	Query string
+	Mode  string
+}
+
+// Constraint is a loaded polygon layer and the rule for using it.
+type Constraint struct {
+	index *geo.PolygonIndex
+	mode  string
+}
+
+// NewConstraint builds a constraint from a polygon layer.
+func NewConstraint(polygons []geo.Polygon, mode string) (*Constraint, error) {
+	if mode != ConstraintInside && mode != ConstraintOutside {
+		return nil, fmt.Errorf("unknown constraint mode '%s'", mode)
+	}
+	if len(polygons) == 0 {
+		return nil, fmt.Errorf("constraint layer has no polygons")
+	}
+	return &Constraint{
+		index: geo.NewPolygonIndex(polygons),
+		mode:  mode,
+	}, nil
+}
+
+// Allows reports whether a mover may stand at the point.
+func (c *Constraint) Allows(x, y float64) bool {
+	if c == nil {
+		return true
+	}
+	inside := c.index.Contains(x, y)
+	if c.mode == ConstraintOutside {
+		return !inside
+	}
+	return inside
+}
+
+// RandomPoint picks a uniform random point with the random
+// numbers in the rectangle that the constraint allows, and that
+// passes any further checks, such as those of other constraints.
+func (c *Constraint) RandomPoint(rng *rand.Rand, rect geo.Rectangle, checks ...func(x, y float64) bool) (geo.Point, bool) {
+points:
+	for tries := 0; tries < 1000; tries++ {
+		p := geo.Point{
+			X: rect.MinX + rng.Float64()*(rect.MaxX-rect.MinX),
+			Y: rect.MinY + rng.Float64()*(rect.MaxY-rect.MinY),
+		}
+		if !c.Allows(p.X, p.Y) {
+			continue
+		}
+		for _, allows := range checks {
+			if !allows(p.X, p.Y) {
+				continue points
+			}
+		}
+		return p, true
+	}
+	return geo.Point{}, false
+}
+
+// bounce finds the smallest turn away from the current heading
+// whose next step the constraint allows, trying alternately to
+// either side. It reports false if the mover is boxed in.
+func (m *Mover) bounce(w *World) (int, bool) {
+	side := 1
+	if m.rng.Intn(2) == 0 {
+		side = -1
+	}
+	for turn := 30; turn <= 180; turn += 30 {
+		for _, dir := range []int{side, -side} {
+			heading := NormalizeHeading(m.Heading + dir*turn)
+			x, y, _, _ := m.nextPosition(w, heading)
+			if w.Constraint.Allows(x, y) {
+				return heading, true
+			}
+		}
+	}
+	return m.Heading, false
+}
@@ -0,0 +1,159 @@
+package mover
+
+import (
+	// System
+	"fmt"
+	"math"
+	"sync"
+)
+
+// ConvoyProps groups the fleet into convoys of Size movers, such as
+// platoons or tour groups, as they start: the first of a convoy
+// leads, moving by the movement model, and each of the others
+// follows the one that started before it, Spacing behind, turning
+// at most MaxTurn degrees a tick. A convoy is known by the id of its
+// leader. A Size below two leaves every mover on its own. Movers
+// that lose the one ahead of them, because it died or left, lead
+// the rest of their convoy from then on, and movers started after
+// that form convoys of their own.
+type ConvoyProps struct {
+	Size    int
+	Spacing float64
+	MaxTurn int
+}
+
+func (c ConvoyProps) init() error {
+	if c.Size < 0 {
+		return fmt.Errorf("convoy size %d is negative", c.Size)
+	}
+	if c.Spacing < 0 || c.MaxTurn < 0 {
+		return fmt.Errorf("convoy spacing and turn must not be negative")
+	}
+	return nil
+}
+
+// convoys are the convoys still taking on movers, by region.
+type convoys struct {
+	mutex   sync.Mutex
+	forming map[*Region]*convoy
+}
+
+// convoy is a convoy taking on movers: the id of its leader, the
+// last mover to join it and how many have.
+type convoy struct {
+	id      int
+	last    int
+	members int
+}
+
+// join finds the convoy for a new mover of the region: the one
+// forming there, if it has room, or else a new one it leads. The
+// mover follows the last to join before it, if any.
+func (c *convoys) join(m *Mover, size int) (joined convoy, follows bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.forming == nil {
+		c.forming = make(map[*Region]*convoy)
+	}
+	if forming := c.forming[m.Region]; forming != nil && forming.members < size {
+		joined = *forming
+		forming.last = m.Id
+		forming.members++
+		return joined, true
+	}
+	c.forming[m.Region] = &convoy{id: m.Id, last: m.Id, members: 1}
+	return *c.forming[m.Region], false
+}
+
+// leave closes the convoy forming behind a mover that has stopped,
+// so that no mover started later sets out to follow it.
+func (c *convoys) leave(m *Mover) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if forming := c.forming[m.Region]; forming != nil && forming.last == m.Id {
+		delete(c.forming, m.Region)
+	}
+}
+
+// Leave tells the world a mover has stopped, see ConvoyProps.
+func (w *World) Leave(m *Mover) {
+	w.convoys.leave(m)
+}
+
+// joinConvoy puts a new mover into a convoy, leading a new one or
+// following the last to join the one forming, in its place behind
+// it if that one is already about.
+func (m *Mover) joinConvoy(w *World) {
+	props := w.Props.Convoy
+	if props.Size < 2 {
+		return
+	}
+	joined, follows := w.convoys.join(m, props.Size)
+	if m.Fields == nil {
+		m.Fields = make(map[string]interface{}, 1)
+	}
+	m.Fields["convoy"] = joined.id
+	if !follows {
+		return
+	}
+	m.Leader, m.HasLeader = joined.last, true
+	leader, ok := w.Index.Get(m.Leader)
+	if !ok {
+		return
+	}
+	m.X, m.Y = m.slot(w, leader)
+	m.Heading, m.Velocity = leader.Heading, leader.Velocity
+}
+
+// slot is the place Spacing behind the leader.
+func (m *Mover) slot(w *World, leader Mover) (float64, float64) {
+	return Project(leader.X, leader.Y, leader.Heading, -w.Props.Convoy.Spacing)
+}
+
+// follow steers the mover for its place in the convoy, reporting
+// false when there is no one to follow, to leave it to the model.
+func (m *Mover) follow(w *World) bool {
+	leader, ok := w.Index.Get(m.Leader)
+	if !ok || leader.Exited || leader.Region != m.Region {
+		return false
+	}
+	props := w.Props.Convoy
+	x, y := m.slot(w, leader)
+	dx, dy := x-m.X, y-m.Y
+	if m.boundary(w.Props) == BoundaryWrap {
+		// The short way round, when the leader has wrapped
+		rect := m.bounds(w.Props)
+		dx = shortest(dx, rect.MaxX-rect.MinX)
+		dy = shortest(dy, rect.MaxY-rect.MinY)
+	}
+	dist := math.Hypot(dx, dy)
+	if dist == 0 {
+		m.Heading, m.Velocity = leader.Heading, leader.Velocity
+		return true
+	}
+	m.Heading = TurnToward(m.Heading, VectorHeading(dx, dy), props.MaxTurn)
+	// Close the gap in one tick if it can, catching up at up to
+	// twice the leader speed when it has fallen behind, as fast
+	// as the physics and the safety checks allow
+	v := m.physics(w).clampSpeed(math.Min(dist, 2*leader.Velocity+props.Spacing))
+	if max := w.Props.Safety.MaxVelocity; max > 0 {
+		v = math.Min(v, max)
+	}
+	m.Velocity = v
+	return true
+}
+
+// shortest is the shorter of the two ways round a
+// wrapping range of the given size.
+func shortest(d, size float64) float64 {
+	if size <= 0 {
+		return d
+	}
+	if d > size/2 {
+		return d - size
+	}
+	if d < -size/2 {
+		return d + size
+	}
+	return d
+}
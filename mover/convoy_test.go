@@ -0,0 +1,77 @@
+package mover
+
+import (
+	// System
+	"testing"
+)
+
+func convoyWorld(t *testing.T, size int) *World {
+	t.Helper()
+	props := DefaultProps()
+	props.Convoy.Size = size
+	props.Physics.MaxSpeed = 3
+	if err := props.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return NewWorld(&props, nil)
+}
+
+func TestJoinConvoy(t *testing.T) {
+	w := convoyWorld(t, 3)
+	tests := []struct {
+		id     int
+		leave  bool
+		convoy int
+		leader int
+	}{
+		{1, false, 1, 0},
+		{2, false, 1, 1},
+		{3, false, 1, 2},
+		{4, false, 4, 0},
+		{5, true, 4, 4},
+		// The one it would follow has gone
+		{6, false, 6, 0},
+		{7, false, 6, 6},
+	}
+	for _, test := range tests {
+		m, err := w.NewMover(test.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if convoy := m.Fields["convoy"]; convoy != test.convoy {
+			t.Errorf("mover %d joined convoy %v, want %d", test.id, convoy, test.convoy)
+		}
+		if test.leader == 0 && m.HasLeader {
+			t.Errorf("mover %d follows %d, want it to lead", test.id, m.Leader)
+		} else if test.leader != 0 && (!m.HasLeader || m.Leader != test.leader) {
+			t.Errorf("mover %d follows %d (%t), want %d", test.id, m.Leader, m.HasLeader, test.leader)
+		}
+		w.Index.Update(m)
+		if test.leave {
+			w.Index.Remove(m.Id)
+			w.Leave(&m)
+		}
+	}
+}
+
+func TestFollowSpeed(t *testing.T) {
+	w := convoyWorld(t, 2)
+	leader, err := w.NewMover(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leader.Velocity = 2.5
+	w.Index.Update(leader)
+	m, err := w.NewMover(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Fallen far behind, it would catch up at twice the leader speed
+	m.X, m.Y = leader.X-20, leader.Y-20
+	if !m.follow(w) {
+		t.Fatal("no leader to follow")
+	}
+	if m.Velocity != 3 {
+		t.Errorf("caught up at %g, want the physics MaxSpeed 3", m.Velocity)
+	}
+}
@@ -0,0 +1,153 @@
+package mover
+
+import (
+	// System
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+)
+
+// DensityProps weights where movers start, rather than uniformly
+// over the start rectangle, so the fleet is densest where people
+// are. Weighted points come from a GeoJSON File or a database Query
+// returning a geometry column, with the weight of each in its Weight
+// property or column (one if it has none), such as city centroids
+// with their populations; movers start around a point picked in
+// proportion to its weight, Spread degrees away on average. A Raster
+// is an ESRI ASCII grid of counts, such as population, and movers
+// start anywhere in a cell picked in proportion to its count.
+// Loading the source is up to the program, see NewDensity.
+type DensityProps struct {
+	File   string
+	Query  string
+	Raster string
+	Weight string
+	Spread float64
+}
+
+func (d DensityProps) init() error {
+	sources := 0
+	for _, source := range []string{d.File, d.Query, d.Raster} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return fmt.Errorf("start density takes one of a file, a query or a raster")
+	}
+	if d.Spread < 0 {
+		return fmt.Errorf("start density spread %f is negative", d.Spread)
+	}
+	return nil
+}
+
+// Density is a loaded source of weighted start positions.
+type Density struct {
+	// Places to start in, and the running total of their weights
+	places     []geo.Rectangle
+	cumulative []float64
+	spread     float64
+}
+
+// densityTries is how many samples densityPoint draws before
+// giving up on finding one its bounds allow.
+const densityTries = 1000
+
+// NewDensity weights the points, spreading starts around them.
+func NewDensity(points []geo.Point, weights []float64, spread float64) (*Density, error) {
+	if len(points) != len(weights) {
+		return nil, fmt.Errorf("%d points but %d weights", len(points), len(weights))
+	}
+	d := &Density{spread: spread}
+	for i, p := range points {
+		if err := d.add(geo.Rectangle{MinX: p.X, MinY: p.Y, MaxX: p.X, MaxY: p.Y}, weights[i]); err != nil {
+			return nil, err
+		}
+	}
+	return d, d.check()
+}
+
+// NewGridDensity weights the cells of the grid by their values.
+func NewGridDensity(grid *geo.Grid) (*Density, error) {
+	d := &Density{}
+	for row := 0; row < grid.Rows; row++ {
+		for col := 0; col < grid.Cols; col++ {
+			v := grid.Values[row*grid.Cols+col]
+			if math.IsNaN(v) || v == 0 {
+				continue
+			}
+			if err := d.add(grid.Cell(col, row), v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return d, d.check()
+}
+
+func (d *Density) add(place geo.Rectangle, weight float64) error {
+	if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+		return fmt.Errorf("invalid start weight %f", weight)
+	}
+	if weight == 0 {
+		return nil
+	}
+	total := weight
+	if n := len(d.cumulative); n > 0 {
+		total += d.cumulative[n-1]
+	}
+	d.places = append(d.places, place)
+	d.cumulative = append(d.cumulative, total)
+	return nil
+}
+
+func (d *Density) check() error {
+	if len(d.places) == 0 {
+		return fmt.Errorf("start density has no positive weights")
+	}
+	return nil
+}
+
+// Len is the number of places with weight.
+func (d *Density) Len() int {
+	return len(d.places)
+}
+
+// sample draws a position, weighted by the density.
+func (d *Density) sample(rng *rand.Rand) geo.Point {
+	total := d.cumulative[len(d.cumulative)-1]
+	i := sort.SearchFloat64s(d.cumulative, rng.Float64()*total)
+	if i >= len(d.places) {
+		i = len(d.places) - 1
+	}
+	place := d.places[i]
+	p := geo.Point{
+		X: place.MinX + rng.Float64()*(place.MaxX-place.MinX),
+		Y: place.MinY + rng.Float64()*(place.MaxY-place.MinY),
+	}
+	if d.spread > 0 {
+		p.X += rng.NormFloat64() * d.spread
+		p.Y += rng.NormFloat64() * d.spread
+	}
+	return p
+}
+
+// densityPoint draws a start position from the density inside the
+// rectangle that both the constraint layer and the area of the
+// region, if any, allow, clear of the no-go zones.
+func (w *World) densityPoint(rng *rand.Rand, rect geo.Rectangle, region *Region) (geo.Point, bool) {
+	var area *Constraint
+	if region != nil {
+		area = region.area
+	}
+	for tries := 0; tries < densityTries; tries++ {
+		p := w.Density.sample(rng)
+		if contains(rect, p.X, p.Y) && w.Constraint.Allows(p.X, p.Y) && area.Allows(p.X, p.Y) && w.Zones.Allows(p.X, p.Y) {
+			return p, true
+		}
+	}
+	return geo.Point{}, false
+}
@@ -0,0 +1,80 @@
+package mover
+
+import (
+	// System
+	"math"
+	"time"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+)
+
+// DestinationProps controls the destination-seeking model. Movers
+// head for a random point in the start rectangle, or a random
+// entry of Pois when any are configured, turning at most MaxTurn
+// degrees per tick, and wait DwellTime on arrival.
+type DestinationProps struct {
+	MaxTurn       int
+	ArrivalRadius float64
+	DwellTime     time.Duration
+	Pois          []geo.Point
+}
+
+// pickDestination chooses the next place for the mover to go.
+func (m *Mover) pickDestination(w *World) {
+	props := w.Props
+	pois := props.Destination.Pois
+	if len(pois) > 0 {
+		poi := pois[m.rng.Intn(len(pois))]
+		// Avoid choosing the point we are standing on
+		for tries := 0; tries < len(pois) && poi.X == m.X && poi.Y == m.Y; tries++ {
+			poi = pois[m.rng.Intn(len(pois))]
+		}
+		m.Destination = poi
+	} else if dest, ok := w.startPoint(m.rng, m.bounds(props), m.Region); ok {
+		m.Destination = dest
+	} else {
+		m.Destination = geo.Point{X: m.X, Y: m.Y}
+	}
+	m.HasDestination = true
+	m.Velocity = startVelocity(props, m.Region)
+}
+
+// seek steers the mover toward its destination, slowing on approach
+// so the bounded turn rate cannot leave it circling the target, and
+// parks it for the dwell time once it arrives.
+func (m *Mover) seek(w *World) {
+	props := w.Props.Destination
+	if !m.HasDestination {
+		if m.Ts.Before(m.DwellUntil) {
+			m.Velocity = 0
+			return
+		}
+		m.pickDestination(w)
+	}
+
+	if m.approach(w, m.cruise(w.Props)) {
+		m.DwellUntil = m.Ts.Add(props.DwellTime)
+	}
+}
+
+// approach turns the mover toward its destination and sets its
+// speed for the distance left, reporting whether it has arrived,
+// in which case it is put on the destination and stopped.
+func (m *Mover) approach(w *World, cruise float64) bool {
+	props := w.Props.Destination
+	dx := m.Destination.X - m.X
+	dy := m.Destination.Y - m.Y
+	dist := math.Hypot(dx, dy)
+	if dist <= props.ArrivalRadius || dist <= m.Velocity {
+		m.X = m.Destination.X
+		m.Y = m.Destination.Y
+		m.Velocity = 0
+		m.HasDestination = false
+		return true
+	}
+
+	m.Heading = TurnToward(m.Heading, VectorHeading(dx, dy), props.MaxTurn)
+	m.Velocity = ApproachSpeed(cruise, dist, props.MaxTurn)
+	return false
+}
@@ -0,0 +1,70 @@
+package mover
+
+import (
+	// System
+	"math"
+	"math/rand"
+	"time"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+)
+
+// GpsProps degrades the positions movers report, the way real
+// receivers do, while they keep moving along their true track.
+// NoiseSigma is the standard deviation of the Gaussian position
+// error in meters. Dropout is the chance that any one update is
+// lost. Each mover also suffers outages, OutageRate an hour on
+// average, lasting OutageMean on average, with no updates at all.
+// The zero value reports perfect positions.
+type GpsProps struct {
+	NoiseSigma float64
+	Dropout    float64
+	OutageRate float64
+	OutageMean time.Duration
+}
+
+// Fix reports whether the receiver of the mover gets a position
+// out this tick, starting and ending its outages as time passes.
+func (m *Mover) Fix(props GpsProps) bool {
+	if props.OutageRate > 0 && props.OutageMean > 0 {
+		if m.NextOutage.IsZero() {
+			m.NextOutage = m.Ts.Add(expDuration(m.rng, time.Hour, props.OutageRate))
+		}
+		if !m.Ts.Before(m.NextOutage) {
+			m.OutageUntil = m.NextOutage.Add(expDuration(m.rng, props.OutageMean, 1))
+			m.NextOutage = m.OutageUntil.Add(expDuration(m.rng, time.Hour, props.OutageRate))
+		}
+		if m.Ts.Before(m.OutageUntil) {
+			return false
+		}
+	}
+	return props.Dropout <= 0 || m.rng.Float64() >= props.Dropout
+}
+
+// Observed is the mover as its receiver reports it, with
+// the position error added.
+func (m Mover) Observed(props GpsProps) Mover {
+	if props.NoiseSigma <= 0 {
+		return m
+	}
+	dy := m.rng.NormFloat64() * props.NoiseSigma / geo.MetersPerDegree
+	// Degrees of longitude shrink toward the poles
+	scale := math.Max(math.Cos(m.Y*math.Pi/180.0), 0.01)
+	dx := m.rng.NormFloat64() * props.NoiseSigma / (geo.MetersPerDegree * scale)
+	m.X = m.X + dx
+	if m.X > 180 {
+		m.X -= 360
+	} else if m.X < -180 {
+		m.X += 360
+	}
+	m.Y = math.Max(-90, math.Min(90, m.Y+dy))
+	return m
+}
+
+// expDuration draws an exponentially distributed duration with
+// mean period/rate, as for the gaps between events that happen
+// rate times a period.
+func expDuration(rng *rand.Rand, period time.Duration, rate float64) time.Duration {
+	return time.Duration(rng.ExpFloat64() * float64(period) / rate)
+}
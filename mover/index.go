@@ -0,0 +1,131 @@
+package mover
+
+import (
+	// System
+	"math"
+	"sort"
+	"sync"
+)
+
+type gridCell struct {
+	I int
+	J int
+}
+
+// SpatialIndex is a uniform grid over the current mover positions,
+// so neighbor searches only visit the cells near the query point
+// instead of the whole fleet. Safe for concurrent use.
+type SpatialIndex struct {
+	mutex    sync.RWMutex
+	cellSize float64
+	cells    map[gridCell]map[int]Mover
+	movers   map[int]gridCell
+}
+
+func NewSpatialIndex(cellSize float64) *SpatialIndex {
+	if cellSize <= 0 {
+		cellSize = 1.0
+	}
+	return &SpatialIndex{
+		cellSize: cellSize,
+		cells:    make(map[gridCell]map[int]Mover),
+		movers:   make(map[int]gridCell),
+	}
+}
+
+func (idx *SpatialIndex) cellFor(x, y float64) gridCell {
+	return gridCell{
+		I: int(math.Floor(x / idx.cellSize)),
+		J: int(math.Floor(y / idx.cellSize)),
+	}
+}
+
+// Update records the current state of a mover, moving it
+// between cells if necessary.
+func (idx *SpatialIndex) Update(m Mover) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+	cell := idx.cellFor(m.X, m.Y)
+	if oldCell, ok := idx.movers[m.Id]; ok && oldCell != cell {
+		idx.removeFromCell(oldCell, m.Id)
+	}
+	movers, ok := idx.cells[cell]
+	if !ok {
+		movers = make(map[int]Mover)
+		idx.cells[cell] = movers
+	}
+	movers[m.Id] = m
+	idx.movers[m.Id] = cell
+}
+
+// Remove drops a mover from the index.
+func (idx *SpatialIndex) Remove(moverId int) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+	if cell, ok := idx.movers[moverId]; ok {
+		idx.removeFromCell(cell, moverId)
+		delete(idx.movers, moverId)
+	}
+}
+
+func (idx *SpatialIndex) removeFromCell(cell gridCell, moverId int) {
+	movers := idx.cells[cell]
+	delete(movers, moverId)
+	if len(movers) == 0 {
+		delete(idx.cells, cell)
+	}
+}
+
+// Neighbors returns every indexed mover within radius of (x, y).
+func (idx *SpatialIndex) Neighbors(x, y, radius float64) []Mover {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+	var found []Mover
+	minCell := idx.cellFor(x-radius, y-radius)
+	maxCell := idx.cellFor(x+radius, y+radius)
+	r2 := radius * radius
+	for i := minCell.I; i <= maxCell.I; i++ {
+		for j := minCell.J; j <= maxCell.J; j++ {
+			for _, m := range idx.cells[gridCell{I: i, J: j}] {
+				dx := m.X - x
+				dy := m.Y - y
+				if dx*dx+dy*dy <= r2 {
+					found = append(found, m)
+				}
+			}
+		}
+	}
+	return found
+}
+
+// Get returns the last recorded state of a mover, so movers
+// can keep track of each other.
+func (idx *SpatialIndex) Get(moverId int) (Mover, bool) {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+	cell, ok := idx.movers[moverId]
+	if !ok {
+		return Mover{}, false
+	}
+	m, ok := idx.cells[cell][moverId]
+	return m, ok
+}
+
+// Len is the number of movers indexed.
+func (idx *SpatialIndex) Len() int {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+	return len(idx.movers)
+}
+
+// All returns the last recorded state of every mover, by id.
+func (idx *SpatialIndex) All() []Mover {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+	all := make([]Mover, 0, len(idx.movers))
+	for id, cell := range idx.movers {
+		all = append(all, idx.cells[cell][id])
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Id < all[j].Id })
+	return all
+}
@@ -0,0 +1,76 @@
+package mover
+
+import (
+	// System
+	"fmt"
+	"math"
+	"time"
+)
+
+// Jitter distributions
+const (
+	// Anywhere within Amount of the interval either side
+	JitterUniform = "uniform"
+	// Normally distributed, with a standard deviation of
+	// Amount of the interval
+	JitterNormal = "normal"
+	// Exponentially distributed, as reports arriving at random
+	// at the average rate, whatever the Amount
+	JitterExponential = "exponential"
+	// Like clockwork
+	JitterNone = "none"
+)
+
+// JitterProps spreads the time between a mover's reports around
+// its interval, so a fleet does not report in lockstep. Amount is
+// a fraction of the interval.
+type JitterProps struct {
+	Distribution string
+	Amount       float64
+}
+
+func (j JitterProps) set() bool {
+	return j != JitterProps{}
+}
+
+func (j JitterProps) init() error {
+	switch j.Distribution {
+	case JitterUniform:
+		if j.Amount < 0 || j.Amount > 1 {
+			return fmt.Errorf("uniform jitter amount %g is not between 0 and 1", j.Amount)
+		}
+	case JitterNormal:
+		if j.Amount < 0 {
+			return fmt.Errorf("normal jitter amount %g is negative", j.Amount)
+		}
+	case JitterExponential, JitterNone:
+	default:
+		return fmt.Errorf("unknown jitter distribution '%s'", j.Distribution)
+	}
+	return nil
+}
+
+// jitter is the jitter of the mover class, or of the fleet.
+func (m *Mover) jitter(props *Props) JitterProps {
+	if m.Class != nil && m.Class.Jitter.set() {
+		return m.Class.Jitter
+	}
+	return props.Jitter
+}
+
+// Sleep draws the time until the mover's next report, given its
+// average interval, with the jitter of its class or the fleet.
+func (m *Mover) Sleep(props *Props, interval time.Duration) time.Duration {
+	j := m.jitter(props)
+	d := float64(interval)
+	switch j.Distribution {
+	case JitterUniform:
+		d += (2*m.rng.Float64() - 1) * j.Amount * d
+	case JitterNormal:
+		d += m.rng.NormFloat64() * j.Amount * d
+	case JitterExponential:
+		d = m.rng.ExpFloat64() * d
+	}
+	// Reports cannot come before the last one
+	return time.Duration(math.Max(d, 1))
+}
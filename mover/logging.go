@@ -0,0 +1,30 @@
+package mover
+
+import (
+	// Logging
+	log "github.com/sirupsen/logrus"
+)
+
+// Logger carries the mover identity on every log entry.
+func (m *Mover) Logger() *log.Entry {
+	className := ""
+	if m.Class != nil {
+		className = m.Class.Name
+	}
+	return log.WithFields(log.Fields{
+		"mover": m.Id,
+		"class": className,
+		"tick":  m.Ticks,
+	})
+}
+
+// LogFields describes the mover state, for logging each tick.
+func (m *Mover) LogFields() log.Fields {
+	return log.Fields{
+		"x":        m.X,
+		"y":        m.Y,
+		"heading":  m.Heading,
+		"velocity": m.Velocity,
+		"ts":       m.DeviceTime(m.Ts),
+	}
+}
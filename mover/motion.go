@@ -0,0 +1,102 @@
+package mover
+
+import (
+	// System
+	"math"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+)
+
+// The movement math, kept free of mover state and randomness so
+// that it can be checked on its own. Headings are whole degrees
+// counterclockwise from north.
+
+// HeadingVector converts a heading into a unit direction vector.
+func HeadingVector(heading int) (float64, float64) {
+	radianHeading := math.Pi * float64(heading+90.0) / 180.0
+	return math.Cos(radianHeading), math.Sin(radianHeading)
+}
+
+// VectorHeading is the inverse of HeadingVector.
+func VectorHeading(dx, dy float64) int {
+	degrees := math.Atan2(dy, dx)*180.0/math.Pi - 90.0
+	return NormalizeHeading(int(math.Round(degrees)))
+}
+
+// NormalizeHeading folds a heading into [0, 360).
+func NormalizeHeading(heading int) int {
+	heading = heading % 360
+	if heading < 0 {
+		heading += 360
+	}
+	return heading
+}
+
+// TurnToward returns the heading that moves from current toward
+// target by at most maxTurn degrees, taking the short way round.
+func TurnToward(current, target, maxTurn int) int {
+	diff := NormalizeHeading(target-current+180) - 180
+	if diff > maxTurn {
+		diff = maxTurn
+	} else if diff < -maxTurn {
+		diff = -maxTurn
+	}
+	return NormalizeHeading(current + diff)
+}
+
+// Project moves a point the distance along the heading.
+func Project(x, y float64, heading int, distance float64) (float64, float64) {
+	dx, dy := HeadingVector(heading)
+	return x + dx*distance, y + dy*distance
+}
+
+// StepMeters is how far a step of the distance in degrees along
+// the heading goes over the ground at the latitude, in meters, as
+// degrees of longitude shrink toward the poles.
+func StepMeters(heading int, distance, lat float64) float64 {
+	dx, dy := HeadingVector(heading)
+	east := dx * distance * math.Cos(lat*math.Pi/180.0)
+	north := dy * distance
+	return math.Hypot(east, north) * geo.MetersPerDegree
+}
+
+// Wrap brings a point that has left the rectangle back in at the
+// opposite edge, the way the movers travel round the world.
+func Wrap(x, y float64, rect geo.Rectangle) (float64, float64) {
+	return wrapRange(x, rect.MinX, rect.MaxX), wrapRange(y, rect.MinY, rect.MaxY)
+}
+
+// wrapRange wraps v into [min, max], however far outside it is,
+// as steps can be longer than a small region is wide.
+func wrapRange(v, min, max float64) float64 {
+	size := max - min
+	if (v >= min && v <= max) || size <= 0 {
+		return v
+	}
+	return min + math.Mod(math.Mod(v-min, size)+size, size)
+}
+
+// ApproachSpeed is the speed for a mover cruising at cruise with
+// dist left to its target. Within one turning circle of the target
+// it slows in proportion to the distance, so a bounded turn rate
+// cannot leave it circling, though never below a tenth of cruise.
+func ApproachSpeed(cruise, dist float64, maxTurn int) float64 {
+	turnRadians := float64(maxTurn) * math.Pi / 180.0
+	approach := cruise
+	if turnRadians > 0 {
+		approach = cruise / turnRadians
+	}
+	if dist < approach {
+		return math.Max(cruise*dist/approach, cruise*0.1)
+	}
+	return cruise
+}
+
+func unitVector(x, y float64) (float64, float64, bool) {
+	length := math.Hypot(x, y)
+	if length == 0 {
+		return 0, 0, false
+	}
+	return x / length, y / length, true
+}
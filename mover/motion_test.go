@@ -0,0 +1,223 @@
+package mover
+
+import (
+	// System
+	"math"
+	"testing"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+)
+
+const tolerance = 1e-9
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < tolerance
+}
+
+func TestHeadingVector(t *testing.T) {
+	tests := []struct {
+		heading int
+		dx, dy  float64
+	}{
+		{0, 0, 1},
+		{90, -1, 0},
+		{180, 0, -1},
+		{270, 1, 0},
+		{360, 0, 1},
+		{-90, 1, 0},
+		{45, -math.Sqrt2 / 2, math.Sqrt2 / 2},
+	}
+	for _, test := range tests {
+		dx, dy := HeadingVector(test.heading)
+		if !near(dx, test.dx) || !near(dy, test.dy) {
+			t.Errorf("HeadingVector(%d) = (%g, %g), want (%g, %g)", test.heading, dx, dy, test.dx, test.dy)
+		}
+	}
+}
+
+func TestVectorHeading(t *testing.T) {
+	tests := []struct {
+		dx, dy  float64
+		heading int
+	}{
+		{0, 1, 0},
+		{-1, 0, 90},
+		{0, -1, 180},
+		{1, 0, 270},
+		{-3, 3, 45},
+		{2, 2, 315},
+	}
+	for _, test := range tests {
+		if heading := VectorHeading(test.dx, test.dy); heading != test.heading {
+			t.Errorf("VectorHeading(%g, %g) = %d, want %d", test.dx, test.dy, heading, test.heading)
+		}
+	}
+	for heading := 0; heading < 360; heading++ {
+		if got := VectorHeading(HeadingVector(heading)); got != heading {
+			t.Errorf("VectorHeading(HeadingVector(%d)) = %d", heading, got)
+		}
+	}
+}
+
+func TestNormalizeHeading(t *testing.T) {
+	tests := []struct {
+		heading, want int
+	}{
+		{0, 0},
+		{359, 359},
+		{360, 0},
+		{725, 5},
+		{-1, 359},
+		{-360, 0},
+		{-725, 355},
+	}
+	for _, test := range tests {
+		if got := NormalizeHeading(test.heading); got != test.want {
+			t.Errorf("NormalizeHeading(%d) = %d, want %d", test.heading, got, test.want)
+		}
+	}
+}
+
+func TestTurnToward(t *testing.T) {
+	tests := []struct {
+		name                     string
+		current, target, maxTurn int
+		want                     int
+	}{
+		{"within reach", 10, 30, 45, 30},
+		{"clamped left", 10, 90, 45, 55},
+		{"clamped right", 90, 10, 45, 45},
+		{"short way across north", 350, 20, 10, 0},
+		{"short way back across north", 20, 350, 10, 10},
+		{"already there", 180, 180, 5, 180},
+		{"no turning", 0, 90, 0, 0},
+	}
+	for _, test := range tests {
+		if got := TurnToward(test.current, test.target, test.maxTurn); got != test.want {
+			t.Errorf("%s: TurnToward(%d, %d, %d) = %d, want %d",
+				test.name, test.current, test.target, test.maxTurn, got, test.want)
+		}
+	}
+}
+
+func TestProject(t *testing.T) {
+	tests := []struct {
+		x, y     float64
+		heading  int
+		distance float64
+		wantX    float64
+		wantY    float64
+	}{
+		{0, 0, 0, 1, 0, 1},
+		{10, 20, 90, 2, 8, 20},
+		{10, 20, 180, 0.5, 10, 19.5},
+		{-5, 5, 270, 3, -2, 5},
+		{0, 0, 135, math.Sqrt2, -1, -1},
+		{1, 1, 0, 0, 1, 1},
+	}
+	for _, test := range tests {
+		x, y := Project(test.x, test.y, test.heading, test.distance)
+		if !near(x, test.wantX) || !near(y, test.wantY) {
+			t.Errorf("Project(%g, %g, %d, %g) = (%g, %g), want (%g, %g)",
+				test.x, test.y, test.heading, test.distance, x, y, test.wantX, test.wantY)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	world := geo.Rectangle{MinX: -180, MinY: -90, MaxX: 180, MaxY: 90}
+	small := geo.Rectangle{MinX: 0, MinY: 0, MaxX: 1, MaxY: 1}
+	tests := []struct {
+		name   string
+		rect   geo.Rectangle
+		x, y   float64
+		wx, wy float64
+	}{
+		{"inside", world, 10, 20, 10, 20},
+		{"on the edge", world, 180, -90, 180, -90},
+		{"off the east", world, 181, 0, -179, 0},
+		{"off the west", world, -181, 0, 179, 0},
+		{"off the north", world, 0, 91, 0, -89},
+		{"off the south", world, 0, -95, 0, 85},
+		{"many times round", small, 3.25, -2.5, 0.25, 0.5},
+		{"empty rectangle", geo.Rectangle{MinX: 1, MinY: 1, MaxX: 1, MaxY: 1}, 5, -5, 5, -5},
+	}
+	for _, test := range tests {
+		x, y := Wrap(test.x, test.y, test.rect)
+		if !near(x, test.wx) || !near(y, test.wy) {
+			t.Errorf("%s: Wrap(%g, %g) = (%g, %g), want (%g, %g)",
+				test.name, test.x, test.y, x, y, test.wx, test.wy)
+		}
+	}
+}
+
+func TestApproachSpeed(t *testing.T) {
+	// With a 90 degree turn, the turning circle of a mover at
+	// cruise has a radius of cruise/(pi/2)
+	radius := 1 / (math.Pi / 2)
+	tests := []struct {
+		name    string
+		cruise  float64
+		dist    float64
+		maxTurn int
+		want    float64
+	}{
+		{"far away", 1, 10, 90, 1},
+		{"on the circle", 1, radius, 90, 1},
+		{"inside the circle", 1, radius / 2, 90, 0.5},
+		{"at the floor", 1, radius / 20, 90, 0.1},
+		{"at the target", 1, 0, 90, 0.1},
+		{"no turning", 1, 0.5, 0, 0.5},
+	}
+	for _, test := range tests {
+		if got := ApproachSpeed(test.cruise, test.dist, test.maxTurn); !near(got, test.want) {
+			t.Errorf("%s: ApproachSpeed(%g, %g, %d) = %g, want %g",
+				test.name, test.cruise, test.dist, test.maxTurn, got, test.want)
+		}
+	}
+}
+
+func TestUnitVector(t *testing.T) {
+	tests := []struct {
+		x, y   float64
+		ux, uy float64
+		ok     bool
+	}{
+		{3, 4, 0.6, 0.8, true},
+		{0, -2, 0, -1, true},
+		{0, 0, 0, 0, false},
+	}
+	for _, test := range tests {
+		ux, uy, ok := unitVector(test.x, test.y)
+		if ok != test.ok || !near(ux, test.ux) || !near(uy, test.uy) {
+			t.Errorf("unitVector(%g, %g) = (%g, %g, %t), want (%g, %g, %t)",
+				test.x, test.y, ux, uy, ok, test.ux, test.uy, test.ok)
+		}
+	}
+}
+
+func TestMoved(t *testing.T) {
+	props := DefaultProps()
+	props.StartRectangle = geo.Rectangle{MinX: 0, MinY: 0, MaxX: 10, MaxY: 10}
+	degree := geo.Distance(0, 0, 1, 0)
+	tests := []struct {
+		name     string
+		boundary string
+		x, y     float64
+		toX, toY float64
+		want     float64
+	}{
+		{"a step", BoundaryWrap, 5, 0, 6, 0, degree},
+		{"wrapped east", BoundaryWrap, 9.5, 0, 0.5, 0, degree},
+		{"wrapped west", BoundaryWrap, 0.5, 0, 9.5, 0, degree},
+		{"bounced", BoundaryBounce, 9.5, 0, 0.5, 0, 9 * degree},
+	}
+	for _, test := range tests {
+		props.Boundary = test.boundary
+		m := Mover{X: test.toX, Y: test.toY}
+		if got := m.Moved(&props, test.x, test.y); math.Abs(got-test.want) > 1e-6 {
+			t.Errorf("%s: Moved() = %f meters, want %f", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,478 @@
+// Package mover models the simulated objects and how they move.
+// Movers share a World, holding their settings, an index of where
+// every mover is, and any constraint layer they are confined to.
+package mover
+
+import (
+	// System
+	"fmt"
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+
+	// Feature flags
+	"github.com/pramsey/movesim/feature"
+)
+
+// Type definitions
+
+type Mover struct {
+	Id       int
+	Heading  int
+	Velocity float64
+	X        float64
+	Y        float64
+	// Altitude in meters and the rate it changes by in meters
+	// per tick, for movers with a third dimension
+	Z      float64
+	Climb  float64
+	HasZ   bool
+	Color  string
+	Name   string
+	Class  *Class
+	Region *Region
+	Ticks  int
+	// True time of the current position
+	Ts time.Time
+
+	// Device clock error, see DeviceTime
+	ClockOffset time.Duration
+	ClockDrift  float64
+	ClockStart  time.Time
+
+	// Zero for movers that live forever
+	DiesAt time.Time
+	// Left its bounds, with the respawn boundary behavior
+	Exited bool
+
+	// Time missed before this position
+	Gap time.Duration
+
+	// Reporting behavior, changed by firmware rollouts
+	SleepInterval time.Duration
+	Fields        map[string]interface{}
+	Firmware      string
+	OfflineUntil  time.Time
+	RolloutRank   float64
+	NextRollout   int
+
+	// Full precision values of the class attributes, which the
+	// Fields report rounded, see Attribute
+	attributes map[string]float64
+
+	// Random numbers of this mover alone, see World.Seed
+	rng *rand.Rand
+
+	// Receiver outages, see GpsProps
+	OutageUntil time.Time
+	NextOutage  time.Time
+
+	// The no-go zone it is in, if any, see ObstacleProps
+	Zone string
+
+	// Random walk state, the degrees a tick it is turning, see
+	// WanderProps, and the fraction of a degree yet to turn
+	TurnRate  float64
+	turnCarry float64
+
+	// Destination model state
+	Destination    geo.Point
+	HasDestination bool
+	DwellUntil     time.Time
+
+	// Route model state, the positions still to pass
+	Route []geo.Point
+
+	// Commanded state, see Apply: Steered movers head for their
+	// destination whatever the model, Frozen movers stay put, and
+	// a Cruise velocity replaces the configured one
+	Steered bool
+	Frozen  bool
+	Cruise  float64
+
+	// Convoy followers keep their place behind their Leader
+	Leader    int
+	HasLeader bool
+}
+
+// Props controls how movers start out and how they move.
+type Props struct {
+	MaxHeadingChange  int
+	MaxVelocityChange float64
+	StartVelocity     float64
+	StartRectangle    geo.Rectangle
+	StartDensity      DensityProps
+	SleepInterval     time.Duration
+	Jitter            JitterProps
+	Wander            WanderProps
+	Model             string
+	Boundary          string
+	Altitude          AltitudeProps
+	Physics           PhysicsProps
+	Convoy            ConvoyProps
+	Boids             BoidsProps
+	Destination       DestinationProps
+	Clock             ClockProps
+	Constraint        ConstraintProps
+	Obstacles         ObstacleProps
+	Style             StyleProps
+	Route             RouteProps
+	Safety            SafetyProps
+	Gps               GpsProps
+	Classes           []*Class
+	Regions           []*Region
+}
+
+// World is what the movers of one simulation share.
+type World struct {
+	Props      *Props
+	Index      *SpatialIndex
+	Constraint *Constraint
+	// Street network for the route model, nil if not loaded
+	Network *Network
+	// Weights of start positions, nil for uniform starts
+	Density *Density
+	// No-go zones to steer around, nil if not loaded
+	Zones *Zones
+	// Styles movers had before, nil if they are not kept
+	Styles *Styles
+	// Seed of the random numbers of the movers, each drawing
+	// from its own source seeded from this and its id, so that
+	// a run with the same seed moves them the same way
+	Seed int64
+
+	// Float64 bits of the pace, see SetPace
+	pace atomic.Uint64
+	// Settings changed since the start, see Tune
+	tuning atomic.Pointer[Tuning]
+	// Convoys taking on new movers, see ConvoyProps
+	convoys convoys
+}
+
+// Movement models
+const (
+	ModelRandom      = "random"
+	ModelBoids       = "boids"
+	ModelDestination = "destination"
+	ModelRoute       = "route"
+)
+
+// Switching a model off sends its movers back to the random walk
+var (
+	boidsFeature       = feature.Register("model_boids", "Flocking movement model", true)
+	destinationFeature = feature.Register("model_destination", "Destination seeking movement model", true)
+	routeFeature       = feature.Register("model_route", "Street network routing movement model", true)
+)
+
+// DefaultProps are the settings movers get unless told otherwise.
+func DefaultProps() Props {
+	return Props{
+		MaxHeadingChange:  5,
+		MaxVelocityChange: 0.1,
+		StartVelocity:     2.0,
+		SleepInterval:     time.Second,
+		Jitter: JitterProps{
+			Distribution: JitterUniform,
+			Amount:       0.5,
+		},
+		StartRectangle: geo.Rectangle{
+			MinX: -180,
+			MinY: -70,
+			MaxX: 180,
+			MaxY: 70,
+		},
+		StartDensity: DensityProps{
+			Weight: "weight",
+		},
+		Model:    ModelRandom,
+		Boundary: BoundaryWrap,
+		Boids: BoidsProps{
+			NeighborRadius:   10.0,
+			SeparationRadius: 2.0,
+			SeparationWeight: 1.5,
+			AlignmentWeight:  1.0,
+			CohesionWeight:   1.0,
+			MaxTurn:          20,
+		},
+		Convoy: ConvoyProps{
+			Spacing: 0.2,
+			MaxTurn: 30,
+		},
+		Destination: DestinationProps{
+			MaxTurn:       20,
+			ArrivalRadius: 0.5,
+			DwellTime:     10 * time.Second,
+		},
+		Constraint: ConstraintProps{
+			Mode: ConstraintInside,
+		},
+		Obstacles: ObstacleProps{
+			Name:      "name",
+			Lookahead: 1.0,
+			MaxTurn:   30,
+		},
+		Style: StyleProps{
+			Color: ColorPalette,
+			Name:  NameId,
+			Plate: "LLL DDDD",
+		},
+		Safety: SafetyProps{
+			MaxVelocity: 20.0,
+		},
+	}
+}
+
+// Init checks the settings, and fills in any class settings
+// left out, falling back to the single default class.
+func (p *Props) Init() error {
+	switch p.Model {
+	case ModelRandom, ModelBoids, ModelDestination, ModelRoute:
+	default:
+		return fmt.Errorf("unknown movement model '%s'", p.Model)
+	}
+	if err := validBoundary(p.Boundary); err != nil {
+		return err
+	}
+	if err := p.StartDensity.init(); err != nil {
+		return err
+	}
+	if p.Style.MaxSpeed == 0 {
+		p.Style.MaxSpeed = p.TopSpeed()
+	}
+	if err := p.Style.init(); err != nil {
+		return err
+	}
+	if err := p.Obstacles.init(); err != nil {
+		return err
+	}
+	if err := p.Wander.init(); err != nil {
+		return err
+	}
+	if err := p.Jitter.init(); err != nil {
+		return err
+	}
+	if err := p.Altitude.init(); err != nil {
+		return err
+	}
+	if err := p.Physics.init(); err != nil {
+		return err
+	}
+	if err := p.Convoy.init(); err != nil {
+		return err
+	}
+	if p.Gps.Dropout < 0 || p.Gps.Dropout >= 1 {
+		return fmt.Errorf("gps dropout %f is not a probability below 1", p.Gps.Dropout)
+	}
+	if err := p.initRegions(); err != nil {
+		return err
+	}
+	return p.initClasses()
+}
+
+// NewWorld sets up the shared state for movers with the given
+// settings, confined by the constraint if it is not nil.
+func NewWorld(props *Props, constraint *Constraint) *World {
+	return &World{
+		Props:      props,
+		Index:      NewSpatialIndex(props.Boids.NeighborRadius),
+		Constraint: constraint,
+	}
+}
+
+// SetPace scales how far every mover goes each tick,
+// leaving their velocities as they are.
+func (w *World) SetPace(factor float64) {
+	w.pace.Store(math.Float64bits(factor))
+}
+
+// Pace is the current pace factor, one unless set.
+func (w *World) Pace() float64 {
+	bits := w.pace.Load()
+	if bits == 0 {
+		return 1
+	}
+	return math.Float64frombits(bits)
+}
+
+// NewMover makes a mover at a random start position.
+func (w *World) NewMover(moverId int) (Mover, error) {
+	return w.NewMoverIn(moverId, nil)
+}
+
+// NewMoverIn makes a mover at a random start position in
+// the region, or anywhere if the region is nil.
+func (w *World) NewMoverIn(moverId int, region *Region) (Mover, error) {
+	return w.NewMoverOf(moverId, region, nil)
+}
+
+// NewMoverOf makes a mover of the class in the region, or of the
+// class of its id, see Props.ClassFor, if the class is nil.
+func (w *World) NewMoverOf(moverId int, region *Region, class *Class) (Mover, error) {
+	props := w.Props
+	rect := props.StartRectangle
+	if region != nil {
+		if region.File != "" && region.area == nil {
+			return Mover{}, fmt.Errorf("region '%s' area has not been loaded", region.Name)
+		}
+		rect = region.StartRectangle
+	}
+	rng := rand.New(rand.NewSource(moverSeed(w.Seed, moverId)))
+	startHeading := rng.Intn(360)
+	var startX, startY float64
+	if props.Model == ModelRoute && w.Network != nil {
+		// Route movers start out on the streets
+		start := w.Network.Node(w.Network.RandomNode(rng, rect))
+		startX, startY = start.X, start.Y
+	} else if w.Constraint != nil || region != nil || w.Density != nil || w.Zones != nil {
+		// Regions may be much smaller than a degree across
+		start, ok := geo.Point{}, false
+		if w.Density != nil {
+			start, ok = w.densityPoint(rng, rect, region)
+		}
+		if !ok {
+			// Uniformly where the density does not reach
+			start, ok = w.startPoint(rng, rect, region)
+		}
+		if !ok {
+			return Mover{}, fmt.Errorf("no allowed start position for mover %d", moverId)
+		}
+		startX, startY = start.X, start.Y
+	} else {
+		xSize := rect.MaxX - rect.MinX
+		ySize := rect.MaxY - rect.MinY
+		startX = rect.MinX + float64(rng.Intn(int(xSize)))
+		startY = rect.MinY + float64(rng.Intn(int(ySize)))
+	}
+
+	if class == nil {
+		class = props.ClassFor(moverId)
+	}
+	style := w.style(moverId, class)
+	mover := Mover{
+		Id:            moverId,
+		Heading:       startHeading,
+		Velocity:      startVelocity(props, region),
+		X:             startX,
+		Y:             startY,
+		Color:         style.Color,
+		Name:          style.Name,
+		Class:         class,
+		Region:        region,
+		Ts:            time.Now(),
+		SleepInterval: w.sleepInterval(class),
+
+		RolloutRank: rng.Float64(),
+
+		rng: rng,
+	}
+	if region != nil {
+		mover.Fields = make(map[string]interface{}, len(region.Fields)+1)
+		for k, v := range region.Fields {
+			mover.Fields[k] = v
+		}
+		mover.Fields["region"] = region.Name
+	}
+	mover.joinConvoy(w)
+	mover.Velocity = mover.physics(w).clampSpeed(mover.Velocity)
+	mover.recolor(props)
+	mover.startAltitude(props)
+	mover.startAttributes()
+	mover.skewClock(props.Clock, mover.Ts)
+	return mover, nil
+}
+
+// Step moves the mover on by one tick, as of the given true time,
+// and returns the number of repairs its state needed afterwards.
+func (m *Mover) Step(w *World, ts time.Time) int {
+	last := geo.Point{X: m.X, Y: m.Y}
+	m.Ts = ts
+	if m.Frozen {
+		// Still reporting, from where it stopped
+		m.Ticks++
+		return 0
+	}
+	heading, velocity := m.Heading, m.Velocity
+	routed := false
+	switch model := w.Props.Model; {
+	case m.Steered:
+		m.steer(w)
+	case m.HasLeader && m.follow(w):
+		// Keeping its place in the convoy
+	case model == ModelBoids && boidsFeature.Enabled():
+		m.flock(w, w.Index.Neighbors(m.X, m.Y, w.Props.Boids.NeighborRadius))
+	case model == ModelDestination && destinationFeature.Enabled():
+		m.seek(w)
+	case model == ModelRoute && routeFeature.Enabled() && w.Network != nil:
+		// Following the streets, whatever the boundary
+		m.travel(w)
+		routed = true
+	default:
+		m.wander(w.Props)
+	}
+	if !routed {
+		m.limit(w, heading, velocity)
+		// After the physics, so that its turns are not undone
+		m.avoid(w)
+		m.advance(w)
+	}
+	m.enterZone(w)
+	m.recolor(w.Props)
+	m.climb(w)
+	m.evolveAttributes()
+	m.Ticks++
+	return m.sanitize(w.Props, last)
+}
+
+// wander is the original random walk: jitter the heading, or turn
+// it smoothly, see WanderProps, and drift the velocity a little
+// every tick.
+func (m *Mover) wander(props *Props) {
+	m.Heading = NormalizeHeading(m.Heading + m.turn(props))
+	velocityChange := m.rng.NormFloat64() * props.MaxVelocityChange
+	m.Velocity = m.Velocity + velocityChange
+}
+
+// GroundSpeed is the speed of the mover over the ground in meters
+// per second, from a step of its velocity along its heading, see
+// StepMeters, and how often it steps, its SleepInterval, or zero
+// if that is not known.
+func (m *Mover) GroundSpeed() float64 {
+	if m.SleepInterval <= 0 {
+		return 0
+	}
+	return StepMeters(m.Heading, m.Velocity, m.Y) / m.SleepInterval.Seconds()
+}
+
+// advance steps the mover along its heading, meeting the edges of
+// the start rectangle, or of its region, with its boundary behavior.
+// If a constraint layer forbids the step, the mover bounces onto the
+// nearest heading that is allowed.
+func (m *Mover) advance(w *World) {
+	x, y, heading, out := m.nextPosition(w, m.Heading)
+	if !w.Constraint.Allows(x, y) {
+		turned, ok := m.bounce(w)
+		if !ok {
+			// Boxed in, wait for a better heading next tick
+			return
+		}
+		x, y, heading, out = m.nextPosition(w, turned)
+	}
+	if out {
+		m.Exited = true
+		return
+	}
+	m.X = x
+	m.Y = y
+	m.Heading = heading
+}
+
+// nextPosition is where one step along the heading would lead, and
+// the heading the mover would have there, see confine.
+func (m *Mover) nextPosition(w *World, heading int) (float64, float64, int, bool) {
+	x, y := Project(m.X, m.Y, heading, m.Velocity*w.Pace())
+	return m.confine(w.Props, x, y, heading)
+}
@@ -0,0 +1,259 @@
+package mover
+
+import (
+	// System
+	"container/heap"
+	"fmt"
+	"math"
+	"math/rand"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+)
+
+// Link joins two nodes of a network, both ways unless Oneway,
+// in which case only from From to To.
+type Link struct {
+	From   int
+	To     int
+	Oneway bool
+}
+
+// Network is a graph of streets that movers can find their way
+// around, see the route model. Costs are straight line distances
+// in the units of the coordinates. Only the largest connected part
+// of the graph is used for starts and destinations, so that stray
+// fragments of road do not strand movers.
+type Network struct {
+	nodes []geo.Point
+	arcs  [][]arc
+	// Nodes of the largest connected part
+	main []int32
+
+	// Grid over the main nodes, for Nearest
+	cellSize float64
+	cells    map[gridCell][]int32
+}
+
+type arc struct {
+	to   int32
+	cost float64
+}
+
+// NewNetwork builds a network from its nodes and the links
+// between them, given by node index.
+func NewNetwork(nodes []geo.Point, links []Link) (*Network, error) {
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("network has no nodes")
+	}
+	n := &Network{
+		nodes: nodes,
+		arcs:  make([][]arc, len(nodes)),
+	}
+	for _, l := range links {
+		if l.From < 0 || l.To < 0 || l.From >= len(nodes) || l.To >= len(nodes) {
+			return nil, fmt.Errorf("link %d-%d is not between network nodes", l.From, l.To)
+		}
+		if l.From == l.To {
+			continue
+		}
+		a, b := nodes[l.From], nodes[l.To]
+		cost := math.Hypot(b.X-a.X, b.Y-a.Y)
+		n.arcs[l.From] = append(n.arcs[l.From], arc{to: int32(l.To), cost: cost})
+		if !l.Oneway {
+			n.arcs[l.To] = append(n.arcs[l.To], arc{to: int32(l.From), cost: cost})
+		}
+	}
+	n.main = n.largestPart()
+	if len(n.main) < 2 {
+		return nil, fmt.Errorf("network has no connected roads")
+	}
+	n.indexNodes()
+	return n, nil
+}
+
+// Len is the number of nodes movers can start and end up at.
+func (n *Network) Len() int {
+	return len(n.main)
+}
+
+// largestPart finds the largest set of nodes joined by links,
+// whichever way they run.
+func (n *Network) largestPart() []int32 {
+	// Links both ways, to walk the parts
+	undirected := make([][]int32, len(n.nodes))
+	for from, arcs := range n.arcs {
+		for _, a := range arcs {
+			undirected[from] = append(undirected[from], a.to)
+			undirected[a.to] = append(undirected[a.to], int32(from))
+		}
+	}
+	seen := make([]bool, len(n.nodes))
+	var largest []int32
+	for start := range n.nodes {
+		if seen[start] || len(undirected[start]) == 0 {
+			continue
+		}
+		seen[start] = true
+		part := []int32{int32(start)}
+		for i := 0; i < len(part); i++ {
+			for _, next := range undirected[part[i]] {
+				if !seen[next] {
+					seen[next] = true
+					part = append(part, next)
+				}
+			}
+		}
+		if len(part) > len(largest) {
+			largest = part
+		}
+	}
+	return largest
+}
+
+// indexNodes puts the main nodes in a grid of about one per cell.
+func (n *Network) indexNodes() {
+	rect := geo.Rectangle{MinX: math.Inf(1), MinY: math.Inf(1), MaxX: math.Inf(-1), MaxY: math.Inf(-1)}
+	for _, i := range n.main {
+		p := n.nodes[i]
+		rect.MinX, rect.MaxX = math.Min(rect.MinX, p.X), math.Max(rect.MaxX, p.X)
+		rect.MinY, rect.MaxY = math.Min(rect.MinY, p.Y), math.Max(rect.MaxY, p.Y)
+	}
+	area := math.Max((rect.MaxX-rect.MinX)*(rect.MaxY-rect.MinY), 1e-12)
+	n.cellSize = math.Sqrt(area / float64(len(n.main)))
+	n.cells = make(map[gridCell][]int32)
+	for _, i := range n.main {
+		cell := n.cellFor(n.nodes[i])
+		n.cells[cell] = append(n.cells[cell], i)
+	}
+}
+
+func (n *Network) cellFor(p geo.Point) gridCell {
+	return gridCell{
+		I: int(math.Floor(p.X / n.cellSize)),
+		J: int(math.Floor(p.Y / n.cellSize)),
+	}
+}
+
+// Node is the position of a node.
+func (n *Network) Node(i int) geo.Point {
+	return n.nodes[i]
+}
+
+// Nearest is the main node closest to the point.
+func (n *Network) Nearest(p geo.Point) int {
+	center := n.cellFor(p)
+	best, bestDist := -1, math.Inf(1)
+	// Search rings of cells outward until no closer node can be
+	// in the next ring
+	for ring := 0; ; ring++ {
+		for i := center.I - ring; i <= center.I+ring; i++ {
+			for j := center.J - ring; j <= center.J+ring; j++ {
+				if i != center.I-ring && i != center.I+ring && j != center.J-ring && j != center.J+ring {
+					continue
+				}
+				for _, k := range n.cells[gridCell{I: i, J: j}] {
+					q := n.nodes[k]
+					if d := math.Hypot(q.X-p.X, q.Y-p.Y); d < bestDist {
+						best, bestDist = int(k), d
+					}
+				}
+			}
+		}
+		if best >= 0 && bestDist <= float64(ring)*n.cellSize {
+			return best
+		}
+		if ring > len(n.main) {
+			// Far away from the whole network
+			break
+		}
+	}
+	if best < 0 {
+		best = int(n.main[0])
+	}
+	return best
+}
+
+// RandomNode picks a main node with the random numbers, inside
+// the rectangle if any are to be found there.
+func (n *Network) RandomNode(rng *rand.Rand, rect geo.Rectangle) int {
+	for tries := 0; tries < 100; tries++ {
+		i := n.main[rng.Intn(len(n.main))]
+		p := n.nodes[i]
+		if p.X >= rect.MinX && p.X <= rect.MaxX && p.Y >= rect.MinY && p.Y <= rect.MaxY {
+			return int(i)
+		}
+	}
+	return n.Nearest(geo.Point{X: (rect.MinX + rect.MaxX) / 2, Y: (rect.MinY + rect.MaxY) / 2})
+}
+
+// Route finds the shortest path between two nodes with A*,
+// returning the positions along it after the first, or false
+// if there is no way there.
+func (n *Network) Route(from, to int) ([]geo.Point, bool) {
+	if from == to {
+		return nil, true
+	}
+	goal := n.nodes[to]
+	estimate := func(i int32) float64 {
+		p := n.nodes[i]
+		return math.Hypot(goal.X-p.X, goal.Y-p.Y)
+	}
+	cost := map[int32]float64{int32(from): 0}
+	previous := make(map[int32]int32)
+	open := &searchQueue{{node: int32(from), priority: estimate(int32(from))}}
+	for open.Len() > 0 {
+		current := heap.Pop(open).(searchItem)
+		if current.node == int32(to) {
+			break
+		}
+		if current.priority > cost[current.node]+estimate(current.node) {
+			// Already reached more cheaply
+			continue
+		}
+		for _, a := range n.arcs[current.node] {
+			c := cost[current.node] + a.cost
+			if known, ok := cost[a.to]; ok && known <= c {
+				continue
+			}
+			cost[a.to] = c
+			previous[a.to] = current.node
+			heap.Push(open, searchItem{node: a.to, priority: c + estimate(a.to)})
+		}
+	}
+	if _, ok := previous[int32(to)]; !ok {
+		return nil, false
+	}
+	var path []geo.Point
+	for i := int32(to); i != int32(from); i = previous[i] {
+		path = append(path, n.nodes[i])
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, true
+}
+
+type searchItem struct {
+	node     int32
+	priority float64
+}
+
+// searchQueue is a heap of nodes to visit, the most
+// promising first.
+type searchQueue []searchItem
+
+func (q searchQueue) Len() int           { return len(q) }
+func (q searchQueue) Less(i, j int) bool { return q[i].priority < q[j].priority }
+func (q searchQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *searchQueue) Push(x interface{}) {
+	*q = append(*q, x.(searchItem))
+}
+
+func (q *searchQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,149 @@
+package mover
+
+import (
+	// System
+	"fmt"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+)
+
+// ObstacleProps names a layer of no-go zones, such as construction
+// sites or restricted airspace, that movers steer around, read from
+// a GeoJSON File or from a database Query returning a geometry
+// column, like the constraint layer. Each zone is named by its Name
+// property or column, for the violation events of movers that end
+// up inside one anyway. Every tick a mover looks Lookahead degrees
+// (at least one step) along its heading and, if that leads into a
+// zone, turns by at most MaxTurn degrees toward the nearest heading
+// that stays clear. Loading the layer is up to the program, see
+// NewZones.
+type ObstacleProps struct {
+	File      string
+	Query     string
+	Name      string
+	Lookahead float64
+	MaxTurn   int
+}
+
+func (o ObstacleProps) init() error {
+	if o.File != "" && o.Query != "" {
+		return fmt.Errorf("obstacles take one of a file or a query")
+	}
+	if o.Lookahead < 0 {
+		return fmt.Errorf("obstacle lookahead %f is negative", o.Lookahead)
+	}
+	if o.MaxTurn <= 0 || o.MaxTurn > 180 {
+		return fmt.Errorf("obstacle max turn %d is not between 1 and 180 degrees", o.MaxTurn)
+	}
+	return nil
+}
+
+// Zones is a loaded layer of no-go zones.
+type Zones struct {
+	zones []zone
+}
+
+type zone struct {
+	name   string
+	index  *geo.PolygonIndex
+	extent geo.Rectangle
+}
+
+// zoneProbes is how many points along the lookahead are tested.
+const zoneProbes = 4
+
+// NewZones builds the no-go zones from their names and polygons,
+// with zones unnamed given their number, from one.
+func NewZones(names []string, polygons [][]geo.Polygon) (*Zones, error) {
+	if len(names) != len(polygons) {
+		return nil, fmt.Errorf("%d zone names for %d zones", len(names), len(polygons))
+	}
+	z := &Zones{}
+	for i, p := range polygons {
+		if len(p) == 0 {
+			continue
+		}
+		name := names[i]
+		if name == "" {
+			name = fmt.Sprintf("zone %d", i+1)
+		}
+		index := geo.NewPolygonIndex(p)
+		z.zones = append(z.zones, zone{name: name, index: index, extent: index.Extent()})
+	}
+	if len(z.zones) == 0 {
+		return nil, fmt.Errorf("obstacle layer has no polygons")
+	}
+	return z, nil
+}
+
+// Len is the number of zones.
+func (z *Zones) Len() int {
+	return len(z.zones)
+}
+
+// Zone is the name of the zone the point falls in, if any.
+func (z *Zones) Zone(x, y float64) (string, bool) {
+	if z == nil {
+		return "", false
+	}
+	for _, zone := range z.zones {
+		if contains(zone.extent, x, y) && zone.index.Contains(x, y) {
+			return zone.name, true
+		}
+	}
+	return "", false
+}
+
+// Allows reports whether the point is clear of every zone.
+func (z *Zones) Allows(x, y float64) bool {
+	_, in := z.Zone(x, y)
+	return !in
+}
+
+// avoid steers the mover around the no-go zones: if its heading
+// leads into one within the lookahead, it turns toward the nearest
+// heading that does not, trying the left before the right so it
+// keeps to one side of the zone from tick to tick. It turns by its
+// own MaxTurn, whatever that of the physics, which would otherwise
+// hold movers on a heading into the zone. A mover already inside
+// a zone carries on until it is out.
+func (m *Mover) avoid(w *World) {
+	props := w.Props.Obstacles
+	if w.Zones == nil || !w.Zones.Allows(m.X, m.Y) {
+		return
+	}
+	lookahead := props.Lookahead
+	if step := m.Velocity * w.Pace(); step > lookahead {
+		lookahead = step
+	}
+	if m.clearAhead(w.Zones, m.Heading, lookahead) {
+		return
+	}
+	for turn := 10; turn <= 180; turn += 10 {
+		for _, dir := range []int{1, -1} {
+			heading := NormalizeHeading(m.Heading + dir*turn)
+			if m.clearAhead(w.Zones, heading, lookahead) {
+				m.Heading = TurnToward(m.Heading, heading, props.MaxTurn)
+				return
+			}
+		}
+	}
+}
+
+// clearAhead reports whether the way along the heading is clear
+// of zones for the distance.
+func (m *Mover) clearAhead(z *Zones, heading int, distance float64) bool {
+	for i := 1; i <= zoneProbes; i++ {
+		x, y := Project(m.X, m.Y, heading, distance*float64(i)/zoneProbes)
+		if !z.Allows(x, y) {
+			return false
+		}
+	}
+	return true
+}
+
+// enterZone notes the no-go zone the mover is in now, if any.
+func (m *Mover) enterZone(w *World) {
+	m.Zone, _ = w.Zones.Zone(m.X, m.Y)
+}
@@ -0,0 +1,40 @@
+package mover
+
+import (
+	// System
+	"testing"
+	"time"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+)
+
+func TestAvoidBeyondPhysics(t *testing.T) {
+	props := DefaultProps()
+	props.Physics.MaxTurn = 1
+	props.Obstacles.MaxTurn = 30
+	if err := props.Init(); err != nil {
+		t.Fatal(err)
+	}
+	w := NewWorld(&props, nil)
+	square := geo.Polygon{{{X: -0.5, Y: 0.5}, {X: 0.5, Y: 0.5}, {X: 0.5, Y: 1.5}, {X: -0.5, Y: 1.5}, {X: -0.5, Y: 0.5}}}
+	zones, err := NewZones([]string{"site"}, [][]geo.Polygon{{square}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Zones = zones
+	m, err := w.NewMover(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Heading north, straight into the zone
+	m.X, m.Y, m.Heading, m.Velocity = 0, 0, 0, 0.01
+	m.Step(w, time.Now())
+	turn := NormalizeHeading(m.Heading)
+	if turn > 180 {
+		turn = 360 - turn
+	}
+	if turn <= props.Physics.MaxTurn {
+		t.Errorf("mover turned to heading %d, want it to steer clear beyond the physics MaxTurn", m.Heading)
+	}
+}
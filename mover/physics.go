@@ -0,0 +1,67 @@
+package mover
+
+import (
+	// System
+	"fmt"
+	"math"
+)
+
+// PhysicsProps keeps trajectories kinematically plausible. Speeds
+// stay between MinSpeed and MaxSpeed, in degrees per tick, change
+// by at most MaxAcceleration a tick, and headings turn by at most
+// MaxTurn degrees a tick, whatever the movement model asks for.
+// Zero leaves a limit off. Speeds never go negative, and movers
+// can always stop, say on reaching a destination.
+type PhysicsProps struct {
+	MinSpeed        float64
+	MaxSpeed        float64
+	MaxAcceleration float64
+	MaxTurn         int
+}
+
+func (p PhysicsProps) set() bool {
+	return p != PhysicsProps{}
+}
+
+func (p PhysicsProps) init() error {
+	if p.MinSpeed < 0 || p.MaxSpeed < 0 || p.MaxAcceleration < 0 || p.MaxTurn < 0 {
+		return fmt.Errorf("physics limits must not be negative")
+	}
+	if p.MaxSpeed > 0 && p.MinSpeed > p.MaxSpeed {
+		return fmt.Errorf("minimum speed %g is above the maximum %g", p.MinSpeed, p.MaxSpeed)
+	}
+	return nil
+}
+
+// physics is the physics of the mover class, or of the fleet,
+// as tuned, see World.Tune.
+func (m *Mover) physics(w *World) PhysicsProps {
+	return w.physics(m.Class)
+}
+
+// clampSpeed keeps a speed within the limits.
+func (p PhysicsProps) clampSpeed(v float64) float64 {
+	v = math.Max(v, p.MinSpeed)
+	if p.MaxSpeed > 0 {
+		v = math.Min(v, p.MaxSpeed)
+	}
+	return v
+}
+
+// limit holds the heading and velocity the movement model chose
+// to what the physics allows from where the mover was last tick.
+func (m *Mover) limit(w *World, heading int, velocity float64) {
+	p := m.physics(w)
+	if p.MaxTurn > 0 {
+		m.Heading = TurnToward(heading, m.Heading, p.MaxTurn)
+	}
+	if m.Velocity == 0 {
+		// Stopping is always allowed
+		return
+	}
+	v := m.Velocity
+	if p.MaxAcceleration > 0 {
+		v = math.Max(velocity-p.MaxAcceleration, math.Min(velocity+p.MaxAcceleration, v))
+	}
+	m.Velocity = p.clampSpeed(v)
+}
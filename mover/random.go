@@ -0,0 +1,21 @@
+package mover
+
+import (
+	// System
+	"math/rand"
+)
+
+// moverSeed mixes the seed of the world with the id of a mover,
+// so that movers with neighbouring ids draw unrelated numbers.
+func moverSeed(seed int64, id int) int64 {
+	z := uint64(seed) + uint64(id)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return int64(z ^ (z >> 31))
+}
+
+// Rand is the source of the random numbers of the mover, which
+// only the goroutine moving it may draw from.
+func (m *Mover) Rand() *rand.Rand {
+	return m.rng
+}
@@ -0,0 +1,61 @@
+package mover
+
+import (
+	// System
+	"testing"
+	"time"
+)
+
+// moveFleet starts movers in a world seeded with the seed and
+// moves them, each as far as the others, in the order given.
+func moveFleet(t *testing.T, seed int64, ids []int) map[int]Mover {
+	t.Helper()
+	props := DefaultProps()
+	if err := props.Init(); err != nil {
+		t.Fatal(err)
+	}
+	w := NewWorld(&props, nil)
+	w.Seed = seed
+	movers := make(map[int]Mover, len(ids))
+	for _, id := range ids {
+		m, err := w.NewMover(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		movers[id] = m
+	}
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for tick := 1; tick <= 50; tick++ {
+		for _, id := range ids {
+			m := movers[id]
+			m.Step(w, ts.Add(time.Duration(tick)*time.Second))
+			movers[id] = m
+		}
+	}
+	return movers
+}
+
+func TestSeededMovers(t *testing.T) {
+	a := moveFleet(t, 7, []int{1, 2, 3, 4})
+	b := moveFleet(t, 7, []int{4, 2, 1, 3})
+	for id, m := range a {
+		if m.X != b[id].X || m.Y != b[id].Y || m.Heading != b[id].Heading || m.Velocity != b[id].Velocity {
+			t.Errorf("mover %d: moved to %.6f %.6f, and with the same seed to %.6f %.6f", id, m.X, m.Y, b[id].X, b[id].Y)
+		}
+	}
+	c := moveFleet(t, 8, []int{1})
+	if c[1].X == a[1].X && c[1].Y == a[1].Y {
+		t.Errorf("mover 1 moved to %.6f %.6f with either seed", c[1].X, c[1].Y)
+	}
+}
+
+func TestMoverSeed(t *testing.T) {
+	seen := make(map[int64]int)
+	for id := 0; id < 1000; id++ {
+		seed := moverSeed(7, id)
+		if other, ok := seen[seed]; ok {
+			t.Fatalf("movers %d and %d have the same seed", other, id)
+		}
+		seen[seed] = id
+	}
+}
@@ -0,0 +1,101 @@
+package mover
+
+import (
+	// System
+	"fmt"
+	"math/rand"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+)
+
+// Region is a named area with its own share of the fleet, such as
+// a city. Count movers start inside it, within the StartRectangle
+// or the polygons of a GeoJSON File, and meet its edges rather
+// than those of the world, see Props.Boundary. StartVelocity, if set, replaces
+// the global one, and Fields are added to the payload of every
+// mover in the region, along with the region name. Loading the
+// polygons is up to the program, see SetArea.
+type Region struct {
+	Name           string
+	Count          int
+	StartRectangle geo.Rectangle
+	File           string
+	StartVelocity  float64
+	Fields         map[string]interface{}
+
+	area *Constraint
+}
+
+// SetArea confines the starting points of the region to the
+// polygons, and takes their extent as the rectangle of the
+// region if it has none.
+func (r *Region) SetArea(polygons []geo.Polygon) error {
+	area, err := NewConstraint(polygons, ConstraintInside)
+	if err != nil {
+		return fmt.Errorf("region '%s': %w", r.Name, err)
+	}
+	r.area = area
+	if r.StartRectangle == (geo.Rectangle{}) {
+		r.StartRectangle = area.index.Extent()
+	}
+	return nil
+}
+
+// RegionNamed finds a region by name, or returns nil.
+func (p *Props) RegionNamed(name string) *Region {
+	for _, region := range p.Regions {
+		if region.Name == name {
+			return region
+		}
+	}
+	return nil
+}
+
+// initRegions checks the region settings.
+func (p *Props) initRegions() error {
+	names := make(map[string]bool, len(p.Regions))
+	for i, region := range p.Regions {
+		if region.Name == "" {
+			return fmt.Errorf("region %d has no name", i)
+		}
+		if names[region.Name] {
+			return fmt.Errorf("region '%s' is defined twice", region.Name)
+		}
+		names[region.Name] = true
+		if region.Count < 0 {
+			return fmt.Errorf("region '%s' has a negative count", region.Name)
+		}
+		if region.StartRectangle == (geo.Rectangle{}) && region.File == "" {
+			return fmt.Errorf("region '%s' has neither a StartRectangle nor a File", region.Name)
+		}
+	}
+	return nil
+}
+
+// startPoint picks a random point in the rectangle that both the
+// constraint layer and the area of the region, if any, allow,
+// clear of the no-go zones.
+func (w *World) startPoint(rng *rand.Rand, rect geo.Rectangle, region *Region) (geo.Point, bool) {
+	var area *Constraint
+	if region != nil {
+		area = region.area
+	}
+	return w.Constraint.RandomPoint(rng, rect, area.Allows, w.Zones.Allows)
+}
+
+// startVelocity is the velocity movers in the region start at.
+func startVelocity(props *Props, region *Region) float64 {
+	if region != nil && region.StartVelocity > 0 {
+		return region.StartVelocity
+	}
+	return props.StartVelocity
+}
+
+// bounds is the rectangle the mover wraps around in.
+func (m *Mover) bounds(props *Props) geo.Rectangle {
+	if m.Region != nil {
+		return m.Region.StartRectangle
+	}
+	return props.StartRectangle
+}
@@ -0,0 +1,65 @@
+package mover
+
+import (
+	// System
+	"sort"
+	"time"
+)
+
+// RolloutProps is one stage of a simulated tracker firmware
+// rollout. At the given time after the start of the run, Fraction
+// of the fleet installs the Firmware: those movers go offline for
+// the Offline window, then report every SleepInterval (if set) with
+// the extra payload Fields. Stages of one rollout share a Firmware
+// name with a growing Fraction, and a mover updated in an early
+// stage stays updated.
+type RolloutProps struct {
+	Firmware      string
+	At            time.Duration
+	Fraction      float64
+	SleepInterval time.Duration
+	Offline       time.Duration
+	Fields        map[string]interface{}
+}
+
+// SortRollouts puts the rollout stages in time order.
+func SortRollouts(rollouts []RolloutProps) {
+	sort.SliceStable(rollouts, func(i, j int) bool {
+		return rollouts[i].At < rollouts[j].At
+	})
+}
+
+// ApplyRollouts installs any of the time ordered rollout stages
+// that have come due since the last tick, elapsed being the time
+// since the start.
+func (m *Mover) ApplyRollouts(rollouts []RolloutProps, elapsed time.Duration) {
+	for ; m.NextRollout < len(rollouts); m.NextRollout++ {
+		r := rollouts[m.NextRollout]
+		if r.At > elapsed {
+			return
+		}
+		if m.Firmware == r.Firmware {
+			// Installed already, as on a mover adopted from an
+			// earlier run, it still reports at its interval
+			if r.SleepInterval > 0 {
+				m.SleepInterval = r.SleepInterval
+			}
+			continue
+		}
+		if m.RolloutRank >= r.Fraction {
+			continue
+		}
+		m.Logger().Debugf("Installing firmware %s", r.Firmware)
+		m.Firmware = r.Firmware
+		m.OfflineUntil = m.Ts.Add(r.Offline)
+		if r.SleepInterval > 0 {
+			m.SleepInterval = r.SleepInterval
+		}
+		fields := m.copyFields(len(r.Fields) + 1)
+		for k, v := range r.Fields {
+			fields[k] = v
+		}
+		fields["firmware"] = r.Firmware
+		m.Fields = fields
+	}
+}
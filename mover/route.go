@@ -0,0 +1,81 @@
+package mover
+
+import (
+	// System
+	"math"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+)
+
+// RouteProps names the street network for the route model, an
+// OpenStreetMap .osm.pbf extract of which only the ways tagged
+// highway are used, or only those of the listed Highways kinds
+// (such as "primary" or "residential") when any are given.
+// Loading the network is up to the program, see NewNetwork.
+type RouteProps struct {
+	File     string
+	Highways []string
+}
+
+// routeTries is how many destinations planRoute tries before
+// giving up on the mover until the next tick.
+const routeTries = 5
+
+// planRoute finds the way from the nearest network node to a
+// random one in the mover bounds, reporting whether there is one.
+func (m *Mover) planRoute(w *World) bool {
+	network := w.Network
+	from := network.Nearest(geo.Point{X: m.X, Y: m.Y})
+	for tries := 0; tries < routeTries; tries++ {
+		to := network.RandomNode(m.rng, m.bounds(w.Props))
+		if to == from {
+			continue
+		}
+		if route, ok := network.Route(from, to); ok {
+			// Get onto the network first if off it
+			start := network.Node(from)
+			if start.X != m.X || start.Y != m.Y {
+				route = append([]geo.Point{start}, route...)
+			}
+			m.Route = route
+			return true
+		}
+	}
+	return false
+}
+
+// travel moves the mover along its route at its cruise velocity,
+// going round corners within the tick, and waits the destination
+// dwell time at the end before setting off on a new route.
+func (m *Mover) travel(w *World) {
+	if len(m.Route) == 0 {
+		if m.Ts.Before(m.DwellUntil) || !m.planRoute(w) {
+			m.Velocity = 0
+			return
+		}
+	}
+	m.Velocity = m.cruise(w.Props)
+	left := m.Velocity * w.Pace()
+	for left > 0 && len(m.Route) > 0 {
+		next := m.Route[0]
+		dx, dy := next.X-m.X, next.Y-m.Y
+		dist := math.Hypot(dx, dy)
+		if dist > 0 {
+			m.Heading = VectorHeading(dx, dy)
+		}
+		if dist > left {
+			m.X += dx / dist * left
+			m.Y += dy / dist * left
+			break
+		}
+		m.X, m.Y = next.X, next.Y
+		left -= dist
+		// Never changed in place, copies of the mover share it
+		m.Route = m.Route[1:]
+	}
+	if len(m.Route) == 0 {
+		m.Route = nil
+		m.DwellUntil = m.Ts.Add(w.Props.Destination.DwellTime)
+	}
+}
@@ -0,0 +1,69 @@
+package mover
+
+import (
+	// System
+	"math"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+)
+
+// SafetyProps bounds the mover state allowed to reach a sink.
+// Velocities above MaxVelocity are clamped to it.
+type SafetyProps struct {
+	MaxVelocity float64
+}
+
+// TopSpeed is the fastest movers can go, in degrees per tick: the
+// MaxSpeed of the physics if set, or else the MaxVelocity of the
+// safety checks, zero if neither is.
+func (p *Props) TopSpeed() float64 {
+	if p.Physics.MaxSpeed > 0 && (p.Safety.MaxVelocity <= 0 || p.Physics.MaxSpeed < p.Safety.MaxVelocity) {
+		return p.Physics.MaxSpeed
+	}
+	return p.Safety.MaxVelocity
+}
+
+func validCoordinate(x, y float64) bool {
+	return !math.IsNaN(x) && !math.IsNaN(y) &&
+		x >= -180 && x <= 180 && y >= -90 && y <= 90
+}
+
+// sanitize repairs any mover state that would write nonsense to the
+// sinks, such as NaN or out of range coordinates after a long
+// velocity drift, falling back to the last good position. It
+// returns the number of repairs made.
+func (m *Mover) sanitize(props *Props, last geo.Point) int {
+	repairs := 0
+	if math.IsNaN(m.Velocity) || math.IsInf(m.Velocity, 0) {
+		m.Logger().Warnf("Invalid velocity %f, resetting", m.Velocity)
+		m.Velocity = props.StartVelocity
+		repairs++
+	}
+	if m.Velocity < 0 {
+		// Moving backwards is moving forwards the other way
+		m.Velocity = -m.Velocity
+		m.Heading = NormalizeHeading(m.Heading + 180)
+		repairs++
+	}
+	if max := props.Safety.MaxVelocity; max > 0 && m.Velocity > max {
+		m.Logger().Warnf("Velocity %f exceeds %f, clamping", m.Velocity, max)
+		m.Velocity = max
+		repairs++
+	}
+	if m.Heading < 0 || m.Heading >= 360 {
+		m.Heading = NormalizeHeading(m.Heading)
+		repairs++
+	}
+	if !validCoordinate(m.X, m.Y) {
+		m.Logger().Warnf("Invalid position (%f, %f), restoring (%f, %f)", m.X, m.Y, last.X, last.Y)
+		if validCoordinate(last.X, last.Y) {
+			m.X, m.Y = last.X, last.Y
+		} else {
+			rect := props.StartRectangle
+			m.X, m.Y = (rect.MinX+rect.MaxX)/2, (rect.MinY+rect.MaxY)/2
+		}
+		repairs++
+	}
+	return repairs
+}
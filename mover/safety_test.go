@@ -0,0 +1,32 @@
+package mover
+
+import (
+	// System
+	"testing"
+)
+
+func TestTopSpeed(t *testing.T) {
+	tests := []struct {
+		physics, safety, style float64
+		want                   float64
+	}{
+		{0, 20, 0, 20},
+		{3, 20, 0, 3},
+		{30, 20, 0, 20},
+		{3, 0, 0, 3},
+		{3, 20, 5, 5},
+	}
+	for _, test := range tests {
+		props := DefaultProps()
+		props.Physics.MaxSpeed = test.physics
+		props.Safety.MaxVelocity = test.safety
+		props.Style.MaxSpeed = test.style
+		if err := props.Init(); err != nil {
+			t.Fatal(err)
+		}
+		if props.Style.MaxSpeed != test.want {
+			t.Errorf("physics %g, safety %g, style %g: ramp tops out at %g, want %g",
+				test.physics, test.safety, test.style, props.Style.MaxSpeed, test.want)
+		}
+	}
+}
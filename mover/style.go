@@ -0,0 +1,225 @@
+package mover
+
+import (
+	// System
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// StyleProps picks the colors and names movers are drawn with.
+//
+// Color is the color strategy: "palette" cycles through the Colors
+// (by default a built-in list of CSS colors) by id, "class" gives
+// movers the Color of their class, or a palette color for classes
+// without one, and "speed" recolors movers every tick along the
+// Ramp of colors, from slowest at MinSpeed to fastest at MaxSpeed
+// (degrees per tick). MaxSpeed left at zero is the fastest movers
+// can go, the Physics MaxSpeed if set, or else the Safety
+// MaxVelocity, see Props.TopSpeed.
+//
+// Name is the naming strategy: "id" names movers by their number,
+// "pool" takes names from Names (by default a built-in list of ship
+// names), numbering them once they run out, and "plate" makes
+// license plates to the Plate pattern, in which every L is a letter
+// and every D a digit.
+//
+// Colors and names follow from the mover id, and are the same from
+// run to run. Persist keeps them in the database as well, so they
+// stay the same even if the settings change, see Styles.
+type StyleProps struct {
+	Color    string
+	Colors   []string
+	Ramp     []string
+	MinSpeed float64
+	MaxSpeed float64
+	Name     string
+	Names    []string
+	Plate    string
+	Persist  bool
+}
+
+// Color strategies
+const (
+	ColorPalette = "palette"
+	ColorClass   = "class"
+	ColorSpeed   = "speed"
+)
+
+// Naming strategies
+const (
+	NameId    = "id"
+	NamePool  = "pool"
+	NamePlate = "plate"
+)
+
+var colorList = []string{
+	"aqua", "fuchsia", "lime", "maroon", "red",
+	"orange", "yellow", "green", "blue", "indigo", "violet",
+	"navy", "purple", "teal", "greenyellow", "darkred", "cyan",
+	"darkcyan", "darkorange", "lightpink", "salmon", "slategray",
+}
+
+var rampList = []string{"blue", "teal", "green", "yellow", "orange", "red"}
+
+var shipNames = []string{
+	"Aurora", "Albatross", "Bluefin", "Boreas", "Calypso", "Cormorant",
+	"Dauntless", "Discovery", "Endeavour", "Egret", "Fair Wind", "Falcon",
+	"Gannet", "Golden Hind", "Halcyon", "Heron", "Intrepid", "Islander",
+	"Juniper", "Kestrel", "Kingfisher", "Larkspur", "Mariner", "Meridian",
+	"Narwhal", "Northern Star", "Osprey", "Orca", "Pelican", "Polaris",
+	"Puffin", "Resolute", "Sea Breeze", "Serenity", "Sandpiper", "Tern",
+	"Tidewater", "Valiant", "Voyager", "Wanderer", "Westwind", "Zephyr",
+}
+
+func (s *StyleProps) init() error {
+	switch s.Color {
+	case ColorPalette, ColorClass, ColorSpeed:
+	default:
+		return fmt.Errorf("unknown color strategy '%s'", s.Color)
+	}
+	switch s.Name {
+	case NameId, NamePool:
+	case NamePlate:
+		if !strings.ContainsAny(s.Plate, "LD") {
+			return fmt.Errorf("plate pattern '%s' has no L or D to fill in", s.Plate)
+		}
+	default:
+		return fmt.Errorf("unknown naming strategy '%s'", s.Name)
+	}
+	if s.Color == ColorSpeed && s.MaxSpeed <= s.MinSpeed {
+		return fmt.Errorf("color ramp MaxSpeed %f is not above MinSpeed %f", s.MaxSpeed, s.MinSpeed)
+	}
+	if len(s.Colors) == 0 {
+		s.Colors = colorList
+	}
+	if len(s.Ramp) == 0 {
+		s.Ramp = rampList
+	}
+	if len(s.Names) == 0 {
+		s.Names = shipNames
+	}
+	return nil
+}
+
+// Style is how a mover is drawn: its color and its name.
+type Style struct {
+	Color string
+	Name  string
+}
+
+// Styles remembers the style of every mover that has had one, so
+// that it keeps it, whatever the settings say, for as long as the
+// styles are kept, such as in the database. Safe for concurrent use.
+type Styles struct {
+	mutex  sync.Mutex
+	styles map[int]Style
+	added  map[int]Style
+}
+
+// NewStyles remembers the styles, by mover id.
+func NewStyles(styles map[int]Style) *Styles {
+	if styles == nil {
+		styles = make(map[int]Style)
+	}
+	return &Styles{styles: styles, added: make(map[int]Style)}
+}
+
+// Len is the number of styles remembered.
+func (s *Styles) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.styles)
+}
+
+// Added hands over the styles remembered since the last call,
+// those still to be kept, by mover id.
+func (s *Styles) Added() map[int]Style {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	added := s.added
+	s.added = make(map[int]Style)
+	return added
+}
+
+// lookup is the style remembered for the mover, or else the one
+// given, which is remembered from now on.
+func (s *Styles) lookup(moverId int, style Style) Style {
+	if s == nil {
+		return style
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if known, ok := s.styles[moverId]; ok {
+		return known
+	}
+	s.styles[moverId] = style
+	s.added[moverId] = style
+	return style
+}
+
+// style is how the mover of the id and class is drawn, by the
+// strategies, or as it was before if the styles are remembered.
+func (w *World) style(moverId int, class *Class) Style {
+	props := &w.Props.Style
+	style := Style{Color: props.Colors[moverId%len(props.Colors)]}
+	if props.Color == ColorClass && class.Color != "" {
+		style.Color = class.Color
+	}
+	switch props.Name {
+	case NamePool:
+		style.Name = props.Names[moverId%len(props.Names)]
+		if round := moverId / len(props.Names); round > 0 {
+			style.Name = fmt.Sprintf("%s %d", style.Name, round+1)
+		}
+	case NamePlate:
+		style.Name = plate(props.Plate, moverId)
+	default:
+		style.Name = fmt.Sprintf("Object %d", moverId)
+	}
+	return w.Styles.lookup(moverId, style)
+}
+
+// plate fills in the pattern with letters and digits drawn
+// from a hash of the id, the same every time.
+func plate(pattern string, moverId int) string {
+	state := uint64(moverId)
+	var b strings.Builder
+	for _, c := range pattern {
+		switch c {
+		case 'L':
+			b.WriteByte('A' + byte(splitmix(&state)%26))
+		case 'D':
+			b.WriteByte('0' + byte(splitmix(&state)%10))
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// splitmix is the SplitMix64 generator, stepping the state.
+func splitmix(state *uint64) uint64 {
+	*state += 0x9e3779b97f4a7c15
+	z := *state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// recolor sets the color of the mover from the ramp by its
+// velocity, with the speed color strategy.
+func (m *Mover) recolor(props *Props) {
+	style := &props.Style
+	if style.Color != ColorSpeed {
+		return
+	}
+	n := len(style.Ramp)
+	i := int(float64(n) * (m.Velocity - style.MinSpeed) / (style.MaxSpeed - style.MinSpeed))
+	if i < 0 {
+		i = 0
+	} else if i >= n {
+		i = n - 1
+	}
+	m.Color = style.Ramp[i]
+}
@@ -0,0 +1,113 @@
+package mover
+
+import (
+	// System
+	"fmt"
+	"time"
+)
+
+// Tuning is the part of the mover settings that can change while
+// the movers run, see World.Tune: how often they report, and the
+// limits of their physics, for the fleet and, by name, for each
+// class. Class settings left at zero fall back on the fleet ones.
+type Tuning struct {
+	SleepInterval time.Duration
+	Physics       PhysicsProps
+	Classes       map[string]ClassTuning
+}
+
+// ClassTuning is the tuning of the movers of one class.
+type ClassTuning struct {
+	SleepInterval time.Duration
+	Physics       PhysicsProps
+}
+
+// Tuning is the tuning the settings start out with.
+func (p *Props) Tuning() Tuning {
+	t := Tuning{
+		SleepInterval: p.SleepInterval,
+		Physics:       p.Physics,
+		Classes:       make(map[string]ClassTuning, len(p.Classes)),
+	}
+	for _, class := range p.Classes {
+		t.Classes[class.Name] = ClassTuning{
+			SleepInterval: class.SleepInterval,
+			Physics:       class.Physics,
+		}
+	}
+	return t
+}
+
+func (t Tuning) check() error {
+	if t.SleepInterval <= 0 {
+		return fmt.Errorf("sleep interval %s is not positive", t.SleepInterval)
+	}
+	if err := t.Physics.init(); err != nil {
+		return err
+	}
+	for name, class := range t.Classes {
+		if class.SleepInterval < 0 {
+			return fmt.Errorf("mover class '%s' has a negative sleep interval", name)
+		}
+		if err := class.Physics.init(); err != nil {
+			return fmt.Errorf("mover class '%s': %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Tune changes the tuning of the movers. New movers start out with
+// it, and running movers take it on with Retune.
+func (w *World) Tune(t Tuning) error {
+	if err := t.check(); err != nil {
+		return err
+	}
+	w.tuning.Store(&t)
+	return nil
+}
+
+// sleepInterval is how often movers of the class report.
+func (w *World) sleepInterval(class *Class) time.Duration {
+	t := w.tuning.Load()
+	if t == nil {
+		if class != nil && class.SleepInterval > 0 {
+			return class.SleepInterval
+		}
+		return w.Props.SleepInterval
+	}
+	if class != nil && t.Classes[class.Name].SleepInterval > 0 {
+		return t.Classes[class.Name].SleepInterval
+	}
+	return t.SleepInterval
+}
+
+// physics is the physics of the mover class, or of the fleet.
+func (w *World) physics(class *Class) PhysicsProps {
+	t := w.tuning.Load()
+	if t == nil {
+		if class != nil && class.Physics.set() {
+			return class.Physics
+		}
+		return w.Props.Physics
+	}
+	if class != nil && t.Classes[class.Name].Physics.set() {
+		return t.Classes[class.Name].Physics
+	}
+	return t.Physics
+}
+
+// Retune brings a running mover in line with the tuning of the
+// world, keeping the reporting interval of any firmware installed
+// by the rollouts, see ApplyRollouts.
+func (m *Mover) Retune(w *World, rollouts []RolloutProps) {
+	m.SleepInterval = w.sleepInterval(m.Class)
+	for i := 0; i < m.NextRollout && i < len(rollouts); i++ {
+		r := rollouts[i]
+		if r.Firmware == m.Firmware && r.SleepInterval > 0 {
+			m.SleepInterval = r.SleepInterval
+		}
+	}
+	if m.Velocity != 0 {
+		m.Velocity = m.physics(w).clampSpeed(m.Velocity)
+	}
+}
@@ -0,0 +1,54 @@
+package mover
+
+import (
+	// System
+	"fmt"
+	"math"
+)
+
+// WanderProps smooths the random walk into a correlated random
+// walk, for tracks that look like animals or vehicles rather than
+// jagged noise. The rate at which a mover turns follows an
+// Ornstein-Uhlenbeck process: every tick it keeps Persistence of its
+// turn rate, between zero and one, and the rest is drawn afresh, so
+// that turn rates settle around zero with a standard deviation of
+// TurnStdDev degrees a tick (MaxHeadingChange unless set). The
+// higher the persistence, the longer and smoother the bends. A
+// Persistence of zero keeps the uniform heading jitter of
+// MaxHeadingChange.
+type WanderProps struct {
+	Persistence float64
+	TurnStdDev  float64
+}
+
+func (p WanderProps) init() error {
+	if p.Persistence < 0 || p.Persistence >= 1 {
+		return fmt.Errorf("wander persistence %f is not at least zero and below one", p.Persistence)
+	}
+	if p.TurnStdDev < 0 {
+		return fmt.Errorf("wander turn standard deviation %f is negative", p.TurnStdDev)
+	}
+	return nil
+}
+
+// turn is the heading change of the next tick of the random walk.
+func (m *Mover) turn(props *Props) int {
+	wp := props.Wander
+	if wp.Persistence == 0 {
+		if props.MaxHeadingChange <= 0 {
+			return 0
+		}
+		return m.rng.Intn(2*props.MaxHeadingChange) - props.MaxHeadingChange
+	}
+	sd := wp.TurnStdDev
+	if sd == 0 {
+		sd = float64(props.MaxHeadingChange)
+	}
+	// The fresh part keeps the stationary spread of the rate
+	m.TurnRate = wp.Persistence*m.TurnRate + math.Sqrt(1-wp.Persistence*wp.Persistence)*sd*m.rng.NormFloat64()
+	// Headings are whole degrees, carry the rest to the next tick
+	turn := m.TurnRate + m.turnCarry
+	whole := math.Round(turn)
+	m.turnCarry = turn - whole
+	return int(whole)
+}
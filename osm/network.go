@@ -0,0 +1,234 @@
+package osm
+
+import (
+	// System
+	"fmt"
+
+	// Protocol buffer wire format
+	"google.golang.org/protobuf/encoding/protowire"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+// Highway values that are not (yet, or any longer) roads
+var notRoads = map[string]bool{
+	"proposed":     true,
+	"construction": true,
+	"abandoned":    true,
+	"razed":        true,
+	"platform":     true,
+}
+
+// way is a street, as the ids of its nodes in order, and the
+// direction it may be travelled in: one for only along the
+// nodes, minus one for only against them, zero for both.
+type way struct {
+	refs   []int64
+	oneway int
+}
+
+// ReadNetwork builds a street network from the ways tagged highway
+// in an .osm.pbf extract, or only those of the given kinds if any.
+// The file is read twice, once for the ways and once for the
+// positions of the nodes they use, so only those are kept.
+func ReadNetwork(path string, highways []string) (*mover.Network, error) {
+	var kinds map[string]bool
+	if len(highways) > 0 {
+		kinds = make(map[string]bool, len(highways))
+		for _, h := range highways {
+			kinds[h] = true
+		}
+	}
+
+	var ways []way
+	nodes := make(map[int64]int)
+	err := readBlocks(path, func(data []byte) error {
+		b, err := parseBlock(data)
+		if err != nil {
+			return err
+		}
+		for _, group := range b.groups {
+			err := eachField(group, func(f field) error {
+				if f.num != groupWays {
+					return nil
+				}
+				w, ok, err := b.parseWay(f.data, kinds)
+				if ok {
+					ways = append(ways, w)
+					for _, ref := range w.refs {
+						nodes[ref] = -1
+					}
+				}
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(ways) == 0 {
+		return nil, fmt.Errorf("%s has no highway ways", path)
+	}
+
+	var points []geo.Point
+	found := func(id int64, lat, lon float64) {
+		if i, ok := nodes[id]; ok && i < 0 {
+			nodes[id] = len(points)
+			points = append(points, geo.Point{X: lon, Y: lat})
+		}
+	}
+	err = readBlocks(path, func(data []byte) error {
+		b, err := parseBlock(data)
+		if err != nil {
+			return err
+		}
+		for _, group := range b.groups {
+			err := eachField(group, func(f field) error {
+				switch f.num {
+				case groupNodes:
+					return b.parseNode(f.data, found)
+				case groupDense:
+					return b.parseDense(f.data, found)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var links []mover.Link
+	for _, w := range ways {
+		for i := 1; i < len(w.refs); i++ {
+			from, to := nodes[w.refs[i-1]], nodes[w.refs[i]]
+			if from < 0 || to < 0 {
+				// Node missing from the extract
+				continue
+			}
+			if w.oneway < 0 {
+				from, to = to, from
+			}
+			links = append(links, mover.Link{From: from, To: to, Oneway: w.oneway != 0})
+		}
+	}
+	network, err := mover.NewNetwork(points, links)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return network, nil
+}
+
+// parseWay reads a way, reporting whether it is a street of
+// one of the kinds, or of any kind when kinds is nil.
+func (b *block) parseWay(data []byte, kinds map[string]bool) (way, bool, error) {
+	var w way
+	var keys, vals []uint64
+	err := eachField(data, func(f field) error {
+		switch f.num {
+		case wayKeys:
+			return varints(f, func(v uint64) { keys = append(keys, v) })
+		case wayVals:
+			return varints(f, func(v uint64) { vals = append(vals, v) })
+		case wayRefs:
+			return deltas(f, func(v int64) { w.refs = append(w.refs, v) })
+		}
+		return nil
+	})
+	if err != nil || len(keys) != len(vals) {
+		return w, false, err
+	}
+
+	tags := make(map[string]string, len(keys))
+	for i := range keys {
+		k, err := b.str(keys[i])
+		if err != nil {
+			return w, false, err
+		}
+		v, err := b.str(vals[i])
+		if err != nil {
+			return w, false, err
+		}
+		tags[k] = v
+	}
+	highway, ok := tags["highway"]
+	if !ok || notRoads[highway] || tags["area"] == "yes" || len(w.refs) < 2 {
+		return w, false, nil
+	}
+	if kinds != nil && !kinds[highway] {
+		return w, false, nil
+	}
+
+	switch tags["oneway"] {
+	case "yes", "true", "1":
+		w.oneway = 1
+	case "-1", "reverse":
+		w.oneway = -1
+	case "no", "false", "0":
+	default:
+		// Implied by the kind of road
+		if highway == "motorway" || tags["junction"] == "roundabout" {
+			w.oneway = 1
+		}
+	}
+	return w, true, nil
+}
+
+// parseNode reads a node stored on its own.
+func (b *block) parseNode(data []byte, found func(id int64, lat, lon float64)) error {
+	var id, lat, lon int64
+	err := eachField(data, func(f field) error {
+		switch f.num {
+		case nodeId:
+			id = protowire.DecodeZigZag(f.v)
+		case nodeLat:
+			lat = protowire.DecodeZigZag(f.v)
+		case nodeLon:
+			lon = protowire.DecodeZigZag(f.v)
+		}
+		return nil
+	})
+	if err == nil {
+		found(id, b.coordinate(b.latOffset, lat), b.coordinate(b.lonOffset, lon))
+	}
+	return err
+}
+
+// parseDense reads a group of densely stored nodes, whose ids and
+// coordinates are delta coded in parallel lists.
+func (b *block) parseDense(data []byte, found func(id int64, lat, lon float64)) error {
+	var ids, lats, lons []int64
+	err := eachField(data, func(f field) error {
+		switch f.num {
+		case denseId:
+			return deltas(f, func(v int64) { ids = append(ids, v) })
+		case denseLat:
+			return deltas(f, func(v int64) { lats = append(lats, v) })
+		case denseLon:
+			return deltas(f, func(v int64) { lons = append(lons, v) })
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(lats) != len(ids) || len(lons) != len(ids) {
+		return fmt.Errorf("dense nodes have %d ids but %d latitudes and %d longitudes", len(ids), len(lats), len(lons))
+	}
+	for i, id := range ids {
+		found(id, b.coordinate(b.latOffset, lats[i]), b.coordinate(b.lonOffset, lons[i]))
+	}
+	return nil
+}
@@ -0,0 +1,315 @@
+// Package osm reads street networks for the route model from
+// OpenStreetMap extracts in the .osm.pbf format, without needing
+// pgRouting, osm2pgsql or any other preprocessing.
+package osm
+
+import (
+	// System
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	// Protocol buffer wire format
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The parts of the OpenStreetMap PBF format needed for streets. See
+// https://wiki.openstreetmap.org/wiki/PBF_Format
+
+// Field numbers of the file messages
+const (
+	headerType     = 1
+	headerDataSize = 3
+
+	blobRaw     = 1
+	blobRawSize = 2
+	blobZlib    = 3
+
+	headerRequiredFeatures = 4
+
+	blockStrings     = 1
+	blockGroups      = 2
+	blockGranularity = 17
+	blockLatOffset   = 19
+	blockLonOffset   = 20
+
+	stringEntry = 1
+
+	groupNodes = 1
+	groupDense = 2
+	groupWays  = 3
+
+	nodeId  = 1
+	nodeLat = 8
+	nodeLon = 9
+
+	denseId  = 1
+	denseLat = 8
+	denseLon = 9
+
+	wayId   = 1
+	wayKeys = 2
+	wayVals = 3
+	wayRefs = 8
+)
+
+// Largest blob header and blob the format allows
+const (
+	maxHeaderSize = 64 * 1024
+	maxBlobSize   = 32 * 1024 * 1024
+)
+
+// Features a reader has to understand to read a file, of which
+// only these two are needed for streets
+var supportedFeatures = map[string]bool{
+	"OsmSchema-V0.6": true,
+	"DenseNodes":     true,
+}
+
+// field is one field of a protocol buffer message, with the value
+// in v for numbers and in data for strings, bytes and messages.
+type field struct {
+	num  protowire.Number
+	typ  protowire.Type
+	v    uint64
+	data []byte
+}
+
+// eachField calls fn with every field of the message, in order.
+func eachField(msg []byte, fn func(f field) error) error {
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		msg = msg[n:]
+		f := field{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.v, n = protowire.ConsumeVarint(msg)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(msg)
+			f.v = uint64(v)
+		case protowire.Fixed64Type:
+			f.v, n = protowire.ConsumeFixed64(msg)
+		case protowire.BytesType:
+			f.data, n = protowire.ConsumeBytes(msg)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, msg)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		msg = msg[n:]
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// varints calls fn with every value of a repeated integer field,
+// whether packed or not.
+func varints(f field, fn func(v uint64)) error {
+	if f.typ != protowire.BytesType {
+		fn(f.v)
+		return nil
+	}
+	for data := f.data; len(data) > 0; {
+		v, n := protowire.ConsumeVarint(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		fn(v)
+		data = data[n:]
+	}
+	return nil
+}
+
+// deltas calls fn with the running sums of a delta coded
+// repeated sint64 field, starting from zero.
+func deltas(f field, fn func(v int64)) error {
+	var sum int64
+	return varints(f, func(v uint64) {
+		sum += protowire.DecodeZigZag(v)
+		fn(sum)
+	})
+}
+
+// readBlocks calls fn with every data block of the file, in order,
+// after checking the file header asks for nothing unsupported.
+func readBlocks(path string, fn func(block []byte) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	in := bufio.NewReader(file)
+
+	for {
+		kind, blob, err := readBlob(in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		data, err := unpackBlob(blob)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		switch kind {
+		case "OSMHeader":
+			err = checkHeader(data)
+		case "OSMData":
+			err = fn(data)
+		}
+		// Other kinds of blob are to be skipped
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+}
+
+// readBlob reads the next blob and the kind named in its header.
+func readBlob(in io.Reader) (string, []byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(in, size[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return "", nil, errors.New("truncated blob header")
+		}
+		return "", nil, err
+	}
+	headerSize := binary.BigEndian.Uint32(size[:])
+	if headerSize > maxHeaderSize {
+		return "", nil, fmt.Errorf("blob header of %d bytes is too large", headerSize)
+	}
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(in, header); err != nil {
+		return "", nil, errors.New("truncated blob header")
+	}
+
+	var kind string
+	var blobSize uint64
+	err := eachField(header, func(f field) error {
+		switch f.num {
+		case headerType:
+			kind = string(f.data)
+		case headerDataSize:
+			blobSize = f.v
+		}
+		return nil
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("bad blob header: %v", err)
+	}
+	if blobSize > maxBlobSize {
+		return "", nil, fmt.Errorf("blob of %d bytes is too large", blobSize)
+	}
+	blob := make([]byte, blobSize)
+	if _, err := io.ReadFull(in, blob); err != nil {
+		return "", nil, errors.New("truncated blob")
+	}
+	return kind, blob, nil
+}
+
+// unpackBlob returns the contents of a blob, inflating them if
+// need be. Only zlib compression is supported, as almost every
+// extract uses it.
+func unpackBlob(blob []byte) ([]byte, error) {
+	var raw, compressed []byte
+	var size uint64
+	err := eachField(blob, func(f field) error {
+		switch f.num {
+		case blobRaw:
+			raw = f.data
+		case blobRawSize:
+			size = f.v
+		case blobZlib:
+			compressed = f.data
+		default:
+			return fmt.Errorf("unsupported blob compression, field %d", f.num)
+		}
+		return nil
+	})
+	if err != nil || compressed == nil {
+		return raw, err
+	}
+	if size > maxBlobSize {
+		return nil, fmt.Errorf("blob of %d bytes is too large", size)
+	}
+	r, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("bad zlib data: %v", err)
+	}
+	return data, nil
+}
+
+// checkHeader fails for files needing features beyond plain
+// OpenStreetMap data, such as history extracts.
+func checkHeader(header []byte) error {
+	return eachField(header, func(f field) error {
+		if f.num == headerRequiredFeatures && !supportedFeatures[string(f.data)] {
+			return fmt.Errorf("unsupported feature '%s'", f.data)
+		}
+		return nil
+	})
+}
+
+// block is the part of a data block shared by its groups.
+type block struct {
+	strings     []string
+	groups      [][]byte
+	granularity int64
+	latOffset   int64
+	lonOffset   int64
+}
+
+func parseBlock(data []byte) (*block, error) {
+	b := &block{granularity: 100}
+	err := eachField(data, func(f field) error {
+		switch f.num {
+		case blockStrings:
+			return eachField(f.data, func(s field) error {
+				if s.num == stringEntry {
+					b.strings = append(b.strings, string(s.data))
+				}
+				return nil
+			})
+		case blockGroups:
+			b.groups = append(b.groups, f.data)
+		case blockGranularity:
+			b.granularity = int64(f.v)
+		case blockLatOffset:
+			b.latOffset = int64(f.v)
+		case blockLonOffset:
+			b.lonOffset = int64(f.v)
+		}
+		return nil
+	})
+	return b, err
+}
+
+// str looks up an entry of the string table.
+func (b *block) str(i uint64) (string, error) {
+	if i >= uint64(len(b.strings)) {
+		return "", fmt.Errorf("string %d is not in the table", i)
+	}
+	return b.strings[i], nil
+}
+
+// coordinate converts a stored latitude or longitude to degrees.
+func (b *block) coordinate(offset, v int64) float64 {
+	return 1e-9 * float64(offset+b.granularity*v)
+}
@@ -0,0 +1,315 @@
+package osm
+
+import (
+	// System
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	// Protocol buffer wire format
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Helpers writing the messages the reader expects
+
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func packed(vs ...uint64) []byte {
+	var b []byte
+	for _, v := range vs {
+		b = protowire.AppendVarint(b, v)
+	}
+	return b
+}
+
+// packedDeltas delta and zigzag codes the values.
+func packedDeltas(vs ...int64) []byte {
+	var b []byte
+	var last int64
+	for _, v := range vs {
+		b = protowire.AppendVarint(b, protowire.EncodeZigZag(v-last))
+		last = v
+	}
+	return b
+}
+
+// blob frames the data as a blob of the kind, compressed or not.
+func blob(t *testing.T, kind string, data []byte, compress bool) []byte {
+	var body []byte
+	if compress {
+		var z bytes.Buffer
+		w := zlib.NewWriter(&z)
+		w.Write(data)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		body = appendVarint(body, blobRawSize, uint64(len(data)))
+		body = appendBytes(body, blobZlib, z.Bytes())
+	} else {
+		body = appendBytes(body, blobRaw, data)
+	}
+	header := appendBytes(nil, headerType, []byte(kind))
+	header = appendVarint(header, headerDataSize, uint64(len(body)))
+	out := binary.BigEndian.AppendUint32(nil, uint32(len(header)))
+	out = append(out, header...)
+	return append(out, body...)
+}
+
+func TestEachField(t *testing.T) {
+	var msg []byte
+	msg = appendVarint(msg, 1, 150)
+	msg = appendBytes(msg, 2, []byte("abc"))
+	msg = protowire.AppendTag(msg, 3, protowire.Fixed32Type)
+	msg = protowire.AppendFixed32(msg, 7)
+	msg = protowire.AppendTag(msg, 4, protowire.Fixed64Type)
+	msg = protowire.AppendFixed64(msg, 9)
+
+	want := []field{
+		{num: 1, typ: protowire.VarintType, v: 150},
+		{num: 2, typ: protowire.BytesType, data: []byte("abc")},
+		{num: 3, typ: protowire.Fixed32Type, v: 7},
+		{num: 4, typ: protowire.Fixed64Type, v: 9},
+	}
+	var got []field
+	err := eachField(msg, func(f field) error {
+		got = append(got, f)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("eachField read %+v, want %+v", got, want)
+	}
+
+	for _, bad := range [][]byte{
+		{0x08},            // varint without a value
+		{0x12, 0x05, 'a'}, // bytes cut short
+		{0x80},            // tag cut short
+	} {
+		if err := eachField(bad, func(field) error { return nil }); err == nil {
+			t.Errorf("eachField(%x) did not fail", bad)
+		}
+	}
+}
+
+func TestVarints(t *testing.T) {
+	tests := []struct {
+		name string
+		f    field
+		want []uint64
+	}{
+		{"unpacked", field{typ: protowire.VarintType, v: 300}, []uint64{300}},
+		{"packed", field{typ: protowire.BytesType, data: packed(1, 300, 1<<40)}, []uint64{1, 300, 1 << 40}},
+		{"packed empty", field{typ: protowire.BytesType}, nil},
+	}
+	for _, test := range tests {
+		var got []uint64
+		if err := varints(test.f, func(v uint64) { got = append(got, v) }); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+	bad := field{typ: protowire.BytesType, data: []byte{0x80}}
+	if err := varints(bad, func(uint64) {}); err == nil {
+		t.Error("varints of a truncated value did not fail")
+	}
+}
+
+func TestDeltas(t *testing.T) {
+	tests := [][]int64{
+		{5},
+		{100, 101, 99, -5, 1 << 40},
+		{-1, -2, -3},
+	}
+	for _, want := range tests {
+		var got []int64
+		f := field{typ: protowire.BytesType, data: packedDeltas(want...)}
+		if err := deltas(f, func(v int64) { got = append(got, v) }); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("deltas got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestReadBlob(t *testing.T) {
+	data := []byte("the contents of a block")
+	tests := []struct {
+		name     string
+		compress bool
+	}{
+		{"raw", false},
+		{"zlib", true},
+	}
+	for _, test := range tests {
+		in := bytes.NewReader(blob(t, "OSMData", data, test.compress))
+		kind, b, err := readBlob(in)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if kind != "OSMData" {
+			t.Errorf("%s: kind %q, want OSMData", test.name, kind)
+		}
+		got, err := unpackBlob(b)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%s: unpacked %q, want %q", test.name, got, data)
+		}
+		if _, _, err := readBlob(in); err.Error() != "EOF" {
+			t.Errorf("%s: read past the end gave %v, want EOF", test.name, err)
+		}
+	}
+
+	whole := blob(t, "OSMData", data, false)
+	for _, cut := range []int{2, 6, len(whole) - 1} {
+		if _, _, err := readBlob(bytes.NewReader(whole[:cut])); err == nil || !strings.Contains(err.Error(), "truncated") {
+			t.Errorf("blob cut to %d bytes gave %v, want truncated", cut, err)
+		}
+	}
+
+	lzma := appendBytes(nil, 4, []byte("xx"))
+	if _, err := unpackBlob(lzma); err == nil || !strings.Contains(err.Error(), "unsupported blob compression") {
+		t.Errorf("lzma blob gave %v, want unsupported compression", err)
+	}
+}
+
+func TestCheckHeader(t *testing.T) {
+	tests := []struct {
+		features []string
+		ok       bool
+	}{
+		{nil, true},
+		{[]string{"OsmSchema-V0.6", "DenseNodes"}, true},
+		{[]string{"OsmSchema-V0.6", "HistoricalInformation"}, false},
+	}
+	for _, test := range tests {
+		var header []byte
+		for _, feature := range test.features {
+			header = appendBytes(header, headerRequiredFeatures, []byte(feature))
+		}
+		if err := checkHeader(header); (err == nil) != test.ok {
+			t.Errorf("checkHeader(%v) gave %v", test.features, err)
+		}
+	}
+}
+
+func TestCoordinate(t *testing.T) {
+	tests := []struct {
+		granularity, offset, v int64
+		want                   float64
+	}{
+		{100, 0, 495000000, 49.5},
+		{100, 0, -1230000000, -123},
+		{1000, 0, 49500000, 49.5},
+		{100, 500000000, 0, 0.5},
+		{100, -500000000, 10000000, 0.5},
+	}
+	for _, test := range tests {
+		b := block{granularity: test.granularity}
+		if got := b.coordinate(test.offset, test.v); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("coordinate(%d, %d) with granularity %d = %g, want %g",
+				test.offset, test.v, test.granularity, got, test.want)
+		}
+	}
+}
+
+// testExtract writes an extract with a header, a block of dense
+// nodes and a block of ways, one not a street and one oneway.
+func testExtract(t *testing.T) string {
+	header := appendBytes(nil, headerRequiredFeatures, []byte("OsmSchema-V0.6"))
+	header = appendBytes(header, headerRequiredFeatures, []byte("DenseNodes"))
+
+	// Four nodes in a row, and one off on its own
+	dense := appendBytes(nil, denseId, packedDeltas(1, 2, 3, 4, 5))
+	dense = appendBytes(dense, denseLat, packedDeltas(490000000, 490000000, 490000000, 490000000, 500000000))
+	dense = appendBytes(dense, denseLon, packedDeltas(-1230000000, -1229000000, -1228000000, -1227000000, -1200000000))
+	nodes := appendBytes(nil, blockGroups, appendBytes(nil, groupDense, dense))
+
+	var table []byte
+	for _, s := range []string{"", "highway", "residential", "oneway", "yes", "building", "house"} {
+		table = appendBytes(table, stringEntry, []byte(s))
+	}
+	street := appendVarint(nil, wayId, 10)
+	street = appendBytes(street, wayKeys, packed(1))
+	street = appendBytes(street, wayVals, packed(2))
+	street = appendBytes(street, wayRefs, packedDeltas(1, 2, 3))
+	oneway := appendVarint(nil, wayId, 11)
+	oneway = appendBytes(oneway, wayKeys, packed(1, 3))
+	oneway = appendBytes(oneway, wayVals, packed(2, 4))
+	oneway = appendBytes(oneway, wayRefs, packedDeltas(3, 4))
+	house := appendVarint(nil, wayId, 12)
+	house = appendBytes(house, wayKeys, packed(5))
+	house = appendBytes(house, wayVals, packed(6))
+	house = appendBytes(house, wayRefs, packedDeltas(4, 5))
+	var group []byte
+	for _, w := range [][]byte{street, oneway, house} {
+		group = appendBytes(group, groupWays, w)
+	}
+	ways := appendBytes(nil, blockStrings, table)
+	ways = appendBytes(ways, blockGroups, group)
+
+	var file []byte
+	file = append(file, blob(t, "OSMHeader", header, false)...)
+	file = append(file, blob(t, "OSMData", nodes, true)...)
+	file = append(file, blob(t, "OSMData", ways, true)...)
+	path := filepath.Join(t.TempDir(), "test.osm.pbf")
+	if err := os.WriteFile(path, file, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadNetwork(t *testing.T) {
+	path := testExtract(t)
+	tests := []struct {
+		name     string
+		highways []string
+		nodes    int
+		err      string
+	}{
+		{"all streets", nil, 4, ""},
+		{"residential", []string{"residential"}, 4, ""},
+		{"no such kind", []string{"motorway"}, 0, "has no highway ways"},
+	}
+	for _, test := range tests {
+		network, err := ReadNetwork(path, test.highways)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: got error %v, want one about %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if network.Len() != test.nodes {
+			t.Errorf("%s: network has %d nodes, want %d", test.name, network.Len(), test.nodes)
+		}
+		for i := 0; i < network.Len(); i++ {
+			p := network.Node(i)
+			if math.Abs(p.Y-49) > 1e-9 || p.X < -123-1e-9 || p.X > -122.7+1e-9 {
+				t.Errorf("%s: node %d at (%g, %g) is not on the street", test.name, i, p.X, p.Y)
+			}
+		}
+	}
+}
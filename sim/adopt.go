@@ -0,0 +1,73 @@
+package sim
+
+import (
+	// System
+	"sort"
+	"time"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+// adopt has the new mover carry on from the state an earlier run
+// left for its id, if any, see Options.Adopt. Each state is taken
+// by the first mover of its id alone, whether spawned, generated
+// or added.
+func (s *Simulation) adopt(m *mover.Mover) {
+	s.mutex.Lock()
+	state, ok := s.adoptable[m.Id]
+	delete(s.adoptable, m.Id)
+	s.mutex.Unlock()
+	if ok {
+		m.Adopt(s.world, state)
+	}
+}
+
+// shareSize is how many movers this shard starts with.
+func (s *Simulation) shareSize() int {
+	if len(s.opts.Regions) == 0 {
+		return s.opts.Shard.share(s.opts.MaxMovers)
+	}
+	share := 0
+	for _, region := range s.opts.Regions {
+		share += s.opts.Shard.share(region.Count)
+	}
+	return share
+}
+
+// retireLeftovers deletes the movers an earlier run left with ids
+// of this shard that are past the fleet this run starts, as when
+// the fleet shrank, which nothing would adopt, so that they do not
+// stand still in the objects table for good.
+func (s *Simulation) retireLeftovers() {
+	share := s.shareSize()
+	s.mutex.Lock()
+	var leftovers []mover.State
+	for id, state := range s.adoptable {
+		if n, ok := s.opts.Shard.index(id, s.opts.Convoy.Size); ok && n >= share {
+			leftovers = append(leftovers, state)
+			delete(s.adoptable, id)
+		}
+	}
+	s.mutex.Unlock()
+	if len(leftovers) == 0 {
+		return
+	}
+	sort.Slice(leftovers, func(i, j int) bool {
+		return leftovers[i].Id < leftovers[j].Id
+	})
+	for _, state := range leftovers {
+		s.delete(mover.Mover{
+			Id:    state.Id,
+			X:     state.X,
+			Y:     state.Y,
+			Ts:    time.Now(),
+			Color: state.Color,
+			Class: s.world.Props.ClassFor(state.Id),
+		})
+	}
+	log.Infof("Retired %d movers of an earlier run beyond the fleet", len(leftovers))
+}
@@ -0,0 +1,69 @@
+package sim
+
+import (
+	// System
+	"context"
+	"time"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+// Gap handling modes
+const (
+	// Replay the missed ticks with timestamps spread over the gap
+	GapInterpolate = "interpolate"
+	// Carry on from where the mover was, flagging the gap
+	GapMarker = "marker"
+)
+
+// GapProps controls what happens when a mover wakes up much later
+// than planned, because the process was suspended or starved. A
+// wake up more than Threshold late (default five sleep intervals)
+// is a gap. With Mode "interpolate" up to MaxCatchUp missed ticks
+// are replayed, longer gaps are marked instead.
+type GapProps struct {
+	Mode       string
+	Threshold  time.Duration
+	MaxCatchUp int
+}
+
+// catchUp checks how late the mover is waking up, given the sleep
+// it expected, and deals with any gap before the regular tick.
+func (s *Simulation) catchUp(m *mover.Mover, now time.Time, expected time.Duration) {
+	props := s.opts.Gap
+	threshold := props.Threshold
+	if threshold <= 0 {
+		threshold = 5 * s.interval(m)
+	}
+	// On the wall clock, as the monotonic one stands still while
+	// the machine is suspended
+	elapsed := now.Round(0).Sub(m.Ts.Round(0))
+	late := elapsed - expected
+	if late <= threshold {
+		return
+	}
+
+	missed := int(late / s.interval(m))
+	if props.Mode == GapInterpolate && missed <= props.MaxCatchUp {
+		m.Logger().Infof("Woke %s late, replaying %d ticks", late, missed)
+		start := m.Ts
+		step := elapsed / time.Duration(missed+1)
+		replayed := make([]mover.Mover, 0, missed)
+		for k := 1; k <= missed && !m.Exited; k++ {
+			if s.step(m, start.Add(time.Duration(k)*step)) {
+				replayed = append(replayed, m.Observed(s.opts.Gps))
+			}
+		}
+		// The replayed ticks go out together
+		if len(replayed) > 0 {
+			if err := s.write(context.Background(), m.Ticks, replayed); err != nil {
+				m.Logger().Errorf("Unable to move mover: %v", err)
+			}
+		}
+		return
+	}
+
+	m.Logger().Warnf("Woke %s late, marking gap", late)
+	m.Gap = late
+}
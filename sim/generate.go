@@ -0,0 +1,155 @@
+package sim
+
+import (
+	// System
+	"container/heap"
+	"context"
+	"time"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/sink"
+)
+
+// Generate moves the fleet through simulated time rather than real
+// time, from start for the span, as fast as it can, then closes the
+// sinks and returns the statistics of the run per sink. Movers take
+// their ticks in time order, at the same jittered intervals as in a
+// live run, and their positions go to the sinks batchSize at a time.
+// Lifetimes and rollouts play out in simulated time, and movers
+// adopt the states of an earlier run as in a live one; the schedule,
+// the speed factor and added movers do not apply.
+func (s *Simulation) Generate(ctx context.Context, start time.Time, span time.Duration, batchSize int) []*sink.RunStats {
+	s.started = start
+	s.retireLeftovers()
+	end := start.Add(span)
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	var table timetable
+	spawn := func(region *mover.Region, ts time.Time) {
+		moverId := s.NewId()
+		m, err := s.world.NewMoverIn(moverId, region)
+		if err != nil {
+			log.WithField("mover", moverId).Error(err)
+			return
+		}
+		m.Ts, m.ClockStart = ts, ts
+		s.adopt(&m)
+		if lifetime := s.opts.Population.lifetime(m.Rand()); lifetime > 0 {
+			m.DiesAt = ts.Add(lifetime)
+		}
+		for _, sink := range s.sinks {
+			if err := sink.Create(m); err != nil {
+				m.Logger().Errorf("Unable to create mover in %s: %v", sink.Name(), err)
+			}
+		}
+		s.world.Index.Update(m)
+		heap.Push(&table, &scheduled{mover: &m, region: region, next: ts.Add(s.sleep(&m))})
+	}
+	if len(s.opts.Regions) == 0 {
+		for i := 0; i < s.opts.Shard.share(s.opts.MaxMovers); i++ {
+			spawn(nil, start)
+		}
+	}
+	for _, region := range s.opts.Regions {
+		for i := 0; i < s.opts.Shard.share(region.Count); i++ {
+			spawn(region, start)
+		}
+	}
+
+	batch := make([]mover.Mover, 0, batchSize)
+	flush := func(turn int) {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.write(ctx, turn, batch); err != nil {
+			log.Errorf("Unable to write positions: %v", err)
+		}
+		batch = make([]mover.Mover, 0, batchSize)
+	}
+	// Movers that died or left their bounds make way for new ones
+	replace := func(next *scheduled, ts time.Time) {
+		flush(next.mover.Ticks)
+		heap.Pop(&table)
+		s.endTrip(next.mover)
+		s.delete(*next.mover)
+		s.world.Index.Remove(next.mover.Id)
+		s.world.Leave(next.mover)
+		if s.proximity != nil {
+			s.proximity.forget(next.mover.Id)
+		}
+		spawn(next.region, ts)
+	}
+
+	progress := start.Add(span / 10)
+	for table.Len() > 0 && ctx.Err() == nil {
+		next := table[0]
+		m, ts := next.mover, next.next
+		if ts.After(end) {
+			break
+		}
+		if ts.After(progress) {
+			log.Infof("Generated %s of %s", progress.Sub(start), span)
+			progress = progress.Add(span / 10)
+		}
+		if !m.DiesAt.IsZero() && ts.After(m.DiesAt) {
+			replace(next, m.DiesAt)
+			continue
+		}
+		m.ApplyRollouts(s.opts.Rollouts, ts.Sub(start))
+		if s.step(m, ts) {
+			batch = append(batch, m.Observed(s.opts.Gps))
+			m.Gap = 0
+		}
+		if m.Exited {
+			replace(next, ts)
+			continue
+		}
+		next.next = ts.Add(s.sleep(m))
+		heap.Fix(&table, 0)
+		if len(batch) >= batchSize {
+			flush(m.Ticks)
+		}
+	}
+	flush(0)
+	for _, next := range table {
+		s.endTrip(next.mover)
+	}
+	return s.close()
+}
+
+// sleep draws the simulated time until the mover's next tick.
+func (s *Simulation) sleep(m *mover.Mover) time.Duration {
+	return m.Sleep(s.world.Props, s.nominalInterval(m))
+}
+
+// scheduled is a mover waiting for its next tick.
+type scheduled struct {
+	mover  *mover.Mover
+	region *mover.Region
+	next   time.Time
+}
+
+// timetable is a heap of movers, the next to tick first.
+type timetable []*scheduled
+
+func (t timetable) Len() int           { return len(t) }
+func (t timetable) Less(i, j int) bool { return t[i].next.Before(t[j].next) }
+func (t timetable) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+
+func (t *timetable) Push(x interface{}) {
+	*t = append(*t, x.(*scheduled))
+}
+
+func (t *timetable) Pop() interface{} {
+	old := *t
+	n := len(old)
+	x := old[n-1]
+	*t = old[:n-1]
+	return x
+}
@@ -0,0 +1,270 @@
+package sim
+
+import (
+	// System
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+// PopulationProps controls how the fleet comes and goes. Movers
+// spawn at SpawnRate per second until MaxMovers are alive (zero
+// spawns them all at once). When MaxLifetime is set each mover
+// lives for a random time between MinLifetime and MaxLifetime, is
+// deleted from the objects table when it dies, and is replaced by
+// a new mover with a new id, in the same region. A fleet resized
+// while it runs, see Simulation.Reconfigure, grows or shrinks
+// evenly over Ramp.
+type PopulationProps struct {
+	SpawnRate   float64
+	MinLifetime time.Duration
+	MaxLifetime time.Duration
+	Ramp        time.Duration
+}
+
+// lifetime draws a random lifetime, or zero for immortal movers.
+func (p PopulationProps) lifetime(rng *rand.Rand) time.Duration {
+	if p.MaxLifetime <= 0 {
+		return 0
+	}
+	if p.MaxLifetime <= p.MinLifetime {
+		return p.MaxLifetime
+	}
+	return p.MinLifetime + time.Duration(rng.Int63n(int64(p.MaxLifetime-p.MinLifetime)))
+}
+
+// How often the population checks its size while ramping
+const rampStep = time.Second
+
+// member is where a mover of the population runs, and its class.
+type member struct {
+	region *mover.Region
+	class  *mover.Class
+}
+
+// runPopulation spawns movers, ramping up at the spawn rate and
+// replacing the ones that die, retires movers when the schedule
+// thins the fleet, or their region or class is over its count,
+// and starts any added movers, until the context is cancelled and
+// every mover has stopped.
+func (s *Simulation) runPopulation(ctx context.Context) {
+	props := s.opts.Population
+	schedule := &s.opts.Schedule
+
+	var wg sync.WaitGroup
+	deaths := make(chan mover.Mover)
+	// The living movers of the population, by id, and how many
+	// there are in each region and of each class
+	alive := make(map[int]member)
+	regionAlive := make(map[*mover.Region]int)
+	classAlive := make(map[*mover.Class]int)
+	start := func(m mover.Mover, replace bool) {
+		// Tracked before it starts, so that it can be retired at once
+		h := s.track(m.Id)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s.moverRoutine(ctx, m, h) && replace {
+				select {
+				case deaths <- m:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+	spawn := func(region *mover.Region, class *mover.Class) {
+		moverId := s.NewId()
+		m, err := s.world.NewMoverOf(moverId, region, class)
+		if err != nil {
+			log.WithField("mover", moverId).Error(err)
+			return
+		}
+		s.adopt(&m)
+		if lifetime := props.lifetime(m.Rand()); lifetime > 0 {
+			m.DiesAt = m.Ts.Add(lifetime)
+		}
+		alive[moverId] = member{region: region, class: m.Class}
+		regionAlive[region]++
+		classAlive[m.Class]++
+		// Indexed at once, for any convoy followers spawned next
+		s.world.Index.Update(m)
+		start(m, true)
+	}
+	retire := func(moverId int) {
+		m := alive[moverId]
+		delete(alive, moverId)
+		regionAlive[m.region]--
+		classAlive[m.class]--
+		s.RemoveMover(moverId)
+	}
+
+	// Check the timetable as the schedule clock moves on
+	var clock <-chan time.Time
+	if len(schedule.Periods) > 0 {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		clock = ticker.C
+	}
+	factors := noFactors
+	// Ramp towards the latest size the fleet was given
+	ramp := sizeRamp{to: s.startSize()}
+
+	for {
+		if r := s.takeResize(); r != nil {
+			from, _ := ramp.at(time.Now())
+			if r.size.classes != nil && from.classes == nil {
+				// From the split of the weights at the start
+				from.classes = s.startClasses(from.movers)
+			}
+			ramp = sizeRamp{from: from, to: r.size, start: time.Now(), span: r.ramp}
+		}
+		size, ramping := ramp.at(time.Now())
+		if len(schedule.Periods) > 0 {
+			now := schedule.factors(schedule.clock(s.started))
+			if now != factors {
+				s.logSchedule(now)
+				factors = now
+				s.world.SetPace(factors.velocity)
+			}
+		}
+		var interval time.Duration
+		if props.SpawnRate > 0 {
+			interval = time.Duration(float64(time.Second) / (props.SpawnRate * factors.spawnRate))
+		}
+
+		for _, m := range s.takePending() {
+			start(m, false)
+		}
+		classTargets := s.classTargets(size, factors.movers)
+		over := func(m member) (bool, bool) {
+			return regionAlive[m.region] > s.target(size, m.region, factors.movers),
+				classTargets != nil && classAlive[m.class] > classTargets[m.class]
+		}
+		// Those over in both their region and their class first,
+		// so as to retire no more movers than it takes
+		for moverId, m := range alive {
+			if region, class := over(m); region && class {
+				retire(moverId)
+			}
+		}
+		for moverId, m := range alive {
+			if region, class := over(m); region || class {
+				retire(moverId)
+			}
+		}
+		// Without a spawn rate fill up at once, otherwise add one
+		// mover per wake up, allowing for rounding per region
+		limit := scaled(size.movers, factors.movers) + len(s.opts.Regions)
+		for spawned := 0; spawned < limit; spawned++ {
+			region, short := s.nextRegion(size, regionAlive, factors.movers)
+			if !short {
+				break
+			}
+			spawn(region, s.nextClass(classTargets, classAlive))
+			if interval > 0 {
+				break
+			}
+		}
+
+		var spawnClock, rampClock <-chan time.Time
+		if _, short := s.nextRegion(size, regionAlive, factors.movers); interval > 0 && short {
+			spawnClock = time.After(interval)
+		}
+		if ramping {
+			rampClock = time.After(rampStep)
+		}
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case m := <-deaths:
+			// Unless it was retired as it died
+			if member, ok := alive[m.Id]; ok {
+				delete(alive, m.Id)
+				regionAlive[member.region]--
+				classAlive[member.class]--
+			}
+		case <-s.added:
+		case <-spawnClock:
+		case <-rampClock:
+		case <-clock:
+		}
+	}
+}
+
+// target is how many movers the population of this shard keeps
+// alive in the region, or in all when there are no regions, for
+// the fleet size at the density.
+func (s *Simulation) target(size fleetSize, region *mover.Region, density float64) int {
+	if region == nil {
+		return s.opts.Shard.share(scaled(size.movers, density))
+	}
+	return s.opts.Shard.share(scaled(size.regions[region], density))
+}
+
+// nextRegion is the region furthest short of its mover count, nil
+// when there are no regions, reporting whether any is short at all.
+func (s *Simulation) nextRegion(size fleetSize, alive map[*mover.Region]int, density float64) (*mover.Region, bool) {
+	if len(s.opts.Regions) == 0 {
+		return nil, alive[nil] < s.target(size, nil, density)
+	}
+	var next *mover.Region
+	short := 0
+	for _, region := range s.opts.Regions {
+		if missing := s.target(size, region, density) - alive[region]; missing > short {
+			next, short = region, missing
+		}
+	}
+	return next, short > 0
+}
+
+// classTargets is how many movers of each class the population of
+// this shard keeps alive for the fleet size at the density, nil to
+// leave the class of each mover to its id.
+func (s *Simulation) classTargets(size fleetSize, density float64) map[*mover.Class]int {
+	if size.classes == nil {
+		return nil
+	}
+	total := 0
+	if len(s.opts.Regions) == 0 {
+		total = s.target(size, nil, density)
+	}
+	for _, region := range s.opts.Regions {
+		total += s.target(size, region, density)
+	}
+	weights := make(map[*mover.Class]float64, len(size.classes))
+	for class, count := range size.classes {
+		weights[class] = float64(count)
+	}
+	return apportion(total, s.opts.Classes, weights)
+}
+
+// nextClass is the class furthest short of its target, nil when
+// there are no targets or none is short.
+func (s *Simulation) nextClass(targets map[*mover.Class]int, alive map[*mover.Class]int) *mover.Class {
+	var next *mover.Class
+	short := 0
+	for _, class := range s.opts.Classes {
+		if missing := targets[class] - alive[class]; missing > short {
+			next, short = class, missing
+		}
+	}
+	return next
+}
+
+// logSchedule reports a change of the schedule factors.
+func (s *Simulation) logSchedule(factors scheduleFactors) {
+	periods := factors.periods
+	if periods == "" {
+		periods = "none"
+	}
+	log.Infof("Schedule periods %s: movers x%g, velocity x%g, spawn rate x%g",
+		periods, factors.movers, factors.velocity, factors.spawnRate)
+}
@@ -0,0 +1,123 @@
+package sim
+
+import (
+	// System
+	"sync"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/sink"
+)
+
+// ProximityProps turns on proximity events: whenever two movers
+// come within Distance (in meters) of each other, an event goes to
+// every sink that records events, with how far apart they are, in
+// meters too. The pair must move apart again before it raises another.
+type ProximityProps struct {
+	Distance float64
+}
+
+// proximity keeps its own index of the fleet, with cells the
+// size of the distance in degrees of latitude, and the pairs of
+// movers that are close.
+type proximity struct {
+	distance float64
+	index    *mover.SpatialIndex
+
+	mutex sync.Mutex
+	close map[int]map[int]bool
+}
+
+func newProximity(distance float64) *proximity {
+	return &proximity{
+		distance: distance,
+		index:    mover.NewSpatialIndex(distance / geo.MetersPerDegree),
+		close:    make(map[int]map[int]bool),
+	}
+}
+
+// check indexes the new position of the mover, returning an
+// event for every mover it has just come close to.
+func (p *proximity) check(m mover.Mover) []sink.Event {
+	p.index.Update(m)
+	near := p.index.Neighbors(m.X, m.Y, geo.DegreesAround(p.distance, m.Y))
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	was := p.close[m.Id]
+	now := make(map[int]bool, len(near))
+	var events []sink.Event
+	for _, other := range near {
+		if other.Id == m.Id {
+			continue
+		}
+		distance := geo.Distance(m.X, m.Y, other.X, other.Y)
+		if distance > p.distance {
+			continue
+		}
+		now[other.Id] = true
+		if was[other.Id] {
+			continue
+		}
+		p.link(m.Id, other.Id)
+		events = append(events, sink.Event{
+			Kind:     sink.EventProximity,
+			Ts:       m.DeviceTime(m.Ts),
+			A:        m,
+			B:        other,
+			Distance: distance,
+		})
+	}
+	for id := range was {
+		if !now[id] {
+			p.unlink(m.Id, id)
+		}
+	}
+	return events
+}
+
+// forget drops a mover that has stopped.
+func (p *proximity) forget(moverId int) {
+	p.index.Remove(moverId)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for id := range p.close[moverId] {
+		p.unlink(moverId, id)
+	}
+}
+
+func (p *proximity) link(a, b int) {
+	for _, pair := range [][2]int{{a, b}, {b, a}} {
+		partners, ok := p.close[pair[0]]
+		if !ok {
+			partners = make(map[int]bool)
+			p.close[pair[0]] = partners
+		}
+		partners[pair[1]] = true
+	}
+}
+
+func (p *proximity) unlink(a, b int) {
+	for _, pair := range [][2]int{{a, b}, {b, a}} {
+		delete(p.close[pair[0]], pair[1])
+		if len(p.close[pair[0]]) == 0 {
+			delete(p.close, pair[0])
+		}
+	}
+}
+
+// writeEvents sends the events to every sink that records them.
+func (s *Simulation) writeEvents(m *mover.Mover, events []sink.Event) {
+	for _, e := range events {
+		for _, out := range s.sinks {
+			if recorder, ok := out.(sink.EventSink); ok {
+				if err := recorder.WriteEvent(e); err != nil {
+					m.Logger().Errorf("Unable to write %s event to %s: %v", e.Kind, out.Name(), err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package sim
+
+import (
+	// System
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+// LiveProps are the settings a running simulation can take on
+// without a restart, see Reconfigure: the size of the fleet, or
+// with regions the Count of each by name, the Weight of each class
+// by name, and the tuning of the movers. A change of size or of
+// the class weights is ramped in over Ramp.
+type LiveProps struct {
+	MaxMovers int
+	Regions   map[string]int
+	Classes   map[string]float64
+	Tuning    mover.Tuning
+	Ramp      time.Duration
+}
+
+// Live is the part of the options a running simulation can
+// take on, see Reconfigure.
+func (o *Options) Live() LiveProps {
+	live := LiveProps{
+		MaxMovers: o.MaxMovers,
+		Tuning:    o.Props.Tuning(),
+		Ramp:      o.Population.Ramp,
+	}
+	if len(o.Regions) > 0 {
+		live.Regions = make(map[string]int, len(o.Regions))
+		for _, region := range o.Regions {
+			live.Regions[region.Name] = region.Count
+		}
+	}
+	if len(o.Classes) > 0 {
+		live.Classes = make(map[string]float64, len(o.Classes))
+		for _, class := range o.Classes {
+			live.Classes[class.Name] = class.Weight
+		}
+	}
+	return live
+}
+
+// FleetSize is how many movers the options keep alive in all,
+// across all shards: with regions the sum of their Count, and
+// otherwise MaxMovers.
+func (o *Options) FleetSize() int {
+	if len(o.Regions) == 0 {
+		return o.MaxMovers
+	}
+	movers := 0
+	for _, region := range o.Regions {
+		movers += region.Count
+	}
+	return movers
+}
+
+// fleetSize is how many movers the population keeps alive in all,
+// with regions in each of them, and once the class weights have
+// changed while it runs, of each class. Without class counts the
+// class of a mover is that of its id, see mover.Props.ClassFor.
+type fleetSize struct {
+	movers  int
+	regions map[*mover.Region]int
+	classes map[*mover.Class]int
+}
+
+// resize is a change of fleet size waiting for the population.
+type resize struct {
+	size fleetSize
+	ramp time.Duration
+}
+
+// startSize is the fleet size of the options.
+func (s *Simulation) startSize() fleetSize {
+	size := fleetSize{movers: s.opts.MaxMovers, regions: make(map[*mover.Region]int, len(s.opts.Regions))}
+	for _, region := range s.opts.Regions {
+		size.regions[region] = region.Count
+	}
+	return size
+}
+
+// startClasses is how the class weights of the options split a
+// fleet of n movers.
+func (s *Simulation) startClasses(n int) map[*mover.Class]int {
+	weights := make(map[*mover.Class]float64, len(s.opts.Classes))
+	for _, class := range s.opts.Classes {
+		weights[class] = class.Weight
+	}
+	return apportion(n, s.opts.Classes, weights)
+}
+
+// apportion splits n movers between the classes in proportion to
+// their weights, by largest remainder in the order of the classes,
+// so that the counts add up to n. Without any weight the classes
+// share alike, as in mover.Props.ClassFor.
+func apportion(n int, classes []*mover.Class, weights map[*mover.Class]float64) map[*mover.Class]int {
+	counts := make(map[*mover.Class]int, len(classes))
+	if len(classes) == 0 {
+		return counts
+	}
+	var total float64
+	for _, class := range classes {
+		total += weights[class]
+	}
+	share := func(class *mover.Class) float64 {
+		if total <= 0 {
+			return float64(n) / float64(len(classes))
+		}
+		return float64(n) * weights[class] / total
+	}
+	left := n
+	for _, class := range classes {
+		counts[class] = int(math.Floor(share(class)))
+		left -= counts[class]
+	}
+	for ; left > 0; left-- {
+		var next *mover.Class
+		largest := -1.0
+		for _, class := range classes {
+			if remainder := share(class) - float64(counts[class]); remainder > largest {
+				next, largest = class, remainder
+			}
+		}
+		counts[next]++
+	}
+	return counts
+}
+
+// liveSize is the fleet size of the live settings, which can
+// change the count of the regions and the weight of the classes,
+// but not add or take them away.
+func (s *Simulation) liveSize(live LiveProps) (fleetSize, error) {
+	size, err := s.liveRegions(live)
+	if err != nil {
+		return fleetSize{}, err
+	}
+	if len(live.Classes) != len(s.opts.Classes) {
+		return fleetSize{}, fmt.Errorf("mover classes cannot be added to or taken from a running simulation, set their Weight instead")
+	}
+	weights := make(map[*mover.Class]float64, len(s.opts.Classes))
+	changed := false
+	for _, class := range s.opts.Classes {
+		weight, ok := live.Classes[class.Name]
+		if !ok {
+			return fleetSize{}, fmt.Errorf("mover class '%s' cannot be taken from a running simulation, set its Weight instead", class.Name)
+		}
+		if weight < 0 {
+			return fleetSize{}, fmt.Errorf("mover class '%s' has a negative weight", class.Name)
+		}
+		weights[class] = weight
+		changed = changed || weight != class.Weight
+	}
+	// Once changed, the class counts are kept to, even when the
+	// weights go back to those of the start
+	s.mutex.Lock()
+	changed = changed || s.classesLive
+	s.mutex.Unlock()
+	if changed {
+		size.classes = apportion(size.movers, s.opts.Classes, weights)
+	}
+	return size, nil
+}
+
+// liveRegions is the fleet size of the live settings, in all and
+// by region.
+func (s *Simulation) liveRegions(live LiveProps) (fleetSize, error) {
+	if len(s.opts.Regions) == 0 {
+		if len(live.Regions) > 0 {
+			return fleetSize{}, fmt.Errorf("regions cannot be added to a running simulation")
+		}
+		if live.MaxMovers < 0 {
+			return fleetSize{}, fmt.Errorf("mover count %d is negative", live.MaxMovers)
+		}
+		return fleetSize{movers: live.MaxMovers}, nil
+	}
+	if len(live.Regions) != len(s.opts.Regions) {
+		return fleetSize{}, fmt.Errorf("regions cannot be added to or taken from a running simulation, set their Count instead")
+	}
+	size := fleetSize{regions: make(map[*mover.Region]int, len(s.opts.Regions))}
+	for _, region := range s.opts.Regions {
+		count, ok := live.Regions[region.Name]
+		if !ok {
+			return fleetSize{}, fmt.Errorf("region '%s' cannot be taken from a running simulation, set its Count instead", region.Name)
+		}
+		if count < 0 {
+			return fleetSize{}, fmt.Errorf("region '%s' has a negative count", region.Name)
+		}
+		size.regions[region] = count
+		size.movers += count
+	}
+	return size, nil
+}
+
+// Reconfigure changes the settings of the running simulation: the
+// movers take on the new tuning from their next tick, and the
+// population grows or shrinks to the new size, and to the new share
+// of each class, over the ramp, rather than all at once. Nothing
+// changes if any setting is invalid.
+func (s *Simulation) Reconfigure(live LiveProps) error {
+	if live.Ramp < 0 {
+		return fmt.Errorf("ramp %s is negative", live.Ramp)
+	}
+	size, err := s.liveSize(live)
+	if err != nil {
+		return err
+	}
+	if err := s.world.Tune(live.Tuning); err != nil {
+		return err
+	}
+	s.tuned.Add(1)
+
+	s.mutex.Lock()
+	s.resize = &resize{size: size, ramp: live.Ramp}
+	s.classesLive = size.classes != nil
+	s.mutex.Unlock()
+	select {
+	case s.added <- struct{}{}:
+	default:
+	}
+	log.Infof("Reconfigured for %d movers over %s, reporting every %s",
+		size.movers, live.Ramp, live.Tuning.SleepInterval)
+	if size.classes != nil {
+		counts := make([]string, len(s.opts.Classes))
+		for i, class := range s.opts.Classes {
+			counts[i] = fmt.Sprintf("%d %s", size.classes[class], class.Name)
+		}
+		log.Infof("Reconfigured mover classes for %s", strings.Join(counts, ", "))
+	}
+	return nil
+}
+
+// takeResize hands over the latest change of size, if any.
+func (s *Simulation) takeResize() *resize {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	r := s.resize
+	s.resize = nil
+	return r
+}
+
+// sizeRamp moves the fleet size from one to another over a span
+// of time, in even steps.
+type sizeRamp struct {
+	from  fleetSize
+	to    fleetSize
+	start time.Time
+	span  time.Duration
+}
+
+// at is the fleet size at the time, and whether it is still
+// on its way to the final size.
+func (r sizeRamp) at(now time.Time) (fleetSize, bool) {
+	elapsed := now.Sub(r.start)
+	if r.span <= 0 || elapsed >= r.span {
+		return r.to, false
+	}
+	f := float64(elapsed) / float64(r.span)
+	between := func(from, to int) int {
+		return from + int(math.Round(float64(to-from)*f))
+	}
+	size := fleetSize{
+		movers:  between(r.from.movers, r.to.movers),
+		regions: make(map[*mover.Region]int, len(r.to.regions)),
+	}
+	for region, count := range r.to.regions {
+		size.regions[region] = between(r.from.regions[region], count)
+	}
+	if r.to.classes != nil {
+		size.classes = make(map[*mover.Class]int, len(r.to.classes))
+		for class, count := range r.to.classes {
+			size.classes[class] = between(r.from.classes[class], count)
+		}
+	}
+	return size, true
+}
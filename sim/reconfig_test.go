@@ -0,0 +1,183 @@
+package sim
+
+import (
+	// System
+	"context"
+	"testing"
+	"time"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+func TestApportion(t *testing.T) {
+	car := &mover.Class{Name: "car"}
+	truck := &mover.Class{Name: "truck"}
+	bus := &mover.Class{Name: "bus"}
+	classes := []*mover.Class{car, truck, bus}
+	tests := []struct {
+		n       int
+		weights []float64
+		counts  []int
+	}{
+		{10, []float64{1, 1, 0}, []int{5, 5, 0}},
+		{10, []float64{3, 1, 1}, []int{6, 2, 2}},
+		{10, []float64{1, 1, 1}, []int{4, 3, 3}},
+		{10, []float64{0, 0, 0}, []int{4, 3, 3}},
+		{7, []float64{2, 5, 0}, []int{2, 5, 0}},
+		{0, []float64{1, 2, 3}, []int{0, 0, 0}},
+	}
+	for _, test := range tests {
+		weights := make(map[*mover.Class]float64)
+		for i, class := range classes {
+			weights[class] = test.weights[i]
+		}
+		counts := apportion(test.n, classes, weights)
+		for i, class := range classes {
+			if counts[class] != test.counts[i] {
+				t.Errorf("apportion(%d, %v): %s = %d, want %d", test.n, test.weights, class.Name, counts[class], test.counts[i])
+			}
+		}
+	}
+	if counts := apportion(10, nil, nil); len(counts) != 0 {
+		t.Errorf("apportion without classes = %v, want none", counts)
+	}
+}
+
+// classOptions are the options of a simulation of ten cars and
+// trucks, half of each.
+func classOptions() Options {
+	opts := DefaultOptions()
+	opts.MaxMovers = 10
+	opts.SleepInterval = 10 * time.Millisecond
+	opts.Classes = []*mover.Class{
+		{Name: "car", Weight: 1},
+		{Name: "truck", Weight: 1},
+	}
+	return opts
+}
+
+func TestLiveSizeClasses(t *testing.T) {
+	s, err := NewSimulation(classOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	car, truck := s.opts.Classes[0], s.opts.Classes[1]
+	live := s.opts.Live()
+
+	size, err := s.liveSize(live)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size.classes != nil {
+		t.Errorf("unchanged weights give class counts %v", size.classes)
+	}
+
+	live.Classes = map[string]float64{"car": 4, "truck": 1}
+	if size, err = s.liveSize(live); err != nil {
+		t.Fatal(err)
+	}
+	if size.classes[car] != 8 || size.classes[truck] != 2 {
+		t.Errorf("class counts = %d car, %d truck, want 8 and 2", size.classes[car], size.classes[truck])
+	}
+
+	// Back to the weights of the start, the counts are kept to
+	// once the weights have been changed
+	if err := s.Reconfigure(live); err != nil {
+		t.Fatal(err)
+	}
+	live.Classes = map[string]float64{"car": 1, "truck": 1}
+	if size, err = s.liveSize(live); err != nil {
+		t.Fatal(err)
+	}
+	if size.classes[car] != 5 || size.classes[truck] != 5 {
+		t.Errorf("class counts = %d car, %d truck, want 5 and 5", size.classes[car], size.classes[truck])
+	}
+
+	for _, classes := range []map[string]float64{
+		{"car": 1},
+		{"car": 1, "truck": 1, "bus": 1},
+		{"car": 1, "bus": 1},
+		{"car": 1, "truck": -1},
+	} {
+		live.Classes = classes
+		if _, err := s.liveSize(live); err == nil {
+			t.Errorf("liveSize(%v) took invalid classes", classes)
+		}
+	}
+}
+
+func TestSizeRampClasses(t *testing.T) {
+	car := &mover.Class{Name: "car"}
+	truck := &mover.Class{Name: "truck"}
+	start := time.Now()
+	r := sizeRamp{
+		from:  fleetSize{movers: 10, classes: map[*mover.Class]int{car: 10, truck: 0}},
+		to:    fleetSize{movers: 20, classes: map[*mover.Class]int{car: 0, truck: 20}},
+		start: start,
+		span:  10 * time.Second,
+	}
+	size, ramping := r.at(start.Add(5 * time.Second))
+	if !ramping || size.movers != 15 || size.classes[car] != 5 || size.classes[truck] != 10 {
+		t.Errorf("halfway: %d movers, %d car, %d truck, ramping %t, want 15, 5, 10, true",
+			size.movers, size.classes[car], size.classes[truck], ramping)
+	}
+	size, ramping = r.at(start.Add(10 * time.Second))
+	if ramping || size.classes[car] != 0 || size.classes[truck] != 20 {
+		t.Errorf("at the end: %d car, %d truck, ramping %t, want 0, 20, false",
+			size.classes[car], size.classes[truck], ramping)
+	}
+}
+
+func TestReconfigureClasses(t *testing.T) {
+	s, err := NewSimulation(classOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	counts := func() map[string]int {
+		counts := make(map[string]int)
+		for _, m := range s.Movers() {
+			counts[m.Class.Name]++
+		}
+		return counts
+	}
+	until := func(car, truck int) {
+		t.Helper()
+		for ctx.Err() == nil {
+			if c := counts(); c["car"] == car && c["truck"] == truck {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		c := counts()
+		t.Fatalf("fleet of %d car, %d truck, want %d and %d", c["car"], c["truck"], car, truck)
+	}
+	until(5, 5)
+
+	live := s.opts.Live()
+	live.Classes = map[string]float64{"car": 4, "truck": 1}
+	live.Ramp = 0
+	if err := s.Reconfigure(live); err != nil {
+		t.Fatal(err)
+	}
+	until(8, 2)
+
+	live.MaxMovers = 4
+	live.Classes = map[string]float64{"car": 0, "truck": 1}
+	if err := s.Reconfigure(live); err != nil {
+		t.Fatal(err)
+	}
+	until(0, 4)
+}
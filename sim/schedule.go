@@ -0,0 +1,142 @@
+package sim
+
+import (
+	// System
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// SchedulePeriod changes the fleet every day between From and To,
+// times of day on the schedule clock such as "07:00" and "09:00",
+// wrapping past midnight when To is earlier than From. Movers
+// scales the number of movers kept alive, Velocity how far they go
+// each tick, and SpawnRate how fast they spawn. Factors left out
+// stay at one, and the factors of overlapping periods multiply.
+type SchedulePeriod struct {
+	Name      string
+	From      string
+	To        string
+	Movers    float64
+	Velocity  float64
+	SpawnRate float64
+
+	from, to time.Duration
+}
+
+// ScheduleProps is a daily timetable for the fleet. The schedule
+// clock starts at the Start time of day (the local time by default)
+// and runs TimeScale times faster than real time, so a day of rush
+// hours can be played through in minutes.
+type ScheduleProps struct {
+	Start     string
+	TimeScale float64
+	Periods   []SchedulePeriod
+
+	start time.Duration
+}
+
+// scheduleFactors are the multipliers in force at one time of day.
+type scheduleFactors struct {
+	movers    float64
+	velocity  float64
+	spawnRate float64
+	periods   string
+}
+
+var noFactors = scheduleFactors{movers: 1, velocity: 1, spawnRate: 1}
+
+const day = 24 * time.Hour
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day '%s', use HH:MM", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// init checks the schedule and parses its times.
+func (p *ScheduleProps) init() error {
+	if p.TimeScale < 0 {
+		return fmt.Errorf("schedule time scale %f is negative", p.TimeScale)
+	}
+	if p.Start != "" {
+		start, err := parseTimeOfDay(p.Start)
+		if err != nil {
+			return err
+		}
+		p.start = start
+	}
+	for i := range p.Periods {
+		period := &p.Periods[i]
+		if period.Name == "" {
+			period.Name = fmt.Sprintf("%s-%s", period.From, period.To)
+		}
+		var err error
+		if period.from, err = parseTimeOfDay(period.From); err != nil {
+			return fmt.Errorf("schedule period '%s': %w", period.Name, err)
+		}
+		if period.to, err = parseTimeOfDay(period.To); err != nil {
+			return fmt.Errorf("schedule period '%s': %w", period.Name, err)
+		}
+		if period.Movers < 0 || period.Velocity < 0 || period.SpawnRate < 0 {
+			return fmt.Errorf("schedule period '%s' has a negative factor", period.Name)
+		}
+	}
+	return nil
+}
+
+// clock is the time of day on the schedule clock, for
+// a run that started at the given time.
+func (p *ScheduleProps) clock(started time.Time) time.Duration {
+	start := p.start
+	if p.Start == "" {
+		year, month, date := started.Date()
+		start = started.Sub(time.Date(year, month, date, 0, 0, 0, 0, started.Location()))
+	}
+	scale := p.TimeScale
+	if scale == 0 {
+		scale = 1
+	}
+	elapsed := time.Duration(float64(time.Since(started)) * scale)
+	return (start + elapsed) % day
+}
+
+func (period *SchedulePeriod) covers(clock time.Duration) bool {
+	if period.from <= period.to {
+		return clock >= period.from && clock < period.to
+	}
+	return clock >= period.from || clock < period.to
+}
+
+// factors are the multipliers of the periods covering the time of day.
+func (p *ScheduleProps) factors(clock time.Duration) scheduleFactors {
+	factors := noFactors
+	var names []string
+	for i := range p.Periods {
+		period := &p.Periods[i]
+		if !period.covers(clock) {
+			continue
+		}
+		names = append(names, period.Name)
+		factors.movers *= factorOrOne(period.Movers)
+		factors.velocity *= factorOrOne(period.Velocity)
+		factors.spawnRate *= factorOrOne(period.SpawnRate)
+	}
+	factors.periods = strings.Join(names, ", ")
+	return factors
+}
+
+func factorOrOne(factor float64) float64 {
+	if factor == 0 {
+		return 1
+	}
+	return factor
+}
+
+// scaled is a mover count times the density factor.
+func scaled(count int, factor float64) int {
+	return int(math.Round(float64(count) * factor))
+}
@@ -0,0 +1,75 @@
+package sim
+
+import (
+	// System
+	"fmt"
+)
+
+// ShardProps splits one fleet between Count processes, each run
+// with the same configuration and its own Index, from zero, so that
+// they can write to the same database from several hosts without
+// any coordinator. Each process keeps alive its share of MaxMovers,
+// and of every region, and hands out ids of its own, so no two
+// write to the same rows. Ids go out in blocks of the convoy size,
+// keeping every convoy within one process. A Count below two runs
+// the whole fleet.
+type ShardProps struct {
+	Index int
+	Count int
+}
+
+func (p ShardProps) init() error {
+	if p.Count < 0 {
+		return fmt.Errorf("shard count %d is negative", p.Count)
+	}
+	if p.Index < 0 || p.Index > 0 && p.Index >= p.Count {
+		return fmt.Errorf("shard index %d is not below the shard count %d", p.Index, p.Count)
+	}
+	return nil
+}
+
+// Sharded reports whether the fleet is split between processes.
+func (p ShardProps) Sharded() bool {
+	return p.Count > 1
+}
+
+// share is the part of n movers this shard runs, the first
+// shards taking one more when they do not divide evenly.
+func (p ShardProps) share(n int) int {
+	if !p.Sharded() {
+		return n
+	}
+	share := n / p.Count
+	if p.Index < n%p.Count {
+		share++
+	}
+	return share
+}
+
+// id is the nth id of this shard, handed out in blocks of the
+// given size.
+func (p ShardProps) id(n, block int) int {
+	if !p.Sharded() {
+		return n
+	}
+	if block < 1 {
+		block = 1
+	}
+	return (n/block*p.Count+p.Index)*block + n%block
+}
+
+// index is the inverse of id: the n of the id, and whether the id
+// is one of this shard at all.
+func (p ShardProps) index(id, block int) (int, bool) {
+	if !p.Sharded() {
+		return id, true
+	}
+	if block < 1 {
+		block = 1
+	}
+	b := id / block
+	if b%p.Count != p.Index {
+		return 0, false
+	}
+	return b/p.Count*block + id%block, true
+}
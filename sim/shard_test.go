@@ -0,0 +1,81 @@
+package sim
+
+import (
+	// System
+	"testing"
+)
+
+func TestShardIndex(t *testing.T) {
+	for _, block := range []int{0, 1, 3} {
+		shards := []ShardProps{{Index: 0, Count: 3}, {Index: 1, Count: 3}, {Index: 2, Count: 3}}
+		owners := make(map[int]int)
+		for i, shard := range shards {
+			for n := 0; n < 20; n++ {
+				id := shard.id(n, block)
+				if got, ok := shard.index(id, block); !ok || got != n {
+					t.Errorf("shard %d block %d: index(%d) = %d, %t, want %d", i, block, id, got, ok, n)
+				}
+				owners[id]++
+				for j, other := range shards {
+					if _, ok := other.index(id, block); ok != (i == j) {
+						t.Errorf("shard %d block %d: id %d taken by shard %d", i, block, id, j)
+					}
+				}
+			}
+		}
+		for id, count := range owners {
+			if count != 1 {
+				t.Errorf("block %d: id %d handed out %d times", block, id, count)
+			}
+		}
+	}
+	if n, ok := (ShardProps{}).index(42, 3); !ok || n != 42 {
+		t.Errorf("unsharded index(42) = %d, %t, want 42", n, ok)
+	}
+}
+
+func TestShardShare(t *testing.T) {
+	tests := []struct {
+		movers int
+		count  int
+		shares []int
+	}{
+		{10, 0, []int{10}},
+		{10, 1, []int{10}},
+		{10, 2, []int{5, 5}},
+		{10, 3, []int{4, 3, 3}},
+		{2, 3, []int{1, 1, 0}},
+	}
+	for _, test := range tests {
+		total := 0
+		for i, want := range test.shares {
+			shard := ShardProps{Index: i, Count: test.count}
+			if got := shard.share(test.movers); got != want {
+				t.Errorf("shard %d of %d: share(%d) = %d, want %d", i, test.count, test.movers, got, want)
+			}
+			total += want
+		}
+		if total != test.movers {
+			t.Errorf("%d shards share %d of %d movers", test.count, total, test.movers)
+		}
+	}
+}
+
+func TestShardInit(t *testing.T) {
+	tests := []struct {
+		shard ShardProps
+		ok    bool
+	}{
+		{ShardProps{}, true},
+		{ShardProps{Index: 2, Count: 3}, true},
+		{ShardProps{Index: 3, Count: 3}, false},
+		{ShardProps{Index: -1, Count: 3}, false},
+		{ShardProps{Index: 0, Count: -1}, false},
+		{ShardProps{Index: 1}, false},
+	}
+	for _, test := range tests {
+		if err := test.shard.init(); (err == nil) != test.ok {
+			t.Errorf("%+v: init() = %v, want ok %t", test.shard, err, test.ok)
+		}
+	}
+}
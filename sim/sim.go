@@ -0,0 +1,515 @@
+// Package sim runs a fleet of movers, each in its own goroutine,
+// writing every position they report to a set of sinks.
+//
+//	s, err := sim.NewSimulation(sim.DefaultOptions(), sinks...)
+//	if err != nil {
+//		return err
+//	}
+//	stats := s.Run(ctx)
+package sim
+
+import (
+	// System
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// OpenTelemetry
+	"go.opentelemetry.io/otel/attribute"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/sink"
+	"github.com/pramsey/movesim/telemetry"
+)
+
+// Options controls a simulation. The mover settings sit at the
+// top level, as in the Movers section of the configuration file.
+type Options struct {
+	mover.Props `mapstructure:",squash"`
+	MaxMovers   int
+	Population  PopulationProps
+	Schedule    ScheduleProps
+	Proximity   ProximityProps
+	Trips       TripProps
+	Shard       ShardProps
+	Gap         GapProps
+	Rollouts    []mover.RolloutProps
+
+	// The loaded constraint layer, if any, see mover.NewConstraint
+	Layer *mover.Constraint `mapstructure:"-"`
+	// The loaded street network, needed by the route model,
+	// see mover.NewNetwork
+	Network *mover.Network `mapstructure:"-"`
+	// The loaded start density, if any, see mover.NewDensity
+	Density *mover.Density `mapstructure:"-"`
+	// The loaded no-go zones, if any, see mover.NewZones
+	Zones *mover.Zones `mapstructure:"-"`
+	// Styles of the movers of earlier runs, if they are kept,
+	// see mover.Styles
+	Styles *mover.Styles `mapstructure:"-"`
+	// States of an earlier run, by id, for the movers spawned,
+	// generated or added with those ids to carry on from, see
+	// mover.Adopt. Those of ids past the fleet are deleted.
+	Adopt map[int]mover.State `mapstructure:"-"`
+	// Log the state of every mover at debug level on one
+	// tick in LogEvery, zero never logs it
+	LogEvery int `mapstructure:"-"`
+	// Seed of the random numbers of the movers, see mover.World
+	Seed int64 `mapstructure:"-"`
+}
+
+// DefaultOptions runs fifty random walkers.
+func DefaultOptions() Options {
+	return Options{
+		Props:     mover.DefaultProps(),
+		MaxMovers: 50,
+		Population: PopulationProps{
+			Ramp: time.Minute,
+		},
+		Trips: TripProps{
+			MinPause: 30 * time.Second,
+		},
+		Gap: GapProps{
+			Mode:       GapInterpolate,
+			MaxCatchUp: 600,
+		},
+		LogEvery: 1,
+	}
+}
+
+// Init checks the options and fills in anything left out.
+func (o *Options) Init() error {
+	if err := o.Props.Init(); err != nil {
+		return err
+	}
+	if len(o.Regions) > 0 {
+		// The regions make up the whole fleet
+		o.MaxMovers = o.FleetSize()
+	}
+	if err := o.Schedule.init(); err != nil {
+		return err
+	}
+	if o.Proximity.Distance < 0 {
+		return fmt.Errorf("proximity distance %f is negative", o.Proximity.Distance)
+	}
+	if err := o.Shard.init(); err != nil {
+		return err
+	}
+	if err := o.Trips.init(); err != nil {
+		return err
+	}
+	if o.Gap.Mode != GapInterpolate && o.Gap.Mode != GapMarker {
+		return fmt.Errorf("unknown gap mode '%s'", o.Gap.Mode)
+	}
+	mover.SortRollouts(o.Rollouts)
+	return nil
+}
+
+// Simulation is one run of a fleet of movers.
+type Simulation struct {
+	opts      Options
+	world     *mover.World
+	sinks     []sink.Sink
+	started   time.Time
+	proximity *proximity
+	trips     *trips
+	tally     *tally
+
+	// Movers added with AddMover, waiting to start, any change
+	// of size waiting for the population and whether the class
+	// weights have changed, see Reconfigure, the running movers
+	// by id, and the states not yet adopted
+	mutex       sync.Mutex
+	pending     []mover.Mover
+	resize      *resize
+	classesLive bool
+	added       chan struct{}
+	live        map[int]*handle
+	adoptable   map[int]mover.State
+
+	nextId atomic.Int64
+	// Float64 bits of the speed factor, see SetSpeed
+	speed atomic.Uint64
+	// Times reconfigured, for the movers to retune
+	tuned atomic.Int64
+}
+
+// NewSimulation sets up a simulation writing to the sinks.
+func NewSimulation(opts Options, sinks ...sink.Sink) (*Simulation, error) {
+	if err := opts.Init(); err != nil {
+		return nil, err
+	}
+	if opts.Model == mover.ModelRoute && opts.Network == nil {
+		return nil, fmt.Errorf("route model needs a street network")
+	}
+	s := &Simulation{
+		opts:  opts,
+		sinks: sinks,
+		added: make(chan struct{}, 1),
+		live:  make(map[int]*handle),
+		tally: newTally(),
+	}
+	s.adoptable = make(map[int]mover.State, len(opts.Adopt))
+	for id, state := range opts.Adopt {
+		s.adoptable[id] = state
+	}
+	s.speed.Store(math.Float64bits(1.0))
+	s.world = mover.NewWorld(&s.opts.Props, opts.Layer)
+	s.world.Network = opts.Network
+	s.world.Density = opts.Density
+	s.world.Zones = opts.Zones
+	s.world.Styles = opts.Styles
+	s.world.Seed = opts.Seed
+	if opts.Proximity.Distance > 0 {
+		s.proximity = newProximity(opts.Proximity.Distance)
+	}
+	if opts.Trips.Enabled {
+		s.trips = newTrips(opts.Trips, opts.Shard)
+	}
+	return s, nil
+}
+
+// NewId hands out a mover id that no other mover of the
+// simulation has, nor any of the other shards, for movers
+// made for AddMover.
+func (s *Simulation) NewId() int {
+	return s.opts.Shard.id(int(s.nextId.Add(1)-1), s.opts.Convoy.Size)
+}
+
+// SetSpeed runs the simulation faster or slower than real time,
+// by ticking the movers factor times as often.
+func (s *Simulation) SetSpeed(factor float64) error {
+	if factor <= 0 || math.IsInf(factor, 0) || math.IsNaN(factor) {
+		return fmt.Errorf("invalid speed factor %f", factor)
+	}
+	s.speed.Store(math.Float64bits(factor))
+	log.Infof("Simulation speed set to %gx", factor)
+	return nil
+}
+
+// Speed is the current speed factor.
+func (s *Simulation) Speed() float64 {
+	return math.Float64frombits(s.speed.Load())
+}
+
+// World is shared by the movers of the simulation. Use its
+// NewMover to make movers for AddMover.
+func (s *Simulation) World() *mover.World {
+	return s.world
+}
+
+// Movers returns the current state of every running mover,
+// by id, including those offline or without a fix.
+func (s *Simulation) Movers() []mover.Mover {
+	return s.world.Index.All()
+}
+
+// Count is the number of running movers.
+func (s *Simulation) Count() int {
+	return s.world.Index.Len()
+}
+
+// AddMover puts a mover into the simulation, alongside the
+// MaxMovers the population keeps alive, starting it straight away
+// if the simulation is running. Its id must not clash with the ids
+// of the population, so take it from NewId. Added movers are not
+// replaced when their lifetime runs out, and carry on from the state
+// of an earlier run left for their id, if no other mover has.
+func (s *Simulation) AddMover(m mover.Mover) {
+	s.adopt(&m)
+	s.mutex.Lock()
+	s.pending = append(s.pending, m)
+	s.mutex.Unlock()
+	select {
+	case s.added <- struct{}{}:
+	default:
+	}
+}
+
+// Commands that may wait for a mover to pick them up
+const commandBuffer = 16
+
+// handle reaches a running mover: RemoveMover closes removed
+// to stop it, and Command queues commands for its next tick.
+type handle struct {
+	removed  chan struct{}
+	commands chan mover.Command
+}
+
+// RemoveMover stops a running mover and deletes it from the sinks,
+// reporting whether it was found. Removed movers are not replaced.
+func (s *Simulation) RemoveMover(id int) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	h, ok := s.live[id]
+	if ok {
+		close(h.removed)
+		delete(s.live, id)
+	}
+	return ok
+}
+
+// Command hands a command to a running mover, which carries it
+// out on its next tick.
+func (s *Simulation) Command(id int, c mover.Command) error {
+	if err := c.Check(); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	h, ok := s.live[id]
+	if !ok {
+		return fmt.Errorf("no running mover %d", id)
+	}
+	select {
+	case h.commands <- c:
+		return nil
+	default:
+		return fmt.Errorf("mover %d has too many commands waiting", id)
+	}
+}
+
+// track registers a mover about to start, returning its handle,
+// so that it can be removed or commanded from the moment it is
+// spawned rather than only once it has reached the sinks.
+func (s *Simulation) track(id int) *handle {
+	h := &handle{
+		removed:  make(chan struct{}),
+		commands: make(chan mover.Command, commandBuffer),
+	}
+	s.mutex.Lock()
+	s.live[id] = h
+	s.mutex.Unlock()
+	return h
+}
+
+// untrack forgets a mover that has stopped.
+func (s *Simulation) untrack(id int, h *handle) {
+	s.mutex.Lock()
+	if s.live[id] == h {
+		delete(s.live, id)
+	}
+	s.mutex.Unlock()
+}
+
+// applyCommands carries out the commands waiting for the mover.
+func (s *Simulation) applyCommands(m *mover.Mover, commands <-chan mover.Command) {
+	for {
+		select {
+		case c := <-commands:
+			m.Apply(s.world, c)
+		default:
+			return
+		}
+	}
+}
+
+// takePending hands over the movers added since the last call.
+func (s *Simulation) takePending() []mover.Mover {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	pending := s.pending
+	s.pending = nil
+	return pending
+}
+
+// Run moves the fleet until the context is cancelled, writing
+// every position to each of the sinks, then closes the sinks and
+// returns the statistics of the run per sink.
+func (s *Simulation) Run(ctx context.Context) []*sink.RunStats {
+	s.started = time.Now()
+	s.retireLeftovers()
+	s.runPopulation(ctx)
+	return s.close()
+}
+
+// close flushes and closes the sinks once the movers have
+// all stopped, returning the statistics of the run per sink.
+func (s *Simulation) close() []*sink.RunStats {
+	stats := make([]*sink.RunStats, 0, len(s.sinks))
+	for _, sink := range s.sinks {
+		if err := sink.Close(); err != nil {
+			log.Errorf("Unable to close %s: %v", sink.Name(), err)
+		}
+		sink.Stats().Stop()
+		stats = append(stats, sink.Stats())
+	}
+	return stats
+}
+
+// tick moves the mover one step and writes it, as of the given
+// true time, traced as a tick span with the move and the writes
+// as its children.
+func (s *Simulation) tick(m *mover.Mover, ts time.Time) (err error) {
+	ctx, span := telemetry.Tracer().Start(context.Background(), "tick")
+	span.SetAttributes(attribute.Int("mover.id", m.Id), attribute.String("mover.class", m.Class.Name),
+		attribute.Int("mover.tick", m.Ticks+1))
+	defer func() { telemetry.End(span, err) }()
+
+	_, move := telemetry.Tracer().Start(ctx, "move")
+	moved := s.step(m, ts)
+	move.End()
+	if !moved {
+		return nil
+	}
+	err = s.write(ctx, m.Ticks, []mover.Mover{m.Observed(s.opts.Gps)})
+	m.Gap = 0
+	return err
+}
+
+// step moves the mover one step, reporting whether the new
+// position should be written: it is not while the mover is offline
+// or its receiver has no fix.
+func (s *Simulation) step(m *mover.Mover, ts time.Time) bool {
+	zone, x, y := m.Zone, m.X, m.Y
+	if repairs := m.Step(s.world, ts); repairs > 0 {
+		for _, sink := range s.sinks {
+			sink.Stats().RecordRepairs(repairs)
+		}
+	}
+	s.tally.add(m, s.world.Props, x, y)
+	s.world.Index.Update(*m)
+	if s.proximity != nil {
+		s.writeEvents(m, s.proximity.check(*m))
+	}
+	if s.trips != nil {
+		s.writeTrips(m, s.trips.check(*m))
+	}
+	if m.Zone != "" && m.Zone != zone {
+		s.writeEvents(m, []sink.Event{violation(*m)})
+	}
+	if m.Exited {
+		return false
+	}
+	// Keeps moving while offline, but is not reporting
+	if m.Ts.Before(m.OfflineUntil) {
+		return false
+	}
+	return m.Fix(s.opts.Gps)
+}
+
+// write sends positions to every sink, in a span per sink under
+// the span of the context. The write order alternates with the turn
+// so that, when comparing targets, neither one always goes first.
+func (s *Simulation) write(ctx context.Context, turn int, ms []mover.Mover) error {
+	var firstErr error
+	n := len(s.sinks)
+	for i := 0; i < n; i++ {
+		out := s.sinks[(i+turn)%n]
+		writeCtx, span := telemetry.Tracer().Start(ctx, "write "+out.Name())
+		span.SetAttributes(attribute.String("sink", out.Name()), attribute.Int("positions", len(ms)))
+		var err error
+		if traced, ok := out.(sink.TracedSink); ok {
+			err = traced.WriteTraced(writeCtx, ms)
+		} else if len(ms) == 1 {
+			err = out.WritePosition(ms[0])
+		} else {
+			err = out.WriteBatch(ms)
+		}
+		telemetry.End(span, err)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// moverRoutine moves one mover, tracked with the handle, until the
+// context is cancelled, returning true if it stopped because its
+// lifetime ran out or it left its bounds, so that it can be replaced.
+func (s *Simulation) moverRoutine(ctx context.Context, mover mover.Mover, h *handle) bool {
+	defer s.untrack(mover.Id, h)
+	s.world.Index.Update(mover)
+	defer s.world.Index.Remove(mover.Id)
+	defer s.world.Leave(&mover)
+	select {
+	case <-h.removed:
+		// Retired before it reached the sinks
+		return false
+	default:
+	}
+	for _, sink := range s.sinks {
+		if err := sink.Create(mover); err != nil {
+			mover.Logger().Errorf("Unable to create mover in %s: %v", sink.Name(), err)
+			return false
+		}
+	}
+	if s.proximity != nil {
+		defer s.proximity.forget(mover.Id)
+	}
+	defer s.endTrip(&mover)
+
+	var sleep time.Duration
+	var tuned int64
+	for {
+		now := time.Now()
+		if !mover.DiesAt.IsZero() && now.After(mover.DiesAt) {
+			s.delete(mover)
+			return true
+		}
+		mover.ApplyRollouts(s.opts.Rollouts, now.Sub(s.started))
+		if t := s.tuned.Load(); t != tuned {
+			mover.Retune(s.world, s.opts.Rollouts)
+			tuned = t
+		}
+		s.applyCommands(&mover, h.commands)
+		if sleep > 0 {
+			s.catchUp(&mover, now, sleep)
+		}
+		err := s.tick(&mover, now)
+		if mover.Exited {
+			// Left its bounds, to be replaced like a mover that died
+			mover.Logger().Debug("Exited")
+			s.delete(mover)
+			return true
+		}
+		if err != nil {
+			mover.Logger().Errorf("Unable to move mover: %v", err)
+		} else if s.opts.LogEvery > 0 && mover.Ticks%s.opts.LogEvery == 0 {
+			mover.Logger().WithFields(mover.LogFields()).Debug("move")
+		}
+		sleep = mover.Sleep(s.world.Props, s.interval(&mover))
+		select {
+		case <-ctx.Done():
+			return false
+		case <-h.removed:
+			mover.Logger().Debug("Removed")
+			s.delete(mover)
+			return false
+		case <-time.After(sleep):
+		}
+	}
+}
+
+// delete removes the mover from every sink.
+func (s *Simulation) delete(m mover.Mover) {
+	for _, sink := range s.sinks {
+		if err := sink.Delete(m); err != nil {
+			m.Logger().Errorf("Unable to delete mover from %s: %v", sink.Name(), err)
+		}
+	}
+}
+
+// interval is the average time between the mover's reports,
+// in real time at the current speed.
+func (s *Simulation) interval(m *mover.Mover) time.Duration {
+	interval := s.nominalInterval(m)
+	// Never so short that the jitter has nothing to spread
+	return time.Duration(math.Max(float64(interval)/s.Speed(), 2))
+}
+
+// nominalInterval is the average time between the mover's
+// reports in simulated time.
+func (s *Simulation) nominalInterval(m *mover.Mover) time.Duration {
+	if m.SleepInterval > 0 {
+		return m.SleepInterval
+	}
+	return s.opts.SleepInterval
+}
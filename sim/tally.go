@@ -0,0 +1,56 @@
+package sim
+
+import (
+	// System
+	"sync"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+// Tally is what the movers of a simulation have done so far: how
+// many ticks they have moved, and how far, in meters, by class.
+// Movers without a class are counted under "".
+type Tally struct {
+	Ticks    int64              `json:"ticks"`
+	Distance map[string]float64 `json:"distance"`
+}
+
+type tally struct {
+	mutex    sync.Mutex
+	ticks    int64
+	distance map[string]float64
+}
+
+func newTally() *tally {
+	return &tally{distance: make(map[string]float64)}
+}
+
+// add counts one tick of the mover, from where it was before it,
+// see Mover.Moved.
+func (t *tally) add(m *mover.Mover, props *mover.Props, x, y float64) {
+	class := ""
+	if m.Class != nil {
+		class = m.Class.Name
+	}
+	step := m.Moved(props, x, y)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.ticks++
+	t.distance[class] += step
+}
+
+func (t *tally) get() Tally {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	distance := make(map[string]float64, len(t.distance))
+	for class, d := range t.distance {
+		distance[class] = d
+	}
+	return Tally{Ticks: t.ticks, Distance: distance}
+}
+
+// Tally reports the ticks moved and distance covered so far.
+func (s *Simulation) Tally() Tally {
+	return s.tally.get()
+}
@@ -0,0 +1,162 @@
+package sim
+
+import (
+	// System
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/sink"
+)
+
+// TripProps turns on trip segmentation: every sink that records
+// trips gets a record when a mover sets off, and another when it
+// arrives at its destination, stands still (moving no more than
+// StillDistance meters a tick) for MinPause, or stops altogether.
+// Shorter pauses, such as at traffic lights, are part of the trip.
+type TripProps struct {
+	Enabled       bool
+	MinPause      time.Duration
+	StillDistance float64
+}
+
+func (p TripProps) init() error {
+	if p.MinPause < 0 {
+		return fmt.Errorf("trip minimum pause %s is negative", p.MinPause)
+	}
+	if p.StillDistance < 0 {
+		return fmt.Errorf("trip still distance %f is negative", p.StillDistance)
+	}
+	return nil
+}
+
+// trips follows the trip of every mover, numbering the trips
+// apart from those of the other shards.
+type trips struct {
+	props  TripProps
+	shard  ShardProps
+	nextId atomic.Int64
+
+	mutex  sync.Mutex
+	movers map[int]*tripState
+}
+
+// tripState is where a mover was on its last tick, and the trip
+// it is on, if any.
+type tripState struct {
+	x, y  float64
+	ts    time.Time
+	dwell time.Time
+	// Set while on a trip
+	trip *sink.Trip
+	// When and where the mover came to a halt, if it has
+	stillSince time.Time
+	stillX     float64
+	stillY     float64
+}
+
+func newTrips(props TripProps, shard ShardProps) *trips {
+	return &trips{
+		props:  props,
+		shard:  shard,
+		movers: make(map[int]*tripState),
+	}
+}
+
+// check follows the mover to its new position, returning the
+// record of any trip that has just ended or started.
+func (t *trips) check(m mover.Mover) []sink.Trip {
+	ts := m.DeviceTime(m.Ts)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	state, ok := t.movers[m.Id]
+	if !ok {
+		t.movers[m.Id] = &tripState{x: m.X, y: m.Y, ts: ts, dwell: m.DwellUntil}
+		return nil
+	}
+
+	var records []sink.Trip
+	step := geo.Distance(state.x, state.y, m.X, m.Y)
+	moving := step > t.props.StillDistance
+	// The models dwell on arrival
+	arrived := m.DwellUntil.After(state.dwell)
+	if state.trip == nil && moving {
+		state.trip = &sink.Trip{
+			Id:     int64(t.shard.id(int(t.nextId.Add(1)), 1)),
+			Mover:  m,
+			Start:  state.ts,
+			StartX: state.x,
+			StartY: state.y,
+		}
+		records = append(records, *state.trip)
+	}
+	if state.trip != nil {
+		state.trip.Length += step
+		switch {
+		case arrived:
+			records = append(records, state.end(m, ts, m.X, m.Y, sink.TripArrival))
+		case moving:
+			state.stillSince = time.Time{}
+		case state.stillSince.IsZero():
+			state.stillSince, state.stillX, state.stillY = ts, m.X, m.Y
+		case ts.Sub(state.stillSince) >= t.props.MinPause:
+			records = append(records, state.end(m, state.stillSince, state.stillX, state.stillY, sink.TripPause))
+		}
+	}
+	state.x, state.y, state.ts, state.dwell = m.X, m.Y, ts, m.DwellUntil
+	return records
+}
+
+// end finishes the trip, returning its record.
+func (s *tripState) end(m mover.Mover, ts time.Time, x, y float64, reason string) sink.Trip {
+	trip := *s.trip
+	trip.Mover = m
+	trip.End, trip.EndX, trip.EndY = ts, x, y
+	trip.Reason = reason
+	s.trip = nil
+	s.stillSince = time.Time{}
+	return trip
+}
+
+// forget drops a mover that has stopped, returning the
+// record of the end of its trip, if it was on one.
+func (t *trips) forget(m mover.Mover) []sink.Trip {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	state, ok := t.movers[m.Id]
+	delete(t.movers, m.Id)
+	if !ok || state.trip == nil {
+		return nil
+	}
+	if !state.stillSince.IsZero() {
+		return []sink.Trip{state.end(m, state.stillSince, state.stillX, state.stillY, sink.TripStop)}
+	}
+	return []sink.Trip{state.end(m, state.ts, state.x, state.y, sink.TripStop)}
+}
+
+// writeTrips sends the trips to every sink that records them.
+func (s *Simulation) writeTrips(m *mover.Mover, trips []sink.Trip) {
+	for _, t := range trips {
+		for _, out := range s.sinks {
+			if recorder, ok := out.(sink.TripSink); ok {
+				if err := recorder.WriteTrip(t); err != nil {
+					m.Logger().Errorf("Unable to write trip %d to %s: %v", t.Id, out.Name(), err)
+				}
+			}
+		}
+	}
+}
+
+// endTrip ends the trip of a mover that has stopped, if it
+// was on one.
+func (s *Simulation) endTrip(m *mover.Mover) {
+	if s.trips != nil {
+		s.writeTrips(m, s.trips.forget(*m))
+	}
+}
@@ -0,0 +1,19 @@
+package sim
+
+import (
+	// Movers
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/sink"
+)
+
+// violation is the event of the mover entering the no-go zone
+// it is in, which its steering failed to keep it out of, such as
+// when it spawned close by or the zone cut across its destination.
+func violation(m mover.Mover) sink.Event {
+	return sink.Event{
+		Kind: sink.EventViolation,
+		Ts:   m.DeviceTime(m.Ts),
+		A:    m,
+		Zone: m.Zone,
+	}
+}
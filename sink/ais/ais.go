@@ -0,0 +1,305 @@
+// Package ais streams mover positions as AIS NMEA sentences,
+// for marine tracking software.
+package ais
+
+import (
+	// System
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/sink"
+)
+
+// Props configures the AIS sink. With Protocol "tcp" the sink
+// listens on Address and streams sentences to every connected
+// client (the way OpenCPN and most decoders expect a feed), with
+// "udp" it sends datagrams to Address. Each mover gets the MMSI at
+// its id in MmsiList, or MmsiBase plus its id.
+type Props struct {
+	Protocol string
+	Address  string
+	MmsiBase int
+	MmsiList []int
+}
+
+// Meters per nautical mile
+const metersPerNauticalMile = 1852.0
+
+// Speed over ground when it is not known
+const sogNotAvailable = 1023
+
+// Sentences queued per TCP client, past which the client is
+// dropped as too slow rather than holding up the movers
+const clientBuffer = 4096
+
+// How long a TCP client has to take each write
+const writeTimeout = 10 * time.Second
+
+// aisPayload packs bits into the six-bit ASCII armoring of AIVDM.
+type aisPayload struct {
+	bits []byte
+}
+
+func (p *aisPayload) add(value int64, width int) {
+	for i := width - 1; i >= 0; i-- {
+		p.bits = append(p.bits, byte((value>>uint(i))&1))
+	}
+}
+
+func (p *aisPayload) armor() (string, int) {
+	fill := (6 - len(p.bits)%6) % 6
+	bits := append(p.bits, make([]byte, fill)...)
+	out := make([]byte, 0, len(bits)/6)
+	for i := 0; i < len(bits); i += 6 {
+		v := byte(0)
+		for j := 0; j < 6; j++ {
+			v = v<<1 | bits[i+j]
+		}
+		v += 48
+		if v > 87 {
+			v += 8
+		}
+		out = append(out, v)
+	}
+	return string(out), fill
+}
+
+// compassHeading converts a mover heading, counterclockwise from
+// north, into a course clockwise from north.
+func compassHeading(heading int) int {
+	return mover.NormalizeHeading(360 - heading)
+}
+
+// Mmsi is the maritime identity the AIS sink reports for a mover.
+func (p Props) Mmsi(moverId int) int {
+	if moverId >= 0 && moverId < len(p.MmsiList) {
+		return p.MmsiList[moverId]
+	}
+	return p.MmsiBase + moverId
+}
+
+// aisPositionReport encodes the mover as a type 1 position report
+// sentence, on radio channel A or B.
+func aisPositionReport(m mover.Mover, mmsi int, channel byte) string {
+	var p aisPayload
+	p.add(1, 6) // message type
+	p.add(0, 2) // repeat indicator
+	p.add(int64(mmsi), 30)
+	navStatus := int64(0) // under way using engine
+	if m.Velocity == 0 {
+		navStatus = 1 // at anchor
+	}
+	p.add(navStatus, 4)
+	p.add(-128, 8) // rate of turn not available
+
+	// Speed over ground in tenths of a knot, see
+	// mover.Mover.GroundSpeed, unknown without a tick interval
+	sog := int64(sogNotAvailable)
+	if m.SleepInterval > 0 {
+		knots := m.GroundSpeed() * 3600 / metersPerNauticalMile
+		sog = int64(math.Min(math.Round(knots*10), 1022))
+	}
+	p.add(sog, 10)
+
+	p.add(0, 1) // position accuracy
+	p.add(int64(math.Round(m.X*600000)), 28)
+	p.add(int64(math.Round(m.Y*600000)), 27)
+	course := compassHeading(m.Heading)
+	p.add(int64(course*10), 12)
+	p.add(int64(course), 9)
+	p.add(int64(m.DeviceTime(m.Ts).UTC().Second()), 6)
+	p.add(0, 2)  // maneuver indicator
+	p.add(0, 3)  // spare
+	p.add(0, 1)  // RAIM
+	p.add(0, 19) // radio status
+
+	payload, fill := p.armor()
+	return nmeaSentence(fmt.Sprintf("AIVDM,1,1,,%c,%s,%d", channel, payload, fill))
+}
+
+// nmeaSentence wraps the body in a sentence, with the checksum
+// of its characters.
+func nmeaSentence(body string) string {
+	checksum := byte(0)
+	for i := 0; i < len(body); i++ {
+		checksum ^= body[i]
+	}
+	return fmt.Sprintf("!%s*%02X\r\n", body, checksum)
+}
+
+// Sink streams every position as an AIS !AIVDM sentence.
+type Sink struct {
+	mutex    sync.Mutex
+	props    Props
+	listener net.Listener
+	clients  map[*client]struct{}
+	udp      net.Conn
+	stats    *sink.RunStats
+	channel  byte
+}
+
+// client is one TCP connection, written by its own goroutine
+// from its queue, so that a slow client holds up no other.
+type client struct {
+	conn      net.Conn
+	sentences chan []byte
+}
+
+func NewSink(props Props) (*Sink, error) {
+	s := &Sink{
+		props:   props,
+		stats:   sink.NewRunStats(),
+		clients: make(map[*client]struct{}),
+		channel: 'A',
+	}
+	switch props.Protocol {
+	case "tcp":
+		listener, err := net.Listen("tcp", props.Address)
+		if err != nil {
+			return nil, err
+		}
+		s.listener = listener
+		log.Infof("Serving AIS sentences on tcp://%s", listener.Addr())
+		go s.accept()
+	case "udp":
+		conn, err := net.Dial("udp", props.Address)
+		if err != nil {
+			return nil, err
+		}
+		s.udp = conn
+		log.Infof("Sending AIS sentences to udp://%s", props.Address)
+	default:
+		return nil, fmt.Errorf("unknown AIS protocol '%s'", props.Protocol)
+	}
+	return s, nil
+}
+
+func (s *Sink) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			// Listener closed
+			return
+		}
+		log.Infof("AIS client connected from %s", conn.RemoteAddr())
+		c := &client{conn: conn, sentences: make(chan []byte, clientBuffer)}
+		s.mutex.Lock()
+		s.clients[c] = struct{}{}
+		s.mutex.Unlock()
+		go s.serve(c)
+	}
+}
+
+// serve writes the queued sentences to the client until the
+// queue is closed or a write fails.
+func (s *Sink) serve(c *client) {
+	defer c.conn.Close()
+	for sentence := range c.sentences {
+		c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err := c.conn.Write(sentence); err != nil {
+			if s.drop(c) {
+				log.Infof("AIS client %s disconnected: %v", c.conn.RemoteAddr(), err)
+			}
+			return
+		}
+	}
+}
+
+// drop forgets the client, closing its queue, reporting
+// whether it had not been dropped already.
+func (s *Sink) drop(c *client) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.clients[c]; !ok {
+		return false
+	}
+	delete(s.clients, c)
+	close(c.sentences)
+	return true
+}
+
+func (s *Sink) Name() string {
+	return "ais"
+}
+
+func (s *Sink) Stats() *sink.RunStats {
+	return s.stats
+}
+
+func (s *Sink) Create(m mover.Mover) error {
+	return s.WritePosition(m)
+}
+
+func (s *Sink) WritePosition(m mover.Mover) error {
+	return s.WriteBatch([]mover.Mover{m})
+}
+
+func (s *Sink) WriteBatch(ms []mover.Mover) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	start := time.Now()
+	var err error
+	for _, m := range ms {
+		if err = s.send(m); err != nil {
+			break
+		}
+	}
+	s.stats.RecordWrite(len(ms), time.Since(start), err)
+	return err
+}
+
+// send transmits one position report, alternating radio channels.
+func (s *Sink) send(m mover.Mover) error {
+	sentence := []byte(aisPositionReport(m, s.props.Mmsi(m.Id), s.channel))
+	if s.channel == 'A' {
+		s.channel = 'B'
+	} else {
+		s.channel = 'A'
+	}
+
+	if s.udp != nil {
+		_, err := s.udp.Write(sentence)
+		return err
+	}
+	for c := range s.clients {
+		select {
+		case c.sentences <- sentence:
+		default:
+			// Drop clients that cannot keep up rather than
+			// stalling the whole fleet
+			log.Warnf("AIS client %s fell behind, disconnecting", c.conn.RemoteAddr())
+			delete(s.clients, c)
+			close(c.sentences)
+			c.conn.Close()
+		}
+	}
+	return nil
+}
+
+// Delete is a no-op, AIS receivers time out silent vessels.
+func (s *Sink) Delete(m mover.Mover) error {
+	return nil
+}
+
+func (s *Sink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.udp != nil {
+		return s.udp.Close()
+	}
+	err := s.listener.Close()
+	for c := range s.clients {
+		// Whatever is queued still goes out
+		delete(s.clients, c)
+		close(c.sentences)
+	}
+	return err
+}
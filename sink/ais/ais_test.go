@@ -0,0 +1,157 @@
+package ais
+
+import (
+	// System
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+// A type 1 position report from the AIVDM/AIVDO decoding notes of
+// gpsd: MMSI 477553000, moored, stopped at -122.345833, 47.582833,
+// course 51 and heading 181, at second 15.
+const knownSentence = "!AIVDM,1,1,,B,177KQJ5000G?tO`K>RA1wUbN0TKH,0*5C\r\n"
+
+// The widths of the fields of a type 1 message, in order
+var reportWidths = []int{6, 2, 30, 4, 8, 10, 1, 28, 27, 12, 9, 6, 2, 3, 1, 19}
+
+// Signed fields: rate of turn, longitude and latitude
+var reportSigned = map[int]bool{4: true, 7: true, 8: true}
+
+// decodeReport checks the sentence and its checksum, and reads the
+// fields of its payload, straight from the specification rather
+// than from the encoder.
+func decodeReport(t *testing.T, sentence string) []int64 {
+	t.Helper()
+	if !strings.HasPrefix(sentence, "!") || !strings.HasSuffix(sentence, "\r\n") {
+		t.Fatalf("sentence %q is not framed by ! and CRLF", sentence)
+	}
+	body, sum, ok := strings.Cut(strings.TrimSuffix(sentence[1:], "\r\n"), "*")
+	if !ok {
+		t.Fatalf("sentence %q has no checksum", sentence)
+	}
+	checksum := byte(0)
+	for i := 0; i < len(body); i++ {
+		checksum ^= body[i]
+	}
+	if want := fmt.Sprintf("%02X", checksum); sum != want {
+		t.Fatalf("sentence %q has checksum %s, want %s", sentence, sum, want)
+	}
+	parts := strings.Split(body, ",")
+	if len(parts) != 7 || parts[0] != "AIVDM" {
+		t.Fatalf("sentence %q is not a single part AIVDM", sentence)
+	}
+	fill, err := strconv.Atoi(parts[6])
+	if err != nil || fill < 0 || fill > 5 {
+		t.Fatalf("sentence %q has fill bits %q", sentence, parts[6])
+	}
+	var bits []byte
+	for _, c := range []byte(parts[5]) {
+		v := c - 48
+		if v > 40 {
+			v -= 8
+		}
+		for i := 5; i >= 0; i-- {
+			bits = append(bits, (v>>uint(i))&1)
+		}
+	}
+	bits = bits[:len(bits)-fill]
+	fields := make([]int64, len(reportWidths))
+	at := 0
+	for i, width := range reportWidths {
+		if at+width > len(bits) {
+			t.Fatalf("payload of %d bits is too short", len(bits))
+		}
+		var v int64
+		for _, b := range bits[at : at+width] {
+			v = v<<1 | int64(b)
+		}
+		if reportSigned[i] && v>>(width-1) == 1 {
+			v -= 1 << width
+		}
+		fields[i] = v
+		at += width
+	}
+	if at != len(bits) {
+		t.Fatalf("payload has %d bits, want %d", len(bits), at)
+	}
+	return fields
+}
+
+func TestKnownSentence(t *testing.T) {
+	fields := decodeReport(t, knownSentence)
+	want := map[int]int64{0: 1, 2: 477553000, 3: 5, 4: 0, 5: 0, 9: 510, 10: 181, 11: 15}
+	for i, v := range want {
+		if fields[i] != v {
+			t.Errorf("field %d is %d, want %d", i, fields[i], v)
+		}
+	}
+	if lon := float64(fields[7]) / 600000; math.Abs(lon+122.345833) > 1e-6 {
+		t.Errorf("longitude is %f, want -122.345833", lon)
+	}
+	if lat := float64(fields[8]) / 600000; math.Abs(lat-47.582833) > 1e-6 {
+		t.Errorf("latitude is %f, want 47.582833", lat)
+	}
+
+	// Encoded again, the fields give back the same sentence
+	var p aisPayload
+	for i, v := range fields {
+		p.add(v, reportWidths[i])
+	}
+	payload, fill := p.armor()
+	if got := nmeaSentence(fmt.Sprintf("AIVDM,1,1,,B,%s,%d", payload, fill)); got != knownSentence {
+		t.Errorf("sentence is %q, want %q", got, knownSentence)
+	}
+}
+
+func TestArmorFill(t *testing.T) {
+	var p aisPayload
+	p.add(0x3f, 6)
+	p.add(1, 4)
+	payload, fill := p.armor()
+	// 111111 and 0001 padded to 000100
+	if payload != "w4" || fill != 2 {
+		t.Errorf("armor() = %q with %d fill bits, want \"w4\" with 2", payload, fill)
+	}
+}
+
+func TestPositionReport(t *testing.T) {
+	m := mover.Mover{
+		Id:            3,
+		X:             -123.1,
+		Y:             60,
+		Velocity:      0.0001,
+		Heading:       270,
+		SleepInterval: time.Second,
+		Ts:            time.Date(2024, 1, 1, 12, 0, 42, 0, time.UTC),
+	}
+	fields := decodeReport(t, aisPositionReport(m, 316001234, 'A'))
+	// Heading east at 60N, a step of 0.0001 degrees is about 5.6 m
+	knots := 0.0001 * 111320.0 * 0.5 * 3600 / 1852
+	want := map[int]int64{0: 1, 2: 316001234, 3: 0, 4: -128, 5: int64(math.Round(knots * 10)), 9: 900, 10: 90, 11: 42}
+	for i, v := range want {
+		if fields[i] != v {
+			t.Errorf("field %d is %d, want %d", i, fields[i], v)
+		}
+	}
+	if fields[7] != -123.1*600000 || fields[8] != 60*600000 {
+		t.Errorf("position is (%d, %d), want (%d, %d)", fields[7], fields[8], int64(-123.1*600000), 60*600000)
+	}
+
+	// Backing up, the speed is still over the ground
+	m.Velocity = -m.Velocity
+	if sog := decodeReport(t, aisPositionReport(m, 316001234, 'A'))[5]; sog != want[5] {
+		t.Errorf("speed backing up is %d, want %d", sog, want[5])
+	}
+	// Without an interval, not available
+	m.SleepInterval = 0
+	if sog := decodeReport(t, aisPositionReport(m, 316001234, 'A'))[5]; sog != sogNotAvailable {
+		t.Errorf("speed without an interval is %d, want %d", sog, sogNotAvailable)
+	}
+}
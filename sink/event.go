@@ -0,0 +1,55 @@
+package sink
+
+import (
+	// System
+	"time"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+// Event kinds, see Pair for which are between two movers
+const (
+	// Two movers came within the proximity distance
+	EventProximity = "proximity"
+	// A mover entered a no-go zone, for all its steering
+	EventViolation = "violation"
+)
+
+// Event is something that happened between two movers, the
+// Distance in meters apart, or to a mover in a no-go Zone, at the
+// device time of the mover that noticed it. Its Kind says which.
+type Event struct {
+	Kind     string
+	Ts       time.Time
+	A        mover.Mover
+	B        mover.Mover
+	Distance float64
+	Zone     string
+}
+
+// Pair reports whether the event is between two movers, A and B,
+// rather than of A alone, by its kind.
+func (e Event) Pair() bool {
+	switch e.Kind {
+	case EventProximity:
+		return true
+	default:
+		return false
+	}
+}
+
+// Midpoint is halfway between the two movers, or where A is
+// for events of A alone.
+func (e Event) Midpoint() (float64, float64) {
+	if !e.Pair() {
+		return e.A.X, e.A.Y
+	}
+	return (e.A.X + e.B.X) / 2, (e.A.Y + e.B.Y) / 2
+}
+
+// EventSink is a sink that also records events. Simulations
+// send events to those of their sinks that implement it.
+type EventSink interface {
+	WriteEvent(e Event) error
+}
@@ -0,0 +1,33 @@
+package sink
+
+import (
+	// System
+	"testing"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+func TestEventPair(t *testing.T) {
+	a := mover.Mover{Id: 1, X: 0, Y: 0}
+	b := mover.Mover{Id: 2, X: 2, Y: 4}
+	tests := []struct {
+		name string
+		e    Event
+		pair bool
+		x, y float64
+	}{
+		{"proximity", Event{Kind: EventProximity, A: a, B: b}, true, 1, 2},
+		{"violation", Event{Kind: EventViolation, A: a, Zone: "site"}, false, 0, 0},
+		// Taken by its kind, not by whether it names a zone
+		{"violation of an unnamed zone", Event{Kind: EventViolation, A: a}, false, 0, 0},
+	}
+	for _, test := range tests {
+		if pair := test.e.Pair(); pair != test.pair {
+			t.Errorf("%s: Pair() = %t, want %t", test.name, pair, test.pair)
+		}
+		if x, y := test.e.Midpoint(); x != test.x || y != test.y {
+			t.Errorf("%s: Midpoint() = (%g, %g), want (%g, %g)", test.name, x, y, test.x, test.y)
+		}
+	}
+}
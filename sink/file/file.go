@@ -0,0 +1,251 @@
+// Package file writes mover positions to files or stdout,
+// without any database.
+package file
+
+import (
+	// System
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/sink"
+)
+
+// File sink formats
+const (
+	// One JSON record per line for every position written, a
+	// GeoJSON Feature when the geometry encoding is geojson
+	FormatLines = "lines"
+	// A GeoJSON FeatureCollection of the whole fleet,
+	// rewritten every interval
+	FormatCollection = "collection"
+	// Tab separated id, timestamp and geometry per line, which
+	// PostgreSQL COPY can load directly
+	FormatRaw = "raw"
+)
+
+type geojsonCollection struct {
+	Type     string         `json:"type"`
+	Features []sink.Feature `json:"features"`
+}
+
+// Sink writes positions without any database: as JSON lines
+// (to a file or stdout, ready to pipe into tippecanoe or jq), as
+// raw tab separated geometry lines, or as a FeatureCollection
+// file of the current fleet, atomically replaced every interval.
+type Sink struct {
+	mutex    sync.Mutex
+	filename string
+	format   string
+	encoding string
+	file     io.WriteCloser
+	buffer   *bufio.Writer
+	encoder  *json.Encoder
+	movers   map[int]sink.Feature
+	stats    *sink.RunStats
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func NewSink(filename, format, encoding string, interval time.Duration) (*Sink, error) {
+	if _, err := geo.EncodePoint(0, 0, encoding); err != nil {
+		return nil, err
+	}
+	s := &Sink{
+		filename: filename,
+		format:   format,
+		encoding: encoding,
+		stats:    sink.NewRunStats(),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	switch format {
+	case FormatLines, FormatRaw:
+		if filename == "" || filename == "-" {
+			s.file = os.Stdout
+		} else {
+			file, err := os.Create(filename)
+			if err != nil {
+				return nil, err
+			}
+			s.file = file
+		}
+		s.buffer = bufio.NewWriter(s.file)
+		s.encoder = json.NewEncoder(s.buffer)
+	case FormatCollection:
+		if filename == "" || filename == "-" {
+			return nil, fmt.Errorf("the %s format needs an output file", format)
+		}
+		if encoding != geo.EncodingGeoJSON {
+			return nil, fmt.Errorf("the %s format only supports geojson geometry", format)
+		}
+		s.movers = make(map[int]sink.Feature)
+	default:
+		return nil, fmt.Errorf("unknown file format '%s'", format)
+	}
+	go s.run(interval)
+	return s, nil
+}
+
+func (s *Sink) Name() string {
+	return "file"
+}
+
+func (s *Sink) Stats() *sink.RunStats {
+	return s.stats
+}
+
+func (s *Sink) Create(m mover.Mover) error {
+	return s.WritePosition(m)
+}
+
+func (s *Sink) WritePosition(m mover.Mover) error {
+	return s.WriteBatch([]mover.Mover{m})
+}
+
+func (s *Sink) WriteBatch(ms []mover.Mover) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	start := time.Now()
+	var err error
+	for _, m := range ms {
+		if err = s.write(m); err != nil {
+			break
+		}
+	}
+	s.stats.RecordWrite(len(ms), time.Since(start), err)
+	return err
+}
+
+func (s *Sink) write(m mover.Mover) error {
+	switch {
+	case s.movers != nil:
+		s.movers[m.Id] = sink.NewFeature(m)
+		return nil
+	case s.format == FormatRaw:
+		return s.writeRaw(m)
+	case s.encoding == geo.EncodingGeoJSON:
+		return s.encoder.Encode(sink.NewFeature(m))
+	default:
+		return s.writeRecord(m)
+	}
+}
+
+// writeRecord writes the mover properties as a flat JSON
+// object, with the encoded geometry in "geom".
+func (s *Sink) writeRecord(m mover.Mover) error {
+	geom, err := geo.EncodeCoordinates(sink.Coordinates(m), s.encoding)
+	if err != nil {
+		return err
+	}
+	record := sink.Properties(m)
+	record["geom"] = geom
+	return s.encoder.Encode(record)
+}
+
+func (s *Sink) writeRaw(m mover.Mover) error {
+	geom, err := geo.EncodeCoordinates(sink.Coordinates(m), s.encoding)
+	if err != nil {
+		return err
+	}
+	s.buffer.WriteString(strconv.Itoa(m.Id))
+	s.buffer.WriteByte('\t')
+	s.buffer.WriteString(m.DeviceTime(m.Ts).Format(time.RFC3339Nano))
+	s.buffer.WriteByte('\t')
+	s.buffer.WriteString(geom)
+	return s.buffer.WriteByte('\n')
+}
+
+// Delete drops the mover from the collection. Lines
+// already written cannot be taken back.
+func (s *Sink) Delete(m mover.Mover) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.movers != nil {
+		delete(s.movers, m.Id)
+	}
+	return nil
+}
+
+func (s *Sink) Close() error {
+	close(s.stop)
+	<-s.done
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.movers != nil {
+		return s.writeCollection()
+	}
+	if err := s.buffer.Flush(); err != nil {
+		return err
+	}
+	if s.file != os.Stdout {
+		return s.file.Close()
+	}
+	return nil
+}
+
+// run flushes lines, or rewrites the collection, every interval.
+func (s *Sink) run(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mutex.Lock()
+			var err error
+			if s.movers != nil {
+				err = s.writeCollection()
+			} else {
+				err = s.buffer.Flush()
+			}
+			s.mutex.Unlock()
+			if err != nil {
+				s.stats.RecordWrite(0, 0, err)
+			}
+		}
+	}
+}
+
+// writeCollection replaces the collection file, writing to a
+// temporary file first so readers never see a partial file.
+func (s *Sink) writeCollection() error {
+	collection := geojsonCollection{
+		Type:     "FeatureCollection",
+		Features: make([]sink.Feature, 0, len(s.movers)),
+	}
+	for _, f := range s.movers {
+		collection.Features = append(collection.Features, f)
+	}
+	sort.Slice(collection.Features, func(i, j int) bool {
+		return collection.Features[i].Id < collection.Features[j].Id
+	})
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.filename), ".movesim-*.geojson")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := json.NewEncoder(tmp).Encode(collection); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.filename)
+}
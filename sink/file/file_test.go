@@ -0,0 +1,180 @@
+package file
+
+import (
+	// System
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+func testMover(id int, x, y float64) mover.Mover {
+	return mover.Mover{
+		Id:    id,
+		X:     x,
+		Y:     y,
+		Ts:    time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		Class: &mover.Class{Name: "car"},
+	}
+}
+
+// readLines decodes every line of the file as a JSON object.
+func readLines(t *testing.T, filename string) []map[string]interface{} {
+	t.Helper()
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("line %q is not JSON: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestLines(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "fleet.json")
+	s, err := NewSink(filename, FormatLines, geo.EncodingGeoJSON, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Create(testMover(1, -123.1, 49.2))
+	s.WriteBatch([]mover.Mover{testMover(1, -123.2, 49.3), testMover(2, -123.3, 49.4)})
+	s.Delete(testMover(1, 0, 0))
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := readLines(t, filename)
+	want := []struct {
+		id   float64
+		x, y float64
+	}{{1, -123.1, 49.2}, {1, -123.2, 49.3}, {2, -123.3, 49.4}}
+	if len(lines) != len(want) {
+		t.Fatalf("file has %d lines, want %d", len(lines), len(want))
+	}
+	for i, w := range want {
+		line := lines[i]
+		coords := line["geometry"].(map[string]interface{})["coordinates"].([]interface{})
+		if line["type"] != "Feature" || line["id"] != w.id || coords[0] != w.x || coords[1] != w.y {
+			t.Errorf("line %d is %v, want mover %g at (%g, %g)", i, line, w.id, w.x, w.y)
+		}
+		if class := line["properties"].(map[string]interface{})["class"]; class != "car" {
+			t.Errorf("line %d has class %v, want car", i, class)
+		}
+	}
+}
+
+func TestCollection(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "fleet.geojson")
+	s, err := NewSink(filename, FormatCollection, geo.EncodingGeoJSON, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Create(testMover(2, -123.1, 49.2))
+	s.Create(testMover(1, -123.2, 49.3))
+	s.Create(testMover(3, -123.3, 49.4))
+	s.WritePosition(testMover(1, -123.5, 49.5))
+	s.Delete(testMover(3, 0, 0))
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var collection geojsonCollection
+	if err := json.Unmarshal(data, &collection); err != nil {
+		t.Fatal(err)
+	}
+	features := collection.Features
+	if collection.Type != "FeatureCollection" || len(features) != 2 {
+		t.Fatalf("file is a %s of %d features, want a FeatureCollection of 2", collection.Type, len(features))
+	}
+	if features[0].Id != 1 || features[1].Id != 2 {
+		t.Errorf("features are movers %d and %d, want 1 and 2", features[0].Id, features[1].Id)
+	}
+	if coords := features[0].Geometry.Coordinates; coords[0] != -123.5 || coords[1] != 49.5 {
+		t.Errorf("mover 1 is at %v, want its latest position", coords)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(filepath.Dir(filename), ".movesim-*")); len(matches) > 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+}
+
+func TestRecords(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "fleet.json")
+	s, err := NewSink(filename, FormatLines, geo.EncodingWKT, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.WritePosition(testMover(1, -123.5, 49.25))
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	lines := readLines(t, filename)
+	if len(lines) != 1 || lines[0]["geom"] != "POINT(-123.5 49.25)" || lines[0]["id"] != 1.0 || lines[0]["class"] != "car" {
+		t.Errorf("file is %v, want one flat record of mover 1 with a WKT geom", lines)
+	}
+}
+
+func TestRaw(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "fleet.tsv")
+	s, err := NewSink(filename, FormatRaw, geo.EncodingEWKB, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.WritePosition(testMover(7, -123.5, 49.25))
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Little endian point with an SRID of 4326
+	ewkb := []byte{1}
+	ewkb = binary.LittleEndian.AppendUint32(ewkb, 0x20000001)
+	ewkb = binary.LittleEndian.AppendUint32(ewkb, 4326)
+	ewkb = binary.LittleEndian.AppendUint64(ewkb, math.Float64bits(-123.5))
+	ewkb = binary.LittleEndian.AppendUint64(ewkb, math.Float64bits(49.25))
+	want := "7\t2024-01-01T12:00:00Z\t" + strings.ToUpper(hex.EncodeToString(ewkb)) + "\n"
+	if string(data) != want {
+		t.Errorf("file is %q, want %q", data, want)
+	}
+}
+
+func TestNewSinkErrors(t *testing.T) {
+	if _, err := NewSink("", FormatCollection, geo.EncodingGeoJSON, time.Hour); err == nil {
+		t.Error("collection without a file, want an error")
+	}
+	if _, err := NewSink("", "xml", geo.EncodingGeoJSON, time.Hour); err == nil {
+		t.Error("unknown format, want an error")
+	}
+	if _, err := NewSink("", FormatLines, "gml", time.Hour); err == nil {
+		t.Error("unknown encoding, want an error")
+	}
+	if _, err := NewSink(filepath.Join(t.TempDir(), "fleet.geojson"), FormatCollection, geo.EncodingWKT, time.Hour); err == nil {
+		t.Error("collection of WKT, want an error")
+	}
+}
@@ -0,0 +1,72 @@
+package sink
+
+import (
+	// System
+	"time"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+// Point is a GeoJSON point geometry.
+type Point struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+// Feature is a mover position as a GeoJSON Feature, the
+// record the JSON based sinks write.
+type Feature struct {
+	Type       string                 `json:"type"`
+	Id         int                    `json:"id"`
+	Geometry   Point                  `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Properties describes the mover, with its payload fields.
+func Properties(m mover.Mover) map[string]interface{} {
+	props := map[string]interface{}{
+		"id":       m.Id,
+		"name":     m.Name,
+		"color":    m.Color,
+		"class":    m.Class.Name,
+		"priority": m.Class.Priority,
+		"minzoom":  m.Class.MinZoom,
+		"maxzoom":  m.Class.MaxZoom,
+		"heading":  m.Heading,
+		"velocity": m.Velocity,
+		"ts":       m.DeviceTime(m.Ts).Format(time.RFC3339Nano),
+	}
+	if m.HasZ {
+		props["climb"] = m.Climb
+	}
+	if m.Gap > 0 {
+		props["gap_s"] = m.Gap.Seconds()
+	}
+	for k, v := range m.Fields {
+		props[k] = v
+	}
+	return props
+}
+
+// NewFeature is the current position of the mover as a Feature.
+func NewFeature(m mover.Mover) Feature {
+	return Feature{
+		Type: "Feature",
+		Id:   m.Id,
+		Geometry: Point{
+			Type:        "Point",
+			Coordinates: Coordinates(m),
+		},
+		Properties: Properties(m),
+	}
+}
+
+// Coordinates is the position of the mover, with
+// its altitude if it has one.
+func Coordinates(m mover.Mover) []float64 {
+	if m.HasZ {
+		return []float64{m.X, m.Y, m.Z}
+	}
+	return []float64{m.X, m.Y}
+}
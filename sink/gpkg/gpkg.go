@@ -0,0 +1,298 @@
+//go:build gpkg
+
+// Package gpkg writes mover positions to a GeoPackage file, with
+// the objects and history tables of the database sink, so a run can
+// be inspected in QGIS without a PostgreSQL server. Its SQLite
+// driver needs cgo, so it is only built with the gpkg build tag.
+package gpkg
+
+import (
+	// System
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	// SQLite driver, which needs cgo
+	_ "github.com/mattn/go-sqlite3"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/sink"
+)
+
+// GeoPackage identifiers, "GPKG" and version 1.2
+const (
+	applicationId = 0x47504B47
+	userVersion   = 10200
+)
+
+// The metadata tables and the two feature tables. Times are
+// GeoPackage datetimes, ISO 8601 text in UTC.
+var schemaSql = []string{
+	`CREATE TABLE gpkg_spatial_ref_sys (
+		srs_name TEXT NOT NULL,
+		srs_id INTEGER PRIMARY KEY,
+		organization TEXT NOT NULL,
+		organization_coordsys_id INTEGER NOT NULL,
+		definition TEXT NOT NULL,
+		description TEXT)`,
+	`INSERT INTO gpkg_spatial_ref_sys VALUES
+		('Undefined cartesian SRS', -1, 'NONE', -1, 'undefined', 'undefined cartesian coordinate reference system'),
+		('Undefined geographic SRS', 0, 'NONE', 0, 'undefined', 'undefined geographic coordinate reference system'),
+		('WGS 84 geodetic', 4326, 'EPSG', 4326, 'GEOGCS["WGS 84",DATUM["WGS_1984",SPHEROID["WGS 84",6378137,298.257223563,AUTHORITY["EPSG","7030"]],AUTHORITY["EPSG","6326"]],PRIMEM["Greenwich",0,AUTHORITY["EPSG","8901"]],UNIT["degree",0.0174532925199433,AUTHORITY["EPSG","9122"]],AUTHORITY["EPSG","4326"]]', 'longitude/latitude coordinates in decimal degrees on the WGS 84 spheroid')`,
+	`CREATE TABLE gpkg_contents (
+		table_name TEXT NOT NULL PRIMARY KEY,
+		data_type TEXT NOT NULL,
+		identifier TEXT UNIQUE,
+		description TEXT DEFAULT '',
+		last_change DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')),
+		min_x DOUBLE, min_y DOUBLE, max_x DOUBLE, max_y DOUBLE,
+		srs_id INTEGER REFERENCES gpkg_spatial_ref_sys(srs_id))`,
+	`CREATE TABLE gpkg_geometry_columns (
+		table_name TEXT NOT NULL,
+		column_name TEXT NOT NULL,
+		geometry_type_name TEXT NOT NULL,
+		srs_id INTEGER NOT NULL REFERENCES gpkg_spatial_ref_sys(srs_id),
+		z TINYINT NOT NULL,
+		m TINYINT NOT NULL,
+		PRIMARY KEY (table_name, column_name))`,
+	`CREATE TABLE objects (
+		fid INTEGER PRIMARY KEY,
+		geom POINT,
+		name TEXT,
+		color TEXT,
+		ts DATETIME,
+		class TEXT,
+		priority INTEGER,
+		minzoom INTEGER,
+		maxzoom INTEGER,
+		heading INTEGER,
+		velocity DOUBLE,
+		props TEXT)`,
+	`CREATE TABLE history (
+		fid INTEGER PRIMARY KEY AUTOINCREMENT,
+		geom POINT,
+		mover_id INTEGER NOT NULL,
+		ts DATETIME,
+		heading INTEGER,
+		velocity DOUBLE,
+		props TEXT)`,
+	`CREATE INDEX history_mover_id_ts_x ON history (mover_id, ts)`,
+	`INSERT INTO gpkg_contents (table_name, data_type, identifier, description, min_x, min_y, max_x, max_y, srs_id) VALUES
+		('objects', 'features', 'objects', 'Latest position of every mover', -180, -90, 180, 90, 4326),
+		('history', 'features', 'history', 'Every position written', -180, -90, 180, 90, 4326)`,
+	`INSERT INTO gpkg_geometry_columns VALUES
+		('objects', 'geom', 'POINT', 4326, 2, 0),
+		('history', 'geom', 'POINT', 4326, 2, 0)`,
+}
+
+const (
+	upsertSql = `INSERT INTO objects (fid, geom, name, color, ts, class, priority, minzoom, maxzoom, heading, velocity, props)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (fid) DO UPDATE SET geom = excluded.geom, ts = excluded.ts,
+		heading = excluded.heading, velocity = excluded.velocity, props = excluded.props`
+	historySql = `INSERT INTO history (geom, mover_id, ts, heading, velocity, props) VALUES (?, ?, ?, ?, ?, ?)`
+	deleteSql  = `DELETE FROM objects WHERE fid = ?`
+)
+
+// GeoPackage datetime format
+const datetime = "2006-01-02T15:04:05.000Z"
+
+// Sink writes to one GeoPackage file, replacing any file already
+// there. SQLite commits are slow, so writes are gathered into a
+// transaction committed every interval. The history table is only
+// filled when history is set.
+type Sink struct {
+	mutex   sync.Mutex
+	db      *sql.DB
+	tx      *sql.Tx
+	history bool
+	stats   *sink.RunStats
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func NewSink(filename string, history bool, interval time.Duration) (*Sink, error) {
+	if filename == "" || filename == "-" {
+		return nil, fmt.Errorf("the gpkg sink needs an output file")
+	}
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", filename)
+	if err != nil {
+		return nil, err
+	}
+	// One connection, so the transaction sees every write
+	db.SetMaxOpenConns(1)
+	statements := append([]string{
+		fmt.Sprintf("PRAGMA application_id = %d", applicationId),
+		fmt.Sprintf("PRAGMA user_version = %d", userVersion),
+	}, schemaSql...)
+	for _, statement := range statements {
+		if _, err := db.Exec(statement); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("unable to create GeoPackage %s: %w", filename, err)
+		}
+	}
+
+	s := &Sink{
+		db:      db,
+		history: history,
+		stats:   sink.NewRunStats(),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go s.run(interval)
+	return s, nil
+}
+
+func (s *Sink) Name() string {
+	return "gpkg"
+}
+
+func (s *Sink) Stats() *sink.RunStats {
+	return s.stats
+}
+
+// begin opens the transaction for the current interval.
+func (s *Sink) begin() (*sql.Tx, error) {
+	if s.tx == nil {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		s.tx = tx
+	}
+	return s.tx, nil
+}
+
+func (s *Sink) commit() error {
+	if s.tx == nil {
+		return nil
+	}
+	err := s.tx.Commit()
+	s.tx = nil
+	return err
+}
+
+func (s *Sink) Create(m mover.Mover) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.upsert(m)
+}
+
+func (s *Sink) WritePosition(m mover.Mover) error {
+	return s.WriteBatch([]mover.Mover{m})
+}
+
+func (s *Sink) WriteBatch(ms []mover.Mover) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	start := time.Now()
+	var err error
+	for _, m := range ms {
+		if err = s.upsert(m); err != nil {
+			break
+		}
+		if s.history {
+			if err = s.append(m); err != nil {
+				break
+			}
+		}
+	}
+	s.stats.RecordWrite(len(ms), time.Since(start), err)
+	return err
+}
+
+func (s *Sink) upsert(m mover.Mover) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	props, err := propsText(m)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(upsertSql, m.Id, geo.CoordinatesGPKG(sink.Coordinates(m)), m.Name, m.Color,
+		m.DeviceTime(m.Ts).UTC().Format(datetime), m.Class.Name, m.Class.Priority,
+		m.Class.MinZoom, m.Class.MaxZoom, m.Heading, m.Velocity, props)
+	return err
+}
+
+func (s *Sink) append(m mover.Mover) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	props, err := propsText(m)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(historySql, geo.CoordinatesGPKG(sink.Coordinates(m)), m.Id,
+		m.DeviceTime(m.Ts).UTC().Format(datetime), m.Heading, m.Velocity, props)
+	return err
+}
+
+func (s *Sink) Delete(m mover.Mover) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(deleteSql, m.Id)
+	return err
+}
+
+// Close commits the last writes and closes the file.
+func (s *Sink) Close() error {
+	close(s.stop)
+	<-s.done
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.commit(); err != nil {
+		s.db.Close()
+		return err
+	}
+	return s.db.Close()
+}
+
+// run commits the writes every interval.
+func (s *Sink) run(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mutex.Lock()
+			err := s.commit()
+			s.mutex.Unlock()
+			if err != nil {
+				s.stats.RecordWrite(0, 0, err)
+			}
+		}
+	}
+}
+
+// propsText is the mover payload fields as JSON text,
+// NULL rather than an empty object when there are none.
+func propsText(m mover.Mover) (interface{}, error) {
+	if len(m.Fields) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(m.Fields)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
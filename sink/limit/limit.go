@@ -0,0 +1,261 @@
+// Package limit puts a bounded, rate limited queue in front of
+// another sink, so a large fleet cannot overwhelm a small demo
+// database. Rather than falling further and further behind, the
+// queue keeps only the latest position of each mover and, once
+// full, drops updates or holds the movers back.
+package limit
+
+import (
+	// System
+	"sync"
+	"time"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/sink"
+)
+
+// Props caps the position updates reaching the sink at Rate a
+// second, zero for no cap, with at most Queue movers waiting. When
+// the queue is full, updates of movers not already in it are
+// dropped, or with Block, their movers wait for room.
+type Props struct {
+	Rate  float64
+	Queue int
+	Block bool
+}
+
+// Enabled reports whether the props ask for a queue at all.
+func (p Props) Enabled() bool {
+	return p.Rate > 0 || p.Queue > 0
+}
+
+// Most positions sent to the sink in one batch
+const maxBatch = 1000
+
+// How often to wake up and send what the rate allows
+const tick = 10 * time.Millisecond
+
+// Log drops and coalescing no more often than this
+const reportInterval = 10 * time.Second
+
+// Sink queues position updates for the sink it wraps. Creates,
+// deletes and events go straight through; a delete first discards
+// the queued position of the mover.
+type Sink struct {
+	out   sink.Sink
+	props Props
+
+	mutex   sync.Mutex
+	room    *sync.Cond
+	order   []int
+	pending map[int]mover.Mover
+	closed  bool
+
+	// Held while writing to the wrapped sink, so that a
+	// delete cannot overtake a position already dequeued
+	writing sync.Mutex
+
+	// Counts since they were last recorded in the stats
+	dropped, coalesced int
+	// Totals as of the last report
+	reported int64
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func NewSink(out sink.Sink, props Props) *Sink {
+	if props.Queue <= 0 {
+		props.Queue = 10000
+	}
+	s := &Sink{
+		out:     out,
+		props:   props,
+		pending: make(map[int]mover.Mover),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	s.room = sync.NewCond(&s.mutex)
+	go s.run()
+	return s
+}
+
+func (s *Sink) Name() string {
+	return s.out.Name()
+}
+
+func (s *Sink) Stats() *sink.RunStats {
+	return s.out.Stats()
+}
+
+func (s *Sink) Create(m mover.Mover) error {
+	return s.out.Create(m)
+}
+
+func (s *Sink) WritePosition(m mover.Mover) error {
+	return s.WriteBatch([]mover.Mover{m})
+}
+
+// WriteBatch queues the positions, coalescing each with any
+// position of the same mover still waiting.
+func (s *Sink) WriteBatch(ms []mover.Mover) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, m := range ms {
+		if _, ok := s.pending[m.Id]; ok {
+			s.pending[m.Id] = m
+			s.coalesced++
+			continue
+		}
+		for s.props.Block && !s.closed && len(s.order) >= s.props.Queue {
+			s.room.Wait()
+		}
+		if len(s.order) >= s.props.Queue {
+			s.dropped++
+			continue
+		}
+		s.order = append(s.order, m.Id)
+		s.pending[m.Id] = m
+	}
+	return nil
+}
+
+func (s *Sink) Delete(m mover.Mover) error {
+	s.writing.Lock()
+	defer s.writing.Unlock()
+	s.mutex.Lock()
+	if _, ok := s.pending[m.Id]; ok {
+		delete(s.pending, m.Id)
+		for i, id := range s.order {
+			if id == m.Id {
+				s.order = append(s.order[:i], s.order[i+1:]...)
+				break
+			}
+		}
+		s.room.Broadcast()
+	}
+	s.mutex.Unlock()
+	return s.out.Delete(m)
+}
+
+func (s *Sink) WriteEvent(e sink.Event) error {
+	if recorder, ok := s.out.(sink.EventSink); ok {
+		return recorder.WriteEvent(e)
+	}
+	return nil
+}
+
+func (s *Sink) WriteTrip(t sink.Trip) error {
+	if recorder, ok := s.out.(sink.TripSink); ok {
+		return recorder.WriteTrip(t)
+	}
+	return nil
+}
+
+// Close sends whatever is still queued, ignoring the rate,
+// and closes the wrapped sink.
+func (s *Sink) Close() error {
+	close(s.stop)
+	<-s.done
+	s.mutex.Lock()
+	s.closed = true
+	s.room.Broadcast()
+	s.mutex.Unlock()
+	for s.send(maxBatch) > 0 {
+		// Until the queue is empty
+	}
+	s.report()
+	return s.out.Close()
+}
+
+// take dequeues up to n positions, oldest first.
+func (s *Sink) take(n int) []mover.Mover {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if n > len(s.order) {
+		n = len(s.order)
+	}
+	ms := make([]mover.Mover, 0, n)
+	for _, id := range s.order[:n] {
+		ms = append(ms, s.pending[id])
+		delete(s.pending, id)
+	}
+	s.order = s.order[n:]
+	s.out.Stats().RecordQueue(len(s.order), s.dropped, s.coalesced)
+	s.dropped, s.coalesced = 0, 0
+	if n > 0 {
+		s.room.Broadcast()
+	}
+	return ms
+}
+
+// send writes up to n queued positions to the wrapped sink,
+// returning how many it sent.
+func (s *Sink) send(n int) int {
+	s.writing.Lock()
+	defer s.writing.Unlock()
+	ms := s.take(n)
+	if len(ms) == 0 {
+		return 0
+	}
+	var err error
+	if len(ms) == 1 {
+		err = s.out.WritePosition(ms[0])
+	} else {
+		err = s.out.WriteBatch(ms)
+	}
+	if err != nil {
+		log.Errorf("Unable to write %d queued updates to %s: %v", len(ms), s.out.Name(), err)
+	}
+	return len(ms)
+}
+
+// run sends the queue on as fast as the rate allows, from a token
+// bucket holding up to a second of updates.
+func (s *Sink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	reporter := time.NewTicker(reportInterval)
+	defer reporter.Stop()
+	tokens := 0.0
+	last := time.Now()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-reporter.C:
+			s.report()
+		case now := <-ticker.C:
+			n := maxBatch
+			if s.props.Rate > 0 {
+				tokens += s.props.Rate * now.Sub(last).Seconds()
+				if burst := s.props.Rate; tokens > burst {
+					tokens = burst
+				}
+				if float64(n) > tokens {
+					n = int(tokens)
+				}
+			}
+			last = now
+			sent := s.send(n)
+			tokens -= float64(sent)
+		}
+	}
+}
+
+// report logs how far the sink is falling behind, if it
+// has dropped or coalesced anything since the last report.
+func (s *Sink) report() {
+	summary := s.out.Stats().Summary()
+	total := summary.Dropped + summary.Coalesced
+	if total == s.reported {
+		return
+	}
+	s.reported = total
+	log.Warnf("Writes to %s are held back: %d queued, %d updates dropped and %d coalesced so far",
+		s.out.Name(), summary.QueueDepth, summary.Dropped, summary.Coalesced)
+}
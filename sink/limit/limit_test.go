@@ -0,0 +1,86 @@
+package limit
+
+import (
+	// System
+	"testing"
+	"time"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/sink/memory"
+)
+
+func TestCoalesceAndDrop(t *testing.T) {
+	out := memory.NewSink(true)
+	// Too slow a rate to send anything before the close
+	s := NewSink(out, Props{Rate: 0.001, Queue: 2})
+	s.WriteBatch([]mover.Mover{{Id: 1, X: 1}, {Id: 2, X: 1}, {Id: 1, X: 2}, {Id: 3, X: 1}})
+	s.Delete(mover.Mover{Id: 2})
+	s.WritePosition(mover.Mover{Id: 4, X: 1})
+	if history := out.History(); len(history) != 0 {
+		t.Fatalf("%d positions sent past the rate", len(history))
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 coalesced, 3 dropped while the queue was full, 2 deleted
+	// while queued, making room for 4
+	history := out.History()
+	if len(history) != 2 || history[0].Id != 1 || history[0].X != 2 || history[1].Id != 4 {
+		t.Errorf("sent %v, want the latest of mover 1, then mover 4", history)
+	}
+	if _, deleted := out.Counts(); deleted != 1 {
+		t.Errorf("%d deletes passed on, want 1", deleted)
+	}
+	summary := out.Stats().Summary()
+	if summary.Coalesced != 1 || summary.Dropped != 1 || summary.QueueDepth != 0 {
+		t.Errorf("stats have %d coalesced, %d dropped and %d queued, want 1, 1 and 0",
+			summary.Coalesced, summary.Dropped, summary.QueueDepth)
+	}
+}
+
+func TestRate(t *testing.T) {
+	out := memory.NewSink(true)
+	s := NewSink(out, Props{Rate: 50, Queue: 1000})
+	var ms []mover.Mover
+	for id := 0; id < 500; id++ {
+		ms = append(ms, mover.Mover{Id: id})
+	}
+	s.WriteBatch(ms)
+	time.Sleep(200 * time.Millisecond)
+	// About ten in a fifth of a second, with plenty of slack
+	if sent := len(out.History()); sent > 100 {
+		t.Errorf("%d positions sent in 200ms at 50 a second", sent)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if sent := len(out.History()); sent != 500 {
+		t.Errorf("%d positions sent by the close, want all 500", sent)
+	}
+}
+
+func TestBlock(t *testing.T) {
+	out := memory.NewSink(true)
+	s := NewSink(out, Props{Queue: 1, Block: true})
+	written := make(chan struct{})
+	go func() {
+		s.WriteBatch([]mover.Mover{{Id: 1}, {Id: 2}, {Id: 3}})
+		close(written)
+	}()
+	select {
+	case <-written:
+	case <-time.After(5 * time.Second):
+		t.Fatal("writes still held back after 5s")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if history := out.History(); len(history) != 3 {
+		t.Errorf("sent %v, want all three movers", history)
+	}
+	if summary := out.Stats().Summary(); summary.Dropped != 0 {
+		t.Errorf("%d dropped, want none when blocking", summary.Dropped)
+	}
+}
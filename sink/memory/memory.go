@@ -0,0 +1,146 @@
+// Package memory keeps mover positions in memory, for dry runs
+// and for checking what a simulation writes without any database.
+package memory
+
+import (
+	// System
+	"sort"
+	"sync"
+	"time"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/sink"
+)
+
+// Sink holds the latest state of every live mover and, when asked
+// to, every position ever written. Safe for concurrent use.
+type Sink struct {
+	mutex   sync.Mutex
+	movers  map[int]mover.Mover
+	history []mover.Mover
+	events  []sink.Event
+	trips   []sink.Trip
+	keep    bool
+	created int
+	deleted int
+	stats   *sink.RunStats
+}
+
+// NewSink makes an empty sink, keeping the full history of
+// positions if keepHistory is set.
+func NewSink(keepHistory bool) *Sink {
+	return &Sink{
+		movers: make(map[int]mover.Mover),
+		keep:   keepHistory,
+		stats:  sink.NewRunStats(),
+	}
+}
+
+func (s *Sink) Name() string {
+	return "memory"
+}
+
+func (s *Sink) Stats() *sink.RunStats {
+	return s.stats
+}
+
+func (s *Sink) Create(m mover.Mover) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.movers[m.Id] = m
+	s.created++
+	return nil
+}
+
+func (s *Sink) WritePosition(m mover.Mover) error {
+	return s.WriteBatch([]mover.Mover{m})
+}
+
+func (s *Sink) WriteBatch(ms []mover.Mover) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	start := time.Now()
+	for _, m := range ms {
+		s.movers[m.Id] = m
+	}
+	if s.keep {
+		s.history = append(s.history, ms...)
+	}
+	s.stats.RecordWrite(len(ms), time.Since(start), nil)
+	return nil
+}
+
+func (s *Sink) Delete(m mover.Mover) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.movers, m.Id)
+	s.deleted++
+	return nil
+}
+
+func (s *Sink) WriteEvent(e sink.Event) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.events = append(s.events, e)
+	return nil
+}
+
+func (s *Sink) WriteTrip(t sink.Trip) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.trips = append(s.trips, t)
+	return nil
+}
+
+func (s *Sink) Close() error {
+	return nil
+}
+
+// Movers returns the latest state of the live movers, by id.
+func (s *Sink) Movers() []mover.Mover {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	movers := make([]mover.Mover, 0, len(s.movers))
+	for _, m := range s.movers {
+		movers = append(movers, m)
+	}
+	sort.Slice(movers, func(i, j int) bool { return movers[i].Id < movers[j].Id })
+	return movers
+}
+
+// History returns every position written, in order, if the
+// sink was asked to keep them.
+func (s *Sink) History() []mover.Mover {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	history := make([]mover.Mover, len(s.history))
+	copy(history, s.history)
+	return history
+}
+
+// Events returns every event written, in order.
+func (s *Sink) Events() []sink.Event {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	events := make([]sink.Event, len(s.events))
+	copy(events, s.events)
+	return events
+}
+
+// Trips returns every trip record written, in order, both
+// those of trips starting and of trips ending.
+func (s *Sink) Trips() []sink.Trip {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	trips := make([]sink.Trip, len(s.trips))
+	copy(trips, s.trips)
+	return trips
+}
+
+// Counts reports how many movers were created and deleted.
+func (s *Sink) Counts() (created, deleted int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.created, s.deleted
+}
@@ -0,0 +1,66 @@
+package memory
+
+import (
+	// System
+	"testing"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/sink"
+)
+
+// The memory sink stands in for the database in dry runs
+var _ sink.Sink = (*Sink)(nil)
+
+func TestSink(t *testing.T) {
+	s := NewSink(true)
+	for _, id := range []int{3, 1, 2} {
+		if err := s.Create(mover.Mover{Id: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.WriteBatch([]mover.Mover{{Id: 3, X: 1}, {Id: 1, X: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WritePosition(mover.Mover{Id: 3, X: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(mover.Mover{Id: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	movers := s.Movers()
+	if len(movers) != 2 || movers[0].Id != 1 || movers[1].Id != 3 {
+		t.Fatalf("Movers() = %v, want movers 1 and 3 by id", movers)
+	}
+	if movers[0].X != 2 || movers[1].X != 3 {
+		t.Errorf("Movers() = %v, want the latest positions", movers)
+	}
+	history := s.History()
+	if len(history) != 3 || history[0].X != 1 || history[1].X != 2 || history[2].X != 3 {
+		t.Errorf("History() = %v, want the three positions in order", history)
+	}
+	history[0].X = 42
+	if s.History()[0].X != 1 {
+		t.Error("History() handed out the history itself")
+	}
+	if created, deleted := s.Counts(); created != 3 || deleted != 1 {
+		t.Errorf("Counts() = %d, %d, want 3, 1", created, deleted)
+	}
+	if summary := s.Stats().Summary(); summary.Updates != 3 || summary.Writes != 2 {
+		t.Errorf("stats have %d updates in %d writes, want 3 in 2", summary.Updates, summary.Writes)
+	}
+}
+
+func TestSinkWithoutHistory(t *testing.T) {
+	s := NewSink(false)
+	if err := s.WritePosition(mover.Mover{Id: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if history := s.History(); len(history) != 0 {
+		t.Errorf("History() = %v, want nothing kept", history)
+	}
+	if movers := s.Movers(); len(movers) != 1 {
+		t.Errorf("Movers() = %v, want the mover written", movers)
+	}
+}
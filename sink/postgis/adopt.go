@@ -0,0 +1,67 @@
+package postgis
+
+import (
+	// System
+	"context"
+	"fmt"
+	"time"
+
+	// PostgreSQL connection
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+// The row before the latest, of movers without a heading or
+// velocity, is only looked up in history for them
+const statesSql = `SELECT o.id, ST_X(o.geog::geometry), ST_Y(o.geog::geometry),
+	coalesce(ST_Z(o.geog::geometry), 0), o.ts, o.heading, o.velocity,
+	coalesce(o.class, ''), coalesce(o.color, ''), o.props,
+	ST_X(h.geog::geometry), ST_Y(h.geog::geometry), h.ts
+	FROM %s o
+	LEFT JOIN LATERAL (
+		SELECT geog, ts FROM %s
+		WHERE id = o.id AND ts < o.ts
+		ORDER BY ts DESC LIMIT 1
+	) h ON o.heading IS NULL OR o.velocity IS NULL
+	WHERE o.geog IS NOT NULL AND o.ts IS NOT NULL`
+
+// ReadStates reads the last state of every mover in the objects
+// table, by default moving.objects, with its class, color and props,
+// for new movers to carry on from, see mover.Adopt. Movers without a heading or velocity, such as
+// those written by custom statements, get the position before their
+// latest from the history table, by default moving.history, to work
+// them out from.
+func ReadStates(ctx context.Context, dbPool *pgxpool.Pool, objects, history string) (map[int]mover.State, error) {
+	if objects == "" {
+		objects = DefaultObjectsTable
+	}
+	if history == "" {
+		history = DefaultHistoryTable
+	}
+	rows, err := dbPool.Query(ctx, fmt.Sprintf(statesSql, quoteTable(objects), quoteTable(history)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	states := make(map[int]mover.State)
+	for rows.Next() {
+		var s mover.State
+		var x, y *float64
+		var ts *time.Time
+		if err := rows.Scan(&s.Id, &s.X, &s.Y, &s.Z, &s.Ts, &s.Heading, &s.Velocity,
+			&s.Class, &s.Color, &s.Props, &x, &y, &ts); err != nil {
+			return nil, err
+		}
+		if x != nil && y != nil && ts != nil {
+			s.Previous = &geo.Point{X: *x, Y: *y}
+			s.PreviousTs = *ts
+		}
+		states[s.Id] = s
+	}
+	return states, rows.Err()
+}
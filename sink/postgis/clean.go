@@ -0,0 +1,62 @@
+package postgis
+
+import (
+	// System
+	"context"
+	"fmt"
+
+	// PostgreSQL connection
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// CleanRun deletes the rows a run wrote to the objects table and,
+// with history, to the history table, returning how many it
+// deleted. An empty run deletes the rows of every run, leaving
+// any written outside of one.
+func CleanRun(ctx context.Context, dbPool *pgxpool.Pool, objects, history, run string, withHistory bool) (int64, error) {
+	tables := []string{objects}
+	if withHistory {
+		tables = append(tables, history)
+	}
+	where, args := "run = $1", []interface{}{run}
+	if run == "" {
+		where, args = "run IS NOT NULL", nil
+	}
+	var deleted int64
+	for _, table := range tables {
+		tag, err := dbPool.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s", quoteTable(table), where), args...)
+		if err != nil {
+			return deleted, fmt.Errorf("unable to clean %s: %w", table, err)
+		}
+		deleted += tag.RowsAffected()
+	}
+	return deleted, nil
+}
+
+// Cleanup deletes the rows the writer's run wrote, from every
+// table it wrote to, and with history from their history tables.
+func (w *Writer) Cleanup(ctx context.Context, withHistory bool) (int64, error) {
+	return cleanup(ctx, w.dbPool, w.name, &w.templated, w.runId, withHistory)
+}
+
+// Cleanup deletes the rows the writer's run wrote, from every
+// history table it loaded with withHistory. It loads nothing else,
+// but the objects tables are cleaned as for the Writer all the same.
+func (w *CopyWriter) Cleanup(ctx context.Context, withHistory bool) (int64, error) {
+	return cleanup(ctx, w.dbPool, w.name, &w.templated, w.runId, withHistory)
+}
+
+func cleanup(ctx context.Context, dbPool *pgxpool.Pool, name string, templated *tableSet, run string, withHistory bool) (int64, error) {
+	if run == "" {
+		return 0, fmt.Errorf("%s has no run id to clean up", name)
+	}
+	var deleted int64
+	for _, tables := range templated.tables() {
+		n, err := CleanRun(ctx, dbPool, tables[0], tables[1], run, withHistory)
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
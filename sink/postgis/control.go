@@ -0,0 +1,106 @@
+package postgis
+
+import (
+	// System
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	// PostgreSQL connection
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+// ControlChannel is where commands for running movers are
+// announced, as JSON objects with the mover id, the command
+// and its arguments:
+//
+//	{"mover": 7, "command": "destination", "x": -123.1, "y": 49.3}
+//
+// Inserting into moving.commands announces them too.
+const ControlChannel = "movesim_commands"
+
+// Wait this long before listening again after losing the connection
+const relistenDelay = 5 * time.Second
+
+// controlMessage is a command as announced. The arguments are
+// null in moving.commands when a command does not take them.
+type controlMessage struct {
+	Mover    int      `json:"mover"`
+	Command  string   `json:"command"`
+	X        *float64 `json:"x"`
+	Y        *float64 `json:"y"`
+	Velocity *float64 `json:"velocity"`
+}
+
+// command is the mover command of the message, refusing a
+// destination without both coordinates, rather than taking
+// the missing ones for zero.
+func (msg controlMessage) command() (mover.Command, error) {
+	c := mover.Command{Kind: msg.Command}
+	if msg.Command == mover.CommandDestination && (msg.X == nil || msg.Y == nil) {
+		return c, fmt.Errorf("destination needs both x and y")
+	}
+	if msg.X != nil && msg.Y != nil {
+		c.X, c.Y = *msg.X, *msg.Y
+	}
+	if msg.Velocity != nil {
+		c.Velocity = *msg.Velocity
+	}
+	return c, nil
+}
+
+// ListenCommands hands the commands announced on the control
+// channel to handle until the context is done, listening again
+// whenever the connection is lost. Malformed commands are logged
+// and skipped.
+func ListenCommands(ctx context.Context, dbPool *pgxpool.Pool, handle func(id int, c mover.Command) error) {
+	for ctx.Err() == nil {
+		err := listen(ctx, dbPool, handle)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Errorf("Lost the %s channel: %v", ControlChannel, err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(relistenDelay):
+		}
+	}
+}
+
+func listen(ctx context.Context, dbPool *pgxpool.Pool, handle func(id int, c mover.Command) error) error {
+	conn, err := dbPool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(ctx, "LISTEN "+ControlChannel); err != nil {
+		return err
+	}
+	log.Infof("Listening for commands on the %s channel", ControlChannel)
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var msg controlMessage
+		if err := json.Unmarshal([]byte(notification.Payload), &msg); err != nil {
+			log.Warnf("Ignoring malformed command %s: %v", notification.Payload, err)
+			continue
+		}
+		c, err := msg.command()
+		if err != nil {
+			log.Warnf("Ignoring command %s: %v", notification.Payload, err)
+			continue
+		}
+		if err := handle(msg.Mover, c); err != nil {
+			log.Warnf("Ignoring command %s: %v", notification.Payload, err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package postgis
+
+import (
+	// System
+	"encoding/json"
+	"testing"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+func TestControlMessage(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    mover.Command
+		fails   bool
+	}{
+		{`{"mover": 7, "command": "destination", "x": -123.1, "y": 49.3, "velocity": null}`,
+			mover.Command{Kind: mover.CommandDestination, X: -123.1, Y: 49.3}, false},
+		{`{"mover": 7, "command": "destination", "x": null, "y": null, "velocity": null}`,
+			mover.Command{}, true},
+		{`{"mover": 7, "command": "destination", "x": 0, "y": null}`,
+			mover.Command{}, true},
+		{`{"mover": 7, "command": "destination"}`,
+			mover.Command{}, true},
+		{`{"mover": 7, "command": "speed", "x": null, "y": null, "velocity": 0.5}`,
+			mover.Command{Kind: mover.CommandSpeed, Velocity: 0.5}, false},
+		{`{"mover": 7, "command": "freeze", "x": null, "y": null, "velocity": null}`,
+			mover.Command{Kind: mover.CommandFreeze}, false},
+	}
+	for _, test := range tests {
+		var msg controlMessage
+		if err := json.Unmarshal([]byte(test.payload), &msg); err != nil {
+			t.Fatalf("%s: %v", test.payload, err)
+		}
+		c, err := msg.command()
+		if test.fails {
+			if err == nil {
+				t.Errorf("%s: got %+v, want an error", test.payload, c)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.payload, err)
+		} else if c != test.want {
+			t.Errorf("%s: got %+v, want %+v", test.payload, c, test.want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package postgis
+
+import (
+	// System
+	"context"
+	"strings"
+	"time"
+
+	// PostgreSQL connection
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	// OpenTelemetry
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/sink"
+	"github.com/pramsey/movesim/telemetry"
+)
+
+// DefaultCopyRows is how many positions a CopyWriter gathers
+// before loading them.
+const DefaultCopyRows = 10000
+
+var copyColumns = []string{"id", "geog", "ts", "props", "heading", "velocity", "run"}
+
+// CopyWriter bulk loads positions into the history tables of a
+// target with COPY, rows at a time, for generating large datasets
+// rather than following a live fleet. Only the history is kept, so
+// creating and deleting movers does nothing; events are inserted
+// as the Writer inserts them. COPY cannot run a statement, so with
+// an Append template the rows are inserted with it instead, as a
+// batch of statements rows at a time.
+type CopyWriter struct {
+	name      string
+	dbPool    *pgxpool.Pool
+	tables    Tables
+	templated tableSet
+	pointZ    bool
+	runId     string
+	rows      int
+	pending   map[string][][]interface{}
+	appends   *pgx.Batch
+	links     []trace.Link
+	count     int
+	stats     *sink.RunStats
+}
+
+// NewCopyWriter loads positions into the history tables of the
+// target, whatever its strategy, rows at a time, by default
+// DefaultCopyRows. It is not safe for concurrent use, which
+// Simulation.Generate does not need.
+func NewCopyWriter(target Target, rows int) *CopyWriter {
+	if rows <= 0 {
+		rows = DefaultCopyRows
+	}
+	return &CopyWriter{
+		name:      target.Name,
+		dbPool:    target.DbPool,
+		tables:    target.Tables,
+		templated: tableSet{templates: Templates{Append: target.Templates.Append}, points: pointFormat{z: target.PointZ}, partitioner: target.Partitioner},
+		pointZ:    target.PointZ,
+		runId:     target.Run,
+		rows:      rows,
+		pending:   make(map[string][][]interface{}),
+		appends:   &pgx.Batch{},
+		stats:     sink.NewRunStats(),
+	}
+}
+
+func (w *CopyWriter) Name() string {
+	return w.name
+}
+
+func (w *CopyWriter) Stats() *sink.RunStats {
+	return w.stats
+}
+
+func (w *CopyWriter) Create(m mover.Mover) error {
+	return nil
+}
+
+func (w *CopyWriter) Delete(m mover.Mover) error {
+	return nil
+}
+
+func (w *CopyWriter) WritePosition(m mover.Mover) error {
+	return w.WriteBatch([]mover.Mover{m})
+}
+
+// WriteBatch gathers the positions, loading them once
+// there are enough.
+func (w *CopyWriter) WriteBatch(ms []mover.Mover) error {
+	return w.WriteTraced(context.Background(), ms)
+}
+
+// WriteTraced gathers the positions as WriteBatch, for the spans
+// of the COPY that loads them to link to the span of the context.
+func (w *CopyWriter) WriteTraced(ctx context.Context, ms []mover.Mover) error {
+	w.links = telemetry.Link(w.links, trace.SpanContextFromContext(ctx))
+	for _, m := range ms {
+		objects, history := w.tables.resolve(m)
+		if w.templated.templates.set() {
+			stmts, err := w.templated.statements(ctx, w.dbPool, objects, history)
+			if err != nil {
+				return err
+			}
+			w.appends.Queue(stmts.append.sql, stmts.append.args(m, w.runId)...)
+			w.count++
+			continue
+		}
+		if history != DefaultHistoryTable {
+			if _, err := w.templated.statements(context.Background(), w.dbPool, objects, history); err != nil {
+				return err
+			}
+		}
+		// With the altitude for PointZ tables, like the Writer
+		coordinates := []float64{m.X, m.Y}
+		if w.pointZ {
+			coordinates = append(coordinates, m.Z)
+		}
+		wkb := geo.CoordinatesWKB(coordinates, true)
+		w.pending[history] = append(w.pending[history], []interface{}{
+			m.Id, string(wkb), m.DeviceTime(m.Ts), propsParam(m), m.Heading, m.Velocity, runParam(w.runId),
+		})
+		w.count++
+	}
+	if w.count >= w.rows {
+		return w.flush()
+	}
+	return nil
+}
+
+// flush loads the gathered positions, one COPY per table, or the
+// batch of templated inserts, each a trace of its own linked to
+// the writes that gathered them.
+func (w *CopyWriter) flush() error {
+	var firstErr error
+	if n := w.appends.Len(); n > 0 {
+		ctx, span := telemetry.Tracer().Start(context.Background(), "insert "+w.name, trace.WithLinks(w.links...))
+		span.SetAttributes(attribute.Int("positions", n))
+		start := time.Now()
+		err := w.dbPool.SendBatch(ctx, w.appends).Close()
+		w.stats.RecordWrite(n, time.Since(start), err)
+		telemetry.End(span, err)
+		firstErr = err
+		w.appends = &pgx.Batch{}
+	}
+	for table, rows := range w.pending {
+		ctx, span := telemetry.Tracer().Start(context.Background(), "copy "+table, trace.WithLinks(w.links...))
+		span.SetAttributes(attribute.Int("positions", len(rows)))
+		start := time.Now()
+		_, err := w.dbPool.CopyFrom(ctx, pgx.Identifier(strings.Split(table, ".")),
+			copyColumns, pgx.CopyFromRows(rows))
+		w.stats.RecordWrite(len(rows), time.Since(start), err)
+		telemetry.End(span, err)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(w.pending, table)
+	}
+	w.count = 0
+	w.links = nil
+	return firstErr
+}
+
+func (w *CopyWriter) WriteEvent(e sink.Event) error {
+	_, err := w.dbPool.Exec(context.Background(), eventSql, eventArgs(e, w.runId)...)
+	return err
+}
+
+// Close loads whatever positions are left.
+func (w *CopyWriter) Close() error {
+	return w.flush()
+}
@@ -0,0 +1,31 @@
+package postgis
+
+import (
+	// PostgreSQL connection
+	"github.com/jackc/pgtype"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+// ewkbParam is a point as a query parameter in PostGIS extended
+// WKB, with the SRID, sent in binary so the server reads it straight
+// into a geometry or geography column, with no function to call.
+type ewkbParam []float64
+
+// EncodeBinary makes pgx send the parameter in binary.
+func (p ewkbParam) EncodeBinary(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	return append(buf, geo.CoordinatesWKB(p, true)...), nil
+}
+
+// pointParam is the position of the mover as EWKB, with its
+// altitude, zero for movers without one, if z is set.
+func pointParam(m mover.Mover, z bool) ewkbParam {
+	if z {
+		return ewkbParam{m.X, m.Y, m.Z}
+	}
+	return ewkbParam{m.X, m.Y}
+}
@@ -0,0 +1,73 @@
+package postgis
+
+import (
+	// System
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	// PostgreSQL connection
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+// HistoryQuery picks positions out of a history table, by
+// default moving.history: those of the Run, if given, from From
+// and before To, where they are set.
+type HistoryQuery struct {
+	Table string
+	Run   string
+	From  time.Time
+	To    time.Time
+}
+
+// ReadHistory calls fn with every position the query picks, by
+// mover and then by time, as movers with their id, position, time,
+// heading and velocity, until fn returns an error.
+func ReadHistory(ctx context.Context, dbPool *pgxpool.Pool, q HistoryQuery, fn func(m mover.Mover) error) error {
+	table := q.Table
+	if table == "" {
+		table = DefaultHistoryTable
+	}
+	var where []string
+	var args []interface{}
+	for _, c := range []struct {
+		condition string
+		value     interface{}
+		set       bool
+	}{
+		{"run = $%d", q.Run, q.Run != ""},
+		{"ts >= $%d", q.From, !q.From.IsZero()},
+		{"ts < $%d", q.To, !q.To.IsZero()},
+	} {
+		if c.set {
+			args = append(args, c.value)
+			where = append(where, fmt.Sprintf(c.condition, len(args)))
+		}
+	}
+	sql := fmt.Sprintf(`SELECT id, ts, ST_X(geog::geometry), ST_Y(geog::geometry),
+		coalesce(heading, 0), coalesce(velocity, 0) FROM %s`, quoteTable(table))
+	if len(where) > 0 {
+		sql += " WHERE " + strings.Join(where, " AND ")
+	}
+	sql += " ORDER BY id, ts"
+
+	rows, err := dbPool.Query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m mover.Mover
+		if err := rows.Scan(&m.Id, &m.Ts, &m.X, &m.Y, &m.Heading, &m.Velocity); err != nil {
+			return err
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,462 @@
+package postgis
+
+import (
+	// System
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	// PostgreSQL connection
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+)
+
+// Partition intervals of the history tables
+const (
+	PartitionHour = "hour"
+	PartitionDay  = "day"
+	PartitionWeek = "week"
+)
+
+// How often a Partitioner makes new partitions and drops old ones
+const partitionCheck = time.Minute
+
+// PartitionProps splits the history tables into a native partition
+// per Interval of position time, hour, day or week, in UTC, so old
+// positions can be dropped a partition at a time rather than
+// deleted row by row. Partitions are made Ahead intervals ahead of
+// time, and those entirely older than Retention are dropped, unless
+// it is zero. An empty Interval leaves the tables alone.
+type PartitionProps struct {
+	Interval  string
+	Ahead     int
+	Retention time.Duration
+}
+
+// Enabled reports whether the history is to be partitioned.
+func (p PartitionProps) Enabled() bool {
+	return p.Interval != ""
+}
+
+// Check fails for settings that cannot be used.
+func (p PartitionProps) Check() error {
+	switch p.Interval {
+	case "", PartitionHour, PartitionDay, PartitionWeek:
+	default:
+		return fmt.Errorf("unknown partition interval '%s'", p.Interval)
+	}
+	if p.Enabled() && p.Ahead < 1 {
+		return fmt.Errorf("partitions ahead %d is not at least one, positions would go to the default partition", p.Ahead)
+	}
+	if p.Retention < 0 {
+		return fmt.Errorf("retention %s is negative", p.Retention)
+	}
+	if p.Retention > 0 && !p.Enabled() {
+		return fmt.Errorf("retention needs a partition interval")
+	}
+	return nil
+}
+
+// start is the start of the partition holding the time.
+func (p PartitionProps) start(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch p.Interval {
+	case PartitionHour:
+		return t.Truncate(time.Hour)
+	case PartitionWeek:
+		// Weeks start on Monday
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	default:
+		return day
+	}
+}
+
+// nextPartition is the start of the partition after the one
+// starting then.
+func nextPartition(interval string, start time.Time) time.Time {
+	switch interval {
+	case PartitionHour:
+		return start.Add(time.Hour)
+	case PartitionWeek:
+		return start.AddDate(0, 0, 7)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// Partitions are named for the table and the time they start,
+// such as moving.history_p20240501 for a day
+const partitionPrefix = "_p"
+
+func partitionLayout(interval string) string {
+	if interval == PartitionHour {
+		return "2006010215"
+	}
+	return "20060102"
+}
+
+// parsePartition reads the interval and start of a partition
+// from the suffix of its name, reporting false for other names.
+func (p PartitionProps) parsePartition(suffix string) (string, time.Time, bool) {
+	interval := PartitionDay
+	switch {
+	case len(suffix) == len(partitionLayout(PartitionHour)):
+		interval = PartitionHour
+	case p.Interval == PartitionWeek:
+		interval = PartitionWeek
+	}
+	start, err := time.Parse(partitionLayout(interval), suffix)
+	return interval, start, err == nil
+}
+
+// Partitioner keeps history tables partitioned by time, making
+// partitions before positions arrive for them, and dropping them
+// once they pass out of the retention window. Positions outside
+// every partition go to a default partition rather than failing.
+// Safe for concurrent use.
+type Partitioner struct {
+	dbPool *pgxpool.Pool
+	props  PartitionProps
+	mutex  sync.Mutex
+	tables map[string]bool
+	made   map[string]bool
+	// Span to cover besides the present, see Cover
+	from, to time.Time
+}
+
+func NewPartitioner(dbPool *pgxpool.Pool, props PartitionProps) *Partitioner {
+	return &Partitioner{
+		dbPool: dbPool,
+		props:  props,
+		tables: make(map[string]bool),
+		made:   make(map[string]bool),
+	}
+}
+
+// Cover has partitions made over the span as well as around the
+// present, for positions with times of their own, as generated
+// datasets have. Tables added already are covered straight away.
+func (p *Partitioner) Cover(ctx context.Context, from, to time.Time) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.from, p.to = from, to
+	var firstErr error
+	for table := range p.tables {
+		if err := p.extend(ctx, table, time.Now()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Add partitions the history table, if it is not yet, and makes
+// the partitions it needs, moving the rows it holds into them.
+func (p *Partitioner) Add(ctx context.Context, table string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.tables[table] {
+		return nil
+	}
+	from, to, err := p.partition(ctx, table)
+	if err != nil {
+		return err
+	}
+	p.tables[table] = true
+	if !from.IsZero() {
+		// Partitions for the positions the table held
+		starts := make(map[time.Time]bool)
+		p.between(starts, from, to)
+		if err := p.makePartitions(ctx, table, starts); err != nil {
+			return err
+		}
+	}
+	return p.extend(ctx, table, time.Now())
+}
+
+// Run makes new partitions ahead of time, and drops expired ones,
+// until the context is done.
+func (p *Partitioner) Run(ctx context.Context) {
+	ticker := time.NewTicker(partitionCheck)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			p.maintain(ctx, now)
+		}
+	}
+}
+
+func (p *Partitioner) maintain(ctx context.Context, now time.Time) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for table := range p.tables {
+		if err := p.extend(ctx, table, now); err != nil {
+			log.Errorf("Unable to make partitions of %s: %v", table, err)
+		}
+		if err := p.expire(ctx, table, now); err != nil {
+			log.Errorf("Unable to drop old partitions of %s: %v", table, err)
+		}
+	}
+}
+
+// partition turns a plain table into a partitioned one with the
+// same columns and indexes, under the same names, moving any rows
+// it holds into the default partition, and returns the span of
+// their times, zero if there were none, for partitions to be made
+// over, see extend.
+func (p *Partitioner) partition(ctx context.Context, table string) (time.Time, time.Time, error) {
+	var none time.Time
+	var kind string
+	err := p.dbPool.QueryRow(ctx, "SELECT relkind::text FROM pg_class WHERE oid = to_regclass($1)", quoteTable(table)).Scan(&kind)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return none, none, fmt.Errorf("history table %s does not exist", table)
+	}
+	if err != nil {
+		return none, none, err
+	}
+	switch kind {
+	case "p":
+		return none, none, nil
+	case "r":
+	default:
+		return none, none, fmt.Errorf("%s is not a table that can be partitioned", table)
+	}
+
+	// Made again once the table is gone, rather than copied with
+	// new names, so that re-running sql/movesim.sql, which makes
+	// its indexes if they do not exist, does not make them twice
+	var indexes []string
+	rows, err := p.dbPool.Query(ctx, `SELECT pg_get_indexdef(indexrelid) FROM pg_index
+		WHERE indrelid = to_regclass($1)`, quoteTable(table))
+	if err != nil {
+		return none, none, err
+	}
+	for rows.Next() {
+		var index string
+		if err := rows.Scan(&index); err != nil {
+			rows.Close()
+			return none, none, err
+		}
+		indexes = append(indexes, index)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return none, none, err
+	}
+
+	old := table + "_unpartitioned"
+	tx, err := p.dbPool.Begin(ctx)
+	if err != nil {
+		return none, none, err
+	}
+	defer tx.Rollback(ctx)
+	sqls := []string{
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quoteTable(table), pgx.Identifier{tableName(old)}.Sanitize()),
+		fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS INCLUDING CONSTRAINTS) PARTITION BY RANGE (ts)",
+			quoteTable(table), quoteTable(old)),
+		fmt.Sprintf("CREATE TABLE %s PARTITION OF %s DEFAULT", quoteTable(table+defaultSuffix), quoteTable(table)),
+		fmt.Sprintf("INSERT INTO %s SELECT * FROM %s", quoteTable(table), quoteTable(old)),
+		fmt.Sprintf("DROP TABLE %s", quoteTable(old)),
+	}
+	var moved int64
+	for i, sql := range append(sqls, indexes...) {
+		tag, err := tx.Exec(ctx, sql)
+		if err != nil {
+			return none, none, fmt.Errorf("unable to partition %s: %w", table, err)
+		}
+		if i == 3 {
+			moved = tag.RowsAffected()
+		}
+	}
+	var from, to *time.Time
+	if moved > 0 {
+		err = tx.QueryRow(ctx, fmt.Sprintf("SELECT min(ts), max(ts) FROM %s", quoteTable(table))).Scan(&from, &to)
+		if err != nil {
+			return none, none, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return none, none, err
+	}
+	log.Infof("Partitioned %s by %s", table, p.props.Interval)
+	if from == nil || to == nil {
+		return none, none, nil
+	}
+	log.Infof("Moved the %d positions %s held, from %s to %s, into partitions", moved, table,
+		from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+	return *from, *to, nil
+}
+
+// tableName is the name of a table without its schema.
+func tableName(table string) string {
+	parts := strings.Split(table, ".")
+	return parts[len(parts)-1]
+}
+
+// extend makes the partitions from the one before the present to
+// Ahead after it, and over the covered span, if not made already.
+func (p *Partitioner) extend(ctx context.Context, table string, now time.Time) error {
+	// From the partition before the present, for clocks running
+	// behind, to Ahead after it
+	first := p.props.start(p.props.start(now).Add(-time.Nanosecond))
+	last := p.props.start(now)
+	for i := 0; i < p.props.Ahead; i++ {
+		last = nextPartition(p.props.Interval, last)
+	}
+	starts := make(map[time.Time]bool)
+	p.between(starts, first, last)
+	if !p.from.IsZero() {
+		p.between(starts, p.from, p.to)
+	}
+	return p.makePartitions(ctx, table, starts)
+}
+
+// between adds the starts of the partitions from the one
+// holding from to the one holding to.
+func (p *Partitioner) between(starts map[time.Time]bool, from, to time.Time) {
+	for start := p.props.start(from); !start.After(to); start = nextPartition(p.props.Interval, start) {
+		starts[start] = true
+	}
+}
+
+// Suffix of the default partition of a table
+const defaultSuffix = "_default"
+
+// makePartitions makes the default partition of the table, and those
+// starting at the times, unless they were made already.
+func (p *Partitioner) makePartitions(ctx context.Context, table string, starts map[time.Time]bool) error {
+	name := table + defaultSuffix
+	if !p.made[name] {
+		sql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s DEFAULT", quoteTable(name), quoteTable(table))
+		if _, err := p.dbPool.Exec(ctx, sql); err != nil {
+			return fmt.Errorf("unable to make partition %s: %w", name, err)
+		}
+		p.made[name] = true
+	}
+	var firstErr error
+	for start := range starts {
+		name := table + partitionPrefix + start.Format(partitionLayout(p.props.Interval))
+		if p.made[name] {
+			continue
+		}
+		if err := p.attach(ctx, table, name, start, nextPartition(p.props.Interval, start)); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("unable to make partition %s: %w", name, err)
+			}
+			continue
+		}
+		p.made[name] = true
+	}
+	return firstErr
+}
+
+// attach makes the partition of the table for the times from and
+// up to to, if it does not exist yet. A partition cannot be made
+// over rows in the default partition, so any there are moved into
+// it first.
+func (p *Partitioner) attach(ctx context.Context, table, name string, from, to time.Time) error {
+	tx, err := p.dbPool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	var exists, stray bool
+	err = tx.QueryRow(ctx, fmt.Sprintf(`SELECT to_regclass($1) IS NOT NULL,
+		EXISTS (SELECT 1 FROM %s WHERE ts >= $2 AND ts < $3)`, quoteTable(table+defaultSuffix)),
+		quoteTable(name), from, to).Scan(&exists, &stray)
+	if err != nil || exists {
+		return err
+	}
+	bounds := fmt.Sprintf("FOR VALUES FROM ('%s') TO ('%s')", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	if !stray {
+		if _, err := tx.Exec(ctx, fmt.Sprintf("CREATE TABLE %s PARTITION OF %s %s", quoteTable(name), quoteTable(table), bounds)); err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	}
+	var moved int64
+	for i, sql := range []string{
+		fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS INCLUDING CONSTRAINTS)", quoteTable(name), quoteTable(table)),
+		fmt.Sprintf(`WITH moved AS (DELETE FROM %s WHERE ts >= '%s' AND ts < '%s' RETURNING *)
+			INSERT INTO %s SELECT * FROM moved`, quoteTable(table+defaultSuffix),
+			from.Format(time.RFC3339), to.Format(time.RFC3339), quoteTable(name)),
+		fmt.Sprintf("ALTER TABLE %s ATTACH PARTITION %s %s", quoteTable(table), quoteTable(name), bounds),
+	} {
+		tag, err := tx.Exec(ctx, sql)
+		if err != nil {
+			return err
+		}
+		if i == 1 {
+			moved = tag.RowsAffected()
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	log.Infof("Moved %d positions from %s into the new partition %s", moved, table+defaultSuffix, name)
+	return nil
+}
+
+// expire drops the partitions of the table whose positions are all
+// older than the retention window.
+func (p *Partitioner) expire(ctx context.Context, table string, now time.Time) error {
+	if p.props.Retention <= 0 {
+		return nil
+	}
+	rows, err := p.dbPool.Query(ctx, `SELECT c.relname::text FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = to_regclass($1)`, quoteTable(table))
+	if err != nil {
+		return err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	cutoff := now.Add(-p.props.Retention)
+	// Positions outside every partition expire row by row
+	tag, err := p.dbPool.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE ts < $1", quoteTable(table+defaultSuffix)), cutoff)
+	if err != nil {
+		return err
+	}
+	if n := tag.RowsAffected(); n > 0 {
+		log.Infof("Deleted %d positions before %s from %s", n, cutoff.Format(time.RFC3339), table+defaultSuffix)
+	}
+	prefix := tableName(table) + partitionPrefix
+	schema := strings.TrimSuffix(table, tableName(table))
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		interval, start, ok := p.props.parsePartition(strings.TrimPrefix(name, prefix))
+		if !ok || nextPartition(interval, start).After(cutoff) {
+			continue
+		}
+		partition := schema + name
+		if _, err := p.dbPool.Exec(ctx, "DROP TABLE IF EXISTS "+quoteTable(partition)); err != nil {
+			return err
+		}
+		delete(p.made, partition)
+		log.Infof("Dropped partition %s, of positions before %s", partition, nextPartition(interval, start).Format(time.RFC3339))
+	}
+	return nil
+}
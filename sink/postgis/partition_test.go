@@ -0,0 +1,55 @@
+package postgis
+
+import (
+	// System
+	"testing"
+	"time"
+)
+
+func TestPartitionCheck(t *testing.T) {
+	tests := []struct {
+		props PartitionProps
+		valid bool
+	}{
+		{PartitionProps{}, true},
+		{PartitionProps{Interval: PartitionDay, Ahead: 2}, true},
+		{PartitionProps{Interval: PartitionHour, Ahead: 1, Retention: time.Hour}, true},
+		{PartitionProps{Interval: PartitionDay, Ahead: 0}, false},
+		{PartitionProps{Interval: PartitionDay, Ahead: -1}, false},
+		{PartitionProps{Interval: "month", Ahead: 1}, false},
+		{PartitionProps{Retention: time.Hour}, false},
+	}
+	for _, test := range tests {
+		if err := test.props.Check(); (err == nil) != test.valid {
+			t.Errorf("%+v: got %v, want valid %t", test.props, err, test.valid)
+		}
+	}
+}
+
+func TestPartitionsBetween(t *testing.T) {
+	tests := []struct {
+		interval string
+		from, to string
+		want     int
+	}{
+		{PartitionDay, "2024-05-01T10:00:00Z", "2024-05-01T11:00:00Z", 1},
+		{PartitionDay, "2024-05-01T23:00:00Z", "2024-05-03T01:00:00Z", 3},
+		{PartitionHour, "2024-05-01T10:30:00Z", "2024-05-01T12:00:00Z", 3},
+		{PartitionWeek, "2024-05-01T00:00:00Z", "2024-05-14T00:00:00Z", 3},
+	}
+	for _, test := range tests {
+		p := NewPartitioner(nil, PartitionProps{Interval: test.interval, Ahead: 1})
+		from, _ := time.Parse(time.RFC3339, test.from)
+		to, _ := time.Parse(time.RFC3339, test.to)
+		starts := make(map[time.Time]bool)
+		p.between(starts, from, to)
+		if len(starts) != test.want {
+			t.Errorf("%s from %s to %s: %d partitions, want %d", test.interval, test.from, test.to, len(starts), test.want)
+		}
+		for start := range starts {
+			if !start.Equal(p.props.start(start)) {
+				t.Errorf("%s partition starts at %s, not on an interval", test.interval, start)
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package postgis
+
+import (
+	// System
+	"context"
+	"fmt"
+	"time"
+
+	// PostgreSQL connection
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// RunRecord is the provenance of a run in moving.runs: the
+// command line, host, start time and random seed it ran with,
+// the size of its fleet and a snapshot of its configuration.
+type RunRecord struct {
+	Id      string
+	Command string
+	Host    string
+	Started time.Time
+	Seed    int64
+	Movers  int
+	Config  map[string]interface{}
+}
+
+const (
+	// The shards of a run share its record, the first to
+	// start writes it
+	runStartSql = `INSERT INTO moving.runs (run, command, host, started, seed, movers, config)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (run) DO NOTHING`
+	runEndSql     = `UPDATE moving.runs SET ended = $2 WHERE run = $1`
+	runSummarySql = `UPDATE moving.runs SET summary = $2 WHERE run = $1`
+)
+
+// StartRun records the start of a run.
+func StartRun(ctx context.Context, dbPool *pgxpool.Pool, r RunRecord) error {
+	_, err := dbPool.Exec(ctx, runStartSql, r.Id, r.Command, r.Host, r.Started, r.Seed, r.Movers, r.Config)
+	return err
+}
+
+// EndRun records the end of a run.
+func EndRun(ctx context.Context, dbPool *pgxpool.Pool, run string, ended time.Time) error {
+	_, err := dbPool.Exec(ctx, runEndSql, run, ended)
+	return err
+}
+
+// WriteRunSummary records the summary of a run, anything
+// that encodes as JSON, as it ends. It fails if the run was not
+// recorded as it started, see StartRun.
+func WriteRunSummary(ctx context.Context, dbPool *pgxpool.Pool, run string, summary interface{}) error {
+	tag, err := dbPool.Exec(ctx, runSummarySql, run, summary)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("run %s is not in moving.runs", run)
+	}
+	return nil
+}
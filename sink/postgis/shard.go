@@ -0,0 +1,102 @@
+package postgis
+
+import (
+	// System
+	"context"
+	"time"
+
+	// PostgreSQL connection
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	// Movers
+	"github.com/pramsey/movesim/sink"
+)
+
+// ShardStats is what one process of a sharded run last reported
+// to moving.shards, about its share of the fleet.
+type ShardStats struct {
+	Run     string
+	Shard   int
+	Shards  int
+	Host    string
+	Movers  int
+	Updated time.Time
+	sink.RunSummary
+}
+
+const (
+	shardWriteSql = `INSERT INTO moving.shards (run, shard, shards, host, movers, updated,
+		duration_s, updates, writes, errors, updates_per_sec, latency_mean_ms, latency_p95_ms, latency_max_ms)
+	VALUES ($1, $2, $3, $4, $5, now(), $6, $7, $8, $9, $10, $11, $12, $13)
+	ON CONFLICT (run, shard) DO UPDATE SET shards = EXCLUDED.shards, host = EXCLUDED.host,
+		movers = EXCLUDED.movers, updated = EXCLUDED.updated, duration_s = EXCLUDED.duration_s,
+		updates = EXCLUDED.updates, writes = EXCLUDED.writes, errors = EXCLUDED.errors,
+		updates_per_sec = EXCLUDED.updates_per_sec, latency_mean_ms = EXCLUDED.latency_mean_ms,
+		latency_p95_ms = EXCLUDED.latency_p95_ms, latency_max_ms = EXCLUDED.latency_max_ms`
+	shardReadSql = `SELECT shard, shards, host, movers, updated, duration_s, updates, writes,
+		errors, updates_per_sec, latency_mean_ms, latency_p95_ms, latency_max_ms
+	FROM moving.shards WHERE run = $1 ORDER BY shard`
+)
+
+// WriteShardStats records the latest stats of a shard,
+// replacing what it reported before.
+func WriteShardStats(ctx context.Context, dbPool *pgxpool.Pool, s ShardStats) error {
+	_, err := dbPool.Exec(ctx, shardWriteSql, s.Run, s.Shard, s.Shards, s.Host, s.Movers,
+		s.Duration, s.Updates, s.Writes, s.Errors, s.UpdatesPerSec, s.LatencyMean, s.LatencyP95, s.LatencyMax)
+	return err
+}
+
+// ReadShardStats returns what every shard of the run last
+// reported, by shard.
+func ReadShardStats(ctx context.Context, dbPool *pgxpool.Pool, run string) ([]ShardStats, error) {
+	rows, err := dbPool.Query(ctx, shardReadSql, run)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var shards []ShardStats
+	for rows.Next() {
+		s := ShardStats{Run: run}
+		if err := rows.Scan(&s.Shard, &s.Shards, &s.Host, &s.Movers, &s.Updated, &s.Duration,
+			&s.Updates, &s.Writes, &s.Errors, &s.UpdatesPerSec, &s.LatencyMean, &s.LatencyP95, &s.LatencyMax); err != nil {
+			return nil, err
+		}
+		shards = append(shards, s)
+	}
+	return shards, rows.Err()
+}
+
+// CombineShards adds up the stats of the shards of a run. Counts
+// and rates add up, the mean latency is weighted by updates, and
+// the latency percentile and maximum are the worst of any shard,
+// as the samples behind them are not shared.
+func CombineShards(shards []ShardStats) ShardStats {
+	var total ShardStats
+	var latency float64
+	for _, s := range shards {
+		total.Run = s.Run
+		total.Shards = s.Shards
+		total.Movers += s.Movers
+		if s.Updated.After(total.Updated) {
+			total.Updated = s.Updated
+		}
+		if s.Duration > total.Duration {
+			total.Duration = s.Duration
+		}
+		total.Updates += s.Updates
+		total.Writes += s.Writes
+		total.Errors += s.Errors
+		total.UpdatesPerSec += s.UpdatesPerSec
+		latency += s.LatencyMean * float64(s.Updates)
+		if s.LatencyP95 > total.LatencyP95 {
+			total.LatencyP95 = s.LatencyP95
+		}
+		if s.LatencyMax > total.LatencyMax {
+			total.LatencyMax = s.LatencyMax
+		}
+	}
+	if total.Updates > 0 {
+		total.LatencyMean = latency / float64(total.Updates)
+	}
+	return total
+}
@@ -0,0 +1,55 @@
+package postgis
+
+import (
+	// System
+	"context"
+
+	// PostgreSQL connection
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+const (
+	stylesReadSql = `SELECT id, coalesce(color, ''), coalesce(name, '') FROM moving.styles`
+	// The first style a mover gets is the one it keeps,
+	// whichever run or shard gave it
+	styleWriteSql = `INSERT INTO moving.styles (id, color, name)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (id) DO NOTHING`
+)
+
+// ReadStyles reads the styles of the movers of earlier runs from
+// moving.styles, by mover id, see mover.Styles.
+func ReadStyles(ctx context.Context, dbPool *pgxpool.Pool) (map[int]mover.Style, error) {
+	rows, err := dbPool.Query(ctx, stylesReadSql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	styles := make(map[int]mover.Style)
+	for rows.Next() {
+		var id int
+		var s mover.Style
+		if err := rows.Scan(&id, &s.Color, &s.Name); err != nil {
+			return nil, err
+		}
+		styles[id] = s
+	}
+	return styles, rows.Err()
+}
+
+// WriteStyles keeps the styles of movers in moving.styles, in one
+// batch, leaving any a mover already has as they are.
+func WriteStyles(ctx context.Context, dbPool *pgxpool.Pool, styles map[int]mover.Style) error {
+	if len(styles) == 0 {
+		return nil
+	}
+	batch := &pgx.Batch{}
+	for id, s := range styles {
+		batch.Queue(styleWriteSql, id, s.Color, s.Name)
+	}
+	return dbPool.SendBatch(ctx, batch).Close()
+}
@@ -0,0 +1,235 @@
+package postgis
+
+import (
+	// System
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	// PostgreSQL connection
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+// The tables of sql/movesim.sql
+const (
+	DefaultObjectsTable = "moving.objects"
+	DefaultHistoryTable = "moving.history"
+)
+
+// Tables names the tables a writer puts positions in, as schema
+// qualified names that may hold placeholders filled in per mover:
+// {class} and {region} with the mover class and region names, and
+// {tenant} with Tenant, so that one simulator can feed several
+// demo applications, each with their own tables, such as
+// "moving.objects_{class}" or "{tenant}.objects". Empty names are
+// the tables of sql/movesim.sql. Templated tables are created on
+// first use, like the default ones, with the same notifications.
+type Tables struct {
+	Objects string
+	History string
+	Tenant  string
+}
+
+// resolve fills in the placeholders for the mover, returning
+// the objects and history table names.
+func (t Tables) resolve(m mover.Mover) (string, string) {
+	objects, history := t.Objects, t.History
+	if objects == "" {
+		objects = DefaultObjectsTable
+	}
+	if history == "" {
+		history = DefaultHistoryTable
+	}
+	if !strings.Contains(objects+history, "{") {
+		return objects, history
+	}
+	region := ""
+	if m.Region != nil {
+		region = m.Region.Name
+	}
+	replacer := strings.NewReplacer(
+		"{class}", identifierPart(m.Class.Name),
+		"{region}", identifierPart(region),
+		"{tenant}", identifierPart(t.Tenant),
+	)
+	return replacer.Replace(objects), replacer.Replace(history)
+}
+
+// identifierPart folds a name into lower case letters, digits
+// and underscores, to go into a table name.
+func identifierPart(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return '_'
+		}
+	}, name)
+}
+
+// quoteTable quotes a schema qualified table name.
+func quoteTable(name string) string {
+	return pgx.Identifier(strings.Split(name, ".")).Sanitize()
+}
+
+// tableStatements are the statements writing to one pair of tables.
+type tableStatements struct {
+	create, delete, update, append query
+}
+
+func newTableStatements(templates Templates, objects, history string) (tableStatements, error) {
+	var t tableStatements
+	var err error
+	for _, s := range []struct {
+		q        *query
+		template string
+	}{
+		{&t.create, templates.Create},
+		{&t.delete, templates.Delete},
+		{&t.update, templates.Update},
+		{&t.append, templates.Append},
+	} {
+		if *s.q, err = compile(s.template, objects, history); err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+func (t tableStatements) strategy(strategy string) query {
+	if strategy == StrategyAppend {
+		return t.append
+	}
+	return t.update
+}
+
+// tableSet keeps the statements of the templated tables seen so
+// far, from the statement templates if any, creating each table
+// the first time it comes up unless there are templates, and
+// partitioning its history with the partitioner, if any. The
+// default statements pass their points in the format, and with
+// altitudes the tables are made PointZ.
+type tableSet struct {
+	mutex       sync.Mutex
+	templates   Templates
+	points      pointFormat
+	partitioner *Partitioner
+	known       map[[2]string]tableStatements
+}
+
+func (s *tableSet) statements(ctx context.Context, dbPool *pgxpool.Pool, objects, history string) (tableStatements, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := [2]string{objects, history}
+	if stmts, ok := s.known[key]; ok {
+		return stmts, nil
+	}
+	stmts, err := newTableStatements(s.templates.withDefaults(s.points), objects, history)
+	if err != nil {
+		return stmts, err
+	}
+	if !s.templates.set() {
+		if err := createTables(ctx, dbPool, objects, history); err != nil {
+			return tableStatements{}, err
+		}
+		if s.points.z {
+			if err := MigratePointZ(ctx, dbPool, objects, history); err != nil {
+				return tableStatements{}, err
+			}
+		}
+		if s.partitioner != nil {
+			if err := s.partitioner.Add(ctx, history); err != nil {
+				return tableStatements{}, err
+			}
+		}
+	}
+	if s.known == nil {
+		s.known = make(map[[2]string]tableStatements)
+	}
+	s.known[key] = stmts
+	return stmts, nil
+}
+
+// createTables makes any of the tables that do not exist yet
+// in the image of the default ones, notifications included,
+// along with their schemas.
+func createTables(ctx context.Context, dbPool *pgxpool.Pool, objects, history string) error {
+	var sqls []string
+	for _, name := range []string{objects, history} {
+		if parts := strings.Split(name, "."); len(parts) > 1 {
+			sqls = append(sqls, "CREATE SCHEMA IF NOT EXISTS "+pgx.Identifier(parts[:1]).Sanitize())
+		}
+	}
+	if objects != DefaultObjectsTable {
+		table := quoteTable(objects)
+		sqls = append(sqls,
+			fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (LIKE moving.objects INCLUDING ALL)", table),
+			fmt.Sprintf("DROP TRIGGER IF EXISTS objects_notify ON %s", table),
+			fmt.Sprintf(`CREATE TRIGGER objects_notify AFTER INSERT OR UPDATE ON %s
+				FOR EACH ROW EXECUTE FUNCTION moving.objects_notify()`, table))
+	}
+	if history != DefaultHistoryTable {
+		sqls = append(sqls,
+			fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (LIKE moving.history INCLUDING ALL)", quoteTable(history)))
+	}
+	for _, sql := range sqls {
+		if _, err := dbPool.Exec(ctx, sql); err != nil {
+			return fmt.Errorf("unable to set up %s: %w", objects, err)
+		}
+	}
+	return nil
+}
+
+// tables lists the default tables and the templated ones seen so
+// far, as pairs of objects and history table names.
+func (s *tableSet) tables() [][2]string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	tables := [][2]string{{DefaultObjectsTable, DefaultHistoryTable}}
+	for key := range s.known {
+		tables = append(tables, key)
+	}
+	return tables
+}
+
+// MigratePointZ turns the position columns of the tables from
+// Point into PointZ, with zero altitudes, if they are not already,
+// for movers with altitudes. Nothing else needs PointZ, so this is
+// left to runs with altitudes rather than done by sql/movesim.sql.
+// Large tables take a while, as they are rewritten.
+func MigratePointZ(ctx context.Context, dbPool *pgxpool.Pool, tables ...string) error {
+	for _, table := range tables {
+		parts := strings.Split(table, ".")
+		if len(parts) != 2 {
+			return fmt.Errorf("table name %s is not schema qualified", table)
+		}
+		var dims int
+		err := dbPool.QueryRow(ctx, `SELECT coord_dimension FROM geography_columns
+			WHERE f_table_schema = $1 AND f_table_name = $2 AND f_geography_column = 'geog'`,
+			parts[0], parts[1]).Scan(&dims)
+		if err != nil {
+			return fmt.Errorf("unable to find the positions of %s: %w", table, err)
+		}
+		if dims > 2 {
+			continue
+		}
+		log.Infof("Converting the positions of %s to PointZ", table)
+		sql := fmt.Sprintf(`ALTER TABLE %s ALTER COLUMN geog TYPE geography(PointZ, 4326)
+			USING ST_Force3DZ(geog::geometry)::geography`, quoteTable(table))
+		if _, err := dbPool.Exec(ctx, sql); err != nil {
+			return fmt.Errorf("unable to convert %s to PointZ: %w", table, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,291 @@
+package postgis
+
+import (
+	// System
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+// Templates replace the statements a writer uses, so that tables
+// laid out differently from those of sql/movesim.sql, with other
+// column names or with triggers behind them, can be written to
+// without changing the simulator. Each is SQL with named
+// placeholders in braces, passed as query parameters: {id}, {x},
+// {y}, {z}, {color}, {name}, {class}, {priority}, {minzoom},
+// {maxzoom}, {ts}, {props}, {heading}, {velocity} and {run}, and the
+// point as EWKB, which goes straight into geometry and geography
+// columns alike: in 2D, {ewkb}, with the altitude of movers that
+// have one, {ewkbz}, or with an altitude always, zero for movers on
+// the ground, {ewkb_xyz}, for PointZ columns. {objects} and
+// {history} are replaced with the quoted table names, see Tables.
+// Braces in quoted strings, quoted identifiers and comments are left
+// as they are. Create runs when a mover starts, Update or Append, by
+// the write strategy, for every position, and Delete when the mover
+// goes. Empty templates keep the default statements. Tables are not
+// created for writers with templates, they are expected to exist.
+type Templates struct {
+	Create string
+	Update string
+	Append string
+	Delete string
+}
+
+// The default statements
+var defaultTemplates = Templates{
+	Create: `INSERT INTO {objects} (id, geog, color, ts, class, priority, minzoom, maxzoom, props, heading, velocity, run)
+	VALUES ({id}, ST_MakePoint({x}, {y})::geography, {color}, {ts}, {class}, {priority}, {minzoom}, {maxzoom}, {props}, {heading}, {velocity}, {run})
+	ON CONFLICT (id) DO
+	UPDATE SET geog = EXCLUDED.geog,
+	    color = EXCLUDED.color,
+	    ts = EXCLUDED.ts,
+	    class = EXCLUDED.class,
+	    priority = EXCLUDED.priority,
+	    minzoom = EXCLUDED.minzoom,
+	    maxzoom = EXCLUDED.maxzoom,
+	    props = EXCLUDED.props,
+	    heading = EXCLUDED.heading,
+	    velocity = EXCLUDED.velocity,
+	    run = EXCLUDED.run`,
+	Update: "UPDATE {objects} SET geog = ST_MakePoint({x}, {y})::geography, ts = {ts}, color = {color}, props = {props}, heading = {heading}, velocity = {velocity}, run = {run} WHERE id = {id}",
+	Append: "INSERT INTO {history} (id, geog, ts, props, heading, velocity, run) VALUES ({id}, ST_MakePoint({x}, {y})::geography, {ts}, {props}, {heading}, {velocity}, {run})",
+	Delete: "DELETE FROM {objects} WHERE id = {id}",
+}
+
+// The points of the default statements, made from coordinates, and
+// as passed as EWKB instead, in 2D and with altitudes, see
+// Templates.points
+const (
+	makePoint  = "ST_MakePoint({x}, {y})::geography"
+	makePointZ = "ST_MakePoint({x}, {y}, {z})::geography"
+	wkbPoint   = "{ewkb}"
+	wkbPointZ  = "{ewkb_xyz}"
+)
+
+// pointFormat is how the default statements pass points: made from
+// the coordinates or as EWKB, and in 2D or, for PointZ tables, with
+// the altitude, see MigratePointZ.
+type pointFormat struct {
+	wkb bool
+	z   bool
+}
+
+// The formats of the default statements
+var pointFormats = []pointFormat{{}, {wkb: true}, {z: true}, {wkb: true, z: true}}
+
+var placeholder = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// set reports whether any statement is replaced.
+func (t Templates) set() bool {
+	return t != Templates{}
+}
+
+// withDefaults fills in the default statements left out, passing
+// their points in the format.
+func (t Templates) withDefaults(format pointFormat) Templates {
+	defaults := defaultTemplates.points(format)
+	if t.Create == "" {
+		t.Create = defaults.Create
+	}
+	if t.Update == "" {
+		t.Update = defaults.Update
+	}
+	if t.Append == "" {
+		t.Append = defaults.Append
+	}
+	if t.Delete == "" {
+		t.Delete = defaults.Delete
+	}
+	return t
+}
+
+// points has the default statements pass their points in the
+// format: as EWKB parameters, rather than made from the coordinates
+// in SQL, with wkb set, and with the altitude with z set.
+func (t Templates) points(format pointFormat) Templates {
+	point := makePoint
+	switch format {
+	case pointFormat{wkb: true}:
+		point = wkbPoint
+	case pointFormat{z: true}:
+		point = makePointZ
+	case pointFormat{wkb: true, z: true}:
+		point = wkbPointZ
+	}
+	r := strings.NewReplacer(makePoint, point)
+	return Templates{
+		Create: r.Replace(t.Create),
+		Update: r.Replace(t.Update),
+		Append: r.Replace(t.Append),
+		Delete: r.Replace(t.Delete),
+	}
+}
+
+// Check fails for templates with unknown placeholders.
+func (t Templates) Check() error {
+	_, err := newTableStatements(t.withDefaults(pointFormat{}), DefaultObjectsTable, DefaultHistoryTable)
+	return err
+}
+
+// query is a statement, as SQL or the name of a prepared
+// statement, and the placeholders of its parameters in order.
+type query struct {
+	sql    string
+	params []string
+}
+
+// compile turns a template into a query against the tables,
+// numbering its placeholders in order of appearance.
+func compile(template, objects, history string) (query, error) {
+	var q query
+	var err error
+	numbers := make(map[string]int)
+	q.sql = unquoted(template, func(match string) string {
+		name := match[1 : len(match)-1]
+		switch name {
+		case "objects":
+			return quoteTable(objects)
+		case "history":
+			return quoteTable(history)
+		}
+		if _, ok := params[name]; !ok {
+			if err == nil {
+				err = fmt.Errorf("unknown placeholder %s in statement template", match)
+			}
+			return match
+		}
+		n, ok := numbers[name]
+		if !ok {
+			q.params = append(q.params, name)
+			n = len(q.params)
+			numbers[name] = n
+		}
+		return "$" + strconv.Itoa(n)
+	})
+	return q, err
+}
+
+// unquoted replaces the placeholders outside the string literals,
+// quoted identifiers, dollar quoted strings and comments of the SQL.
+func unquoted(sql string, replace func(match string) string) string {
+	var b strings.Builder
+	for len(sql) > 0 {
+		i := quoteStart(sql)
+		template := sql[:i]
+		b.WriteString(placeholder.ReplaceAllStringFunc(template, replace))
+		sql = sql[i:]
+		if len(sql) == 0 {
+			break
+		}
+		// Backslashes escape quotes in E'...' strings
+		escapes := sql[0] == '\'' && i > 0 && (template[i-1] == 'E' || template[i-1] == 'e') && (i == 1 || !isWordByte(template[i-2]))
+		n := quotedLength(sql, escapes)
+		b.WriteString(sql[:n])
+		sql = sql[n:]
+	}
+	return b.String()
+}
+
+// dollarTag matches the opening tag of a dollar quoted string.
+var dollarTag = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z_0-9]*)?\$`)
+
+// quoteStart is where the first quoted text or comment of the
+// SQL starts, its length if there is none.
+func quoteStart(sql string) int {
+	for i := 0; i < len(sql); i++ {
+		switch sql[i] {
+		case '\'', '"':
+			return i
+		case '-':
+			if strings.HasPrefix(sql[i:], "--") {
+				return i
+			}
+		case '/':
+			if strings.HasPrefix(sql[i:], "/*") {
+				return i
+			}
+		case '$':
+			// Not a positional parameter such as $1
+			if dollarTag.MatchString(sql[i:]) && (i == 0 || !isWordByte(sql[i-1])) {
+				return i
+			}
+		}
+	}
+	return len(sql)
+}
+
+// quotedLength is the length of the quoted text or comment the
+// SQL starts with, to its end, or all of it if it is unterminated.
+func quotedLength(sql string, escapes bool) int {
+	end := func(closing string, from int) int {
+		if i := strings.Index(sql[from:], closing); i >= 0 {
+			return from + i + len(closing)
+		}
+		return len(sql)
+	}
+	switch {
+	case sql[0] == '\'' || sql[0] == '"':
+		// Doubled quotes are part of the text
+		for i := 1; i < len(sql); i++ {
+			if escapes && sql[i] == '\\' {
+				i++
+				continue
+			}
+			if sql[i] != sql[0] {
+				continue
+			}
+			if i+1 < len(sql) && sql[i+1] == sql[0] {
+				i++
+				continue
+			}
+			return i + 1
+		}
+		return len(sql)
+	case strings.HasPrefix(sql, "--"):
+		return end("\n", 2)
+	case strings.HasPrefix(sql, "/*"):
+		return end("*/", 2)
+	default:
+		tag := dollarTag.FindString(sql)
+		return end(tag, len(tag))
+	}
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+// args are the parameters of the query for the mover.
+func (q query) args(m mover.Mover, run string) []interface{} {
+	args := make([]interface{}, len(q.params))
+	for i, name := range q.params {
+		args[i] = params[name](m, run)
+	}
+	return args
+}
+
+// The values of the placeholders
+var params = map[string]func(m mover.Mover, run string) interface{}{
+	"id":       func(m mover.Mover, run string) interface{} { return m.Id },
+	"x":        func(m mover.Mover, run string) interface{} { return m.X },
+	"y":        func(m mover.Mover, run string) interface{} { return m.Y },
+	"z":        func(m mover.Mover, run string) interface{} { return m.Z },
+	"ewkb":     func(m mover.Mover, run string) interface{} { return pointParam(m, false) },
+	"ewkbz":    func(m mover.Mover, run string) interface{} { return pointParam(m, m.HasZ) },
+	"ewkb_xyz": func(m mover.Mover, run string) interface{} { return pointParam(m, true) },
+	"color":    func(m mover.Mover, run string) interface{} { return m.Color },
+	"name":     func(m mover.Mover, run string) interface{} { return m.Name },
+	"class":    func(m mover.Mover, run string) interface{} { return m.Class.Name },
+	"priority": func(m mover.Mover, run string) interface{} { return m.Class.Priority },
+	"minzoom":  func(m mover.Mover, run string) interface{} { return m.Class.MinZoom },
+	"maxzoom":  func(m mover.Mover, run string) interface{} { return m.Class.MaxZoom },
+	"ts":       func(m mover.Mover, run string) interface{} { return m.DeviceTime(m.Ts) },
+	"props":    func(m mover.Mover, run string) interface{} { return propsParam(m) },
+	"heading":  func(m mover.Mover, run string) interface{} { return m.Heading },
+	"velocity": func(m mover.Mover, run string) interface{} { return m.Velocity },
+	"run":      func(m mover.Mover, run string) interface{} { return runParam(run) },
+}
@@ -0,0 +1,120 @@
+package postgis
+
+import (
+	// System
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+func TestDefaultPoints(t *testing.T) {
+	tests := []struct {
+		format pointFormat
+		point  string
+		params []string
+	}{
+		{pointFormat{}, "ST_MakePoint($2, $3)::geography", []string{"id", "x", "y", "ts", "props", "heading", "velocity", "run"}},
+		{pointFormat{z: true}, "ST_MakePoint($2, $3, $4)::geography", []string{"id", "x", "y", "z", "ts", "props", "heading", "velocity", "run"}},
+		{pointFormat{wkb: true}, "VALUES ($1, $2, $3", []string{"id", "ewkb", "ts", "props", "heading", "velocity", "run"}},
+		{pointFormat{wkb: true, z: true}, "VALUES ($1, $2, $3", []string{"id", "ewkb_xyz", "ts", "props", "heading", "velocity", "run"}},
+	}
+	for _, test := range tests {
+		q := defaultStatements[test.format].append
+		if !strings.Contains(q.sql, test.point) {
+			t.Errorf("%+v: append statement %q does not make its point with %q", test.format, q.sql, test.point)
+		}
+		if strings.Join(q.params, ",") != strings.Join(test.params, ",") {
+			t.Errorf("%+v: append statement has parameters %v, want %v", test.format, q.params, test.params)
+		}
+	}
+}
+
+func TestPreparedStatements(t *testing.T) {
+	names := make(map[string]bool)
+	for _, format := range pointFormats {
+		stmts := preparedStatements[format]
+		for _, q := range []query{stmts.create, stmts.delete, stmts.update, stmts.append} {
+			if names[q.sql] {
+				t.Errorf("statement name %s is used twice", q.sql)
+			}
+			names[q.sql] = true
+			if len(q.params) == 0 {
+				t.Errorf("statement %s has no parameters", q.sql)
+			}
+		}
+	}
+}
+
+func TestCompileQuoted(t *testing.T) {
+	tests := []struct {
+		template string
+		sql      string
+		params   []string
+	}{
+		{"UPDATE t SET label = '{name}', name = {name} WHERE id = {id}",
+			"UPDATE t SET label = '{name}', name = $1 WHERE id = $2", []string{"name", "id"}},
+		{"UPDATE t SET label = 'it''s {x}' || {y} WHERE id = {id}",
+			"UPDATE t SET label = 'it''s {x}' || $1 WHERE id = $2", []string{"y", "id"}},
+		{`UPDATE t SET "{x}" = {x} WHERE id = {id}`,
+			`UPDATE t SET "{x}" = $1 WHERE id = $2`, []string{"x", "id"}},
+		{"UPDATE t SET label = E'it\\'s {x}' || {y} WHERE id = {id}",
+			"UPDATE t SET label = E'it\\'s {x}' || $1 WHERE id = $2", []string{"y", "id"}},
+		{"UPDATE t SET label = $tag${x}$tag$, x = {x} WHERE id = {id} -- and {y}\nAND {run} IS NULL",
+			"UPDATE t SET label = $tag${x}$tag$, x = $1 WHERE id = $2 -- and {y}\nAND $3 IS NULL", []string{"x", "id", "run"}},
+		{"UPDATE t SET label = $${x}$$ /* {y} */, x = {x} WHERE id = {id}",
+			"UPDATE t SET label = $${x}$$ /* {y} */, x = $1 WHERE id = $2", []string{"x", "id"}},
+		{"DELETE FROM {objects} WHERE id = {id} AND label = '{objects}'",
+			`DELETE FROM "moving"."objects" WHERE id = $1 AND label = '{objects}'`, []string{"id"}},
+	}
+	for _, test := range tests {
+		q, err := compile(test.template, DefaultObjectsTable, DefaultHistoryTable)
+		if err != nil {
+			t.Errorf("%s: %v", test.template, err)
+			continue
+		}
+		if q.sql != test.sql {
+			t.Errorf("%s: compiled to %s, want %s", test.template, q.sql, test.sql)
+		}
+		if strings.Join(q.params, ",") != strings.Join(test.params, ",") {
+			t.Errorf("%s: parameters %v, want %v", test.template, q.params, test.params)
+		}
+	}
+	if _, err := compile("UPDATE t SET x = {x}, label = '{nothing}' WHERE id = {nothing}", DefaultObjectsTable, DefaultHistoryTable); err == nil {
+		t.Error("unknown placeholder outside the quotes was accepted")
+	}
+}
+
+func TestEwkbDimensions(t *testing.T) {
+	ground := mover.Mover{X: 1, Y: 2}
+	flying := mover.Mover{X: 1, Y: 2, Z: 300, HasZ: true}
+	tests := []struct {
+		placeholder string
+		m           mover.Mover
+		z           bool
+	}{
+		{"ewkb", ground, false},
+		{"ewkb", flying, false},
+		{"ewkbz", ground, false},
+		{"ewkbz", flying, true},
+		{"ewkb_xyz", ground, true},
+		{"ewkb_xyz", flying, true},
+	}
+	for _, test := range tests {
+		buf, err := params[test.placeholder](test.m, "").(ewkbParam).EncodeBinary(nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Byte order, type, SRID, then 8 bytes an ordinate
+		size := 9 + 16
+		if test.z {
+			size += 8
+		}
+		z := binary.LittleEndian.Uint32(buf[1:5])&0x80000000 != 0
+		if len(buf) != size || z != test.z {
+			t.Errorf("{%s} of %+v is %d bytes with Z %t, want Z %t", test.placeholder, test.m, len(buf), z, test.z)
+		}
+	}
+}
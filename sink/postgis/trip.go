@@ -0,0 +1,44 @@
+package postgis
+
+import (
+	// System
+	"context"
+
+	// PostgreSQL connection
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	// Movers
+	"github.com/pramsey/movesim/sink"
+)
+
+// Trips go unprepared like events, rows in moving.trips inserted
+// as they start and filled in as they end
+const (
+	tripStartSql = `INSERT INTO moving.trips (run, id, mover, start_ts, start_geog)
+	VALUES ($1, $2, $3, $4, ST_MakePoint($5, $6)::geography)`
+	tripEndSql = `UPDATE moving.trips SET end_ts = $3, end_geog = ST_MakePoint($4, $5)::geography,
+	length = $6, duration = make_interval(secs => $7), reason = $8
+	WHERE run IS NOT DISTINCT FROM $1 AND mover = $9 AND id = $2`
+)
+
+// writeTrip records the start or the end of the trip.
+func writeTrip(dbPool *pgxpool.Pool, runId string, t sink.Trip) error {
+	var err error
+	if t.Ended() {
+		_, err = dbPool.Exec(context.Background(), tripEndSql, runParam(runId), t.Id,
+			t.End, t.EndX, t.EndY, t.Length, t.Duration().Seconds(), t.Reason, t.Mover.Id)
+	} else {
+		_, err = dbPool.Exec(context.Background(), tripStartSql, runParam(runId), t.Id,
+			t.Mover.Id, t.Start, t.StartX, t.StartY)
+	}
+	return err
+}
+
+// WriteTrip records the trip straight away, even in batch mode.
+func (w *Writer) WriteTrip(t sink.Trip) error {
+	return writeTrip(w.dbPool, w.runId, t)
+}
+
+func (w *CopyWriter) WriteTrip(t sink.Trip) error {
+	return writeTrip(w.dbPool, w.runId, t)
+}
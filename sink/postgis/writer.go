@@ -0,0 +1,468 @@
+// Package postgis writes mover positions to a PostGIS database,
+// in the moving schema created by sql/movesim.sql.
+package postgis
+
+import (
+	// System
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	// PostgreSQL connection
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// OpenTelemetry
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	// Geometry
+	"github.com/pramsey/movesim/geo"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/sink"
+	"github.com/pramsey/movesim/telemetry"
+
+	// Feature flags
+	"github.com/pramsey/movesim/feature"
+)
+
+// Write strategies
+const (
+	// Move the single row per mover in moving.objects
+	StrategyUpdate = "update"
+	// Add a row per position to moving.history
+	StrategyAppend = "append"
+)
+
+// StrategySql is the statement of each strategy for the default tables.
+var StrategySql = map[string]string{
+	StrategyUpdate: defaultStatements[pointFormat{}].update.sql,
+	StrategyAppend: defaultStatements[pointFormat{}].append.sql,
+}
+
+// Where events go: rows in moving.events, notifications on
+// the 'events' channel, or both
+const (
+	EventsTable  = "table"
+	EventsNotify = "notify"
+	EventsBoth   = "both"
+)
+
+// Events are rare enough to go unprepared, so pools work
+// against databases without the events table
+const (
+	eventSql = `INSERT INTO moving.events (kind, ts, a, b, geog, distance, run, zone)
+	VALUES ($1, $2, $3, $4, ST_MakePoint($5, $6)::geography, $7, $8, $9)`
+	notifySql = "SELECT pg_notify('events', $1)"
+)
+
+// Switching a strategy off falls back to the update strategy,
+// which every pool prepares alongside its own
+var appendFeature = feature.Register("strategy_append", "Append write strategy", true)
+
+// defaultStatements are those of the default templates, for
+// the default tables, which always compile, by point format.
+var defaultStatements = func() map[pointFormat]tableStatements {
+	stmts := make(map[pointFormat]tableStatements, len(pointFormats))
+	for _, format := range pointFormats {
+		stmts[format], _ = newTableStatements(defaultTemplates.points(format), DefaultObjectsTable, DefaultHistoryTable)
+	}
+	return stmts
+}()
+
+// Names of the statements prepared on every pooled connection.
+// Passing a name in place of SQL makes pgx execute the prepared
+// statement, in single statements and batches alike, so the
+// server does not parse and plan every position update.
+const (
+	stmtCreate = "movesim_create"
+	stmtDelete = "movesim_delete"
+	// Suffixes of the statements passing points as EWKB, and
+	// with altitudes
+	stmtWkb = "_wkb"
+	stmtZ   = "_z"
+)
+
+func strategyStatement(strategy string) string {
+	return "movesim_" + strategy
+}
+
+// PrepareStatements returns a connection hook that prepares the
+// mover statements and the statement of the write strategy, with
+// points made from coordinates and passed as EWKB alike, in 2D and
+// with altitudes, to set as AfterConnect on the pool configuration.
+func PrepareStatements(strategy string) func(context.Context, *pgx.Conn) error {
+	statements := make(map[string]string)
+	for format, stmts := range defaultStatements {
+		names := preparedStatements[format]
+		for _, s := range []string{StrategyUpdate, strategy} {
+			statements[names.strategy(s).sql] = stmts.strategy(s).sql
+		}
+		statements[names.create.sql] = stmts.create.sql
+		statements[names.delete.sql] = stmts.delete.sql
+	}
+	return func(ctx context.Context, conn *pgx.Conn) error {
+		for name, sql := range statements {
+			if _, err := conn.Prepare(ctx, name, sql); err != nil {
+				return fmt.Errorf("unable to prepare %s: %w", name, err)
+			}
+		}
+		return nil
+	}
+}
+
+// Target is a database to write positions to, and how to write
+// them. The pool must prepare the statements of the same strategy,
+// see PrepareStatements. Events says where events go, by default
+// to the events table, and Tables where positions go, by default
+// to the tables of sql/movesim.sql, with the statements of the
+// Templates, if any, passing points as EWKB with Wkb set, see
+// Templates, and with their altitudes with PointZ set, for tables
+// turned PointZ by MigratePointZ. History tables created on first use are
+// partitioned by the Partitioner, if any; the default ones are
+// up to the program to add to it. Rows are tagged with the Run id,
+// if any, so that Cleanup can remove them afterwards.
+type Target struct {
+	Name        string
+	DbPool      *pgxpool.Pool
+	Strategy    string
+	Events      string
+	Tables      Tables
+	Templates   Templates
+	Wkb         bool
+	PointZ      bool
+	Partitioner *Partitioner
+	Run         string
+}
+
+// Writer sends mover positions to a target, either as
+// one statement per update or, when batchSize is more than one,
+// queued and sent as a pgx batch of up to batchSize updates.
+type Writer struct {
+	name          string
+	dbPool        *pgxpool.Pool
+	strategy      string
+	events        string
+	tables        Tables
+	templated     tableSet
+	points        pointFormat
+	runId         string
+	batchSize     int
+	flushInterval time.Duration
+	stats         *sink.RunStats
+	queue         chan queued
+	done          chan struct{}
+}
+
+// queued is an update waiting in the queue, with the span of the
+// write that queued it, for the flush to link back to.
+type queued struct {
+	mover mover.Mover
+	span  trace.SpanContext
+}
+
+func NewWriter(target Target, batchSize int, flushInterval time.Duration) *Writer {
+	w := &Writer{
+		name:          target.Name,
+		dbPool:        target.DbPool,
+		strategy:      target.Strategy,
+		events:        target.Events,
+		tables:        target.Tables,
+		templated:     tableSet{templates: target.Templates, points: target.points(), partitioner: target.Partitioner},
+		points:        target.points(),
+		runId:         target.Run,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		stats:         sink.NewRunStats(),
+	}
+	if w.events == "" {
+		w.events = EventsTable
+	}
+	if batchSize > 1 {
+		w.queue = make(chan queued, batchSize)
+		w.done = make(chan struct{})
+		go w.run()
+	}
+	return w
+}
+
+func (w *Writer) Name() string {
+	return w.name
+}
+
+func (w *Writer) Stats() *sink.RunStats {
+	return w.stats
+}
+
+func (w *Writer) Create(m mover.Mover) error {
+	stmts, err := w.statements(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.dbPool.Exec(context.Background(), stmts.create.sql, stmts.create.args(m, w.runId)...)
+	return err
+}
+
+func (w *Writer) Delete(m mover.Mover) error {
+	stmts, err := w.statements(m)
+	if err != nil {
+		return err
+	}
+	_, err = w.dbPool.Exec(context.Background(), stmts.delete.sql, stmts.delete.args(m, w.runId)...)
+	return err
+}
+
+// points is the format the default statements pass points in.
+func (t Target) points() pointFormat {
+	return pointFormat{wkb: t.Wkb, z: t.PointZ}
+}
+
+// preparedStatements are the statements prepared on every
+// connection, by name, for the default tables, by point format.
+var preparedStatements = map[pointFormat]tableStatements{
+	{}:                   prepared(defaultStatements[pointFormat{}], ""),
+	{wkb: true}:          prepared(defaultStatements[pointFormat{wkb: true}], stmtWkb),
+	{z: true}:            prepared(defaultStatements[pointFormat{z: true}], stmtZ),
+	{wkb: true, z: true}: prepared(defaultStatements[pointFormat{wkb: true, z: true}], stmtWkb+stmtZ),
+}
+
+// prepared names the statements, with the suffix.
+func prepared(stmts tableStatements, suffix string) tableStatements {
+	return tableStatements{
+		create: query{sql: stmtCreate + suffix, params: stmts.create.params},
+		delete: query{sql: stmtDelete + suffix, params: stmts.delete.params},
+		update: query{sql: strategyStatement(StrategyUpdate) + suffix, params: stmts.update.params},
+		append: query{sql: strategyStatement(StrategyAppend) + suffix, params: stmts.append.params},
+	}
+}
+
+// statements are the statements writing the mover to its tables:
+// the prepared ones for the default tables, or else the SQL for its
+// own, or from the templates, which pgx prepares as it goes.
+func (w *Writer) statements(m mover.Mover) (tableStatements, error) {
+	objects, history := w.tables.resolve(m)
+	if objects == DefaultObjectsTable && history == DefaultHistoryTable && !w.templated.templates.set() {
+		return preparedStatements[w.points], nil
+	}
+	return w.templated.statements(context.Background(), w.dbPool, objects, history)
+}
+
+// WritePosition stores the current position of the mover. In batch
+// mode the write happens later, and errors are only counted in stats.
+func (w *Writer) WritePosition(m mover.Mover) error {
+	return w.WriteTraced(context.Background(), []mover.Mover{m})
+}
+
+// WriteBatch stores several positions in one round trip, or
+// queues them in batch mode.
+func (w *Writer) WriteBatch(ms []mover.Mover) error {
+	return w.WriteTraced(context.Background(), ms)
+}
+
+// WriteTraced writes the positions as WritePosition or WriteBatch,
+// in a flush span under the span of the context or, in batch mode,
+// linked to it.
+func (w *Writer) WriteTraced(ctx context.Context, ms []mover.Mover) error {
+	if w.queue != nil {
+		span := trace.SpanContextFromContext(ctx)
+		for _, m := range ms {
+			w.queue <- queued{mover: m, span: span}
+		}
+		return nil
+	}
+	if len(ms) == 1 {
+		start := time.Now()
+		q, err := w.statement(ms[0])
+		if err == nil {
+			_, err = w.dbPool.Exec(ctx, q.sql, q.args(ms[0], w.runId)...)
+		}
+		w.stats.RecordWrite(1, time.Since(start), err)
+		return err
+	}
+	batch := &pgx.Batch{}
+	for _, m := range ms {
+		if err := w.queueWrite(batch, m); err != nil {
+			return err
+		}
+	}
+	return w.flush(ctx, batch)
+}
+
+func (w *Writer) queueWrite(batch *pgx.Batch, m mover.Mover) error {
+	q, err := w.statement(m)
+	if err != nil {
+		return err
+	}
+	batch.Queue(q.sql, q.args(m, w.runId)...)
+	return nil
+}
+
+// WriteEvent records the event straight away, even in batch mode.
+func (w *Writer) WriteEvent(e sink.Event) error {
+	ctx := context.Background()
+	x, y := e.Midpoint()
+	if w.events == EventsTable || w.events == EventsBoth {
+		_, err := w.dbPool.Exec(ctx, eventSql, eventArgs(e, w.runId)...)
+		if err != nil {
+			return err
+		}
+	}
+	if w.events == EventsNotify || w.events == EventsBoth {
+		notice := map[string]interface{}{
+			"kind":     e.Kind,
+			"ts":       e.Ts,
+			"a":        e.A.Id,
+			"b":        e.B.Id,
+			"x":        x,
+			"y":        y,
+			"distance": e.Distance,
+		}
+		if !e.Pair() {
+			delete(notice, "b")
+			delete(notice, "distance")
+			notice["zone"] = e.Zone
+		}
+		payload, err := json.Marshal(notice)
+		if err != nil {
+			return err
+		}
+		if _, err := w.dbPool.Exec(ctx, notifySql, string(payload)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statement is the statement of the write strategy for the mover,
+// unless its feature has been switched off.
+func (w *Writer) statement(m mover.Mover) (query, error) {
+	stmts, err := w.statements(m)
+	if err != nil {
+		return query{}, err
+	}
+	if w.strategy == StrategyAppend && !appendFeature.Enabled() {
+		return stmts.update, nil
+	}
+	return stmts.strategy(w.strategy), nil
+}
+
+// Close flushes any queued updates. No writes may follow.
+func (w *Writer) Close() error {
+	if w.queue != nil {
+		close(w.queue)
+		<-w.done
+	}
+	return nil
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+	batch := &pgx.Batch{}
+	var links []trace.Link
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case q, ok := <-w.queue:
+			if !ok {
+				w.flushQueued(batch, links)
+				return
+			}
+			if err := w.queueWrite(batch, q.mover); err != nil {
+				log.Errorf("Unable to queue update to %s: %v", w.name, err)
+			}
+			links = telemetry.Link(links, q.span)
+			if batch.Len() >= w.batchSize {
+				w.flushQueued(batch, links)
+				batch, links = &pgx.Batch{}, nil
+			}
+		case <-ticker.C:
+			if batch.Len() > 0 {
+				w.flushQueued(batch, links)
+				batch, links = &pgx.Batch{}, nil
+			}
+		}
+	}
+}
+
+// flush sends the batch, in a span under that of the context,
+// and linked to the spans of any updates it holds.
+func (w *Writer) flush(ctx context.Context, batch *pgx.Batch, links ...trace.Link) error {
+	if batch.Len() == 0 {
+		return nil
+	}
+	ctx, span := telemetry.Tracer().Start(ctx, "flush "+w.name, trace.WithLinks(links...))
+	span.SetAttributes(attribute.Int("positions", batch.Len()))
+	start := time.Now()
+	err := w.dbPool.SendBatch(ctx, batch).Close()
+	w.stats.RecordWrite(batch.Len(), time.Since(start), err)
+	telemetry.End(span, err)
+	return err
+}
+
+// flushQueued sends a batch of queued updates, which have no
+// caller left to hear about any failure. Their tick spans are
+// long over, so the flush is a trace of its own, linked to them.
+func (w *Writer) flushQueued(batch *pgx.Batch, links []trace.Link) {
+	if err := w.flush(context.Background(), batch, links...); err != nil {
+		log.Errorf("Batch write of %d updates to %s failed: %v", batch.Len(), w.name, err)
+	}
+}
+
+// runParam stores rows outside of any run as NULL.
+func runParam(run string) interface{} {
+	if run == "" {
+		return nil
+	}
+	return run
+}
+
+// eventArgs are the parameters of eventSql for the event, with
+// no second mover nor distance for events of one mover alone.
+func eventArgs(e sink.Event, run string) []interface{} {
+	x, y := e.Midpoint()
+	if !e.Pair() {
+		return []interface{}{e.Kind, e.Ts, e.A.Id, nil, x, y, nil, runParam(run), e.Zone}
+	}
+	return []interface{}{e.Kind, e.Ts, e.A.Id, e.B.Id, x, y, e.Distance, runParam(run), nil}
+}
+
+// propsParam is the mover payload fields as a query parameter,
+// NULL rather than an empty object when there are none.
+func propsParam(m mover.Mover) interface{} {
+	if len(m.Fields) == 0 {
+		return nil
+	}
+	return m.Fields
+}
+
+// QueryFeatures runs a query returning a geometry column and reads
+// each row as a GeoJSON feature.
+func QueryFeatures(ctx context.Context, dbPool *pgxpool.Pool, query string) ([]geo.Feature, error) {
+	sql := fmt.Sprintf("SELECT ST_AsGeoJSON(q.*)::text FROM (%s) q", query)
+	rows, err := dbPool.Query(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var features []geo.Feature
+	for rows.Next() {
+		var geojson string
+		if err := rows.Scan(&geojson); err != nil {
+			return nil, err
+		}
+		rowFeatures, err := geo.ParseGeoJSON([]byte(geojson))
+		if err != nil {
+			return nil, err
+		}
+		features = append(features, rowFeatures...)
+	}
+	return features, rows.Err()
+}
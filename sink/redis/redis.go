@@ -0,0 +1,157 @@
+// Package redis sends mover positions to Redis, published on
+// pub/sub channels, kept in a GEO set, or both, to drive Redis
+// based real-time location services.
+package redis
+
+import (
+	// System
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	// Redis connection
+	goredis "github.com/redis/go-redis/v9"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/sink"
+)
+
+// Props configures the Redis sink. Address, Password and Db select
+// the server. With Channel set every update is published there as
+// a GeoJSON feature, or with PerMover on a channel of its own per
+// mover, Channel:<id>. A deleted mover is published once more with
+// a "deleted" property. With GeoKey set the latest position of
+// every mover is kept in that GEO set, with the mover id as member.
+type Props struct {
+	Address  string
+	Password string
+	Db       int
+	Channel  string
+	PerMover bool
+	GeoKey   string
+}
+
+// Latitudes Redis can index, those of the web mercator square
+const maxGeoLatitude = 85.05112878
+
+// Sink writes to one Redis server. Every write is a single
+// pipelined round trip, however many movers it carries.
+type Sink struct {
+	props  Props
+	client *goredis.Client
+	stats  *sink.RunStats
+}
+
+func NewSink(props Props) (*Sink, error) {
+	if props.Channel == "" && props.GeoKey == "" {
+		return nil, fmt.Errorf("the redis sink needs a Channel, a GeoKey or both")
+	}
+	client := goredis.NewClient(&goredis.Options{
+		Addr:     props.Address,
+		Password: props.Password,
+		DB:       props.Db,
+	})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("unable to reach redis at %s: %w", props.Address, err)
+	}
+	return &Sink{
+		props:  props,
+		client: client,
+		stats:  sink.NewRunStats(),
+	}, nil
+}
+
+func (s *Sink) Name() string {
+	return "redis"
+}
+
+func (s *Sink) Stats() *sink.RunStats {
+	return s.stats
+}
+
+func (s *Sink) Create(m mover.Mover) error {
+	return s.send([]mover.Mover{m})
+}
+
+func (s *Sink) WritePosition(m mover.Mover) error {
+	return s.WriteBatch([]mover.Mover{m})
+}
+
+func (s *Sink) WriteBatch(ms []mover.Mover) error {
+	start := time.Now()
+	err := s.send(ms)
+	s.stats.RecordWrite(len(ms), time.Since(start), err)
+	return err
+}
+
+func (s *Sink) send(ms []mover.Mover) error {
+	ctx := context.Background()
+	_, err := s.client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		if s.props.GeoKey != "" {
+			locations := make([]*goredis.GeoLocation, 0, len(ms))
+			for _, m := range ms {
+				// Out of reach of the index, the mover keeps
+				// its last position there
+				if m.Y < -maxGeoLatitude || m.Y > maxGeoLatitude {
+					continue
+				}
+				locations = append(locations, &goredis.GeoLocation{
+					Name:      strconv.Itoa(m.Id),
+					Longitude: m.X,
+					Latitude:  m.Y,
+				})
+			}
+			if len(locations) > 0 {
+				pipe.GeoAdd(ctx, s.props.GeoKey, locations...)
+			}
+		}
+		if s.props.Channel != "" {
+			for _, m := range ms {
+				if err := s.publish(ctx, pipe, sink.NewFeature(m)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *Sink) publish(ctx context.Context, pipe goredis.Pipeliner, feature sink.Feature) error {
+	message, err := json.Marshal(feature)
+	if err != nil {
+		return err
+	}
+	channel := s.props.Channel
+	if s.props.PerMover {
+		channel = fmt.Sprintf("%s:%d", channel, feature.Id)
+	}
+	pipe.Publish(ctx, channel, message)
+	return nil
+}
+
+// Delete takes the mover out of the GEO set and
+// announces that it has gone.
+func (s *Sink) Delete(m mover.Mover) error {
+	ctx := context.Background()
+	_, err := s.client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		if s.props.GeoKey != "" {
+			pipe.ZRem(ctx, s.props.GeoKey, strconv.Itoa(m.Id))
+		}
+		if s.props.Channel != "" {
+			feature := sink.NewFeature(m)
+			feature.Properties["deleted"] = true
+			return s.publish(ctx, pipe, feature)
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *Sink) Close() error {
+	return s.client.Close()
+}
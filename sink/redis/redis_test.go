@@ -0,0 +1,191 @@
+package redis
+
+import (
+	// System
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/sink"
+)
+
+// fakeRedis speaks just enough of the Redis protocol to record the
+// commands a client sends, answering each with a plain reply.
+type fakeRedis struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	commands [][]string
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &fakeRedis{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(conn)
+		}
+	}()
+	return r
+}
+
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	in := bufio.NewReader(conn)
+	for {
+		command, err := readCommand(in)
+		if err != nil {
+			return
+		}
+		r.mutex.Lock()
+		r.commands = append(r.commands, command)
+		r.mutex.Unlock()
+		reply := "+OK\r\n"
+		switch strings.ToUpper(command[0]) {
+		case "HELLO":
+			// As a server older than RESP3
+			reply = "-ERR unknown command 'HELLO'\r\n"
+		case "PING":
+			reply = "+PONG\r\n"
+		case "GEOADD", "PUBLISH", "ZREM":
+			reply = ":1\r\n"
+		}
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+// readCommand reads one command, an array of bulk strings.
+func readCommand(in *bufio.Reader) ([]string, error) {
+	line, err := in.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("command %q is not an array", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	command := make([]string, n)
+	for i := range command {
+		line, err := in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(in, data); err != nil {
+			return nil, err
+		}
+		command[i] = string(data[:size])
+	}
+	return command, nil
+}
+
+// sent is the commands recorded of the names given.
+func (r *fakeRedis) sent(names ...string) [][]string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var commands [][]string
+	for _, command := range r.commands {
+		for _, name := range names {
+			if strings.EqualFold(command[0], name) {
+				commands = append(commands, command)
+			}
+		}
+	}
+	return commands
+}
+
+func testMover(id int, x, y float64) mover.Mover {
+	return mover.Mover{Id: id, X: x, Y: y, Class: &mover.Class{Name: "ship"}}
+}
+
+func TestGeoSet(t *testing.T) {
+	r := newFakeRedis(t)
+	s, err := NewSink(Props{Address: r.listener.Addr().String(), GeoKey: "fleet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	// The mover past the reach of the index is left out
+	if err := s.WriteBatch([]mover.Mover{testMover(1, -123.1, 49.2), testMover(2, 10, 89), testMover(3, 4.5, -1.25)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(testMover(3, 0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	commands := r.sent("GEOADD", "ZREM", "PUBLISH")
+	want := [][]string{
+		{"geoadd", "fleet", "-123.1", "49.2", "1", "4.5", "-1.25", "3"},
+		{"zrem", "fleet", "3"},
+	}
+	if fmt.Sprint(commands) != fmt.Sprint(want) {
+		t.Errorf("sent %v, want %v", commands, want)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	r := newFakeRedis(t)
+	s, err := NewSink(Props{Address: r.listener.Addr().String(), Channel: "movers", PerMover: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.WritePosition(testMover(7, -123.1, 49.2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(testMover(7, -123.1, 49.2)); err != nil {
+		t.Fatal(err)
+	}
+	commands := r.sent("GEOADD", "ZREM", "PUBLISH")
+	if len(commands) != 2 {
+		t.Fatalf("sent %v, want two messages", commands)
+	}
+	for i, command := range commands {
+		if command[1] != "movers:7" {
+			t.Errorf("message %d went to %s, want movers:7", i, command[1])
+		}
+		var feature sink.Feature
+		if err := json.Unmarshal([]byte(command[2]), &feature); err != nil {
+			t.Fatal(err)
+		}
+		if feature.Id != 7 || feature.Geometry.Coordinates[0] != -123.1 || feature.Geometry.Coordinates[1] != 49.2 {
+			t.Errorf("message %d is %v, want mover 7 at its position", i, feature)
+		}
+		if deleted := feature.Properties["deleted"] == true; deleted != (i == 1) {
+			t.Errorf("message %d deleted is %t, want %t", i, deleted, i == 1)
+		}
+	}
+}
+
+func TestNewSinkErrors(t *testing.T) {
+	if _, err := NewSink(Props{Address: "127.0.0.1:0", Channel: "movers"}); err == nil {
+		t.Error("unreachable server, want an error")
+	}
+	if _, err := NewSink(Props{Address: "127.0.0.1:0"}); err == nil {
+		t.Error("neither a channel nor a GEO set, want an error")
+	}
+}
@@ -0,0 +1,37 @@
+// Package sink defines where mover state goes. The sinks
+// themselves live in the packages below this one.
+package sink
+
+import (
+	// System
+	"context"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+// Sink is somewhere mover state is written. Every sink keeps its
+// own statistics, so runs against several sinks can be compared.
+type Sink interface {
+	Name() string
+	// Create records a new mover
+	Create(m mover.Mover) error
+	// WritePosition records the current position of a mover
+	WritePosition(m mover.Mover) error
+	// WriteBatch records several positions in one go, in order
+	WriteBatch(ms []mover.Mover) error
+	// Delete records that a mover has gone away
+	Delete(m mover.Mover) error
+	// Close flushes anything pending. No writes may follow.
+	Close() error
+	Stats() *RunStats
+}
+
+// TracedSink is a sink that writes positions later than it is
+// handed them, as in batches, and so takes the context of the
+// write along, for its later spans to link back to the ticks.
+// Simulations write through it to those of their sinks that
+// implement it.
+type TracedSink interface {
+	WriteTraced(ctx context.Context, ms []mover.Mover) error
+}
@@ -0,0 +1,174 @@
+package sink
+
+import (
+	// System
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Keep at most this many latency samples per run, chosen by
+// reservoir sampling, so long runs use a bounded amount of memory.
+const latencyReservoirSize = 65536
+
+// RunStats accumulates write counts and latencies for one
+// simulation run. Safe for concurrent use.
+type RunStats struct {
+	mutex     sync.Mutex
+	started   time.Time
+	stopped   time.Time
+	updates   int64
+	writes    int64
+	errors    int64
+	repairs   int64
+	latencies []time.Duration
+	// Successful writes, which the latencies are sampled from
+	sampled   int64
+	lastWrite time.Time
+
+	// Backpressure, see RecordQueue
+	dropped    int64
+	coalesced  int64
+	queueDepth int
+	queueMax   int
+}
+
+// RunSummary is the digest of a finished run.
+type RunSummary struct {
+	Duration      float64 `json:"duration_s"`
+	Updates       int64   `json:"updates"`
+	Writes        int64   `json:"writes"`
+	Errors        int64   `json:"errors"`
+	Repairs       int64   `json:"repairs"`
+	Dropped       int64   `json:"dropped"`
+	Coalesced     int64   `json:"coalesced"`
+	QueueDepth    int     `json:"queue_depth"`
+	QueueMax      int     `json:"queue_max"`
+	UpdatesPerSec float64 `json:"updates_per_sec"`
+	LatencyMean   float64 `json:"latency_mean_ms"`
+	LatencyP50    float64 `json:"latency_p50_ms"`
+	LatencyP95    float64 `json:"latency_p95_ms"`
+	LatencyP99    float64 `json:"latency_p99_ms"`
+	LatencyMax    float64 `json:"latency_max_ms"`
+}
+
+func NewRunStats() *RunStats {
+	return &RunStats{started: time.Now()}
+}
+
+// RecordWrite notes one round trip to the sink that carried
+// the given number of position updates.
+func (s *RunStats) RecordWrite(updates int, elapsed time.Duration, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.writes++
+	if err != nil {
+		s.errors++
+		return
+	}
+	s.updates += int64(updates)
+	s.lastWrite = time.Now()
+	s.sampled++
+	if len(s.latencies) < latencyReservoirSize {
+		s.latencies = append(s.latencies, elapsed)
+	} else if i := rand.Int63n(s.sampled); i < latencyReservoirSize {
+		s.latencies[i] = elapsed
+	}
+}
+
+// LastWrite is when the last successful write finished,
+// zero if there has been none.
+func (s *RunStats) LastWrite() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lastWrite
+}
+
+// RecordRepairs notes mover state that had to be corrected
+// before it could be written.
+func (s *RunStats) RecordRepairs(repairs int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.repairs += int64(repairs)
+}
+
+// RecordQueue notes the depth of a queue in front of the sink,
+// and any updates it dropped, or coalesced with a later update of
+// the same mover, since the last call.
+func (s *RunStats) RecordQueue(depth int, dropped, coalesced int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.queueDepth = depth
+	if depth > s.queueMax {
+		s.queueMax = depth
+	}
+	s.dropped += int64(dropped)
+	s.coalesced += int64(coalesced)
+}
+
+// Stop marks the end of the run for throughput calculations.
+func (s *RunStats) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stopped = time.Now()
+}
+
+func (s *RunStats) Summary() RunSummary {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	stopped := s.stopped
+	if stopped.IsZero() {
+		stopped = time.Now()
+	}
+	elapsed := stopped.Sub(s.started)
+
+	summary := RunSummary{
+		Duration: elapsed.Seconds(),
+		Updates:  s.updates,
+		Writes:   s.writes,
+		Errors:   s.errors,
+		Repairs:  s.repairs,
+
+		Dropped:    s.dropped,
+		Coalesced:  s.coalesced,
+		QueueDepth: s.queueDepth,
+		QueueMax:   s.queueMax,
+	}
+	if elapsed > 0 {
+		summary.UpdatesPerSec = float64(s.updates) / elapsed.Seconds()
+	}
+	if len(s.latencies) == 0 {
+		return summary
+	}
+
+	sorted := make([]time.Duration, len(s.latencies))
+	copy(sorted, s.latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, l := range sorted {
+		total += l
+	}
+	summary.LatencyMean = milliseconds(total / time.Duration(len(sorted)))
+	summary.LatencyP50 = milliseconds(percentile(sorted, 0.50))
+	summary.LatencyP95 = milliseconds(percentile(sorted, 0.95))
+	summary.LatencyP99 = milliseconds(percentile(sorted, 0.99))
+	summary.LatencyMax = milliseconds(sorted[len(sorted)-1])
+	return summary
+}
+
+// percentile reads the nearest-rank percentile from sorted samples.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p*float64(len(sorted)) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
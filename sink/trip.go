@@ -0,0 +1,56 @@
+package sink
+
+import (
+	// System
+	"time"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+// Why trips end
+const (
+	// The mover reached its destination
+	TripArrival = "arrival"
+	// The mover stood still for the minimum pause
+	TripPause = "pause"
+	// The mover stopped, died or left its bounds mid trip
+	TripStop = "stop"
+)
+
+// Trip is one stretch of a mover moving between pauses, at the
+// device times of the mover. A trip is written twice: when it
+// starts, without an End or Reason, and again when it ends.
+type Trip struct {
+	// Unique within the run, across its shards
+	Id     int64
+	Mover  mover.Mover
+	Start  time.Time
+	End    time.Time
+	StartX float64
+	StartY float64
+	EndX   float64
+	EndY   float64
+	// Distance moved along the way, in meters
+	Length float64
+	Reason string
+}
+
+// Ended reports whether this is the record of the end of the trip.
+func (t Trip) Ended() bool {
+	return t.Reason != ""
+}
+
+// Duration is how long the trip took, zero until it ends.
+func (t Trip) Duration() time.Duration {
+	if !t.Ended() {
+		return 0
+	}
+	return t.End.Sub(t.Start)
+}
+
+// TripSink is a sink that also records trips. Simulations
+// send trips to those of their sinks that implement it.
+type TripSink interface {
+	WriteTrip(t Trip) error
+}
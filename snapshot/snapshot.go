@@ -0,0 +1,175 @@
+// Package snapshot dumps the current state of a fleet on demand,
+// as a GeoJSON FeatureCollection or as CSV, for debugging and for
+// seeding visualizations without any database.
+package snapshot
+
+import (
+	// System
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/sink"
+)
+
+// Snapshot formats
+const (
+	FormatGeoJSON = "geojson"
+	FormatCSV     = "csv"
+)
+
+// Source is where the fleet state comes from, such as the
+// Movers of a simulation.
+type Source func() []mover.Mover
+
+type collection struct {
+	Type     string         `json:"type"`
+	Features []sink.Feature `json:"features"`
+}
+
+// The leading CSV columns, which every mover has; its other
+// properties follow in columns of their own, by name
+var csvColumns = []string{"id", "name", "class", "color", "ts", "x", "y", "z", "heading", "velocity"}
+
+// Check fails for formats that cannot be written.
+func Check(format string) error {
+	switch format {
+	case FormatGeoJSON, FormatCSV:
+		return nil
+	}
+	return fmt.Errorf("unknown snapshot format '%s'", format)
+}
+
+// ContentType is the media type of the format.
+func ContentType(format string) string {
+	if format == FormatCSV {
+		return "text/csv"
+	}
+	return "application/geo+json"
+}
+
+// Write writes the movers in the format.
+func Write(out io.Writer, format string, movers []mover.Mover) error {
+	switch format {
+	case FormatGeoJSON:
+		return writeGeoJSON(out, movers)
+	case FormatCSV:
+		return writeCSV(out, movers)
+	}
+	return Check(format)
+}
+
+func writeGeoJSON(out io.Writer, movers []mover.Mover) error {
+	c := collection{
+		Type:     "FeatureCollection",
+		Features: make([]sink.Feature, len(movers)),
+	}
+	for i, m := range movers {
+		c.Features[i] = sink.NewFeature(m)
+	}
+	return json.NewEncoder(out).Encode(c)
+}
+
+// writeCSV writes a row per mover, with a column for every
+// property any of them has, left empty for those without it.
+func writeCSV(out io.Writer, movers []mover.Mover) error {
+	fixed := make(map[string]bool, len(csvColumns))
+	for _, c := range csvColumns {
+		fixed[c] = true
+	}
+	props := make([]map[string]interface{}, len(movers))
+	extra := make(map[string]bool)
+	for i, m := range movers {
+		props[i] = sink.Properties(m)
+		for k := range props[i] {
+			if !fixed[k] {
+				extra[k] = true
+			}
+		}
+	}
+	var extraColumns []string
+	for k := range extra {
+		extraColumns = append(extraColumns, k)
+	}
+	sort.Strings(extraColumns)
+
+	buffer := bufio.NewWriter(out)
+	w := csv.NewWriter(buffer)
+	w.Write(append(append([]string{}, csvColumns...), extraColumns...))
+	for i, m := range movers {
+		z := ""
+		if m.HasZ {
+			z = formatFloat(m.Z)
+		}
+		row := []string{
+			strconv.Itoa(m.Id),
+			m.Name,
+			m.Class.Name,
+			m.Color,
+			m.DeviceTime(m.Ts).Format(time.RFC3339Nano),
+			formatFloat(m.X),
+			formatFloat(m.Y),
+			z,
+			strconv.Itoa(m.Heading),
+			formatFloat(m.Velocity),
+		}
+		for _, k := range extraColumns {
+			row = append(row, formatValue(props[i][k]))
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return buffer.Flush()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return formatFloat(value)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// Handler serves the fleet state from the source, as GeoJSON
+// unless asked for ?format=csv.
+func Handler(source Source) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = FormatGeoJSON
+		}
+		if err := Check(format); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Positions change every tick
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", ContentType(format))
+		Write(w, format, source())
+	})
+}
@@ -0,0 +1,98 @@
+package snapshot
+
+import (
+	// System
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+func testMovers() []mover.Mover {
+	ts := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	return []mover.Mover{
+		{
+			Id: 1, Name: "Aurora", Color: "#ff0000", Ts: ts,
+			X: -123.1, Y: 49.2, Heading: 90, Velocity: 0.001,
+			Class:  &mover.Class{Name: "ship", Priority: 2},
+			Fields: map[string]interface{}{"fuel": 42.5},
+		},
+		{
+			Id: 2, Name: "Boreas", Color: "#00ff00", Ts: ts,
+			X: -123.2, Y: 49.3, Z: 120, HasZ: true,
+			Class:  &mover.Class{Name: "drone"},
+			Fields: map[string]interface{}{"region": "west, north"},
+		},
+	}
+}
+
+func TestCSV(t *testing.T) {
+	var out bytes.Buffer
+	if err := Write(&out, FormatCSV, testMovers()); err != nil {
+		t.Fatal(err)
+	}
+	want := "id,name,class,color,ts,x,y,z,heading,velocity,climb,fuel,maxzoom,minzoom,priority,region\n" +
+		"1,Aurora,ship,#ff0000,2024-01-01T12:00:00Z,-123.1,49.2,,90,0.001,,42.5,0,0,2,\n" +
+		"2,Boreas,drone,#00ff00,2024-01-01T12:00:00Z,-123.2,49.3,120,0,0,0,,0,0,0,\"west, north\"\n"
+	if out.String() != want {
+		t.Errorf("CSV is\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestGeoJSON(t *testing.T) {
+	var out bytes.Buffer
+	if err := Write(&out, FormatGeoJSON, testMovers()); err != nil {
+		t.Fatal(err)
+	}
+	var c collection
+	if err := json.Unmarshal(out.Bytes(), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Type != "FeatureCollection" || len(c.Features) != 2 {
+		t.Fatalf("snapshot is a %s of %d features, want a FeatureCollection of 2", c.Type, len(c.Features))
+	}
+	if coords := c.Features[1].Geometry.Coordinates; len(coords) != 3 || coords[2] != 120 {
+		t.Errorf("drone is at %v, want its altitude too", coords)
+	}
+	if err := Write(&out, "xml", nil); err == nil {
+		t.Error("unknown format, want an error")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	handler := Handler(testMovers)
+	tests := []struct {
+		method      string
+		target      string
+		status      int
+		contentType string
+	}{
+		{http.MethodGet, "/snapshot", http.StatusOK, "application/geo+json"},
+		{http.MethodGet, "/snapshot?format=csv", http.StatusOK, "text/csv"},
+		{http.MethodHead, "/snapshot?format=geojson", http.StatusOK, "application/geo+json"},
+		{http.MethodGet, "/snapshot?format=xml", http.StatusBadRequest, ""},
+		{http.MethodPost, "/snapshot", http.StatusMethodNotAllowed, ""},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(test.method, test.target, nil))
+		if w.Code != test.status {
+			t.Errorf("%s %s: status %d, want %d", test.method, test.target, w.Code, test.status)
+			continue
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+		if got := w.Header().Get("Content-Type"); got != test.contentType {
+			t.Errorf("%s %s: content type %s, want %s", test.method, test.target, got, test.contentType)
+		}
+		if w.Header().Get("Cache-Control") != "no-store" {
+			t.Errorf("%s %s: cacheable snapshot", test.method, test.target)
+		}
+	}
+}
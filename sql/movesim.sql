@@ -0,0 +1,228 @@
+-- MoveSim tables
+--
+-- moving.objects holds the latest position of every mover,
+-- and is updated in place by the "update" write strategy.
+-- moving.history holds one row per position, and is appended
+-- to by the "append" write strategy.
+-- Headings are in degrees counterclockwise from north, and
+-- velocities in degrees per tick, as in the file output.
+-- Positions are 2D, until a run with altitudes turns the
+-- tables it writes to PointZ, with the altitude in meters, zero
+-- for movers without one. Rows are tagged with the id of the run
+-- that wrote them, see moving.runs and movesim clean. The
+-- simulator can turn moving.history into a table partitioned by
+-- ts, keeping its rows and index names, see [Database.Partitions]
+-- in the configuration.
+
+CREATE SCHEMA IF NOT EXISTS moving;
+
+CREATE TABLE IF NOT EXISTS moving.objects (
+  id integer PRIMARY KEY,
+  geog geography(Point, 4326),
+  color text,
+  ts timestamptz DEFAULT now(),
+  class text,
+  priority integer DEFAULT 0,
+  minzoom integer DEFAULT 0,
+  maxzoom integer DEFAULT 22,
+  props jsonb,
+  heading integer,
+  velocity double precision,
+  run text
+);
+
+-- Upgrade tables from earlier versions
+ALTER TABLE moving.objects ADD COLUMN IF NOT EXISTS class text;
+ALTER TABLE moving.objects ADD COLUMN IF NOT EXISTS priority integer DEFAULT 0;
+ALTER TABLE moving.objects ADD COLUMN IF NOT EXISTS minzoom integer DEFAULT 0;
+ALTER TABLE moving.objects ADD COLUMN IF NOT EXISTS maxzoom integer DEFAULT 22;
+ALTER TABLE moving.objects ADD COLUMN IF NOT EXISTS props jsonb;
+ALTER TABLE moving.objects ADD COLUMN IF NOT EXISTS heading integer;
+ALTER TABLE moving.objects ADD COLUMN IF NOT EXISTS velocity double precision;
+ALTER TABLE moving.objects ADD COLUMN IF NOT EXISTS run text;
+
+CREATE TABLE IF NOT EXISTS moving.history (
+  id integer NOT NULL,
+  geog geography(Point, 4326),
+  ts timestamptz NOT NULL DEFAULT now(),
+  props jsonb,
+  heading integer,
+  velocity double precision,
+  run text
+);
+
+ALTER TABLE moving.history ADD COLUMN IF NOT EXISTS props jsonb;
+ALTER TABLE moving.history ADD COLUMN IF NOT EXISTS heading integer;
+ALTER TABLE moving.history ADD COLUMN IF NOT EXISTS velocity double precision;
+ALTER TABLE moving.history ADD COLUMN IF NOT EXISTS run text;
+
+-- Made unless the table has an index on the same columns already,
+-- as history tables partitioned by earlier versions of the
+-- simulator have under other names
+DO $$
+BEGIN
+  IF NOT EXISTS (SELECT 1 FROM pg_index
+      WHERE indrelid = 'moving.history'::regclass
+      AND pg_get_indexdef(indexrelid) LIKE '%USING btree (id, ts)') THEN
+    CREATE INDEX history_id_ts_x ON moving.history (id, ts);
+  END IF;
+  IF NOT EXISTS (SELECT 1 FROM pg_index
+      WHERE indrelid = 'moving.history'::regclass
+      AND pg_get_indexdef(indexrelid) LIKE '%USING btree (run)') THEN
+    CREATE INDEX history_run_x ON moving.history (run);
+  END IF;
+END $$;
+
+-- Events between movers, such as two coming close, placed
+-- halfway between them with the distance between them in
+-- meters, and of one mover alone, such as entering a no-go
+-- zone, with no b but the zone
+CREATE TABLE IF NOT EXISTS moving.events (
+  id bigserial PRIMARY KEY,
+  kind text NOT NULL,
+  ts timestamptz NOT NULL DEFAULT now(),
+  a integer NOT NULL,
+  b integer,
+  geog geography(Point, 4326),
+  distance double precision,
+  run text,
+  zone text
+);
+
+ALTER TABLE moving.events ADD COLUMN IF NOT EXISTS run text;
+ALTER TABLE moving.events ADD COLUMN IF NOT EXISTS zone text;
+ALTER TABLE moving.events ALTER COLUMN b DROP NOT NULL;
+
+CREATE INDEX IF NOT EXISTS events_ts_x ON moving.events (ts);
+
+-- The color and name of every mover that has had one, kept
+-- from run to run with Persist in [Movers.Style], so movers are
+-- drawn the same way whatever the settings say; delete rows to
+-- restyle their movers
+CREATE TABLE IF NOT EXISTS moving.styles (
+  id integer PRIMARY KEY,
+  color text,
+  name text
+);
+
+-- Every run that wrote to the database, with the command line and
+-- random seed it ran with, the size of its fleet (all its shards
+-- and regions), and its configuration as loaded, less anything that
+-- may hold a credential,
+-- with the summary of its statistics if asked for
+CREATE TABLE IF NOT EXISTS moving.runs (
+  run text PRIMARY KEY,
+  command text,
+  host text,
+  started timestamptz NOT NULL DEFAULT now(),
+  ended timestamptz,
+  seed bigint,
+  movers integer,
+  config jsonb,
+  summary jsonb
+);
+
+ALTER TABLE moving.runs ADD COLUMN IF NOT EXISTS summary jsonb;
+
+-- Trips of movers between pauses, inserted as they start and
+-- filled in as they end, with the distance moved in meters.
+-- Ids are unique within a run, across all its shards.
+CREATE TABLE IF NOT EXISTS moving.trips (
+  run text,
+  id bigint NOT NULL,
+  mover integer NOT NULL,
+  start_ts timestamptz NOT NULL,
+  end_ts timestamptz,
+  start_geog geography(Point, 4326),
+  end_geog geography(Point, 4326),
+  length double precision,
+  duration interval,
+  reason text
+);
+
+CREATE INDEX IF NOT EXISTS trips_run_id_x ON moving.trips (run, id);
+CREATE INDEX IF NOT EXISTS trips_mover_x ON moving.trips (mover, start_ts);
+
+-- Latest stats of each process of a sharded run, so that the
+-- shards can be added up without a coordinator, see movesim stats
+CREATE TABLE IF NOT EXISTS moving.shards (
+  run text NOT NULL,
+  shard integer NOT NULL,
+  shards integer NOT NULL,
+  host text,
+  movers integer,
+  updated timestamptz NOT NULL DEFAULT now(),
+  duration_s double precision,
+  updates bigint,
+  writes bigint,
+  errors bigint,
+  updates_per_sec double precision,
+  latency_mean_ms double precision,
+  latency_p95_ms double precision,
+  latency_max_ms double precision,
+  PRIMARY KEY (run, shard)
+);
+
+-- Commands for running movers, picked up on their next tick when
+-- the simulator runs with Control set. Inserting a row announces
+-- it on the 'movesim_commands' channel, and so does calling
+-- pg_notify directly with the same JSON. Commands are "destination"
+-- (x, y), "freeze", "resume" and "speed" (velocity). A destination
+-- with a null x or y is ignored, rather than read as 0.
+CREATE TABLE IF NOT EXISTS moving.commands (
+  id bigserial PRIMARY KEY,
+  ts timestamptz NOT NULL DEFAULT now(),
+  mover integer NOT NULL,
+  command text NOT NULL,
+  x double precision,
+  y double precision,
+  velocity double precision
+);
+
+CREATE OR REPLACE FUNCTION moving.commands_notify()
+RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('movesim_commands', json_build_object(
+    'mover', NEW.mover,
+    'command', NEW.command,
+    'x', NEW.x,
+    'y', NEW.y,
+    'velocity', NEW.velocity
+  )::text);
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS commands_notify ON moving.commands;
+CREATE TRIGGER commands_notify
+  AFTER INSERT ON moving.commands
+  FOR EACH ROW EXECUTE FUNCTION moving.commands_notify();
+
+-- Notify listeners (for example pg_eventserv) of every
+-- change to moving.objects on the 'objects' channel
+CREATE OR REPLACE FUNCTION moving.objects_notify()
+RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('objects', json_build_object(
+    'type', 'Feature',
+    'geometry', ST_AsGeoJSON(NEW.geog)::json,
+    'properties', jsonb_build_object(
+      'id', NEW.id,
+      'color', NEW.color,
+      'ts', NEW.ts,
+      'heading', NEW.heading,
+      'velocity', NEW.velocity,
+      'class', NEW.class,
+      'priority', NEW.priority,
+      'minzoom', NEW.minzoom,
+      'maxzoom', NEW.maxzoom
+    ) || coalesce(NEW.props, '{}'::jsonb)
+  )::text);
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS objects_notify ON moving.objects;
+CREATE TRIGGER objects_notify
+  AFTER INSERT OR UPDATE ON moving.objects
+  FOR EACH ROW EXECUTE FUNCTION moving.objects_notify();
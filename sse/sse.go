@@ -0,0 +1,365 @@
+// Package sse streams the live positions of a simulation to
+// browsers as Server-Sent Events, which plain JavaScript reads with
+// EventSource, no client library needed:
+//
+//	const events = new EventSource("/events?bbox=-10,40,10,60");
+//	events.addEventListener("move", e => draw(JSON.parse(e.data)));
+package sse
+
+import (
+	// System
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	// Logging
+	log "github.com/sirupsen/logrus"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/sink"
+)
+
+// Event types. Create, move and leave carry the mover as a GeoJSON
+// Feature, delete just its id. A mover leaves when it moves out of
+// the bounding box of the connection, and is created again when it
+// comes back. Events between movers, such as proximity events, go
+// out under their own kind.
+const (
+	EventCreate = "create"
+	EventMove   = "move"
+	EventDelete = "delete"
+	EventLeave  = "leave"
+)
+
+// Events buffered per connection, past which a slow client
+// misses events rather than holding up the movers. A mover whose
+// create or leave was missed is created or left again on its next
+// move, so the client does not lose track of it for good.
+const clientBuffer = 1024
+
+// Comment sent when there is nothing else to send, so proxies
+// do not close idle connections
+const keepAlive = 15 * time.Second
+
+// Server is a sink publishing every change to the connected
+// clients, and the handler they connect to. Clients can ask for a
+// bounding box, ?bbox=minx,miny,maxx,maxy, and for certain movers,
+// ?ids=1,2,3. On connecting they get a create event for every live
+// mover they asked for.
+type Server struct {
+	mutex   sync.Mutex
+	movers  map[int]mover.Mover
+	clients map[*client]struct{}
+	closed  bool
+	stats   *sink.RunStats
+}
+
+type client struct {
+	bbox    *[4]float64
+	ids     map[int]bool
+	inside  map[int]bool
+	events  chan []byte
+	dropped int
+}
+
+func NewServer() *Server {
+	return &Server{
+		movers:  make(map[int]mover.Mover),
+		clients: make(map[*client]struct{}),
+		stats:   sink.NewRunStats(),
+	}
+}
+
+func (s *Server) Name() string {
+	return "sse"
+}
+
+func (s *Server) Stats() *sink.RunStats {
+	return s.stats
+}
+
+func (s *Server) Create(m mover.Mover) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.movers[m.Id] = m
+	s.publish(m, false)
+	return nil
+}
+
+func (s *Server) WritePosition(m mover.Mover) error {
+	return s.WriteBatch([]mover.Mover{m})
+}
+
+func (s *Server) WriteBatch(ms []mover.Mover) error {
+	start := time.Now()
+	s.mutex.Lock()
+	for _, m := range ms {
+		s.movers[m.Id] = m
+		s.publish(m, false)
+	}
+	s.mutex.Unlock()
+	s.stats.RecordWrite(len(ms), time.Since(start), nil)
+	return nil
+}
+
+func (s *Server) Delete(m mover.Mover) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.movers, m.Id)
+	s.publish(m, true)
+	return nil
+}
+
+// WriteEvent sends the event to the clients watching either
+// mover or, with a bounding box, the place halfway between them.
+// Events of one mover, such as entering a no-go zone, carry the
+// zone rather than a second mover.
+func (s *Server) WriteEvent(e sink.Event) error {
+	x, y := e.Midpoint()
+	fields := map[string]interface{}{
+		"kind":        e.Kind,
+		"ts":          e.Ts.Format(time.RFC3339Nano),
+		"a":           e.A.Id,
+		"b":           e.B.Id,
+		"distance":    e.Distance,
+		"coordinates": []float64{x, y},
+	}
+	if !e.Pair() {
+		delete(fields, "b")
+		delete(fields, "distance")
+		fields["zone"] = e.Zone
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	event := []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", e.Kind, data))
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for c := range s.clients {
+		if c.ids != nil && !c.ids[e.A.Id] && !(e.Pair() && c.ids[e.B.Id]) {
+			continue
+		}
+		if c.contains(x, y) {
+			c.send(event)
+		}
+	}
+	return nil
+}
+
+// Close ends every connection.
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for c := range s.clients {
+		close(c.events)
+		delete(s.clients, c)
+	}
+	s.closed = true
+	return nil
+}
+
+// publish sends the change to every client that wants it,
+// encoding each event once for all of them.
+func (s *Server) publish(m mover.Mover, deleted bool) {
+	encoded := make(map[string][]byte, 1)
+	for c := range s.clients {
+		kind, ok := c.event(m, deleted)
+		if !ok {
+			continue
+		}
+		event, ok := encoded[kind]
+		if !ok {
+			event = encode(kind, m)
+			encoded[kind] = event
+		}
+		if c.send(event) {
+			c.sent(m.Id, kind)
+		}
+	}
+}
+
+// event decides what the client hears of the change, if anything,
+// from the movers it can see.
+func (c *client) event(m mover.Mover, deleted bool) (string, bool) {
+	if c.ids != nil && !c.ids[m.Id] {
+		return "", false
+	}
+	seen := c.inside[m.Id]
+	switch {
+	case deleted:
+		return EventDelete, seen
+	case !c.contains(m.X, m.Y):
+		return EventLeave, seen
+	case !seen:
+		return EventCreate, true
+	default:
+		return EventMove, true
+	}
+}
+
+// sent keeps track of the movers the client can see, once it
+// has been sent the event of the kind.
+func (c *client) sent(id int, kind string) {
+	switch kind {
+	case EventCreate:
+		c.inside[id] = true
+	case EventDelete, EventLeave:
+		delete(c.inside, id)
+	}
+}
+
+func (c *client) contains(x, y float64) bool {
+	if b := c.bbox; b != nil {
+		return x >= b[0] && y >= b[1] && x <= b[2] && y <= b[3]
+	}
+	return true
+}
+
+// send queues the event for the client, reporting false if
+// its buffer is full and the event was dropped.
+func (c *client) send(event []byte) bool {
+	select {
+	case c.events <- event:
+		return true
+	default:
+		c.dropped++
+		return false
+	}
+}
+
+// encode writes one event in the text/event-stream format.
+func encode(kind string, m mover.Mover) []byte {
+	var data []byte
+	if kind == EventDelete {
+		data, _ = json.Marshal(map[string]int{"id": m.Id})
+	} else {
+		data, _ = json.Marshal(sink.NewFeature(m))
+	}
+	return []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", kind, data))
+}
+
+// ServeHTTP streams events until the client goes away
+// or the simulation ends.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	c, err := newClient(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		http.Error(w, "simulation has ended", http.StatusServiceUnavailable)
+		return
+	}
+	for _, m := range s.movers {
+		if kind, ok := c.event(m, false); ok && c.send(encode(kind, m)) {
+			c.sent(m.Id, kind)
+		}
+	}
+	s.clients[c] = struct{}{}
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		delete(s.clients, c)
+		dropped := c.dropped
+		s.mutex.Unlock()
+		if dropped > 0 {
+			log.Warnf("Event stream client fell behind and missed %d events", dropped)
+		}
+	}()
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-store")
+	header.Set("Access-Control-Allow-Origin", "*")
+	// Stop nginx holding events back
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if _, err := w.Write([]byte(": keepalive\n\n")); err != nil {
+				return
+			}
+		case event, ok := <-c.events:
+			if !ok {
+				return
+			}
+			if _, err := w.Write(event); err != nil {
+				return
+			}
+			// Send whatever else is waiting along with it
+			for pending := len(c.events); pending > 0; pending-- {
+				event, ok := <-c.events
+				if !ok {
+					break
+				}
+				if _, err := w.Write(event); err != nil {
+					return
+				}
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// newClient reads the filters of the request.
+func newClient(r *http.Request) (*client, error) {
+	c := &client{
+		inside: make(map[int]bool),
+		events: make(chan []byte, clientBuffer),
+	}
+	query := r.URL.Query()
+	if bbox := query.Get("bbox"); bbox != "" {
+		parts := strings.Split(bbox, ",")
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("bbox must be minx,miny,maxx,maxy")
+		}
+		var b [4]float64
+		for i, part := range parts {
+			v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid bbox coordinate '%s'", part)
+			}
+			b[i] = v
+		}
+		if b[0] > b[2] || b[1] > b[3] {
+			return nil, fmt.Errorf("bbox minimum is above its maximum")
+		}
+		c.bbox = &b
+	}
+	if ids := query.Get("ids"); ids != "" {
+		c.ids = make(map[int]bool)
+		for _, part := range strings.Split(ids, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return nil, fmt.Errorf("invalid mover id '%s'", part)
+			}
+			c.ids[id] = true
+		}
+	}
+	return c, nil
+}
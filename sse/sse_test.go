@@ -0,0 +1,61 @@
+package sse
+
+import (
+	// System
+	"strings"
+	"testing"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+)
+
+// kinds are the kinds of the events waiting for the client.
+func kinds(c *client) []string {
+	var kinds []string
+	for len(c.events) > 0 {
+		event := string(<-c.events)
+		kinds = append(kinds, strings.TrimPrefix(strings.SplitN(event, "\n", 2)[0], "event: "))
+	}
+	return kinds
+}
+
+func TestDroppedEvents(t *testing.T) {
+	s := NewServer()
+	c := &client{
+		bbox:   &[4]float64{0, 0, 10, 10},
+		inside: make(map[int]bool),
+		events: make(chan []byte, 1),
+	}
+	s.clients[c] = struct{}{}
+	full := []byte("event: move\ndata: {}\n\n")
+	m := mover.Mover{Id: 1, X: 5, Y: 5, Class: &mover.Class{Name: "default"}}
+
+	// A create missed with a full buffer is sent again
+	c.events <- full
+	s.WritePosition(m)
+	if c.inside[m.Id] {
+		t.Fatal("mover taken as seen although its create was dropped")
+	}
+	kinds(c)
+	s.WritePosition(m)
+	if got := kinds(c); len(got) != 1 || got[0] != EventCreate {
+		t.Fatalf("got %v after the buffer drained, want a create", got)
+	}
+
+	// So is a leave
+	m.X = 20
+	c.events <- full
+	s.WritePosition(m)
+	kinds(c)
+	s.WritePosition(m)
+	if got := kinds(c); len(got) != 1 || got[0] != EventLeave {
+		t.Fatalf("got %v after the buffer drained, want a leave", got)
+	}
+	s.WritePosition(m)
+	if got := kinds(c); len(got) != 0 {
+		t.Fatalf("got %v once it had left, want nothing", got)
+	}
+	if c.dropped != 2 {
+		t.Errorf("dropped %d events, want 2", c.dropped)
+	}
+}
@@ -0,0 +1,102 @@
+// Package telemetry traces the simulator with OpenTelemetry and
+// exports the spans over OTLP, so the latency from a mover's tick
+// to its database write can be followed in a tracing backend.
+// Packages trace through Tracer whether or not Start was called;
+// until it is, their spans cost next to nothing and go nowhere.
+package telemetry
+
+import (
+	// System
+	"context"
+	"fmt"
+
+	// OpenTelemetry
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Props configures the exporter. Endpoint is the host:port of an
+// OTLP gRPC collector, by default from OTEL_EXPORTER_OTLP_ENDPOINT
+// or else localhost:4317. Insecure skips TLS, as local collectors
+// want. SampleRatio is the share of ticks traced, all of them
+// when zero.
+type Props struct {
+	Enabled     bool
+	Endpoint    string
+	Insecure    bool
+	SampleRatio float64
+	ServiceName string
+}
+
+const instrumentation = "github.com/pramsey/movesim"
+
+// Tracer is what the simulator starts its spans with.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// Start exports spans as the props say, returning the function
+// that flushes the last of them and stops exporting.
+func Start(ctx context.Context, props Props) (func(context.Context) error, error) {
+	if props.SampleRatio < 0 || props.SampleRatio > 1 {
+		return nil, fmt.Errorf("sample ratio %g is not between 0 and 1", props.SampleRatio)
+	}
+	var opts []otlptracegrpc.Option
+	if props.Endpoint != "" {
+		opts = append(opts, otlptracegrpc.WithEndpoint(props.Endpoint))
+	}
+	if props.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to start the OTLP exporter: %w", err)
+	}
+	name := props.ServiceName
+	if name == "" {
+		name = "movesim"
+	}
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", name)))
+	if err != nil {
+		return nil, err
+	}
+	ratio := props.SampleRatio
+	if ratio == 0 {
+		ratio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// End finishes a span, marking it failed if there was an error.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Link adds a span to the links of a later one, such as the flush
+// of a batch of updates queued by many ticks, unless it was not
+// traced or is the one linked last already.
+func Link(links []trace.Link, span trace.SpanContext) []trace.Link {
+	if !span.IsValid() {
+		return links
+	}
+	if n := len(links); n > 0 && links[n-1].SpanContext.Equal(span) {
+		return links
+	}
+	return append(links, trace.Link{SpanContext: span})
+}
@@ -0,0 +1,31 @@
+package telemetry
+
+import (
+	// System
+	"testing"
+
+	// OpenTelemetry
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestLink(t *testing.T) {
+	span := func(id byte) trace.SpanContext {
+		return trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: trace.TraceID{id},
+			SpanID:  trace.SpanID{id},
+		})
+	}
+	var links []trace.Link
+	for _, sc := range []trace.SpanContext{span(1), span(1), {}, span(2), span(1)} {
+		links = Link(links, sc)
+	}
+	want := []trace.SpanContext{span(1), span(2), span(1)}
+	if len(links) != len(want) {
+		t.Fatalf("got %d links, want %d", len(links), len(want))
+	}
+	for i, link := range links {
+		if !link.SpanContext.Equal(want[i]) {
+			t.Errorf("link %d is to %s, want %s", i, link.SpanContext.SpanID(), want[i].SpanID())
+		}
+	}
+}
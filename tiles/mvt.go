@@ -0,0 +1,179 @@
+package tiles
+
+import (
+	// System
+	"math"
+	"sort"
+
+	// Protocol buffer wire format
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The parts of the Mapbox Vector Tile format, version 2, needed
+// for a layer of points. See
+// https://github.com/mapbox/vector-tile-spec/tree/master/2.1
+
+// Field numbers of the tile messages
+const (
+	tileLayers = 3
+
+	layerVersion  = 15
+	layerName     = 1
+	layerFeatures = 2
+	layerKeys     = 3
+	layerValues   = 4
+	layerExtent   = 5
+
+	featureId       = 1
+	featureTags     = 2
+	featureType     = 3
+	featureGeometry = 4
+
+	valueString = 1
+	valueDouble = 3
+	valueSint   = 6
+	valueBool   = 7
+)
+
+const (
+	geomTypePoint = 1
+	cmdMoveTo     = 1
+)
+
+// layer gathers the point features of one tile layer, sharing
+// the keys and values between them as the format asks.
+type layer struct {
+	name     string
+	extent   int
+	features [][]byte
+	keys     []string
+	keyIndex map[string]int
+	values   [][]byte
+	valIndex map[interface{}]int
+}
+
+func newLayer(name string, extent int) *layer {
+	return &layer{
+		name:     name,
+		extent:   extent,
+		keyIndex: make(map[string]int),
+		valIndex: make(map[interface{}]int),
+	}
+}
+
+// addPoint adds a feature at the tile coordinates, with those of
+// its properties the format can carry.
+func (l *layer) addPoint(id uint64, x, y int, props map[string]interface{}) {
+	names := make([]string, 0, len(props))
+	for k := range props {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var tags []byte
+	for _, k := range names {
+		value, ok := l.value(props[k])
+		if !ok {
+			continue
+		}
+		tags = protowire.AppendVarint(tags, uint64(l.key(k)))
+		tags = protowire.AppendVarint(tags, uint64(value))
+	}
+
+	var geom []byte
+	geom = protowire.AppendVarint(geom, uint64(cmdMoveTo|1<<3))
+	geom = protowire.AppendVarint(geom, protowire.EncodeZigZag(int64(x)))
+	geom = protowire.AppendVarint(geom, protowire.EncodeZigZag(int64(y)))
+
+	var f []byte
+	f = protowire.AppendTag(f, featureId, protowire.VarintType)
+	f = protowire.AppendVarint(f, id)
+	f = protowire.AppendTag(f, featureTags, protowire.BytesType)
+	f = protowire.AppendBytes(f, tags)
+	f = protowire.AppendTag(f, featureType, protowire.VarintType)
+	f = protowire.AppendVarint(f, geomTypePoint)
+	f = protowire.AppendTag(f, featureGeometry, protowire.BytesType)
+	f = protowire.AppendBytes(f, geom)
+	l.features = append(l.features, f)
+}
+
+func (l *layer) key(k string) int {
+	i, ok := l.keyIndex[k]
+	if !ok {
+		i = len(l.keys)
+		l.keys = append(l.keys, k)
+		l.keyIndex[k] = i
+	}
+	return i
+}
+
+// value indexes a property value, reporting false for
+// values that are not strings, numbers or booleans.
+func (l *layer) value(v interface{}) (int, bool) {
+	var key interface{}
+	var b []byte
+	switch t := v.(type) {
+	case string:
+		key = t
+		b = protowire.AppendTag(b, valueString, protowire.BytesType)
+		b = protowire.AppendString(b, t)
+	case bool:
+		key = t
+		b = protowire.AppendTag(b, valueBool, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeBool(t))
+	case int:
+		key = int64(t)
+		b = protowire.AppendTag(b, valueSint, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeZigZag(int64(t)))
+	case int64:
+		key = t
+		b = protowire.AppendTag(b, valueSint, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeZigZag(t))
+	case float64:
+		if math.IsNaN(t) || math.IsInf(t, 0) {
+			return 0, false
+		}
+		key = t
+		b = protowire.AppendTag(b, valueDouble, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(t))
+	default:
+		return 0, false
+	}
+	i, ok := l.valIndex[key]
+	if !ok {
+		i = len(l.values)
+		l.values = append(l.values, b)
+		l.valIndex[key] = i
+	}
+	return i, true
+}
+
+// tile encodes a tile holding just this layer, or nothing
+// if the layer has no features.
+func (l *layer) tile() []byte {
+	if len(l.features) == 0 {
+		return nil
+	}
+	var b []byte
+	b = protowire.AppendTag(b, layerVersion, protowire.VarintType)
+	b = protowire.AppendVarint(b, 2)
+	b = protowire.AppendTag(b, layerName, protowire.BytesType)
+	b = protowire.AppendString(b, l.name)
+	for _, f := range l.features {
+		b = protowire.AppendTag(b, layerFeatures, protowire.BytesType)
+		b = protowire.AppendBytes(b, f)
+	}
+	for _, k := range l.keys {
+		b = protowire.AppendTag(b, layerKeys, protowire.BytesType)
+		b = protowire.AppendString(b, k)
+	}
+	for _, v := range l.values {
+		b = protowire.AppendTag(b, layerValues, protowire.BytesType)
+		b = protowire.AppendBytes(b, v)
+	}
+	b = protowire.AppendTag(b, layerExtent, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(l.extent))
+
+	var tile []byte
+	tile = protowire.AppendTag(tile, tileLayers, protowire.BytesType)
+	return protowire.AppendBytes(tile, b)
+}
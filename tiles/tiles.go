@@ -0,0 +1,181 @@
+// Package tiles serves the live positions of a simulation as
+// Mapbox Vector Tiles, straight from memory, so map clients can
+// render the fleet without a tile server or a database.
+package tiles
+
+import (
+	// System
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	// Movers
+	"github.com/pramsey/movesim/mover"
+	"github.com/pramsey/movesim/sink"
+)
+
+// Tile layout
+const (
+	// Name of the layer holding the movers
+	LayerName = "movers"
+	// Tile coordinates run from 0 to extent across a tile
+	extent = 4096
+	// Movers this far outside a tile are drawn in it too, so
+	// symbols on the edge are not cut in half
+	buffer  = 64
+	maxZoom = 24
+)
+
+// Web Mercator stops short of the poles
+const maxLatitude = 85.0511287798066
+
+// Server is a sink keeping the latest position of every mover,
+// and the handler serving them as tiles at {z}/{x}/{y}.pbf under
+// wherever it is mounted. Movers only show at the zooms their
+// class allows, see mover.Class.
+type Server struct {
+	mutex  sync.RWMutex
+	movers map[int]mover.Mover
+	stats  *sink.RunStats
+}
+
+func NewServer() *Server {
+	return &Server{
+		movers: make(map[int]mover.Mover),
+		stats:  sink.NewRunStats(),
+	}
+}
+
+func (s *Server) Name() string {
+	return "tiles"
+}
+
+func (s *Server) Stats() *sink.RunStats {
+	return s.stats
+}
+
+func (s *Server) Create(m mover.Mover) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.movers[m.Id] = m
+	return nil
+}
+
+func (s *Server) WritePosition(m mover.Mover) error {
+	return s.WriteBatch([]mover.Mover{m})
+}
+
+func (s *Server) WriteBatch(ms []mover.Mover) error {
+	start := time.Now()
+	s.mutex.Lock()
+	for _, m := range ms {
+		s.movers[m.Id] = m
+	}
+	s.mutex.Unlock()
+	s.stats.RecordWrite(len(ms), time.Since(start), nil)
+	return nil
+}
+
+func (s *Server) Delete(m mover.Mover) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.movers, m.Id)
+	return nil
+}
+
+func (s *Server) Close() error {
+	return nil
+}
+
+// ServeHTTP serves the tile at the {z}/{x}/{y}.pbf path.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	z, x, y, err := parseTile(r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	tile := s.Tile(z, x, y)
+	// Positions change every tick
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if len(tile) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.mapbox-vector-tile")
+	w.Write(tile)
+}
+
+// parseTile reads the tile address from the end of the path.
+func parseTile(path string) (int, int, int, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 3 || !strings.HasSuffix(parts[len(parts)-1], ".pbf") {
+		return 0, 0, 0, fmt.Errorf("tile path must end in {z}/{x}/{y}.pbf")
+	}
+	parts = parts[len(parts)-3:]
+	parts[2] = strings.TrimSuffix(parts[2], ".pbf")
+	var zxy [3]int
+	for i, part := range parts {
+		v, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid tile coordinate '%s'", part)
+		}
+		zxy[i] = v
+	}
+	z, x, y := zxy[0], zxy[1], zxy[2]
+	if z < 0 || z > maxZoom || x < 0 || y < 0 || x >= 1<<z || y >= 1<<z {
+		return 0, 0, 0, fmt.Errorf("no tile %d/%d/%d", z, x, y)
+	}
+	return z, x, y, nil
+}
+
+// Tile encodes the movers in the tile, or returns nothing
+// if there are none.
+func (s *Server) Tile(z, x, y int) []byte {
+	l := newLayer(LayerName, extent)
+	scale := float64(int(1) << z)
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	for _, m := range s.movers {
+		if m.Class != nil && (z < m.Class.MinZoom || z > m.Class.MaxZoom) {
+			continue
+		}
+		mx, my := mercator(m.X, m.Y)
+		tx := int(math.Floor((mx*scale - float64(x)) * extent))
+		ty := int(math.Floor((my*scale - float64(y)) * extent))
+		if tx < -buffer || tx >= extent+buffer || ty < -buffer || ty >= extent+buffer {
+			continue
+		}
+		l.addPoint(uint64(m.Id), tx, ty, properties(m))
+	}
+	return l.tile()
+}
+
+// mercator projects a position onto the unit square of
+// Web Mercator, with y running down from the north.
+func mercator(lon, lat float64) (float64, float64) {
+	lat = math.Max(-maxLatitude, math.Min(maxLatitude, lat))
+	sin := math.Sin(lat * math.Pi / 180)
+	x := (lon + 180) / 360
+	y := 0.5 - math.Log((1+sin)/(1-sin))/(4*math.Pi)
+	return x, y
+}
+
+// properties are those of the JSON outputs, with the altitude of
+// movers that have one; payload fields other than strings, numbers
+// and booleans are left out.
+func properties(m mover.Mover) map[string]interface{} {
+	props := sink.Properties(m)
+	if m.HasZ {
+		props["altitude"] = m.Z
+	}
+	return props
+}
@@ -0,0 +1,60 @@
+package tiles
+
+import (
+	// System
+	"math"
+	"testing"
+)
+
+func TestMercator(t *testing.T) {
+	tests := []struct {
+		name     string
+		lon, lat float64
+		x, y     float64
+	}{
+		{"origin", 0, 0, 0.5, 0.5},
+		{"west edge", -180, 0, 0, 0.5},
+		{"east edge", 180, 0, 1, 0.5},
+		{"north edge", 0, maxLatitude, 0.5, 0},
+		{"south edge", 0, -maxLatitude, 0.5, 1},
+		{"past the north edge", 0, 90, 0.5, 0},
+		{"past the south edge", 0, -90, 0.5, 1},
+		// The corner of tile 1/0/0 is the middle of the world
+		{"quarter", -90, 66.51326044311186, 0.25, 0.25},
+	}
+	for _, test := range tests {
+		x, y := mercator(test.lon, test.lat)
+		if math.Abs(x-test.x) > 1e-9 || math.Abs(y-test.y) > 1e-9 {
+			t.Errorf("%s: mercator(%g, %g) = (%g, %g), want (%g, %g)",
+				test.name, test.lon, test.lat, x, y, test.x, test.y)
+		}
+	}
+}
+
+func TestParseTile(t *testing.T) {
+	tests := []struct {
+		path    string
+		z, x, y int
+		ok      bool
+	}{
+		{"/0/0/0.pbf", 0, 0, 0, true},
+		{"/tiles/14/2621/5721.pbf", 14, 2621, 5721, true},
+		{"3/7/7.pbf", 3, 7, 7, true},
+		{"/3/8/7.pbf", 0, 0, 0, false},
+		{"/3/7/-1.pbf", 0, 0, 0, false},
+		{"/25/0/0.pbf", 0, 0, 0, false},
+		{"/1/0/0.png", 0, 0, 0, false},
+		{"/1/a/0.pbf", 0, 0, 0, false},
+		{"/0/0.pbf", 0, 0, 0, false},
+	}
+	for _, test := range tests {
+		z, x, y, err := parseTile(test.path)
+		if (err == nil) != test.ok {
+			t.Errorf("parseTile(%q) gave error %v", test.path, err)
+			continue
+		}
+		if z != test.z || x != test.x || y != test.y {
+			t.Errorf("parseTile(%q) = %d/%d/%d, want %d/%d/%d", test.path, z, x, y, test.z, test.x, test.y)
+		}
+	}
+}